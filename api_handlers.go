@@ -0,0 +1,3130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// apiError is the structured error payload returned by every API endpoint.
+type apiError struct {
+	Error apiErrorBody `json:"error"`
+}
+
+type apiErrorBody struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// apiAsset is the JSON representation of an Asset. Dates use the
+// YYYY-MM-DD format and are null when unset. Location is the full path of
+// the asset's location. Fields holds the custom field values the asset's
+// type defines by name, as numbers, booleans or strings.
+type apiAsset struct {
+	ID             int                    `json:"id"`
+	Name           string                 `json:"name"`
+	TypeID         *int                   `json:"type_id"`
+	AssetType      string                 `json:"asset_type"`
+	Fields         map[string]interface{} `json:"fields"`
+	LocationID     *int                   `json:"location_id"`
+	Location       string                 `json:"location"`
+	Manufacturer   string                 `json:"manufacturer"`
+	Model          string                 `json:"model"`
+	SerialNumber   string                 `json:"serial_number"`
+	AssetTag       string                 `json:"asset_tag"`
+	PurchaseDate   *string                `json:"purchase_date"`
+	PurchaseCost   float64                `json:"purchase_cost"`
+	Supplier       string                 `json:"supplier"`
+	OrderReference string                 `json:"order_reference"`
+	WarrantyEnd    *string                `json:"warranty_end"`
+	State          string                 `json:"state"`
+}
+
+// apiAssetInput is the request body for creating or updating an asset.
+// Fields are pointers so PATCH can tell omitted fields from empty ones. The
+// location is given either by location_id or by location, a path or a name
+// only one location has; a location_id of 0 or an empty location removes
+// it. The type is given likewise by type_id or by its name in asset_type.
+// PATCH merges the custom fields in Fields with the asset's, a null value
+// removing one, unless the type changes.
+type apiAssetInput struct {
+	Name           *string                `json:"name"`
+	TypeID         *int                   `json:"type_id"`
+	AssetType      *string                `json:"asset_type"`
+	Fields         map[string]interface{} `json:"fields"`
+	LocationID     *int                   `json:"location_id"`
+	Location       *string                `json:"location"`
+	Manufacturer   *string                `json:"manufacturer"`
+	Model          *string                `json:"model"`
+	SerialNumber   *string                `json:"serial_number"`
+	AssetTag       *string                `json:"asset_tag"`
+	PurchaseDate   nullableStr            `json:"purchase_date"`
+	PurchaseCost   *float64               `json:"purchase_cost"`
+	Supplier       *string                `json:"supplier"`
+	OrderReference *string                `json:"order_reference"`
+	WarrantyEnd    nullableStr            `json:"warranty_end"`
+	// State may only be set when creating an asset; later changes go
+	// through the state endpoint.
+	State *string `json:"state"`
+}
+
+// apiAssetStateChange is the JSON representation of an AssetStateChange.
+// From is null for the state the asset was created in.
+type apiAssetStateChange struct {
+	ID        int       `json:"id"`
+	AssetID   int       `json:"asset_id"`
+	From      *string   `json:"from"`
+	To        string    `json:"to"`
+	Reason    string    `json:"reason"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// apiAssetTransition is the request body for changing an asset's state.
+type apiAssetTransition struct {
+	State  string `json:"state"`
+	Reason string `json:"reason"`
+}
+
+// apiSoftware is the JSON representation of an InstalledSoftware record.
+type apiSoftware struct {
+	ID          int       `json:"id"`
+	AssetID     int       `json:"asset_id"`
+	Product     string    `json:"product"`
+	Publisher   string    `json:"publisher"`
+	Version     string    `json:"version"`
+	InstallDate *string   `json:"install_date"`
+	Source      string    `json:"source"`
+	LastSeen    time.Time `json:"last_seen"`
+}
+
+// apiSoftwareItem is one installation in an inventory report.
+type apiSoftwareItem struct {
+	Product     string  `json:"product"`
+	Publisher   string  `json:"publisher"`
+	Version     string  `json:"version"`
+	InstallDate *string `json:"install_date"`
+}
+
+// apiSoftwareReport is the request body of the inventory ingestion
+// endpoint. It is a complete snapshot of what source found on the asset.
+type apiSoftwareReport struct {
+	Source   string            `json:"source"`
+	Software []apiSoftwareItem `json:"software"`
+}
+
+// apiRisk is the JSON representation of a Risk. NextStatuses lists the
+// statuses the workflow allows moving to.
+type apiRisk struct {
+	ID           int             `json:"id"`
+	Title        string          `json:"title"`
+	Description  string          `json:"description"`
+	Likelihood   int             `json:"likelihood"`
+	Impact       int             `json:"impact"`
+	Score        int             `json:"score"`
+	Level        string          `json:"level"`
+	Owner        string          `json:"owner"`
+	Status       RiskStatus      `json:"status"`
+	NextStatuses []RiskStatus    `json:"next_statuses"`
+	ReviewDate   *string         `json:"review_date"`
+	AssetIDs     []int           `json:"asset_ids"`
+	LicenseIDs   []int           `json:"license_ids"`
+	Mitigations  []apiMitigation `json:"mitigations"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+}
+
+// apiMitigation is the JSON representation of a RiskMitigation.
+type apiMitigation struct {
+	ID          int        `json:"id"`
+	Action      string     `json:"action"`
+	DueDate     *string    `json:"due_date"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+// apiRiskInput is the request body for creating or updating a risk. The
+// status is changed through the status endpoint instead.
+type apiRiskInput struct {
+	Title       *string     `json:"title"`
+	Description *string     `json:"description"`
+	Likelihood  *int        `json:"likelihood"`
+	Impact      *int        `json:"impact"`
+	Owner       *string     `json:"owner"`
+	ReviewDate  nullableStr `json:"review_date"`
+	AssetIDs    *[]int      `json:"asset_ids"`
+	LicenseIDs  *[]int      `json:"license_ids"`
+}
+
+// apiMitigationInput is the request body for adding a mitigation.
+type apiMitigationInput struct {
+	Action  string      `json:"action"`
+	DueDate nullableStr `json:"due_date"`
+}
+
+// apiFOIRequest is the JSON representation of an FOIRequest.
+type apiFOIRequest struct {
+	ID           int         `json:"id"`
+	Reference    string      `json:"reference"`
+	Requester    string      `json:"requester"`
+	Contact      string      `json:"contact"`
+	Subject      string      `json:"subject"`
+	Details      string      `json:"details"`
+	ReceivedDate *string     `json:"received_date"`
+	DueDate      *string     `json:"due_date"`
+	Overdue      bool        `json:"overdue"`
+	Assignee     string      `json:"assignee"`
+	Status       FOIStatus   `json:"status"`
+	NextStatuses []FOIStatus `json:"next_statuses"`
+	AssetIDs     []int       `json:"asset_ids"`
+	LicenseIDs   []int       `json:"license_ids"`
+	ReportRunIDs []int       `json:"report_run_ids"`
+	CreatedAt    time.Time   `json:"created_at"`
+	UpdatedAt    time.Time   `json:"updated_at"`
+}
+
+// apiFOIInput is the request body for creating or updating an FOI request.
+// The due date is always calculated and the status changes through the
+// status endpoint.
+type apiFOIInput struct {
+	Requester    *string     `json:"requester"`
+	Contact      *string     `json:"contact"`
+	Subject      *string     `json:"subject"`
+	Details      *string     `json:"details"`
+	ReceivedDate nullableStr `json:"received_date"`
+	Assignee     *string     `json:"assignee"`
+	AssetIDs     *[]int      `json:"asset_ids"`
+	LicenseIDs   *[]int      `json:"license_ids"`
+	ReportRunIDs *[]int      `json:"report_run_ids"`
+}
+
+// apiReportParam describes a parameter of a built-in report.
+type apiReportParam struct {
+	Name    string `json:"name"`
+	Label   string `json:"label"`
+	Type    string `json:"type"`
+	Default string `json:"default,omitempty"`
+}
+
+// apiReportDefinition is the JSON representation of a built-in report.
+type apiReportDefinition struct {
+	Key         string           `json:"key"`
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Params      []apiReportParam `json:"params"`
+}
+
+// apiSavedReport is the JSON representation of a SavedReport.
+type apiSavedReport struct {
+	ID        int          `json:"id"`
+	Name      string       `json:"name"`
+	Report    string       `json:"report"`
+	Params    ReportParams `json:"params"`
+	CreatedBy string       `json:"created_by"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// apiReportRunInput is the request body for running a report ad hoc or
+// saving a report definition; Name is only used when saving.
+type apiReportRunInput struct {
+	Name   string       `json:"name"`
+	Report string       `json:"report"`
+	Params ReportParams `json:"params"`
+}
+
+// apiReportRun is the JSON representation of a ReportRun. The result is
+// only included when a single run is fetched.
+type apiReportRun struct {
+	ID            int           `json:"id"`
+	SavedReportID *int          `json:"saved_report_id"`
+	Name          string        `json:"name"`
+	Report        string        `json:"report"`
+	Params        ReportParams  `json:"params"`
+	RunBy         string        `json:"run_by"`
+	StartedAt     time.Time     `json:"started_at"`
+	FinishedAt    time.Time     `json:"finished_at"`
+	Succeeded     bool          `json:"succeeded"`
+	Error         string        `json:"error,omitempty"`
+	RowCount      int           `json:"row_count"`
+	Result        *ReportResult `json:"result,omitempty"`
+}
+
+// apiAuditEntry is the JSON representation of an AuditEntry. Before is
+// null for creations and after is null for deletions.
+type apiAuditEntry struct {
+	ID         int             `json:"id"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Actor      string          `json:"actor"`
+	Entity     string          `json:"entity"`
+	EntityID   int             `json:"entity_id"`
+	Action     string          `json:"action"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+}
+
+// apiAuditVerification is the JSON representation of an
+// AuditVerification. BrokenAt and Problem are omitted when the chain is
+// intact.
+type apiAuditVerification struct {
+	Entries   int       `json:"entries"`
+	Intact    bool      `json:"intact"`
+	BrokenAt  *int      `json:"broken_at,omitempty"`
+	Problem   string    `json:"problem,omitempty"`
+	Head      string    `json:"head"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// apiProductPosition is the JSON representation of a ProductPosition.
+// Entitled is null when a site license grants unlimited use.
+type apiProductPosition struct {
+	Product       string        `json:"product"`
+	Metric        LicenseMetric `json:"metric"`
+	Licenses      []string      `json:"licenses"`
+	Position      Position      `json:"position"`
+	Entitled      *int          `json:"entitled"`
+	Installations int           `json:"installations"`
+	Assigned      int           `json:"assigned"`
+	Demand        int           `json:"demand"`
+	Shortfall     int           `json:"shortfall"`
+	Surplus       int           `json:"surplus"`
+	UnitCost      float64       `json:"unit_cost"`
+	Exposure      float64       `json:"exposure"`
+}
+
+// apiComplianceReport is the JSON representation of a ComplianceReport.
+type apiComplianceReport struct {
+	GeneratedAt   time.Time            `json:"generated_at"`
+	Compliant     int                  `json:"compliant"`
+	UnderLicensed int                  `json:"under_licensed"`
+	OverLicensed  int                  `json:"over_licensed"`
+	TotalExposure float64              `json:"total_exposure"`
+	Products      []apiProductPosition `json:"products"`
+}
+
+// apiLicense is the JSON representation of a License. Dates use the
+// YYYY-MM-DD format and are null when unset.
+type apiLicense struct {
+	ID          int     `json:"id"`
+	Name        string  `json:"name"`
+	Vendor      string  `json:"vendor"`
+	ExpiryDate  *string `json:"expiry_date"`
+	RenewalDate *string `json:"renewal_date"`
+	Status      string  `json:"status"`
+	Quantity    int     `json:"quantity"`
+	Metric      string  `json:"metric"`
+	Consumed    int     `json:"consumed"`
+	Available   *int    `json:"available"`
+	Product     string  `json:"product"`
+	UnitCost    float64 `json:"unit_cost"`
+	OwnerEmail  string  `json:"owner_email"`
+}
+
+// apiLicenseInput is the request body for creating or updating a license.
+type apiLicenseInput struct {
+	Name        *string     `json:"name"`
+	Vendor      *string     `json:"vendor"`
+	ExpiryDate  nullableStr `json:"expiry_date"`
+	RenewalDate nullableStr `json:"renewal_date"`
+	Status      *string     `json:"status"`
+	Quantity    *int        `json:"quantity"`
+	Metric      *string     `json:"metric"`
+	Product     *string     `json:"product"`
+	UnitCost    *float64    `json:"unit_cost"`
+	OwnerEmail  *string     `json:"owner_email"`
+}
+
+// apiAssignment is the JSON representation of a LicenseAssignment. AssetID
+// is null for assignments made to a person only, and PersonID for those
+// not linked to a person in the directory.
+type apiAssignment struct {
+	ID         int       `json:"id"`
+	LicenseID  int       `json:"license_id"`
+	AssetID    *int      `json:"asset_id"`
+	AssetName  string    `json:"asset_name,omitempty"`
+	PersonID   *int      `json:"person_id"`
+	PersonName string    `json:"person_name,omitempty"`
+	Assignee   string    `json:"assignee"`
+	Quantity   int       `json:"quantity"`
+	AssignedAt time.Time `json:"assigned_at"`
+}
+
+// apiAssignmentInput is the request body for assigning license seats.
+type apiAssignmentInput struct {
+	AssetID  *int   `json:"asset_id"`
+	PersonID *int   `json:"person_id"`
+	Assignee string `json:"assignee"`
+	Quantity *int   `json:"quantity"`
+}
+
+// apiDepartment is the JSON representation of a Department.
+type apiDepartment struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Members int    `json:"members"`
+}
+
+// apiPerson is the JSON representation of a Person.
+type apiPerson struct {
+	ID           int     `json:"id"`
+	Name         string  `json:"name"`
+	Email        string  `json:"email"`
+	DepartmentID *int    `json:"department_id"`
+	Department   string  `json:"department"`
+	Username     *string `json:"username"`
+}
+
+// apiPersonInput is the request body for creating or updating a person.
+// Omitted fields are left unchanged by PATCH; a department_id of 0 or an
+// empty username removes the department or user link.
+type apiPersonInput struct {
+	Name         *string `json:"name"`
+	Email        *string `json:"email"`
+	DepartmentID *int    `json:"department_id"`
+	Username     *string `json:"username"`
+}
+
+// apiCheckout is the JSON representation of a Checkout. PersonID is null
+// once the person has been removed from the directory.
+type apiCheckout struct {
+	ID             int        `json:"id"`
+	AssetID        int        `json:"asset_id"`
+	AssetName      string     `json:"asset_name"`
+	PersonID       *int       `json:"person_id"`
+	PersonName     string     `json:"person_name"`
+	CheckedOutAt   time.Time  `json:"checked_out_at"`
+	CheckedOutBy   string     `json:"checked_out_by"`
+	ExpectedReturn *string    `json:"expected_return"`
+	Notes          string     `json:"notes"`
+	Overdue        bool       `json:"overdue"`
+	CheckedInAt    *time.Time `json:"checked_in_at"`
+	CheckedInBy    string     `json:"checked_in_by"`
+	ReturnNotes    string     `json:"return_notes"`
+}
+
+// apiCheckoutInput is the request body for checking an asset out.
+type apiCheckoutInput struct {
+	PersonID       int    `json:"person_id"`
+	ExpectedReturn string `json:"expected_return"`
+	Notes          string `json:"notes"`
+}
+
+// apiLocation is the JSON representation of a Location. Assets counts the
+// assets kept directly at the location and TotalAssets those anywhere
+// inside it too.
+type apiLocation struct {
+	ID          int          `json:"id"`
+	ParentID    *int         `json:"parent_id"`
+	Kind        LocationKind `json:"kind"`
+	Name        string       `json:"name"`
+	Path        string       `json:"path"`
+	Assets      int          `json:"assets"`
+	TotalAssets int          `json:"total_assets"`
+}
+
+// apiLocationInput is the request body for creating or updating a
+// location. A parent_id of 0 makes it top-level, as sites are.
+type apiLocationInput struct {
+	ParentID *int    `json:"parent_id"`
+	Kind     *string `json:"kind"`
+	Name     *string `json:"name"`
+}
+
+// apiAssetMove is the JSON representation of an AssetMove. The location
+// ids are null for no location, or once the location has been deleted.
+type apiAssetMove struct {
+	ID             int       `json:"id"`
+	AssetID        int       `json:"asset_id"`
+	FromLocationID *int      `json:"from_location_id"`
+	From           string    `json:"from"`
+	ToLocationID   *int      `json:"to_location_id"`
+	To             string    `json:"to"`
+	MovedAt        time.Time `json:"moved_at"`
+	MovedBy        string    `json:"moved_by"`
+}
+
+// apiAssetType is the JSON representation of an AssetType. Assets counts
+// the assets of the type.
+type apiAssetType struct {
+	ID     int             `json:"id"`
+	Name   string          `json:"name"`
+	Fields []apiAssetField `json:"fields"`
+	Assets int             `json:"assets"`
+}
+
+// apiAssetField is the JSON representation of an AssetField. Options is
+// only given for enum fields.
+type apiAssetField struct {
+	ID       int       `json:"id"`
+	Name     string    `json:"name"`
+	Label    string    `json:"label"`
+	Kind     FieldKind `json:"kind"`
+	Options  []string  `json:"options,omitempty"`
+	Required bool      `json:"required"`
+}
+
+// apiAssetTypeInput is the request body for creating or renaming an asset
+// type. Fields may only be given when creating one; later changes go
+// through the fields endpoints.
+type apiAssetTypeInput struct {
+	Name   *string              `json:"name"`
+	Fields []apiAssetFieldInput `json:"fields"`
+}
+
+// apiAssetFieldInput is the request body for adding or changing a custom
+// field. The name is derived from the label when omitted, and the kind
+// cannot change once the field exists.
+type apiAssetFieldInput struct {
+	Name     *string   `json:"name"`
+	Label    *string   `json:"label"`
+	Kind     *string   `json:"kind"`
+	Options  *[]string `json:"options"`
+	Required *bool     `json:"required"`
+}
+
+// apiCheckinInput is the request body for checking an asset back in.
+type apiCheckinInput struct {
+	Notes string `json:"notes"`
+}
+
+// nullableStr distinguishes an omitted JSON field from an explicit null.
+type nullableStr struct {
+	Set   bool
+	Value *string
+}
+
+func (n *nullableStr) UnmarshalJSON(b []byte) error {
+	n.Set = true
+	return json.Unmarshal(b, &n.Value)
+}
+
+// registerAPIRoutes mounts the JSON API on the given subrouter.
+func (s *server) registerAPIRoutes(api *mux.Router) {
+	assets := func(h http.HandlerFunc) http.HandlerFunc { return authorize(PermViewAssets, PermManageAssets, h) }
+	api.HandleFunc("/assets", assets(s.apiListAssets)).Methods("GET")
+	api.HandleFunc("/assets", assets(s.apiCreateAsset)).Methods("POST")
+	api.HandleFunc("/assets/{id:[0-9]+}", assets(s.apiGetAsset)).Methods("GET")
+	api.HandleFunc("/assets/{id:[0-9]+}", assets(s.apiUpdateAsset)).Methods("PUT", "PATCH")
+	api.HandleFunc("/assets/{id:[0-9]+}", assets(s.apiDeleteAsset)).Methods("DELETE")
+	api.HandleFunc("/assets/{id:[0-9]+}/state", assets(s.apiAssetStateChanges)).Methods("GET")
+	api.HandleFunc("/assets/{id:[0-9]+}/state", assets(s.apiTransitionAsset)).Methods("POST")
+	api.HandleFunc("/assets/{id:[0-9]+}/software", assets(s.apiListSoftware)).Methods("GET")
+	api.HandleFunc("/assets/{id:[0-9]+}/software", assets(s.apiIngestSoftware)).Methods("POST")
+	api.HandleFunc("/assets/{id:[0-9]+}/software/{software:[0-9]+}", assets(s.apiDeleteSoftware)).Methods("DELETE")
+	api.HandleFunc("/assets/{id:[0-9]+}/moves", assets(s.apiAssetMoves)).Methods("GET")
+	api.HandleFunc("/assets/{id:[0-9]+}/custody", assets(s.apiAssetCustody)).Methods("GET")
+	api.HandleFunc("/assets/{id:[0-9]+}/checkout", assets(s.apiCheckOutAsset)).Methods("POST")
+	api.HandleFunc("/assets/{id:[0-9]+}/checkin", assets(s.apiCheckInAsset)).Methods("POST")
+	api.HandleFunc("/people", assets(s.apiListPeople)).Methods("GET")
+	api.HandleFunc("/people", assets(s.apiCreatePerson)).Methods("POST")
+	api.HandleFunc("/people/{id:[0-9]+}", assets(s.apiGetPerson)).Methods("GET")
+	api.HandleFunc("/people/{id:[0-9]+}", assets(s.apiUpdatePerson)).Methods("PUT", "PATCH")
+	api.HandleFunc("/people/{id:[0-9]+}", assets(s.apiDeletePerson)).Methods("DELETE")
+	api.HandleFunc("/people/{id:[0-9]+}/assets", assets(s.apiPersonAssets)).Methods("GET")
+	api.HandleFunc("/departments", assets(s.apiListDepartments)).Methods("GET")
+	api.HandleFunc("/departments", assets(s.apiCreateDepartment)).Methods("POST")
+	api.HandleFunc("/departments/{id:[0-9]+}", assets(s.apiDeleteDepartment)).Methods("DELETE")
+	api.HandleFunc("/locations", assets(s.apiListLocations)).Methods("GET")
+	api.HandleFunc("/locations", assets(s.apiCreateLocation)).Methods("POST")
+	api.HandleFunc("/locations/{id:[0-9]+}", assets(s.apiGetLocation)).Methods("GET")
+	api.HandleFunc("/locations/{id:[0-9]+}", assets(s.apiUpdateLocation)).Methods("PUT", "PATCH")
+	api.HandleFunc("/locations/{id:[0-9]+}", assets(s.apiDeleteLocation)).Methods("DELETE")
+	api.HandleFunc("/locations/{id:[0-9]+}/assets", assets(s.apiLocationAssets)).Methods("GET")
+	api.HandleFunc("/asset-types", assets(s.apiListAssetTypes)).Methods("GET")
+	api.HandleFunc("/asset-types", assets(s.apiCreateAssetType)).Methods("POST")
+	api.HandleFunc("/asset-types/{id:[0-9]+}", assets(s.apiGetAssetType)).Methods("GET")
+	api.HandleFunc("/asset-types/{id:[0-9]+}", assets(s.apiUpdateAssetType)).Methods("PUT", "PATCH")
+	api.HandleFunc("/asset-types/{id:[0-9]+}", assets(s.apiDeleteAssetType)).Methods("DELETE")
+	api.HandleFunc("/asset-types/{id:[0-9]+}/fields", assets(s.apiCreateAssetField)).Methods("POST")
+	api.HandleFunc("/asset-types/{id:[0-9]+}/fields/{field:[0-9]+}", assets(s.apiUpdateAssetField)).Methods("PUT", "PATCH")
+	api.HandleFunc("/asset-types/{id:[0-9]+}/fields/{field:[0-9]+}", assets(s.apiDeleteAssetField)).Methods("DELETE")
+	api.HandleFunc("/me/assets", authorize(PermViewDashboard, PermViewDashboard, s.apiMyAssets)).Methods("GET")
+
+	licenses := func(h http.HandlerFunc) http.HandlerFunc { return authorize(PermViewLicenses, PermManageLicenses, h) }
+	api.HandleFunc("/licenses", licenses(s.apiListLicenses)).Methods("GET")
+	api.HandleFunc("/licenses", licenses(s.apiCreateLicense)).Methods("POST")
+	api.HandleFunc("/licenses/{id:[0-9]+}", licenses(s.apiGetLicense)).Methods("GET")
+	api.HandleFunc("/licenses/{id:[0-9]+}", licenses(s.apiUpdateLicense)).Methods("PUT", "PATCH")
+	api.HandleFunc("/licenses/{id:[0-9]+}", licenses(s.apiDeleteLicense)).Methods("DELETE")
+	api.HandleFunc("/licenses/{id:[0-9]+}/assignments", licenses(s.apiListAssignments)).Methods("GET")
+	api.HandleFunc("/licenses/{id:[0-9]+}/assignments", licenses(s.apiCreateAssignment)).Methods("POST")
+	api.HandleFunc("/licenses/{id:[0-9]+}/assignments/{assignment:[0-9]+}", licenses(s.apiDeleteAssignment)).Methods("DELETE")
+
+	risks := func(h http.HandlerFunc) http.HandlerFunc { return authorize(PermViewRisks, PermManageRisks, h) }
+	api.HandleFunc("/risks", risks(s.apiListRisks)).Methods("GET")
+	api.HandleFunc("/risks", risks(s.apiCreateRisk)).Methods("POST")
+	api.HandleFunc("/risks/{id:[0-9]+}", risks(s.apiGetRisk)).Methods("GET")
+	api.HandleFunc("/risks/{id:[0-9]+}", risks(s.apiUpdateRisk)).Methods("PUT", "PATCH")
+	api.HandleFunc("/risks/{id:[0-9]+}", risks(s.apiDeleteRisk)).Methods("DELETE")
+	api.HandleFunc("/risks/{id:[0-9]+}/status", risks(s.apiTransitionRisk)).Methods("POST")
+	api.HandleFunc("/risks/{id:[0-9]+}/mitigations", risks(s.apiAddMitigation)).Methods("POST")
+	api.HandleFunc("/risks/{id:[0-9]+}/mitigations/{mitigation:[0-9]+}/complete", risks(s.apiCompleteMitigation)).Methods("POST")
+
+	foi := func(h http.HandlerFunc) http.HandlerFunc { return authorize(PermViewFOI, PermManageFOI, h) }
+	api.HandleFunc("/foi", foi(s.apiListFOI)).Methods("GET")
+	api.HandleFunc("/foi", foi(s.apiCreateFOI)).Methods("POST")
+	api.HandleFunc("/foi/{id:[0-9]+}", foi(s.apiGetFOI)).Methods("GET")
+	api.HandleFunc("/foi/{id:[0-9]+}", foi(s.apiUpdateFOI)).Methods("PUT", "PATCH")
+	api.HandleFunc("/foi/{id:[0-9]+}", foi(s.apiDeleteFOI)).Methods("DELETE")
+	api.HandleFunc("/foi/{id:[0-9]+}/status", foi(s.apiTransitionFOI)).Methods("POST")
+
+	reports := func(h http.HandlerFunc) http.HandlerFunc { return authorize(PermViewReports, PermManageReports, h) }
+	runReports := func(h http.HandlerFunc) http.HandlerFunc { return authorize(PermViewReports, PermViewReports, h) }
+	api.HandleFunc("/reports", reports(s.apiListReportDefinitions)).Methods("GET")
+	api.HandleFunc("/reports/run", runReports(s.apiRunReport)).Methods("POST")
+	api.HandleFunc("/reports/saved", reports(s.apiListSavedReports)).Methods("GET")
+	api.HandleFunc("/reports/saved", reports(s.apiCreateSavedReport)).Methods("POST")
+	api.HandleFunc("/reports/saved/{id:[0-9]+}", reports(s.apiGetSavedReport)).Methods("GET")
+	api.HandleFunc("/reports/saved/{id:[0-9]+}", reports(s.apiDeleteSavedReport)).Methods("DELETE")
+	api.HandleFunc("/reports/saved/{id:[0-9]+}/run", runReports(s.apiRunSavedReport)).Methods("POST")
+	api.HandleFunc("/reports/runs", reports(s.apiListReportRuns)).Methods("GET")
+	api.HandleFunc("/reports/runs/{id:[0-9]+}", reports(s.apiGetReportRun)).Methods("GET")
+
+	api.HandleFunc("/compliance", authorize(PermViewCompliance, PermViewCompliance, s.apiCompliance)).Methods("GET")
+	api.HandleFunc("/audit", authorize(PermViewAudit, PermViewAudit, s.apiListAudit)).Methods("GET")
+	api.HandleFunc("/audit/verify", authorize(PermViewAudit, PermViewAudit, s.apiVerifyAudit)).Methods("GET")
+
+	api.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, http.StatusNotFound, "not_found", "no such API endpoint")
+	})
+	api.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method_not_allowed", fmt.Sprintf("method %s is not allowed on this resource", r.Method))
+	})
+}
+
+// writeJSON encodes v as the response body with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding JSON response: %v\n", err)
+	}
+}
+
+// writeAPIError writes a structured error payload.
+func writeAPIError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, apiError{Error: apiErrorBody{Status: status, Code: code, Message: message}})
+}
+
+// writeAPIInternalError logs err and writes a generic 500 payload.
+func writeAPIInternalError(w http.ResponseWriter, msg string, err error) {
+	log.Printf("%s: %v\n", msg, err)
+	writeAPIError(w, http.StatusInternalServerError, "internal_error", "an internal error occurred")
+}
+
+// decodeJSON decodes the request body into v, rejecting unknown fields.
+func decodeJSON(r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid JSON body: %v", err)
+	}
+	return nil
+}
+
+func toAPIAsset(a Asset) apiAsset {
+	return apiAsset{
+		ID:             a.ID,
+		Name:           a.Name,
+		TypeID:         apiID(a.TypeID),
+		AssetType:      a.AssetType,
+		Fields:         toAPIFieldValues(a.Fields),
+		LocationID:     apiID(a.LocationID),
+		Location:       a.Location,
+		Manufacturer:   a.Manufacturer,
+		Model:          a.Model,
+		SerialNumber:   a.SerialNumber,
+		AssetTag:       a.AssetTag,
+		PurchaseDate:   apiDate(a.PurchaseDate),
+		PurchaseCost:   a.PurchaseCost,
+		Supplier:       a.Supplier,
+		OrderReference: a.OrderReference,
+		WarrantyEnd:    apiDate(a.WarrantyEnd),
+		State:          string(a.State),
+	}
+}
+
+// toAPIFieldValues returns custom field values by name, typed by kind.
+func toAPIFieldValues(values []AssetFieldValue) map[string]interface{} {
+	out := make(map[string]interface{}, len(values))
+	for _, v := range values {
+		switch v.Kind {
+		case FieldNumber:
+			n, _ := strconv.ParseFloat(v.Value, 64)
+			out[v.Name] = n
+		case FieldBoolean:
+			out[v.Name] = v.Value == "true"
+		default:
+			out[v.Name] = v.Value
+		}
+	}
+	return out
+}
+
+func toAPIAssetType(t AssetType) apiAssetType {
+	out := apiAssetType{ID: t.ID, Name: t.Name, Fields: make([]apiAssetField, 0, len(t.Fields)), Assets: t.Assets}
+	for _, f := range t.Fields {
+		out.Fields = append(out.Fields, apiAssetField{ID: f.ID, Name: f.Name, Label: f.Label, Kind: f.Kind, Options: f.Options, Required: f.Required})
+	}
+	return out
+}
+
+func toAPIAssetStateChange(c AssetStateChange) apiAssetStateChange {
+	out := apiAssetStateChange{
+		ID:        c.ID,
+		AssetID:   c.AssetID,
+		To:        string(c.To),
+		Reason:    c.Reason,
+		ChangedAt: c.ChangedAt,
+	}
+	if c.From != "" {
+		from := string(c.From)
+		out.From = &from
+	}
+	return out
+}
+
+// apiID returns a pointer to id, or nil for the zero id of a missing
+// reference.
+func apiID(id int) *int {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
+
+func toAPILocation(l Location) apiLocation {
+	return apiLocation{ID: l.ID, ParentID: apiID(l.ParentID), Kind: l.Kind, Name: l.Name, Path: l.Path, Assets: l.Assets, TotalAssets: l.Total}
+}
+
+func toAPIAssetMove(m AssetMove) apiAssetMove {
+	return apiAssetMove{ID: m.ID, AssetID: m.AssetID, FromLocationID: apiID(m.FromID), From: m.From,
+		ToLocationID: apiID(m.ToID), To: m.To, MovedAt: m.MovedAt, MovedBy: m.MovedBy}
+}
+
+func toAPIDepartment(d Department) apiDepartment {
+	return apiDepartment{ID: d.ID, Name: d.Name, Members: d.Members}
+}
+
+func toAPIPerson(p Person) apiPerson {
+	out := apiPerson{ID: p.ID, Name: p.Name, Email: p.Email, Department: p.Department}
+	if p.DepartmentID != 0 {
+		departmentID := p.DepartmentID
+		out.DepartmentID = &departmentID
+	}
+	if p.Username != "" {
+		username := p.Username
+		out.Username = &username
+	}
+	return out
+}
+
+func toAPICheckout(c Checkout) apiCheckout {
+	out := apiCheckout{
+		ID:             c.ID,
+		AssetID:        c.AssetID,
+		AssetName:      c.AssetName,
+		PersonName:     c.PersonName,
+		CheckedOutAt:   c.CheckedOutAt,
+		CheckedOutBy:   c.CheckedOutBy,
+		ExpectedReturn: apiDate(c.ExpectedReturn),
+		Notes:          c.Notes,
+		Overdue:        c.Overdue(),
+		CheckedInBy:    c.CheckedInBy,
+		ReturnNotes:    c.ReturnNotes,
+	}
+	if c.PersonID != 0 {
+		personID := c.PersonID
+		out.PersonID = &personID
+	}
+	if !c.CheckedInAt.IsZero() {
+		checkedInAt := c.CheckedInAt
+		out.CheckedInAt = &checkedInAt
+	}
+	return out
+}
+
+func apiDate(t time.Time) *string {
+	if t.IsZero() {
+		return nil
+	}
+	s := t.Format(dateLayout)
+	return &s
+}
+
+func toAPILicense(l License) apiLicense {
+	out := apiLicense{
+		ID:          l.ID,
+		Name:        l.Name,
+		Vendor:      l.Vendor,
+		ExpiryDate:  apiDate(l.ExpiryDate),
+		RenewalDate: apiDate(l.RenewalDate),
+		Status:      l.Status,
+		Quantity:    l.Quantity,
+		Metric:      string(l.Metric),
+		Consumed:    l.Consumed,
+		Product:     l.CoveredProduct(),
+		UnitCost:    l.UnitCost,
+		OwnerEmail:  l.OwnerEmail,
+	}
+	if !l.Metric.Unlimited() {
+		available := l.Available()
+		out.Available = &available
+	}
+	return out
+}
+
+func toAPISoftware(sw InstalledSoftware) apiSoftware {
+	return apiSoftware{
+		ID:          sw.ID,
+		AssetID:     sw.AssetID,
+		Product:     sw.Product,
+		Publisher:   sw.Publisher,
+		Version:     sw.Version,
+		InstallDate: apiDate(sw.InstallDate),
+		Source:      sw.Source,
+		LastSeen:    sw.LastSeen,
+	}
+}
+
+func toAPIAssignment(a LicenseAssignment) apiAssignment {
+	out := apiAssignment{
+		ID:         a.ID,
+		LicenseID:  a.LicenseID,
+		AssetName:  a.AssetName,
+		PersonName: a.PersonName,
+		Assignee:   a.Assignee,
+		Quantity:   a.Quantity,
+		AssignedAt: a.AssignedAt,
+	}
+	if a.PersonID != 0 {
+		personID := a.PersonID
+		out.PersonID = &personID
+	}
+	if a.AssetID != 0 {
+		assetID := a.AssetID
+		out.AssetID = &assetID
+	}
+	return out
+}
+
+// apply merges the input onto a. When partial is false every field is
+// replaced and omitted fields are cleared.
+func (in apiAssetInput) apply(a *Asset, partial bool) error {
+	if !partial {
+		*a = Asset{ID: a.ID, State: a.State}
+	}
+	if in.State != nil {
+		if a.ID == 0 {
+			state, err := parseInitialAssetState(*in.State)
+			if err != nil {
+				return fmt.Errorf("state: %v", err)
+			}
+			a.State = state
+		} else if AssetState(*in.State) != a.State {
+			return fmt.Errorf("state can only be changed with POST /api/v1/assets/%d/state", a.ID)
+		}
+	} else if a.State == "" {
+		a.State = AssetInStock
+	}
+	if in.Name != nil {
+		a.Name = strings.TrimSpace(*in.Name)
+	}
+	if in.TypeID != nil && in.AssetType != nil {
+		return fmt.Errorf("give type_id or asset_type, not both")
+	}
+	// Custom field values carry over to the same type only. They are kept
+	// in a.Fields by name until apiResolveAssetType checks them.
+	if (in.TypeID != nil && *in.TypeID != a.TypeID) || (in.AssetType != nil && !strings.EqualFold(strings.TrimSpace(*in.AssetType), a.AssetType)) {
+		a.Fields = nil
+	}
+	if in.TypeID != nil {
+		a.TypeID, a.AssetType = *in.TypeID, ""
+	}
+	if in.AssetType != nil {
+		a.TypeID, a.AssetType = 0, *in.AssetType
+	}
+	for name, v := range in.Fields {
+		value, err := apiFieldValue(name, v)
+		if err != nil {
+			return err
+		}
+		var kept []AssetFieldValue
+		for _, current := range a.Fields {
+			if current.Name != name {
+				kept = append(kept, current)
+			}
+		}
+		if value != "" {
+			kept = append(kept, AssetFieldValue{Name: name, Value: value})
+		}
+		a.Fields = kept
+	}
+	if in.LocationID != nil && in.Location != nil {
+		return fmt.Errorf("give location_id or location, not both")
+	}
+	if in.LocationID != nil {
+		a.LocationID, a.Location = *in.LocationID, ""
+	}
+	if in.Location != nil {
+		a.LocationID, a.Location = 0, *in.Location
+	}
+	if in.Manufacturer != nil {
+		a.Manufacturer = strings.TrimSpace(*in.Manufacturer)
+	}
+	if in.Model != nil {
+		a.Model = strings.TrimSpace(*in.Model)
+	}
+	if in.SerialNumber != nil {
+		a.SerialNumber = strings.TrimSpace(*in.SerialNumber)
+	}
+	if in.AssetTag != nil {
+		a.AssetTag = strings.TrimSpace(*in.AssetTag)
+	}
+	if in.PurchaseCost != nil {
+		a.PurchaseCost = *in.PurchaseCost
+	}
+	if in.Supplier != nil {
+		a.Supplier = strings.TrimSpace(*in.Supplier)
+	}
+	if in.OrderReference != nil {
+		a.OrderReference = strings.TrimSpace(*in.OrderReference)
+	}
+	var err error
+	if a.PurchaseDate, err = in.PurchaseDate.date("purchase_date", a.PurchaseDate); err != nil {
+		return err
+	}
+	if a.WarrantyEnd, err = in.WarrantyEnd.date("warranty_end", a.WarrantyEnd); err != nil {
+		return err
+	}
+	if a.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if a.PurchaseCost < 0 {
+		return fmt.Errorf("purchase_cost must not be negative")
+	}
+	if !a.WarrantyEnd.IsZero() && a.WarrantyEnd.Before(a.PurchaseDate) {
+		return fmt.Errorf("warranty_end must not be before purchase_date")
+	}
+	return nil
+}
+
+// apiFieldValue converts a custom field value given in JSON to the text
+// AssetField.parse checks; null removes the value.
+func apiFieldValue(name string, v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	}
+	return "", fmt.Errorf("fields.%s must be a string, number, boolean or null", name)
+}
+
+// apply merges the input onto l. When partial is false every field is
+// replaced and omitted fields are cleared.
+func (in apiLicenseInput) apply(l *License, partial bool) error {
+	if !partial {
+		*l = License{ID: l.ID}
+	}
+	if in.Name != nil {
+		l.Name = strings.TrimSpace(*in.Name)
+	}
+	if in.Vendor != nil {
+		l.Vendor = *in.Vendor
+	}
+	if in.Status != nil {
+		l.Status = *in.Status
+	}
+	if in.Quantity != nil {
+		l.Quantity = *in.Quantity
+	}
+	if in.Metric != nil {
+		l.Metric = LicenseMetric(*in.Metric)
+	}
+	if in.Product != nil {
+		l.Product = strings.TrimSpace(*in.Product)
+	}
+	if in.UnitCost != nil {
+		l.UnitCost = *in.UnitCost
+	}
+	if in.OwnerEmail != nil {
+		email, err := parseEmail(*in.OwnerEmail)
+		if err != nil {
+			return fmt.Errorf("owner_email: %v", err)
+		}
+		l.OwnerEmail = email
+	}
+	if l.Metric == "" {
+		l.Metric = MetricPerUser
+	}
+	var err error
+	if l.ExpiryDate, err = in.ExpiryDate.date("expiry_date", l.ExpiryDate); err != nil {
+		return err
+	}
+	if l.RenewalDate, err = in.RenewalDate.date("renewal_date", l.RenewalDate); err != nil {
+		return err
+	}
+	if l.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if l.Quantity < 0 {
+		return fmt.Errorf("quantity must not be negative")
+	}
+	if l.UnitCost < 0 {
+		return fmt.Errorf("unit_cost must not be negative")
+	}
+	if !validMetric(l.Metric) {
+		return fmt.Errorf("metric must be one of per_user, per_device, per_core, concurrent or site")
+	}
+	return nil
+}
+
+// date parses the field as a YYYY-MM-DD date, keeping current when omitted.
+func (n nullableStr) date(field string, current time.Time) (time.Time, error) {
+	if !n.Set {
+		return current, nil
+	}
+	if n.Value == nil || *n.Value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(dateLayout, *n.Value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be a date in YYYY-MM-DD format", field)
+	}
+	return t, nil
+}
+
+// apiExportFormat reads ?format=, writing a 400 payload if it is not json,
+// csv or xlsx.
+func apiExportFormat(w http.ResponseWriter, r *http.Request) (string, bool) {
+	format, err := exportFormat(r, formatJSON, formatJSON, formatCSV, formatXLSX)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_format", err.Error())
+		return "", false
+	}
+	return format, true
+}
+
+// apiListAssets lists the assets. ?type= limits them to those of a type,
+// given by name, and each ?where= to those meeting a condition on one of
+// its custom fields, such as ram_gb>=16; see parseCondition.
+func (s *server) apiListAssets(w http.ResponseWriter, r *http.Request) {
+	format, ok := apiExportFormat(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	var assets []Asset
+	var err error
+	if typeName := query.Get("type"); typeName != "" {
+		_, assets, err = s.assetsOfType(r.Context(), typeName, query["where"])
+		if errors.Is(err, errInvalidAssetType) || errors.Is(err, errInvalidCondition) || errors.Is(err, errInvalidFieldValue) {
+			writeAPIError(w, http.StatusBadRequest, "invalid_query", err.Error())
+			return
+		}
+	} else if len(query["where"]) > 0 {
+		writeAPIError(w, http.StatusBadRequest, "invalid_query", "where needs the type whose fields it refers to")
+		return
+	} else {
+		assets, err = s.assets.List(r.Context())
+	}
+	if err != nil {
+		writeAPIInternalError(w, "Error listing assets", err)
+		return
+	}
+	if format != formatJSON {
+		writeExport(w, format, exportFilename("assets", format), assetsTable(assets))
+		return
+	}
+	out := make([]apiAsset, 0, len(assets))
+	for _, a := range assets {
+		out = append(out, toAPIAsset(a))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *server) apiGetAsset(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	a, err := s.assets.Get(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("asset %d not found", id))
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error fetching asset", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toAPIAsset(*a))
+}
+
+func (s *server) apiCreateAsset(w http.ResponseWriter, r *http.Request) {
+	var in apiAssetInput
+	if err := decodeJSON(r, &in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+	var a Asset
+	if err := in.apply(&a, false); err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return
+	}
+	if !s.apiResolveLocation(w, r, &a) || !s.apiResolveAssetType(w, r, &a) {
+		return
+	}
+	id, err := s.assets.Create(r.Context(), a)
+	if errors.Is(err, errDuplicateAsset) {
+		writeAPIError(w, http.StatusConflict, "duplicate", err.Error())
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error inserting asset", err)
+		return
+	}
+	a.ID = id
+	s.events.Emit(r.Context(), EventAssetCreated, toAPIAsset(a))
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/assets/%d", id))
+	writeJSON(w, http.StatusCreated, toAPIAsset(a))
+}
+
+func (s *server) apiUpdateAsset(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	var in apiAssetInput
+	if err := decodeJSON(r, &in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+	a, err := s.assets.Get(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("asset %d not found", id))
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error fetching asset", err)
+		return
+	}
+	if err := in.apply(a, r.Method == http.MethodPatch); err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return
+	}
+	if !s.apiResolveLocation(w, r, a) || !s.apiResolveAssetType(w, r, a) {
+		return
+	}
+	err = s.assets.Update(r.Context(), id, *a)
+	if errors.Is(err, errDuplicateAsset) {
+		writeAPIError(w, http.StatusConflict, "duplicate", err.Error())
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error updating asset", err)
+		return
+	}
+	s.events.Emit(r.Context(), EventAssetUpdated, toAPIAsset(*a))
+	writeJSON(w, http.StatusOK, toAPIAsset(*a))
+}
+
+func (s *server) apiDeleteAsset(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	err := s.assets.Delete(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("asset %d not found", id))
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error deleting asset", err)
+		return
+	}
+	s.events.Emit(r.Context(), EventAssetDeleted, map[string]int{"id": id})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiAssetStateChanges lists the asset's state changes, oldest first.
+func (s *server) apiAssetStateChanges(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	if _, err := s.assets.Get(r.Context(), id); errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("asset %d not found", id))
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error fetching asset", err)
+		return
+	}
+	changes, err := s.assets.StateChanges(r.Context(), id)
+	if err != nil {
+		writeAPIInternalError(w, "Error listing asset state changes", err)
+		return
+	}
+	out := make([]apiAssetStateChange, 0, len(changes))
+	for _, c := range changes {
+		out = append(out, toAPIAssetStateChange(c))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// apiTransitionAsset moves an asset to another state. Changes the
+// lifecycle does not allow are rejected with 409 Conflict.
+func (s *server) apiTransitionAsset(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	var in apiAssetTransition
+	if err := decodeJSON(r, &in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+	state, reason, err := parseAssetTransition(in.State, in.Reason)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return
+	}
+	change, err := s.assets.Transition(r.Context(), id, state, reason)
+	if errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("asset %d not found", id))
+		return
+	} else if errors.Is(err, errInvalidTransition) {
+		writeAPIError(w, http.StatusConflict, "invalid_transition", err.Error())
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error changing asset state", err)
+		return
+	}
+	s.events.Emit(r.Context(), EventAssetStateChanged, toAPIAssetStateChange(*change))
+	writeJSON(w, http.StatusOK, toAPIAssetStateChange(*change))
+}
+
+func (s *server) apiListLicenses(w http.ResponseWriter, r *http.Request) {
+	format, ok := apiExportFormat(w, r)
+	if !ok {
+		return
+	}
+	licenses, err := s.licenses.List(r.Context())
+	if err != nil {
+		writeAPIInternalError(w, "Error listing licenses", err)
+		return
+	}
+	if format != formatJSON {
+		writeExport(w, format, exportFilename("licenses", format), licensesTable(licenses))
+		return
+	}
+	out := make([]apiLicense, 0, len(licenses))
+	for _, l := range licenses {
+		out = append(out, toAPILicense(l))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *server) apiGetLicense(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	l, err := s.licenses.Get(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("license %d not found", id))
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error fetching license", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toAPILicense(*l))
+}
+
+func (s *server) apiCreateLicense(w http.ResponseWriter, r *http.Request) {
+	var in apiLicenseInput
+	if err := decodeJSON(r, &in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+	var l License
+	if err := in.apply(&l, false); err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return
+	}
+	id, err := s.licenses.Create(r.Context(), l)
+	if err != nil {
+		writeAPIInternalError(w, "Error inserting license", err)
+		return
+	}
+	l.ID = id
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/licenses/%d", id))
+	writeJSON(w, http.StatusCreated, toAPILicense(l))
+}
+
+func (s *server) apiUpdateLicense(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	var in apiLicenseInput
+	if err := decodeJSON(r, &in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+	l, err := s.licenses.Get(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("license %d not found", id))
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error fetching license", err)
+		return
+	}
+	if err := in.apply(l, r.Method == http.MethodPatch); err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return
+	}
+	if err := s.licenses.Update(r.Context(), id, *l); err != nil {
+		writeAPIInternalError(w, "Error updating license", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toAPILicense(*l))
+}
+
+func (s *server) apiDeleteLicense(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	err := s.licenses.Delete(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("license %d not found", id))
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error deleting license", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) apiListAssignments(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	if _, err := s.licenses.Get(r.Context(), id); errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("license %d not found", id))
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error fetching license", err)
+		return
+	}
+	assignments, err := s.licenses.Assignments(r.Context(), id)
+	if err != nil {
+		writeAPIInternalError(w, "Error listing license assignments", err)
+		return
+	}
+	out := make([]apiAssignment, 0, len(assignments))
+	for _, a := range assignments {
+		out = append(out, toAPIAssignment(a))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *server) apiCreateAssignment(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	var in apiAssignmentInput
+	if err := decodeJSON(r, &in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+	a := LicenseAssignment{LicenseID: id, Assignee: strings.TrimSpace(in.Assignee), Quantity: 1}
+	if in.AssetID != nil {
+		a.AssetID = *in.AssetID
+	}
+	if in.PersonID != nil {
+		a.PersonID = *in.PersonID
+	}
+	if in.Quantity != nil {
+		a.Quantity = *in.Quantity
+	}
+	if err := validateAssignment(a); err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return
+	}
+	if a.AssetID != 0 {
+		asset, err := s.assets.Get(r.Context(), a.AssetID)
+		if errors.Is(err, ErrNotFound) {
+			writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", fmt.Sprintf("asset %d does not exist", a.AssetID))
+			return
+		} else if err != nil {
+			writeAPIInternalError(w, "Error fetching asset", err)
+			return
+		}
+		a.AssetName = asset.Name
+	}
+	if a.PersonID != 0 {
+		p, err := s.people.Get(r.Context(), a.PersonID)
+		if errors.Is(err, ErrNotFound) {
+			writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", fmt.Sprintf("person %d does not exist", a.PersonID))
+			return
+		} else if err != nil {
+			writeAPIInternalError(w, "Error fetching person", err)
+			return
+		}
+		a.PersonName = p.Name
+	}
+
+	assignmentID, err := s.licenses.Assign(r.Context(), a)
+	if errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("license %d not found", id))
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error assigning license", err)
+		return
+	}
+	a.ID = assignmentID
+	a.AssignedAt = time.Now().UTC().Truncate(time.Second)
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/licenses/%d/assignments/%d", id, assignmentID))
+	writeJSON(w, http.StatusCreated, toAPIAssignment(a))
+}
+
+func (s *server) apiDeleteAssignment(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	assignmentID, _ := strconv.Atoi(mux.Vars(r)["assignment"])
+	err := s.licenses.Unassign(r.Context(), id, assignmentID)
+	if errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("assignment %d not found on license %d", assignmentID, id))
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error removing license assignment", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// softwareItems validates an inventory report and converts it into records.
+func (rep apiSoftwareReport) softwareItems(assetID int) ([]InstalledSoftware, error) {
+	source := strings.TrimSpace(rep.Source)
+	if source == "" {
+		return nil, fmt.Errorf("source is required")
+	}
+	items := make([]InstalledSoftware, 0, len(rep.Software))
+	for i, item := range rep.Software {
+		sw := InstalledSoftware{
+			AssetID:   assetID,
+			Product:   strings.TrimSpace(item.Product),
+			Publisher: strings.TrimSpace(item.Publisher),
+			Version:   strings.TrimSpace(item.Version),
+			Source:    source,
+		}
+		var err error
+		if sw.InstallDate, err = (nullableStr{Set: true, Value: item.InstallDate}).date("install_date", time.Time{}); err != nil {
+			return nil, fmt.Errorf("software[%d]: %v", i, err)
+		}
+		if err := validateSoftware(sw); err != nil {
+			return nil, fmt.Errorf("software[%d]: %v", i, err)
+		}
+		items = append(items, sw)
+	}
+	return items, nil
+}
+
+// assetExists writes a 404 payload and returns false if the asset is missing.
+func (s *server) assetExists(w http.ResponseWriter, r *http.Request, id int) bool {
+	_, err := s.assets.Get(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("asset %d not found", id))
+		return false
+	} else if err != nil {
+		writeAPIInternalError(w, "Error fetching asset", err)
+		return false
+	}
+	return true
+}
+
+// writeSoftwareList writes the software installed on an asset.
+func (s *server) writeSoftwareList(w http.ResponseWriter, r *http.Request, assetID int) {
+	software, err := s.software.List(r.Context(), assetID)
+	if err != nil {
+		writeAPIInternalError(w, "Error listing installed software", err)
+		return
+	}
+	out := make([]apiSoftware, 0, len(software))
+	for _, sw := range software {
+		out = append(out, toAPISoftware(sw))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *server) apiListSoftware(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	if !s.assetExists(w, r, id) {
+		return
+	}
+	s.writeSoftwareList(w, r, id)
+}
+
+// apiIngestSoftware accepts an inventory snapshot from a discovery tool. The
+// records previously reported by the same source are replaced; records from
+// other sources, including manual entries, are left alone. The response is
+// the asset's full inventory after the update.
+func (s *server) apiIngestSoftware(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	var rep apiSoftwareReport
+	if err := decodeJSON(r, &rep); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+	items, err := rep.softwareItems(id)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return
+	}
+	if !s.assetExists(w, r, id) {
+		return
+	}
+	if err := s.software.ReplaceSource(r.Context(), id, strings.TrimSpace(rep.Source), items); err != nil {
+		writeAPIInternalError(w, "Error storing software inventory", err)
+		return
+	}
+	s.writeSoftwareList(w, r, id)
+}
+
+func (s *server) apiDeleteSoftware(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	softwareID, _ := strconv.Atoi(mux.Vars(r)["software"])
+	err := s.software.Remove(r.Context(), id, softwareID)
+	if errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("software %d not found on asset %d", softwareID, id))
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error removing installed software", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func toAPICompliance(report ComplianceReport) apiComplianceReport {
+	out := apiComplianceReport{
+		GeneratedAt:   report.GeneratedAt,
+		Compliant:     report.Compliant,
+		UnderLicensed: report.UnderLicensed,
+		OverLicensed:  report.OverLicensed,
+		TotalExposure: report.TotalExposure,
+		Products:      make([]apiProductPosition, 0, len(report.Products)),
+	}
+	for _, p := range report.Products {
+		ap := apiProductPosition{
+			Product:       p.Product,
+			Metric:        p.Metric,
+			Licenses:      p.Licenses,
+			Position:      p.Position,
+			Installations: p.Installations,
+			Assigned:      p.Assigned,
+			Demand:        p.Demand,
+			Shortfall:     p.Shortfall,
+			Surplus:       p.Surplus,
+			UnitCost:      p.UnitCost,
+			Exposure:      p.Exposure,
+		}
+		if !p.Unlimited {
+			entitled := p.Entitled
+			ap.Entitled = &entitled
+		}
+		out.Products = append(out.Products, ap)
+	}
+	return out
+}
+
+// apiCompliance runs a reconciliation and returns the license position.
+func (s *server) apiCompliance(w http.ResponseWriter, r *http.Request) {
+	report, err := s.runCompliance(r.Context())
+	if err != nil {
+		writeAPIInternalError(w, "Error running compliance report", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toAPICompliance(report))
+}
+
+func toAPIRisk(r Risk) apiRisk {
+	out := apiRisk{
+		ID:           r.ID,
+		Title:        r.Title,
+		Description:  r.Description,
+		Likelihood:   r.Likelihood,
+		Impact:       r.Impact,
+		Score:        r.Score(),
+		Level:        r.Level(),
+		Owner:        r.Owner,
+		Status:       r.Status,
+		NextStatuses: r.Status.Next(),
+		ReviewDate:   apiDate(r.ReviewDate),
+		AssetIDs:     append([]int{}, r.AssetIDs...),
+		LicenseIDs:   append([]int{}, r.LicenseIDs...),
+		Mitigations:  make([]apiMitigation, 0, len(r.Mitigations)),
+		CreatedAt:    r.CreatedAt,
+		UpdatedAt:    r.UpdatedAt,
+	}
+	for _, m := range r.Mitigations {
+		am := apiMitigation{ID: m.ID, Action: m.Action, DueDate: apiDate(m.DueDate)}
+		if m.Done() {
+			completed := m.CompletedAt
+			am.CompletedAt = &completed
+		}
+		out.Mitigations = append(out.Mitigations, am)
+	}
+	return out
+}
+
+// apply merges the input onto r. When partial is false every field is
+// replaced and omitted fields are cleared.
+func (in apiRiskInput) apply(r *Risk, partial bool) error {
+	if !partial {
+		*r = Risk{ID: r.ID, Status: r.Status}
+	}
+	if in.Title != nil {
+		r.Title = strings.TrimSpace(*in.Title)
+	}
+	if in.Description != nil {
+		r.Description = *in.Description
+	}
+	if in.Likelihood != nil {
+		r.Likelihood = *in.Likelihood
+	}
+	if in.Impact != nil {
+		r.Impact = *in.Impact
+	}
+	if in.Owner != nil {
+		r.Owner = strings.TrimSpace(*in.Owner)
+	}
+	if in.AssetIDs != nil {
+		r.AssetIDs = *in.AssetIDs
+	}
+	if in.LicenseIDs != nil {
+		r.LicenseIDs = *in.LicenseIDs
+	}
+	var err error
+	if r.ReviewDate, err = in.ReviewDate.date("review_date", r.ReviewDate); err != nil {
+		return err
+	}
+	return validateRisk(*r)
+}
+
+// checkLinks verifies that linked assets and licenses exist.
+func (s *server) checkLinks(ctx context.Context, assetIDs, licenseIDs []int) error {
+	for _, id := range assetIDs {
+		if _, err := s.assets.Get(ctx, id); errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("asset %d does not exist", id)
+		} else if err != nil {
+			return err
+		}
+	}
+	for _, id := range licenseIDs {
+		if _, err := s.licenses.Get(ctx, id); errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("license %d does not exist", id)
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkReportRuns reports an error if any of the report runs do not exist.
+func (s *server) checkReportRuns(ctx context.Context, ids []int) error {
+	for _, id := range ids {
+		if _, err := s.reports.GetRun(ctx, id); errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("report run %d does not exist", id)
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getRisk fetches a risk, writing a 404 or 500 payload if that fails.
+func (s *server) getRisk(w http.ResponseWriter, r *http.Request, id int) (*Risk, bool) {
+	risk, err := s.risks.Get(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("risk %d not found", id))
+		return nil, false
+	} else if err != nil {
+		writeAPIInternalError(w, "Error fetching risk", err)
+		return nil, false
+	}
+	return risk, true
+}
+
+func (s *server) apiListRisks(w http.ResponseWriter, r *http.Request) {
+	risks, err := s.risks.List(r.Context())
+	if err != nil {
+		writeAPIInternalError(w, "Error listing risks", err)
+		return
+	}
+	out := make([]apiRisk, 0, len(risks))
+	for _, risk := range risks {
+		out = append(out, toAPIRisk(risk))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *server) apiGetRisk(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	if risk, ok := s.getRisk(w, r, id); ok {
+		writeJSON(w, http.StatusOK, toAPIRisk(*risk))
+	}
+}
+
+func (s *server) apiCreateRisk(w http.ResponseWriter, r *http.Request) {
+	var in apiRiskInput
+	if err := decodeJSON(r, &in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+	risk := Risk{Status: RiskIdentified}
+	if err := in.apply(&risk, false); err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return
+	}
+	if err := s.checkLinks(r.Context(), risk.AssetIDs, risk.LicenseIDs); err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return
+	}
+	id, err := s.risks.Create(r.Context(), risk)
+	if err != nil {
+		writeAPIInternalError(w, "Error inserting risk", err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/risks/%d", id))
+	if created, ok := s.getRisk(w, r, id); ok {
+		writeJSON(w, http.StatusCreated, toAPIRisk(*created))
+	}
+}
+
+func (s *server) apiUpdateRisk(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	var in apiRiskInput
+	if err := decodeJSON(r, &in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+	risk, ok := s.getRisk(w, r, id)
+	if !ok {
+		return
+	}
+	if err := in.apply(risk, r.Method == http.MethodPatch); err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return
+	}
+	if err := s.checkLinks(r.Context(), risk.AssetIDs, risk.LicenseIDs); err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return
+	}
+	if err := s.risks.Update(r.Context(), id, *risk); err != nil {
+		writeAPIInternalError(w, "Error updating risk", err)
+		return
+	}
+	if updated, ok := s.getRisk(w, r, id); ok {
+		writeJSON(w, http.StatusOK, toAPIRisk(*updated))
+	}
+}
+
+func (s *server) apiDeleteRisk(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	err := s.risks.Delete(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("risk %d not found", id))
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error deleting risk", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiTransitionRisk moves a risk to another status. Changes the workflow
+// does not allow are rejected with 409 Conflict.
+func (s *server) apiTransitionRisk(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	var in struct {
+		Status RiskStatus `json:"status"`
+	}
+	if err := decodeJSON(r, &in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+	err := s.risks.Transition(r.Context(), id, in.Status)
+	if errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("risk %d not found", id))
+		return
+	} else if errors.Is(err, errInvalidTransition) {
+		writeAPIError(w, http.StatusConflict, "invalid_transition", err.Error())
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error changing risk status", err)
+		return
+	}
+	if risk, ok := s.getRisk(w, r, id); ok {
+		writeJSON(w, http.StatusOK, toAPIRisk(*risk))
+	}
+}
+
+func (s *server) apiAddMitigation(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	var in apiMitigationInput
+	if err := decodeJSON(r, &in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+	m := RiskMitigation{RiskID: id, Action: strings.TrimSpace(in.Action)}
+	if m.Action == "" {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", "action is required")
+		return
+	}
+	var err error
+	if m.DueDate, err = in.DueDate.date("due_date", time.Time{}); err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return
+	}
+	_, err = s.risks.AddMitigation(r.Context(), m)
+	if errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("risk %d not found", id))
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error inserting mitigation", err)
+		return
+	}
+	if risk, ok := s.getRisk(w, r, id); ok {
+		writeJSON(w, http.StatusCreated, toAPIRisk(*risk))
+	}
+}
+
+func (s *server) apiCompleteMitigation(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	mitigationID, _ := strconv.Atoi(mux.Vars(r)["mitigation"])
+	err := s.risks.CompleteMitigation(r.Context(), id, mitigationID)
+	if errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("open mitigation %d not found on risk %d", mitigationID, id))
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error completing mitigation", err)
+		return
+	}
+	if risk, ok := s.getRisk(w, r, id); ok {
+		writeJSON(w, http.StatusOK, toAPIRisk(*risk))
+	}
+}
+
+func toAPIFOIRequest(f FOIRequest) apiFOIRequest {
+	return apiFOIRequest{
+		ID:           f.ID,
+		Reference:    f.Reference(),
+		Requester:    f.Requester,
+		Contact:      f.Contact,
+		Subject:      f.Subject,
+		Details:      f.Details,
+		ReceivedDate: apiDate(f.ReceivedDate),
+		DueDate:      apiDate(f.DueDate),
+		Overdue:      f.Overdue(),
+		Assignee:     f.Assignee,
+		Status:       f.Status,
+		NextStatuses: f.Status.Next(),
+		AssetIDs:     append([]int{}, f.AssetIDs...),
+		LicenseIDs:   append([]int{}, f.LicenseIDs...),
+		ReportRunIDs: append([]int{}, f.ReportRunIDs...),
+		CreatedAt:    f.CreatedAt,
+		UpdatedAt:    f.UpdatedAt,
+	}
+}
+
+// apply merges the input onto f. When partial is false every field is
+// replaced and omitted fields are cleared.
+func (in apiFOIInput) apply(f *FOIRequest, partial bool) error {
+	if !partial {
+		*f = FOIRequest{ID: f.ID, Status: f.Status}
+	}
+	if in.Requester != nil {
+		f.Requester = strings.TrimSpace(*in.Requester)
+	}
+	if in.Contact != nil {
+		f.Contact = strings.TrimSpace(*in.Contact)
+	}
+	if in.Subject != nil {
+		f.Subject = strings.TrimSpace(*in.Subject)
+	}
+	if in.Details != nil {
+		f.Details = *in.Details
+	}
+	if in.Assignee != nil {
+		f.Assignee = strings.TrimSpace(*in.Assignee)
+	}
+	if in.AssetIDs != nil {
+		f.AssetIDs = *in.AssetIDs
+	}
+	if in.LicenseIDs != nil {
+		f.LicenseIDs = *in.LicenseIDs
+	}
+	if in.ReportRunIDs != nil {
+		f.ReportRunIDs = *in.ReportRunIDs
+	}
+	var err error
+	if f.ReceivedDate, err = in.ReceivedDate.date("received_date", f.ReceivedDate); err != nil {
+		return err
+	}
+	return validateFOIRequest(*f)
+}
+
+// getFOIRequest fetches an FOI request, writing a 404 or 500 payload if
+// that fails.
+func (s *server) getFOIRequest(w http.ResponseWriter, r *http.Request, id int) (*FOIRequest, bool) {
+	f, err := s.foi.Get(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("FOI request %d not found", id))
+		return nil, false
+	} else if err != nil {
+		writeAPIInternalError(w, "Error fetching FOI request", err)
+		return nil, false
+	}
+	return f, true
+}
+
+func (s *server) apiListFOI(w http.ResponseWriter, r *http.Request) {
+	requests, err := s.foi.List(r.Context())
+	if err != nil {
+		writeAPIInternalError(w, "Error listing FOI requests", err)
+		return
+	}
+	out := make([]apiFOIRequest, 0, len(requests))
+	for _, f := range requests {
+		out = append(out, toAPIFOIRequest(f))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *server) apiGetFOI(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	if f, ok := s.getFOIRequest(w, r, id); ok {
+		writeJSON(w, http.StatusOK, toAPIFOIRequest(*f))
+	}
+}
+
+func (s *server) apiCreateFOI(w http.ResponseWriter, r *http.Request) {
+	var in apiFOIInput
+	if err := decodeJSON(r, &in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+	f := FOIRequest{Status: FOIReceived}
+	if err := in.apply(&f, false); err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return
+	}
+	if err := s.checkLinks(r.Context(), f.AssetIDs, f.LicenseIDs); err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return
+	}
+	if err := s.checkReportRuns(r.Context(), f.ReportRunIDs); err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return
+	}
+	f.DueDate = s.foiCalendar.Deadline(f.ReceivedDate)
+	id, err := s.foi.Create(r.Context(), f)
+	if err != nil {
+		writeAPIInternalError(w, "Error inserting FOI request", err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/foi/%d", id))
+	if created, ok := s.getFOIRequest(w, r, id); ok {
+		writeJSON(w, http.StatusCreated, toAPIFOIRequest(*created))
+	}
+}
+
+func (s *server) apiUpdateFOI(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	var in apiFOIInput
+	if err := decodeJSON(r, &in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+	f, ok := s.getFOIRequest(w, r, id)
+	if !ok {
+		return
+	}
+	if err := in.apply(f, r.Method == http.MethodPatch); err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return
+	}
+	if err := s.checkLinks(r.Context(), f.AssetIDs, f.LicenseIDs); err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return
+	}
+	if err := s.checkReportRuns(r.Context(), f.ReportRunIDs); err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return
+	}
+	f.DueDate = s.foiCalendar.Deadline(f.ReceivedDate)
+	if err := s.foi.Update(r.Context(), id, *f); err != nil {
+		writeAPIInternalError(w, "Error updating FOI request", err)
+		return
+	}
+	if updated, ok := s.getFOIRequest(w, r, id); ok {
+		writeJSON(w, http.StatusOK, toAPIFOIRequest(*updated))
+	}
+}
+
+func (s *server) apiDeleteFOI(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	err := s.foi.Delete(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("FOI request %d not found", id))
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error deleting FOI request", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiTransitionFOI moves an FOI request to another status. Changes the
+// workflow does not allow are rejected with 409 Conflict.
+func (s *server) apiTransitionFOI(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	var in struct {
+		Status FOIStatus `json:"status"`
+	}
+	if err := decodeJSON(r, &in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+	err := s.foi.Transition(r.Context(), id, in.Status)
+	if errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("FOI request %d not found", id))
+		return
+	} else if errors.Is(err, errInvalidTransition) {
+		writeAPIError(w, http.StatusConflict, "invalid_transition", err.Error())
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error changing FOI request status", err)
+		return
+	}
+	if f, ok := s.getFOIRequest(w, r, id); ok {
+		writeJSON(w, http.StatusOK, toAPIFOIRequest(*f))
+	}
+}
+
+func toAPIReportDefinition(d ReportDefinition) apiReportDefinition {
+	out := apiReportDefinition{Key: d.Key, Name: d.Name, Description: d.Description, Params: []apiReportParam{}}
+	for _, p := range d.Params {
+		out.Params = append(out.Params, apiReportParam{Name: p.Name, Label: p.Label, Type: p.Type, Default: p.Default})
+	}
+	return out
+}
+
+func toAPISavedReport(sr SavedReport) apiSavedReport {
+	return apiSavedReport{ID: sr.ID, Name: sr.Name, Report: sr.ReportKey, Params: sr.Params, CreatedBy: sr.CreatedBy, CreatedAt: sr.CreatedAt}
+}
+
+func toAPIReportRun(run ReportRun) apiReportRun {
+	out := apiReportRun{
+		ID:         run.ID,
+		Name:       run.Name,
+		Report:     run.ReportKey,
+		Params:     run.Params,
+		RunBy:      run.RunBy,
+		StartedAt:  run.StartedAt,
+		FinishedAt: run.FinishedAt,
+		Succeeded:  run.Succeeded(),
+		Error:      run.Error,
+		RowCount:   run.RowCount,
+		Result:     run.Result,
+	}
+	if run.SavedReportID != 0 {
+		id := run.SavedReportID
+		out.SavedReportID = &id
+	}
+	return out
+}
+
+// runnableDefinition looks up a report the user may run, writing a 422
+// payload if there is none.
+func runnableDefinition(w http.ResponseWriter, r *http.Request, key string) (*ReportDefinition, bool) {
+	def, ok := reportDefinition(key)
+	if !ok || !currentUser(r).Can(def.Permission) {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", fmt.Sprintf("unknown report %q", key))
+		return nil, false
+	}
+	return def, true
+}
+
+// getSavedReport fetches a saved report the user may run, writing a 404 or
+// 500 payload if that fails.
+func (s *server) getSavedReport(w http.ResponseWriter, r *http.Request, id int) (*SavedReport, bool) {
+	sr, err := s.reports.GetSaved(r.Context(), id)
+	if err == nil {
+		if def := sr.Definition(); def == nil || !currentUser(r).Can(def.Permission) {
+			err = ErrNotFound
+		}
+	}
+	if errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("saved report %d not found", id))
+		return nil, false
+	} else if err != nil {
+		writeAPIInternalError(w, "Error fetching saved report", err)
+		return nil, false
+	}
+	return sr, true
+}
+
+func (s *server) apiListReportDefinitions(w http.ResponseWriter, r *http.Request) {
+	out := []apiReportDefinition{}
+	for _, d := range runnableReports(currentUser(r)) {
+		out = append(out, toAPIReportDefinition(d))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *server) apiListSavedReports(w http.ResponseWriter, r *http.Request) {
+	saved, err := s.reports.ListSaved(r.Context())
+	if err != nil {
+		writeAPIInternalError(w, "Error listing saved reports", err)
+		return
+	}
+	out := make([]apiSavedReport, 0, len(saved))
+	for _, sr := range saved {
+		if def := sr.Definition(); def != nil && currentUser(r).Can(def.Permission) {
+			out = append(out, toAPISavedReport(sr))
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *server) apiGetSavedReport(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	if sr, ok := s.getSavedReport(w, r, id); ok {
+		writeJSON(w, http.StatusOK, toAPISavedReport(*sr))
+	}
+}
+
+func (s *server) apiCreateSavedReport(w http.ResponseWriter, r *http.Request) {
+	var in apiReportRunInput
+	if err := decodeJSON(r, &in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", "name is required")
+		return
+	}
+	def, ok := runnableDefinition(w, r, in.Report)
+	if !ok {
+		return
+	}
+	params, err := def.resolveParams(in.Params)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return
+	}
+
+	id, err := s.reports.CreateSaved(r.Context(), SavedReport{Name: in.Name, ReportKey: def.Key, Params: params, CreatedBy: currentUser(r).Username})
+	if err != nil {
+		writeAPIInternalError(w, "Error saving report", err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/reports/saved/%d", id))
+	if sr, ok := s.getSavedReport(w, r, id); ok {
+		writeJSON(w, http.StatusCreated, toAPISavedReport(*sr))
+	}
+}
+
+func (s *server) apiDeleteSavedReport(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	if _, ok := s.getSavedReport(w, r, id); !ok {
+		return
+	}
+	err := s.reports.DeleteSaved(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("saved report %d not found", id))
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error deleting saved report", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiRunReport runs a built-in report ad hoc and returns the recorded run
+// with its result. A report that fails is still recorded and returned.
+func (s *server) apiRunReport(w http.ResponseWriter, r *http.Request) {
+	var in apiReportRunInput
+	if err := decodeJSON(r, &in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+	def, ok := runnableDefinition(w, r, in.Report)
+	if !ok {
+		return
+	}
+	params, err := def.resolveParams(in.Params)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return
+	}
+	s.apiExecuteReport(w, r, def, params, nil)
+}
+
+func (s *server) apiRunSavedReport(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	sr, ok := s.getSavedReport(w, r, id)
+	if !ok {
+		return
+	}
+	def := sr.Definition()
+	params, err := def.resolveParams(sr.Params)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return
+	}
+	s.apiExecuteReport(w, r, def, params, sr)
+}
+
+func (s *server) apiExecuteReport(w http.ResponseWriter, r *http.Request, def *ReportDefinition, params ReportParams, saved *SavedReport) {
+	run, err := s.executeReport(r.Context(), def, params, saved, currentUser(r))
+	if err != nil {
+		writeAPIInternalError(w, "Error recording report run", err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/reports/runs/%d", run.ID))
+	writeJSON(w, http.StatusCreated, toAPIReportRun(*run))
+}
+
+func (s *server) apiListReportRuns(w http.ResponseWriter, r *http.Request) {
+	runs, err := s.visibleReportRuns(r)
+	if err != nil {
+		writeAPIInternalError(w, "Error listing report runs", err)
+		return
+	}
+	out := make([]apiReportRun, 0, len(runs))
+	for _, run := range runs {
+		out = append(out, toAPIReportRun(run))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// apiGetReportRun returns a run with its result, or with ?format=csv|xlsx
+// just the result as a file.
+func (s *server) apiGetReportRun(w http.ResponseWriter, r *http.Request) {
+	format, ok := apiExportFormat(w, r)
+	if !ok {
+		return
+	}
+	id, _ := routeID(r)
+	run, err := s.reports.GetRun(r.Context(), id)
+	if err == nil && !canSeeRun(currentUser(r), *run) {
+		err = ErrNotFound
+	}
+	if errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("report run %d not found", id))
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error fetching report run", err)
+		return
+	}
+	if format != formatJSON {
+		if run.Result == nil {
+			writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("report run %d failed and has no result", id))
+			return
+		}
+		writeExport(w, format, run.Filename(format), reportResultTable(run.Result))
+		return
+	}
+	writeJSON(w, http.StatusOK, toAPIReportRun(*run))
+}
+
+func toAPIAuditEntry(e AuditEntry) apiAuditEntry {
+	out := apiAuditEntry{
+		ID:         e.ID,
+		OccurredAt: e.OccurredAt,
+		Actor:      e.Actor,
+		Entity:     e.Entity,
+		EntityID:   e.EntityID,
+		Action:     e.Action,
+		Before:     json.RawMessage("null"),
+		After:      json.RawMessage("null"),
+		PrevHash:   e.PrevHash,
+		Hash:       e.Hash,
+	}
+	if e.Before != "" {
+		out.Before = json.RawMessage(e.Before)
+	}
+	if e.After != "" {
+		out.After = json.RawMessage(e.After)
+	}
+	return out
+}
+
+// apiListAudit searches the audit log, newest first. It accepts the same
+// filters as the audit log page plus limit, which defaults to
+// auditPageSize.
+func (s *server) apiListAudit(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAuditFilter(r.URL.Query())
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_parameter", err.Error())
+		return
+	}
+	if filter.Limit == 0 {
+		filter.Limit = auditPageSize
+	} else if filter.Limit > auditAPIMaxLimit {
+		writeAPIError(w, http.StatusBadRequest, "invalid_parameter", fmt.Sprintf("limit must not exceed %d", auditAPIMaxLimit))
+		return
+	}
+	entries, err := s.auditLog.Search(r.Context(), filter)
+	if err != nil {
+		writeAPIInternalError(w, "Error searching audit log", err)
+		return
+	}
+	out := make([]apiAuditEntry, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, toAPIAuditEntry(e))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// apiVerifyAudit checks the audit log's hash chain.
+func (s *server) apiVerifyAudit(w http.ResponseWriter, r *http.Request) {
+	v, err := verifyAuditChain(r.Context(), s.auditLog)
+	if err != nil {
+		writeAPIInternalError(w, "Error verifying audit log", err)
+		return
+	}
+	out := apiAuditVerification{Entries: v.Entries, Intact: v.Intact, Problem: v.Problem, Head: v.Head, CheckedAt: v.CheckedAt}
+	if !v.Intact {
+		out.BrokenAt = &v.BrokenAt
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *server) apiListDepartments(w http.ResponseWriter, r *http.Request) {
+	departments, err := s.people.Departments(r.Context())
+	if err != nil {
+		writeAPIInternalError(w, "Error listing departments", err)
+		return
+	}
+	out := make([]apiDepartment, 0, len(departments))
+	for _, d := range departments {
+		out = append(out, toAPIDepartment(d))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *server) apiCreateDepartment(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Name string `json:"name"`
+	}
+	if err := decodeJSON(r, &in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+	d := Department{Name: strings.TrimSpace(in.Name)}
+	if d.Name == "" {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", "a department name is required")
+		return
+	}
+	var err error
+	d.ID, err = s.createDepartment(r.Context(), d.Name)
+	if errors.Is(err, errDuplicateDepartment) {
+		writeAPIError(w, http.StatusConflict, "duplicate", err.Error())
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error creating department", err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/departments/%d", d.ID))
+	writeJSON(w, http.StatusCreated, toAPIDepartment(d))
+}
+
+// apiDeleteDepartment removes a department; its members are kept without
+// one.
+func (s *server) apiDeleteDepartment(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	err := s.people.DeleteDepartment(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("department %d not found", id))
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error deleting department", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) apiListPeople(w http.ResponseWriter, r *http.Request) {
+	people, err := s.people.List(r.Context())
+	if err != nil {
+		writeAPIInternalError(w, "Error listing people", err)
+		return
+	}
+	out := make([]apiPerson, 0, len(people))
+	for _, p := range people {
+		out = append(out, toAPIPerson(p))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *server) apiGetPerson(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	p, err := s.people.Get(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("person %d not found", id))
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error fetching person", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toAPIPerson(*p))
+}
+
+// apply copies the supplied fields onto p. For a full update (PUT) omitted
+// fields are cleared.
+func (in apiPersonInput) apply(p *Person, partial bool) {
+	if !partial {
+		*p = Person{ID: p.ID}
+	}
+	if in.Name != nil {
+		p.Name = *in.Name
+	}
+	if in.Email != nil {
+		p.Email = *in.Email
+	}
+	if in.DepartmentID != nil {
+		p.DepartmentID = *in.DepartmentID
+	}
+	if in.Username != nil {
+		p.Username = *in.Username
+	}
+}
+
+// apiCheckPerson validates p and reports any problem, returning false if
+// the request has been answered.
+func (s *server) apiCheckPerson(w http.ResponseWriter, r *http.Request, id int, p *Person) bool {
+	if err := validatePerson(p); err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return false
+	}
+	if err := s.checkPerson(r.Context(), id, *p); errors.Is(err, errInvalidPerson) {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return false
+	} else if err != nil {
+		writeAPIInternalError(w, "Error checking person", err)
+		return false
+	}
+	return true
+}
+
+func (s *server) apiCreatePerson(w http.ResponseWriter, r *http.Request) {
+	var in apiPersonInput
+	if err := decodeJSON(r, &in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+	var p Person
+	in.apply(&p, false)
+	if !s.apiCheckPerson(w, r, 0, &p) {
+		return
+	}
+	id, err := s.people.Create(r.Context(), p)
+	if err != nil {
+		writeAPIInternalError(w, "Error creating person", err)
+		return
+	}
+	created, err := s.people.Get(r.Context(), id)
+	if err != nil {
+		writeAPIInternalError(w, "Error fetching person", err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/people/%d", id))
+	writeJSON(w, http.StatusCreated, toAPIPerson(*created))
+}
+
+// apiUpdatePerson replaces (PUT) or patches (PATCH) a person.
+func (s *server) apiUpdatePerson(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	var in apiPersonInput
+	if err := decodeJSON(r, &in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+	p, err := s.people.Get(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("person %d not found", id))
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error fetching person", err)
+		return
+	}
+	in.apply(p, r.Method == http.MethodPatch)
+	if !s.apiCheckPerson(w, r, id, p) {
+		return
+	}
+	if err := s.people.Update(r.Context(), id, *p); errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("person %d not found", id))
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error updating person", err)
+		return
+	}
+	updated, err := s.people.Get(r.Context(), id)
+	if err != nil {
+		writeAPIInternalError(w, "Error fetching person", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toAPIPerson(*updated))
+}
+
+// apiDeletePerson removes a person. People still holding assets are
+// rejected with 409 Conflict.
+func (s *server) apiDeletePerson(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	err := s.people.Delete(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("person %d not found", id))
+		return
+	} else if errors.Is(err, errCustody) {
+		writeAPIError(w, http.StatusConflict, "custody_conflict", err.Error())
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error deleting person", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeCheckouts writes checkouts as a JSON array.
+func writeCheckouts(w http.ResponseWriter, checkouts []Checkout) {
+	out := make([]apiCheckout, 0, len(checkouts))
+	for _, c := range checkouts {
+		out = append(out, toAPICheckout(c))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// apiPersonAssets lists the open checkouts of a person.
+func (s *server) apiPersonAssets(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	if _, err := s.people.Get(r.Context(), id); errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("person %d not found", id))
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error fetching person", err)
+		return
+	}
+	checkouts, err := s.people.Held(r.Context(), id)
+	if err != nil {
+		writeAPIInternalError(w, "Error listing checkouts", err)
+		return
+	}
+	writeCheckouts(w, checkouts)
+}
+
+// apiMyAssets lists the open checkouts of the person linked to the
+// authenticated user, which is empty if no one is linked.
+func (s *server) apiMyAssets(w http.ResponseWriter, r *http.Request) {
+	p, err := s.people.ByUsername(r.Context(), currentUser(r).Username)
+	if errors.Is(err, ErrNotFound) {
+		writeCheckouts(w, nil)
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error fetching person", err)
+		return
+	}
+	checkouts, err := s.people.Held(r.Context(), p.ID)
+	if err != nil {
+		writeAPIInternalError(w, "Error listing checkouts", err)
+		return
+	}
+	writeCheckouts(w, checkouts)
+}
+
+// apiAssetCustody lists the asset's checkouts, newest first.
+func (s *server) apiAssetCustody(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	if _, err := s.assets.Get(r.Context(), id); errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("asset %d not found", id))
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error fetching asset", err)
+		return
+	}
+	checkouts, err := s.people.Custody(r.Context(), id)
+	if err != nil {
+		writeAPIInternalError(w, "Error listing checkouts", err)
+		return
+	}
+	writeCheckouts(w, checkouts)
+}
+
+// apiCheckOutAsset checks an asset out to a person. Assets already checked
+// out or not in service are rejected with 409 Conflict.
+func (s *server) apiCheckOutAsset(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	var in apiCheckoutInput
+	if err := decodeJSON(r, &in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+	c, err := newCheckout(in.PersonID, in.ExpectedReturn, in.Notes)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return
+	}
+	checkout, err := s.checkOut(r.Context(), id, c)
+	if errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("asset %d not found", id))
+		return
+	} else if errors.Is(err, errInvalidPerson) {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return
+	} else if errors.Is(err, errCustody) {
+		writeAPIError(w, http.StatusConflict, "custody_conflict", err.Error())
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error checking out asset", err)
+		return
+	}
+	s.events.Emit(r.Context(), EventAssetCheckedOut, toAPICheckout(*checkout))
+	writeJSON(w, http.StatusCreated, toAPICheckout(*checkout))
+}
+
+// apiCheckInAsset closes the asset's open checkout. Assets that are not
+// checked out are rejected with 409 Conflict.
+func (s *server) apiCheckInAsset(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	var in apiCheckinInput
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &in); err != nil {
+			writeAPIError(w, http.StatusBadRequest, "invalid_json", err.Error())
+			return
+		}
+	}
+	if _, err := s.assets.Get(r.Context(), id); errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("asset %d not found", id))
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error fetching asset", err)
+		return
+	}
+	checkout, err := s.people.CheckIn(r.Context(), id, auditActor(r.Context()), strings.TrimSpace(in.Notes))
+	if errors.Is(err, errCustody) {
+		writeAPIError(w, http.StatusConflict, "custody_conflict", err.Error())
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error checking in asset", err)
+		return
+	}
+	s.events.Emit(r.Context(), EventAssetCheckedIn, toAPICheckout(*checkout))
+	writeJSON(w, http.StatusOK, toAPICheckout(*checkout))
+}
+
+// apiResolveLocation resolves the asset's location and reports one that
+// does not exist, returning false if the request has been answered.
+func (s *server) apiResolveLocation(w http.ResponseWriter, r *http.Request, a *Asset) bool {
+	if err := s.resolveLocation(r.Context(), a); errors.Is(err, errInvalidLocation) {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return false
+	} else if err != nil {
+		writeAPIInternalError(w, "Error fetching locations", err)
+		return false
+	}
+	return true
+}
+
+// apiAssetMoves lists the asset's moves between locations, newest first.
+func (s *server) apiAssetMoves(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	if _, err := s.assets.Get(r.Context(), id); errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("asset %d not found", id))
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error fetching asset", err)
+		return
+	}
+	moves, err := s.assets.Moves(r.Context(), id)
+	if err != nil {
+		writeAPIInternalError(w, "Error listing asset moves", err)
+		return
+	}
+	out := make([]apiAssetMove, 0, len(moves))
+	for _, m := range moves {
+		out = append(out, toAPIAssetMove(m))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// apiListLocations lists every location in tree order, each with the
+// number of assets at and inside it.
+func (s *server) apiListLocations(w http.ResponseWriter, r *http.Request) {
+	locations, err := s.locations.List(r.Context())
+	if err != nil {
+		writeAPIInternalError(w, "Error listing locations", err)
+		return
+	}
+	out := make([]apiLocation, 0, len(locations))
+	for _, l := range locations {
+		out = append(out, toAPILocation(l))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *server) apiGetLocation(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	l, err := s.locations.Get(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("location %d not found", id))
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error fetching location", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toAPILocation(*l))
+}
+
+// apply copies the supplied fields onto l. For a full update (PUT) omitted
+// fields are cleared.
+func (in apiLocationInput) apply(l *Location, partial bool) error {
+	if !partial {
+		*l = Location{ID: l.ID}
+	}
+	if in.ParentID != nil {
+		l.ParentID = *in.ParentID
+	}
+	if in.Name != nil {
+		l.Name = strings.TrimSpace(*in.Name)
+	}
+	if in.Kind != nil {
+		kind, err := parseLocationKind(*in.Kind)
+		if err != nil {
+			return err
+		}
+		l.Kind = kind
+	}
+	if l.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if l.Kind == "" {
+		return fmt.Errorf("kind is required")
+	}
+	return nil
+}
+
+// apiCheckLocation validates l against the hierarchy, returning false if
+// the request has been answered.
+func (s *server) apiCheckLocation(w http.ResponseWriter, r *http.Request, id int, l Location) bool {
+	if err := s.checkLocation(r.Context(), id, l); errors.Is(err, errInvalidLocation) {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return false
+	} else if err != nil {
+		writeAPIInternalError(w, "Error checking location", err)
+		return false
+	}
+	return true
+}
+
+func (s *server) apiCreateLocation(w http.ResponseWriter, r *http.Request) {
+	var in apiLocationInput
+	if err := decodeJSON(r, &in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+	var l Location
+	if err := in.apply(&l, false); err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return
+	}
+	if !s.apiCheckLocation(w, r, 0, l) {
+		return
+	}
+	id, err := s.locations.Create(r.Context(), l)
+	if err != nil {
+		writeAPIInternalError(w, "Error creating location", err)
+		return
+	}
+	created, err := s.locations.Get(r.Context(), id)
+	if err != nil {
+		writeAPIInternalError(w, "Error fetching location", err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/locations/%d", id))
+	writeJSON(w, http.StatusCreated, toAPILocation(*created))
+}
+
+// apiUpdateLocation replaces (PUT) or patches (PATCH) a location. Renaming
+// or moving a location changes the path of everything inside it.
+func (s *server) apiUpdateLocation(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	var in apiLocationInput
+	if err := decodeJSON(r, &in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+	l, err := s.locations.Get(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("location %d not found", id))
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error fetching location", err)
+		return
+	}
+	if err := in.apply(l, r.Method == http.MethodPatch); err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return
+	}
+	if !s.apiCheckLocation(w, r, id, *l) {
+		return
+	}
+	if err := s.locations.Update(r.Context(), id, *l); errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("location %d not found", id))
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error updating location", err)
+		return
+	}
+	updated, err := s.locations.Get(r.Context(), id)
+	if err != nil {
+		writeAPIInternalError(w, "Error fetching location", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toAPILocation(*updated))
+}
+
+// apiDeleteLocation removes an empty location. Locations with assets or
+// other locations inside them are rejected with 409 Conflict.
+func (s *server) apiDeleteLocation(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	err := s.locations.Delete(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("location %d not found", id))
+		return
+	} else if errors.Is(err, errLocationInUse) {
+		writeAPIError(w, http.StatusConflict, "location_in_use", err.Error())
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error deleting location", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiLocationAssets lists the assets kept at a location or anywhere inside
+// it, by path.
+func (s *server) apiLocationAssets(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	locations, err := s.locations.List(r.Context())
+	if err != nil {
+		writeAPIInternalError(w, "Error listing locations", err)
+		return
+	}
+	if _, err := findLocation(locations, id, ""); err != nil {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("location %d not found", id))
+		return
+	}
+	assets, err := s.assets.List(r.Context())
+	if err != nil {
+		writeAPIInternalError(w, "Error listing assets", err)
+		return
+	}
+	inside := locationSubtree(locations, id)
+	out := make([]apiAsset, 0)
+	for _, a := range assets {
+		if inside[a.LocationID] {
+			out = append(out, toAPIAsset(a))
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Location < out[j].Location })
+	writeJSON(w, http.StatusOK, out)
+}
+
+// apiResolveAssetType resolves the asset's type and checks its custom
+// field values, returning false if the request has been answered. Values
+// for fields the type does not define are rejected.
+func (s *server) apiResolveAssetType(w http.ResponseWriter, r *http.Request, a *Asset) bool {
+	given := a.Fields
+	values := make(map[string]string, len(given))
+	for _, v := range given {
+		values[v.Name] = v.Value
+	}
+	err := s.resolveAssetType(r.Context(), a, func(name string) string { return values[name] })
+	if err == nil {
+		for _, v := range given {
+			if a.Field(v.Name) == "" {
+				err = fmt.Errorf("%w: %s has no custom field %q", errInvalidFieldValue, a.AssetType, v.Name)
+				if a.TypeID == 0 {
+					err = fmt.Errorf("%w: custom fields need an asset type", errInvalidFieldValue)
+				}
+				break
+			}
+		}
+	}
+	if errors.Is(err, errInvalidAssetType) || errors.Is(err, errInvalidFieldValue) {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return false
+	} else if err != nil {
+		writeAPIInternalError(w, "Error fetching asset types", err)
+		return false
+	}
+	return true
+}
+
+// apiListAssetTypes lists the asset types by name with their custom fields.
+func (s *server) apiListAssetTypes(w http.ResponseWriter, r *http.Request) {
+	types, err := s.assetTypes.List(r.Context())
+	if err != nil {
+		writeAPIInternalError(w, "Error listing asset types", err)
+		return
+	}
+	out := make([]apiAssetType, 0, len(types))
+	for _, t := range types {
+		out = append(out, toAPIAssetType(t))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// apiFindAssetType fetches the type in the route, writing a 404 payload if
+// it does not exist. It returns nil if the request has been answered.
+func (s *server) apiFindAssetType(w http.ResponseWriter, r *http.Request) *AssetType {
+	id, _ := routeID(r)
+	t, err := s.assetTypes.Get(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("asset type %d not found", id))
+		return nil
+	} else if err != nil {
+		writeAPIInternalError(w, "Error fetching asset type", err)
+		return nil
+	}
+	return t
+}
+
+func (s *server) apiGetAssetType(w http.ResponseWriter, r *http.Request) {
+	if t := s.apiFindAssetType(w, r); t != nil {
+		writeJSON(w, http.StatusOK, toAPIAssetType(*t))
+	}
+}
+
+// apply copies the supplied fields onto f. For a full update (PUT)
+// omitted fields are cleared; the kind is kept unless given.
+func (in apiAssetFieldInput) apply(f *AssetField, partial bool) error {
+	if !partial {
+		*f = AssetField{ID: f.ID, TypeID: f.TypeID, Kind: f.Kind}
+	}
+	if in.Name != nil {
+		f.Name = *in.Name
+	}
+	if in.Label != nil {
+		f.Label = *in.Label
+	}
+	if in.Kind != nil {
+		f.Kind = FieldKind(strings.ToLower(strings.TrimSpace(*in.Kind)))
+	}
+	if in.Options != nil {
+		f.Options = *in.Options
+	}
+	if in.Required != nil {
+		f.Required = *in.Required
+	}
+	if f.Kind == "" {
+		return fmt.Errorf("kind is required")
+	}
+	return validateAssetField(f)
+}
+
+func (s *server) apiCreateAssetType(w http.ResponseWriter, r *http.Request) {
+	var in apiAssetTypeInput
+	if err := decodeJSON(r, &in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+	var t AssetType
+	if in.Name != nil {
+		t.Name = strings.TrimSpace(*in.Name)
+	}
+	err := s.checkAssetType(r.Context(), 0, t.Name)
+	if t.Name == "" {
+		err = fmt.Errorf("%w: name is required", errInvalidAssetType)
+	}
+	for i := 0; err == nil && i < len(in.Fields); i++ {
+		var f AssetField
+		if err = in.Fields[i].apply(&f, false); err == nil {
+			err = checkAssetField(&t, 0, f)
+		}
+		t.Fields = append(t.Fields, f)
+	}
+	if errors.Is(err, errInvalidAssetType) {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error checking asset type", err)
+		return
+	}
+	id, err := s.assetTypes.Create(r.Context(), t)
+	if errors.Is(err, errInvalidAssetType) {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error creating asset type", err)
+		return
+	}
+	created, err := s.assetTypes.Get(r.Context(), id)
+	if err != nil {
+		writeAPIInternalError(w, "Error fetching asset type", err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/asset-types/%d", id))
+	writeJSON(w, http.StatusCreated, toAPIAssetType(*created))
+}
+
+// apiUpdateAssetType renames an asset type. PUT and PATCH are the same,
+// the name being all there is to change.
+func (s *server) apiUpdateAssetType(w http.ResponseWriter, r *http.Request) {
+	var in apiAssetTypeInput
+	if err := decodeJSON(r, &in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+	t := s.apiFindAssetType(w, r)
+	if t == nil {
+		return
+	}
+	if in.Fields != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", fmt.Sprintf("fields are changed through /api/v1/asset-types/%d/fields", t.ID))
+		return
+	}
+	if in.Name != nil {
+		t.Name = strings.TrimSpace(*in.Name)
+	}
+	err := s.checkAssetType(r.Context(), t.ID, t.Name)
+	if t.Name == "" {
+		err = fmt.Errorf("%w: name is required", errInvalidAssetType)
+	}
+	if errors.Is(err, errInvalidAssetType) {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error checking asset type", err)
+		return
+	}
+	if err := s.assetTypes.Update(r.Context(), t.ID, *t); errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("asset type %d not found", t.ID))
+		return
+	} else if errors.Is(err, errInvalidAssetType) {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error updating asset type", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toAPIAssetType(*t))
+}
+
+// apiDeleteAssetType removes an asset type no asset is of. Types in use
+// are rejected with 409 Conflict.
+func (s *server) apiDeleteAssetType(w http.ResponseWriter, r *http.Request) {
+	id, _ := routeID(r)
+	err := s.assetTypes.Delete(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("asset type %d not found", id))
+		return
+	} else if errors.Is(err, errAssetTypeInUse) {
+		writeAPIError(w, http.StatusConflict, "asset_type_in_use", err.Error())
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error deleting asset type", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiCreateAssetField adds a custom field to an asset type and returns
+// the type.
+func (s *server) apiCreateAssetField(w http.ResponseWriter, r *http.Request) {
+	var in apiAssetFieldInput
+	if err := decodeJSON(r, &in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+	t := s.apiFindAssetType(w, r)
+	if t == nil {
+		return
+	}
+	f := AssetField{TypeID: t.ID}
+	err := in.apply(&f, false)
+	if err == nil {
+		err = checkAssetField(t, 0, f)
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return
+	}
+	if _, err := s.assetTypes.CreateField(r.Context(), f); err != nil {
+		writeAPIInternalError(w, "Error creating asset field", err)
+		return
+	}
+	s.apiWriteAssetType(w, r, t.ID, http.StatusCreated)
+}
+
+// apiFindAssetField finds the field in the route among the type's,
+// writing a 404 payload if it has no such field.
+func apiFindAssetField(w http.ResponseWriter, r *http.Request, t *AssetType) *AssetField {
+	id, _ := strconv.Atoi(mux.Vars(r)["field"])
+	for i := range t.Fields {
+		if t.Fields[i].ID == id {
+			return &t.Fields[i]
+		}
+	}
+	writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("asset type %d has no field %d", t.ID, id))
+	return nil
+}
+
+// apiUpdateAssetField replaces (PUT) or patches (PATCH) a custom field and
+// returns its type. Changes that values assets already have would not
+// satisfy, such as dropping an option in use or requiring a field some
+// assets lack, are rejected with 409 Conflict.
+func (s *server) apiUpdateAssetField(w http.ResponseWriter, r *http.Request) {
+	var in apiAssetFieldInput
+	if err := decodeJSON(r, &in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+	t := s.apiFindAssetType(w, r)
+	if t == nil {
+		return
+	}
+	current := apiFindAssetField(w, r, t)
+	if current == nil {
+		return
+	}
+	f := *current
+	err := in.apply(&f, r.Method == http.MethodPatch)
+	if err == nil {
+		err = checkAssetField(t, f.ID, f)
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		return
+	}
+	if err := s.assetTypes.UpdateField(r.Context(), f.ID, f); errors.Is(err, errFieldValuesConflict) {
+		writeAPIError(w, http.StatusConflict, "field_values_conflict", err.Error())
+		return
+	} else if err != nil {
+		writeAPIInternalError(w, "Error updating asset field", err)
+		return
+	}
+	s.apiWriteAssetType(w, r, t.ID, http.StatusOK)
+}
+
+// apiDeleteAssetField removes a custom field and every asset's value for
+// it.
+func (s *server) apiDeleteAssetField(w http.ResponseWriter, r *http.Request) {
+	t := s.apiFindAssetType(w, r)
+	if t == nil {
+		return
+	}
+	f := apiFindAssetField(w, r, t)
+	if f == nil {
+		return
+	}
+	if err := s.assetTypes.DeleteField(r.Context(), t.ID, f.ID); err != nil {
+		writeAPIInternalError(w, "Error deleting asset field", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiWriteAssetType writes the asset type id as it now is.
+func (s *server) apiWriteAssetType(w http.ResponseWriter, r *http.Request, id, status int) {
+	t, err := s.assetTypes.Get(r.Context(), id)
+	if err != nil {
+		writeAPIInternalError(w, "Error fetching asset type", err)
+		return
+	}
+	writeJSON(w, status, toAPIAssetType(*t))
+}
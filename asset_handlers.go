@@ -1,43 +1,348 @@
 package main
 
 import (
-	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// assetHistoryLimit caps the changes shown on an asset's detail page.
+const assetHistoryLimit = 50
+
 // Define structs for data from the database.
 // This struct has been moved from main.go
 type Asset struct {
-	ID        int
-	Name      string
+	ID   int
+	Name string
+	// TypeID is zero for assets without a type. AssetType is the type's
+	// name and Fields the asset's values for the custom fields it defines.
+	TypeID    int
 	AssetType string
-	Location  string
+	Fields    []AssetFieldValue
+	// LocationID is zero for assets without a location. Location is the
+	// full path of the location, see Location.Path.
+	LocationID int
+	Location   string
+	// Manufacturer and Model identify the hardware, e.g. Dell, XPS 15.
+	Manufacturer string
+	Model        string
+	// SerialNumber and AssetTag are optional but, when set, unique across
+	// every asset, including deleted ones.
+	SerialNumber   string
+	AssetTag       string
+	PurchaseDate   time.Time
+	PurchaseCost   float64
+	Supplier       string
+	OrderReference string
+	WarrantyEnd    time.Time
+	// State is the asset's stage of the lifecycle.
+	State AssetState
+}
+
+// errDuplicateAsset reports a serial number or asset tag that another
+// asset already uses.
+var errDuplicateAsset = errors.New("duplicate asset identifier")
+
+// AssetLicense is a license related to an asset, through seats assigned
+// to it or because it covers software installed on it.
+type AssetLicense struct {
+	License     License
+	Assignments []LicenseAssignment
+	// Products lists the installed products the license covers.
+	Products []string
+}
+
+// parseAssetForm reads and validates the asset form fields.
+func parseAssetForm(r *http.Request) (Asset, error) {
+	return parseAssetFields(r.FormValue)
+}
+
+// parseAssetFields validates asset fields named as in the asset form.
+// get returns the value of a field; bulk imports supply CSV columns. The
+// location and type are chosen by id in the form and given by path or
+// name in imports; resolveLocation and resolveAssetType check that they
+// exist, the latter along with the type's custom fields.
+func parseAssetFields(get func(field string) string) (Asset, error) {
+	a := Asset{
+		Name:           strings.TrimSpace(get("name")),
+		AssetType:      strings.TrimSpace(get("asset-type")),
+		Location:       strings.TrimSpace(get("location")),
+		Manufacturer:   strings.TrimSpace(get("manufacturer")),
+		Model:          strings.TrimSpace(get("model")),
+		SerialNumber:   strings.TrimSpace(get("serial-number")),
+		AssetTag:       strings.TrimSpace(get("asset-tag")),
+		Supplier:       strings.TrimSpace(get("supplier")),
+		OrderReference: strings.TrimSpace(get("order-reference")),
+	}
+	if a.Name == "" {
+		return a, fmt.Errorf("an asset name is required")
+	}
+
+	var err error
+	if v := strings.TrimSpace(get("type-id")); v != "" {
+		if a.TypeID, err = strconv.Atoi(v); err != nil {
+			return a, fmt.Errorf("invalid asset type %q", v)
+		}
+	}
+	if v := strings.TrimSpace(get("location-id")); v != "" {
+		if a.LocationID, err = strconv.Atoi(v); err != nil {
+			return a, fmt.Errorf("invalid location %q", v)
+		}
+	}
+	if a.State, err = parseInitialAssetState(get("state")); err != nil {
+		return a, err
+	}
+	if v := strings.TrimSpace(get("purchase-cost")); v != "" {
+		if a.PurchaseCost, err = strconv.ParseFloat(v, 64); err != nil || a.PurchaseCost < 0 {
+			return a, fmt.Errorf("invalid purchase cost %q", v)
+		}
+	}
+	if v := strings.TrimSpace(get("purchase-date")); v != "" {
+		if a.PurchaseDate, err = time.Parse(dateLayout, v); err != nil {
+			return a, fmt.Errorf("invalid purchase date %q", v)
+		}
+	}
+	if v := strings.TrimSpace(get("warranty-end")); v != "" {
+		if a.WarrantyEnd, err = time.Parse(dateLayout, v); err != nil {
+			return a, fmt.Errorf("invalid warranty end date %q", v)
+		}
+	}
+	if !a.WarrantyEnd.IsZero() && a.WarrantyEnd.Before(a.PurchaseDate) {
+		return a, fmt.Errorf("the warranty cannot end before the purchase date")
+	}
+	return a, nil
 }
 
 // assetsHandler handles the asset register page and form submissions.
 // This handler has been moved from main.go
-func assetsHandler(w http.ResponseWriter, r *http.Request) {
+func (s *server) assetsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodPost {
-		name := r.FormValue("name")
-		assetType := r.FormValue("asset-type")
-		location := r.FormValue("location")
-
-		_, err := db.ExecContext(context.Background(), "INSERT INTO assets (name, asset_type, location) VALUES (?, ?, ?)", name, assetType, location)
+		a, err := parseAssetForm(r)
 		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.resolveLocation(r.Context(), &a); errors.Is(err, errInvalidLocation) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if err != nil {
+			log.Printf("Error fetching locations: %v\n", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if err := s.resolveAssetType(r.Context(), &a, formFieldValues(r)); errors.Is(err, errInvalidAssetType) || errors.Is(err, errInvalidFieldValue) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if err != nil {
+			log.Printf("Error fetching asset types: %v\n", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		id, err := s.assets.Create(r.Context(), a)
+		if errors.Is(err, errDuplicateAsset) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if err != nil {
 			log.Printf("Error inserting asset: %v\n", err)
 			http.Error(w, "Error saving asset", http.StatusInternalServerError)
 			return
 		}
+		a.ID = id
+		s.events.Emit(r.Context(), EventAssetCreated, toAPIAsset(a))
 		http.Redirect(w, r, "/assets", http.StatusSeeOther)
 		return
 	}
 
-	data, err := getPageData()
+	data, err := s.getPageData(r.Context())
+	if err != nil {
+		log.Printf("Error fetching page data: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	renderTemplate(w, r, data)
+}
+
+// assetDetailHandler shows a single asset with the software installed on
+// it, its related licenses, its custody, its moves and its change history.
+func (s *server) assetDetailHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	asset, err := s.assets.Get(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		log.Printf("Error fetching asset: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := s.getPageData(r.Context())
 	if err != nil {
 		log.Printf("Error fetching page data: %v\n", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	data.Asset = asset
+	data.Software, err = s.software.List(r.Context(), id)
+	if err != nil {
+		log.Printf("Error fetching installed software: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	data.AssetLicenses, err = s.assetLicenses(r, id, data.Software)
+	if err != nil {
+		log.Printf("Error fetching asset licenses: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	data.AssetStateChanges, err = s.assets.StateChanges(r.Context(), id)
+	if err != nil {
+		log.Printf("Error fetching asset state changes: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	data.AssetMoves, err = s.assets.Moves(r.Context(), id)
+	if err != nil {
+		log.Printf("Error fetching asset moves: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	data.AssetCustody, err = s.people.Custody(r.Context(), id)
+	if err != nil {
+		log.Printf("Error fetching asset custody: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	data.People, err = s.people.List(r.Context())
+	if err != nil {
+		log.Printf("Error fetching people: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	data.AssetHistory, err = s.auditLog.Search(r.Context(), AuditFilter{Entity: AuditAsset, EntityID: id, Limit: assetHistoryLimit})
+	if err != nil {
+		log.Printf("Error fetching asset history: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
 	renderTemplate(w, r, data)
 }
+
+// assetLicenses returns the licenses with seats assigned to the asset or
+// covering software installed on it, in license order.
+func (s *server) assetLicenses(r *http.Request, assetID int, software []InstalledSoftware) ([]AssetLicense, error) {
+	licenses, err := s.licenses.List(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	assignments, err := s.licenses.AssetAssignments(r.Context(), assetID)
+	if err != nil {
+		return nil, err
+	}
+
+	var related []AssetLicense
+	for _, l := range licenses {
+		al := AssetLicense{License: l}
+		for _, a := range assignments {
+			if a.LicenseID == l.ID {
+				al.Assignments = append(al.Assignments, a)
+			}
+		}
+		key := productKey(l.CoveredProduct())
+		for _, sw := range software {
+			if productMatches(sw.Product, key) {
+				al.Products = append(al.Products, sw.Product)
+			}
+		}
+		if len(al.Assignments) > 0 || len(al.Products) > 0 {
+			related = append(related, al)
+		}
+	}
+	return related, nil
+}
+
+// updateAssetHandler saves the edit form on the asset detail page.
+func (s *server) updateAssetHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	existing, err := s.assets.Get(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		log.Printf("Error fetching asset: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	a, err := parseAssetForm(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	a.State = existing.State
+	if err := s.resolveLocation(r.Context(), &a); errors.Is(err, errInvalidLocation) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Printf("Error fetching locations: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if err := s.resolveAssetType(r.Context(), &a, formFieldValues(r)); errors.Is(err, errInvalidAssetType) || errors.Is(err, errInvalidFieldValue) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Printf("Error fetching asset types: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = s.assets.Update(r.Context(), id, a)
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if errors.Is(err, errDuplicateAsset) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Printf("Error updating asset: %v\n", err)
+		http.Error(w, "Error saving asset", http.StatusInternalServerError)
+		return
+	}
+	a.ID = id
+	s.events.Emit(r.Context(), EventAssetUpdated, toAPIAsset(a))
+	http.Redirect(w, r, fmt.Sprintf("/assets/%d", id), http.StatusSeeOther)
+}
+
+// deleteAssetHandler deletes an asset. The record is kept, marked deleted,
+// but the asset's license seats are released and its inventory dropped.
+func (s *server) deleteAssetHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	err = s.assets.Delete(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		log.Printf("Error deleting asset: %v\n", err)
+		http.Error(w, "Error deleting asset", http.StatusInternalServerError)
+		return
+	}
+	s.events.Emit(r.Context(), EventAssetDeleted, map[string]int{"id": id})
+	http.Redirect(w, r, "/assets", http.StatusSeeOther)
+}
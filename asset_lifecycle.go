@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AssetState is a stage of the asset lifecycle.
+type AssetState string
+
+const (
+	AssetOrdered  AssetState = "ordered"
+	AssetInStock  AssetState = "in_stock"
+	AssetDeployed AssetState = "deployed"
+	AssetInRepair AssetState = "in_repair"
+	AssetRetired  AssetState = "retired"
+	AssetDisposed AssetState = "disposed"
+)
+
+// assetStates lists every state in lifecycle order.
+var assetStates = []AssetState{AssetOrdered, AssetInStock, AssetDeployed, AssetInRepair, AssetRetired, AssetDisposed}
+
+// initialAssetStates are the states a new asset may be recorded in.
+var initialAssetStates = []AssetState{AssetOrdered, AssetInStock, AssetDeployed}
+
+// assetTransitions lists the states each state may move to. A retired
+// asset can be returned to stock until it is disposed of.
+var assetTransitions = map[AssetState][]AssetState{
+	AssetOrdered:  {AssetInStock},
+	AssetInStock:  {AssetDeployed, AssetInRepair, AssetRetired},
+	AssetDeployed: {AssetInStock, AssetInRepair, AssetRetired},
+	AssetInRepair: {AssetInStock, AssetDeployed, AssetRetired},
+	AssetRetired:  {AssetInStock, AssetDisposed},
+	AssetDisposed: {},
+}
+
+// Label returns a human readable name for the state.
+func (s AssetState) Label() string {
+	switch s {
+	case AssetInStock:
+		return "In stock"
+	case AssetInRepair:
+		return "In repair"
+	case "":
+		return ""
+	default:
+		return strings.ToUpper(string(s[:1])) + string(s[1:])
+	}
+}
+
+// Next returns the states the asset may move to from s.
+func (s AssetState) Next() []AssetState {
+	return assetTransitions[s]
+}
+
+// CanMoveTo reports whether the lifecycle allows moving from s to next.
+func (s AssetState) CanMoveTo(next AssetState) bool {
+	for _, allowed := range assetTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// RequiresReason reports whether moving to s must be explained.
+func (s AssetState) RequiresReason() bool {
+	return s == AssetRetired || s == AssetDisposed
+}
+
+// parseAssetState accepts a state's value or label, e.g. "in_stock" or
+// "In stock".
+func parseAssetState(v string) (AssetState, error) {
+	v = strings.TrimSpace(v)
+	for _, s := range assetStates {
+		if strings.EqualFold(v, string(s)) || strings.EqualFold(v, s.Label()) {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("unknown asset state %q", v)
+}
+
+// parseInitialAssetState reads the state of a new asset, which defaults to
+// in stock.
+func parseInitialAssetState(v string) (AssetState, error) {
+	if strings.TrimSpace(v) == "" {
+		return AssetInStock, nil
+	}
+	s, err := parseAssetState(v)
+	if err != nil {
+		return "", err
+	}
+	for _, initial := range initialAssetStates {
+		if s == initial {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("a new asset cannot be %s", strings.ToLower(s.Label()))
+}
+
+// parseAssetTransition validates a requested state change: the state must
+// exist and retiring or disposing of an asset needs a reason.
+func parseAssetTransition(state, reason string) (AssetState, string, error) {
+	s, err := parseAssetState(state)
+	if err != nil {
+		return "", "", err
+	}
+	reason = strings.TrimSpace(reason)
+	if s.RequiresReason() && reason == "" {
+		return "", "", fmt.Errorf("a reason is required to move an asset to %s", strings.ToLower(s.Label()))
+	}
+	return s, reason, nil
+}
+
+// AssetStateChange records an asset entering a state. From is empty for
+// the state the asset was created in.
+type AssetStateChange struct {
+	ID        int
+	AssetID   int
+	From      AssetState
+	To        AssetState
+	Reason    string
+	ChangedAt time.Time
+}
+
+// AssetStateCount is the number of assets in a state, for the dashboard.
+type AssetStateCount struct {
+	State AssetState
+	Count int
+}
+
+// countAssetStates counts the assets in each state, in lifecycle order.
+func countAssetStates(assets []Asset) []AssetStateCount {
+	counts := make([]AssetStateCount, len(assetStates))
+	for i, s := range assetStates {
+		counts[i].State = s
+		for _, a := range assets {
+			if a.State == s {
+				counts[i].Count++
+			}
+		}
+	}
+	return counts
+}
+
+// assetStateHandler moves an asset to another stage of its lifecycle.
+func (s *server) assetStateHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	state, reason, err := parseAssetTransition(r.FormValue("state"), r.FormValue("reason"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	change, err := s.assets.Transition(r.Context(), id, state, reason)
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if errors.Is(err, errInvalidTransition) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Printf("Error changing asset state: %v\n", err)
+		http.Error(w, "Error saving asset", http.StatusInternalServerError)
+		return
+	}
+	s.events.Emit(r.Context(), EventAssetStateChanged, toAPIAssetStateChange(*change))
+	http.Redirect(w, r, fmt.Sprintf("/assets/%d", id), http.StatusSeeOther)
+}
@@ -0,0 +1,758 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// FieldKind is the kind of value a custom field holds.
+type FieldKind string
+
+const (
+	FieldText    FieldKind = "text"
+	FieldNumber  FieldKind = "number"
+	FieldDate    FieldKind = "date"
+	FieldEnum    FieldKind = "enum"
+	FieldBoolean FieldKind = "boolean"
+)
+
+// fieldKinds lists the kinds offered when defining a field.
+var fieldKinds = []FieldKind{FieldText, FieldNumber, FieldDate, FieldEnum, FieldBoolean}
+
+// Label returns the kind's display name.
+func (k FieldKind) Label() string {
+	switch k {
+	case FieldEnum:
+		return "Choice"
+	case FieldBoolean:
+		return "Yes/No"
+	case "":
+		return ""
+	}
+	return strings.ToUpper(string(k[:1])) + string(k[1:])
+}
+
+// parseFieldKind validates a field kind.
+func parseFieldKind(v string) (FieldKind, error) {
+	k := FieldKind(strings.ToLower(strings.TrimSpace(v)))
+	for _, kind := range fieldKinds {
+		if kind == k {
+			return k, nil
+		}
+	}
+	return k, fmt.Errorf("invalid field kind %q", v)
+}
+
+// fieldValueMaxLen is the longest custom field value that can be stored.
+const fieldValueMaxLen = 255
+
+// fieldNamePattern matches the names custom fields are stored, exported
+// and queried under.
+var fieldNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// fieldNameSeparators matches the runs of characters replaced by an
+// underscore when a field name is derived from its label.
+var fieldNameSeparators = regexp.MustCompile(`[^a-z0-9]+`)
+
+// AssetField is a custom field defined for the assets of a type.
+type AssetField struct {
+	ID     int
+	TypeID int
+	// Name is the key the field is known by in the API, imports and
+	// queries, e.g. "ram_gb"; Label is shown on the pages, e.g. "RAM (GB)".
+	Name  string
+	Label string
+	Kind  FieldKind
+	// Options lists the choices of an enum field.
+	Options  []string
+	Required bool
+}
+
+// InputType returns the HTML input type used for text, number and date
+// fields; enum and boolean fields use a select.
+func (f AssetField) InputType() string {
+	switch f.Kind {
+	case FieldNumber:
+		return "number"
+	case FieldDate:
+		return "date"
+	}
+	return "text"
+}
+
+// parse validates a value for the field and returns it in the canonical
+// form it is stored and compared in: numbers without exponent or trailing
+// zeros, dates as YYYY-MM-DD, enum values as spelled in Options and
+// booleans as "true" or "false".
+func (f AssetField) parse(v string) (string, error) {
+	v = strings.TrimSpace(v)
+	switch f.Kind {
+	case FieldNumber:
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil || math.IsInf(n, 0) || math.IsNaN(n) {
+			return "", fmt.Errorf("%w: %s must be a number, got %q", errInvalidFieldValue, f.Label, v)
+		}
+		v = strconv.FormatFloat(n, 'f', -1, 64)
+	case FieldDate:
+		t, err := time.Parse(dateLayout, v)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s must be a date in YYYY-MM-DD format, got %q", errInvalidFieldValue, f.Label, v)
+		}
+		return t.Format(dateLayout), nil
+	case FieldEnum:
+		for _, o := range f.Options {
+			if strings.EqualFold(o, v) {
+				return o, nil
+			}
+		}
+		return "", fmt.Errorf("%w: %s must be one of %s, got %q", errInvalidFieldValue, f.Label, strings.Join(f.Options, ", "), v)
+	case FieldBoolean:
+		switch strings.ToLower(v) {
+		case "true", "yes", "y", "1", "on":
+			return "true", nil
+		case "false", "no", "n", "0", "off":
+			return "false", nil
+		}
+		return "", fmt.Errorf("%w: %s must be yes or no, got %q", errInvalidFieldValue, f.Label, v)
+	}
+	if len(v) > fieldValueMaxLen {
+		return "", fmt.Errorf("%w: %s must be at most %d characters", errInvalidFieldValue, f.Label, fieldValueMaxLen)
+	}
+	return v, nil
+}
+
+// AssetType is a managed asset category, such as Laptop or Monitor, with
+// the custom fields its assets have.
+type AssetType struct {
+	ID     int
+	Name   string
+	Fields []AssetField
+	// Assets is the number of assets of the type.
+	Assets int
+}
+
+// field looks a field of the type up by name.
+func (t AssetType) field(name string) (AssetField, bool) {
+	for _, f := range t.Fields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return AssetField{}, false
+}
+
+// AssetFieldValue is an asset's value for a custom field of its type, in
+// the canonical form returned by AssetField.parse.
+type AssetFieldValue struct {
+	Name  string
+	Label string
+	Kind  FieldKind
+	Value string
+}
+
+// Display returns the value as shown on the pages.
+func (v AssetFieldValue) Display() string {
+	switch v.Kind {
+	case FieldBoolean:
+		if v.Value == "true" {
+			return "Yes"
+		}
+		return "No"
+	case FieldDate:
+		if t, err := time.Parse(dateLayout, v.Value); err == nil {
+			return t.Format("01/02/2006")
+		}
+	}
+	return v.Value
+}
+
+// Field returns the asset's value for the named custom field, or "" if it
+// has none.
+func (a Asset) Field(name string) string {
+	for _, v := range a.Fields {
+		if v.Name == name {
+			return v.Value
+		}
+	}
+	return ""
+}
+
+// errInvalidAssetType reports an asset type or custom field definition
+// that is not valid, or an asset type that does not exist.
+var errInvalidAssetType = errors.New("invalid asset type")
+
+// errInvalidFieldValue reports a custom field value the asset's type does
+// not accept.
+var errInvalidFieldValue = errors.New("invalid custom field value")
+
+// errInvalidCondition reports a custom field condition that cannot be
+// applied, see parseCondition.
+var errInvalidCondition = errors.New("invalid condition")
+
+// errAssetTypeInUse reports an asset type that cannot be deleted because
+// assets are of that type.
+var errAssetTypeInUse = errors.New("asset type in use")
+
+// errFieldValuesConflict reports a change to a custom field that values
+// assets already have would not satisfy.
+var errFieldValuesConflict = errors.New("field change conflicts with stored values")
+
+// findAssetType looks a type up by id or, when id is zero, by name,
+// ignoring case.
+func findAssetType(types []AssetType, id int, name string) (*AssetType, error) {
+	for i := range types {
+		if (id != 0 && types[i].ID == id) || (id == 0 && strings.EqualFold(types[i].Name, strings.TrimSpace(name))) {
+			return &types[i], nil
+		}
+	}
+	if id != 0 {
+		return nil, fmt.Errorf("%w: asset type %d does not exist", errInvalidAssetType, id)
+	}
+	return nil, fmt.Errorf("%w: unknown asset type %q", errInvalidAssetType, name)
+}
+
+// resolveAssetType sets the asset's type from its TypeID or, when that is
+// zero, from AssetType, a type name matched ignoring case. AssetType is
+// then set to the type's name and Fields to the values value returns for
+// the type's fields, checked and in canonical form; fields the type does
+// not define are ignored. An asset with neither has no type and no custom
+// fields. Errors wrap errInvalidAssetType or errInvalidFieldValue.
+func (s *server) resolveAssetType(ctx context.Context, a *Asset, value func(name string) string) error {
+	a.Fields = nil
+	if a.TypeID == 0 && strings.TrimSpace(a.AssetType) == "" {
+		a.AssetType = ""
+		return nil
+	}
+	types, err := s.assetTypes.List(ctx)
+	if err != nil {
+		return err
+	}
+	t, err := findAssetType(types, a.TypeID, a.AssetType)
+	if err != nil {
+		return err
+	}
+	a.TypeID, a.AssetType = t.ID, t.Name
+	for _, f := range t.Fields {
+		v := strings.TrimSpace(value(f.Name))
+		if v == "" {
+			if f.Required {
+				return fmt.Errorf("%w: %s is required for a %s", errInvalidFieldValue, f.Label, t.Name)
+			}
+			continue
+		}
+		if v, err = f.parse(v); err != nil {
+			return err
+		}
+		a.Fields = append(a.Fields, AssetFieldValue{Name: f.Name, Label: f.Label, Kind: f.Kind, Value: v})
+	}
+	return nil
+}
+
+// formFieldValues returns the custom field values posted with the asset
+// form, whose inputs are named "field-<name>".
+func formFieldValues(r *http.Request) func(name string) string {
+	return func(name string) string { return r.FormValue("field-" + name) }
+}
+
+// fieldCondition is a condition on a custom field, such as "ram_gb>=16",
+// used to query assets in reports and the API.
+type fieldCondition struct {
+	Field AssetField
+	Op    string
+	// Value is in canonical form; an empty value matches assets without
+	// one with = and those with one with !=.
+	Value string
+}
+
+// fieldOperators are the comparisons a condition can make, two-character
+// ones first so that ">=" is not read as ">".
+var fieldOperators = []string{"!=", ">=", "<=", "=", ">", "<"}
+
+// parseCondition reads a condition of the form <name><op><value> on one
+// of the type's fields. Numbers and dates can be compared with any of
+// fieldOperators; other fields only with = and !=, ignoring case.
+func (t AssetType) parseCondition(expr string) (fieldCondition, error) {
+	var c fieldCondition
+	i, op := -1, ""
+	for j := 0; j < len(expr) && i < 0; j++ {
+		for _, o := range fieldOperators {
+			if strings.HasPrefix(expr[j:], o) {
+				i, op = j, o
+				break
+			}
+		}
+	}
+	if i < 0 {
+		return c, fmt.Errorf("%w: %q needs one of %s", errInvalidCondition, expr, strings.Join(fieldOperators, " "))
+	}
+	name := strings.ToLower(strings.TrimSpace(expr[:i]))
+	f, ok := t.field(name)
+	if !ok {
+		return c, fmt.Errorf("%w: %s has no custom field %q", errInvalidCondition, t.Name, name)
+	}
+	c.Field, c.Op = f, op
+	v := strings.TrimSpace(expr[i+len(op):])
+	ordered := op != "=" && op != "!="
+	if ordered && f.Kind != FieldNumber && f.Kind != FieldDate {
+		return c, fmt.Errorf("%w: %s can only be compared with = and !=", errInvalidCondition, f.Label)
+	}
+	if v == "" {
+		if ordered {
+			return c, fmt.Errorf("%w: %q needs a value", errInvalidCondition, expr)
+		}
+		return c, nil
+	}
+	var err error
+	c.Value, err = f.parse(v)
+	return c, err
+}
+
+// match reports whether the asset meets the condition. The SQL repository
+// applies conditions in the query instead, see conditionSQL.
+func (c fieldCondition) match(a Asset) bool {
+	got := a.Field(c.Field.Name)
+	if c.Value == "" {
+		return (got == "") == (c.Op == "=")
+	}
+	if got == "" {
+		return false
+	}
+	cmp := 0
+	switch c.Field.Kind {
+	case FieldNumber:
+		x, _ := strconv.ParseFloat(got, 64)
+		y, _ := strconv.ParseFloat(c.Value, 64)
+		if x < y {
+			cmp = -1
+		} else if x > y {
+			cmp = 1
+		}
+	case FieldDate:
+		cmp = strings.Compare(got, c.Value)
+	default:
+		if !strings.EqualFold(got, c.Value) {
+			cmp = 1
+		}
+	}
+	switch c.Op {
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return cmp == 0
+}
+
+// assetsOfType returns the type named name and those of its assets that
+// meet every condition in where, see parseCondition, by id. Unknown types
+// are reported wrapping errInvalidAssetType and conditions that cannot be
+// applied wrapping errInvalidCondition or errInvalidFieldValue.
+func (s *server) assetsOfType(ctx context.Context, name string, where []string) (*AssetType, []Asset, error) {
+	types, err := s.assetTypes.List(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	t, err := findAssetType(types, 0, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	var conditions []fieldCondition
+	for _, expr := range where {
+		c, err := t.parseCondition(expr)
+		if err != nil {
+			return nil, nil, err
+		}
+		conditions = append(conditions, c)
+	}
+	assets, err := s.assets.ListOfType(ctx, t.ID, conditions)
+	if err != nil {
+		return nil, nil, err
+	}
+	return t, assets, nil
+}
+
+// validateAssetField trims and checks a field definition on its own:
+// names are lowercase keys, enum fields need distinct options and other
+// kinds have none.
+func validateAssetField(f *AssetField) error {
+	f.Name = strings.ToLower(strings.TrimSpace(f.Name))
+	f.Label = strings.TrimSpace(f.Label)
+	if f.Label == "" {
+		return fmt.Errorf("%w: a field label is required", errInvalidAssetType)
+	}
+	if f.Name == "" {
+		f.Name = strings.Trim(fieldNameSeparators.ReplaceAllString(strings.ToLower(f.Label), "_"), "_")
+	}
+	if !fieldNamePattern.MatchString(f.Name) {
+		return fmt.Errorf("%w: field name %q must start with a letter and have only lowercase letters, digits and underscores", errInvalidAssetType, f.Name)
+	}
+	if _, err := parseFieldKind(string(f.Kind)); err != nil {
+		return fmt.Errorf("%w: %v", errInvalidAssetType, err)
+	}
+	if f.Kind != FieldEnum {
+		f.Options = nil
+		return nil
+	}
+	var options []string
+	for _, o := range f.Options {
+		o = strings.TrimSpace(o)
+		if o == "" {
+			continue
+		}
+		if len(o) > fieldValueMaxLen {
+			return fmt.Errorf("%w: option %q is longer than %d characters", errInvalidAssetType, o, fieldValueMaxLen)
+		}
+		for _, other := range options {
+			if strings.EqualFold(other, o) {
+				return fmt.Errorf("%w: option %q is listed twice", errInvalidAssetType, o)
+			}
+		}
+		options = append(options, o)
+	}
+	if len(options) == 0 {
+		return fmt.Errorf("%w: choice field %s needs at least one option", errInvalidAssetType, f.Label)
+	}
+	f.Options = options
+	return nil
+}
+
+// revalidate checks the values stored for the field, one per asset of its
+// type and "" for assets without one, against the field's new definition.
+// It returns the values the change respells, such as an enum option whose
+// case changed, mapped to their new canonical form, or an error wrapping
+// errFieldValuesConflict if a value would no longer be accepted or a newly
+// required field is missing.
+func (f AssetField) revalidate(values []string) (map[string]string, error) {
+	missing := 0
+	var invalid []string
+	seen := make(map[string]bool)
+	respelled := make(map[string]string)
+	for _, v := range values {
+		if v == "" {
+			if f.Required {
+				missing++
+			}
+			continue
+		}
+		canonical, err := f.parse(v)
+		if err != nil {
+			if !seen[v] {
+				seen[v] = true
+				invalid = append(invalid, strconv.Quote(v))
+			}
+		} else if canonical != v {
+			respelled[v] = canonical
+		}
+	}
+	if len(invalid) > 0 {
+		return nil, fmt.Errorf("%w: assets have %s values %s that would no longer be valid", errFieldValuesConflict, f.Label, strings.Join(invalid, ", "))
+	}
+	if missing > 0 {
+		return nil, fmt.Errorf("%w: %s cannot be required while %d assets have no value for it", errFieldValuesConflict, f.Label, missing)
+	}
+	return respelled, nil
+}
+
+// checkAssetType verifies that no other type than id has the name,
+// ignoring case.
+func (s *server) checkAssetType(ctx context.Context, id int, name string) error {
+	types, err := s.assetTypes.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, t := range types {
+		if t.ID != id && strings.EqualFold(t.Name, name) {
+			return fmt.Errorf("%w: %s already exists", errInvalidAssetType, t.Name)
+		}
+	}
+	return nil
+}
+
+// checkAssetField verifies that a field fits its type: no other field of
+// the type has the same name and, once defined, the kind is kept because
+// stored values depend on it. id is zero for a new field.
+func checkAssetField(t *AssetType, id int, f AssetField) error {
+	for _, other := range t.Fields {
+		if id != 0 && other.ID == id {
+			if other.Kind != f.Kind {
+				return fmt.Errorf("%w: the kind of field %s cannot be changed", errInvalidAssetType, other.Label)
+			}
+			continue
+		}
+		if other.Name == f.Name {
+			return fmt.Errorf("%w: %s already has a field named %q", errInvalidAssetType, t.Name, f.Name)
+		}
+	}
+	return nil
+}
+
+// parseAssetTypeName reads the asset type form's name field.
+func parseAssetTypeName(r *http.Request) (string, error) {
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		return "", errors.New("an asset type name is required")
+	}
+	return name, nil
+}
+
+// parseAssetFieldForm reads and validates the custom field form fields.
+// Enum options are given one per line. The edit form has no kind field;
+// kind is the field's existing kind then, and "" for new fields.
+func parseAssetFieldForm(r *http.Request, kind FieldKind) (AssetField, error) {
+	if kind == "" {
+		kind = FieldKind(r.FormValue("kind"))
+	}
+	f := AssetField{
+		Name:     r.FormValue("name"),
+		Label:    r.FormValue("label"),
+		Kind:     kind,
+		Options:  strings.Split(r.FormValue("options"), "\n"),
+		Required: r.FormValue("required") != "",
+	}
+	return f, validateAssetField(&f)
+}
+
+// assetTypesHandler handles the asset types page and new type submissions.
+func (s *server) assetTypesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		name, err := parseAssetTypeName(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.checkAssetType(r.Context(), 0, name); errors.Is(err, errInvalidAssetType) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if err != nil {
+			log.Printf("Error checking asset type: %v\n", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		id, err := s.assetTypes.Create(r.Context(), AssetType{Name: name})
+		if errors.Is(err, errInvalidAssetType) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if err != nil {
+			log.Printf("Error inserting asset type: %v\n", err)
+			http.Error(w, "Error saving asset type", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("/asset-types/%d", id), http.StatusSeeOther)
+		return
+	}
+
+	data, err := s.getPageData(r.Context())
+	if err != nil {
+		log.Printf("Error fetching page data: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	renderTemplate(w, r, data)
+}
+
+// assetTypeHandler shows an asset type with its custom fields and assets.
+func (s *server) assetTypeHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	data, err := s.getPageData(r.Context())
+	if err != nil {
+		log.Printf("Error fetching page data: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	data.AssetType, err = findAssetType(data.AssetTypes, id, "")
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	for _, a := range data.Assets {
+		if a.TypeID == id {
+			data.TypeAssets = append(data.TypeAssets, a)
+		}
+	}
+	renderTemplate(w, r, data)
+}
+
+// updateAssetTypeHandler renames an asset type.
+func (s *server) updateAssetTypeHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	name, err := parseAssetTypeName(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.checkAssetType(r.Context(), id, name); errors.Is(err, errInvalidAssetType) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Printf("Error checking asset type: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = s.assetTypes.Update(r.Context(), id, AssetType{Name: name})
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if errors.Is(err, errInvalidAssetType) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Printf("Error updating asset type: %v\n", err)
+		http.Error(w, "Error saving asset type", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/asset-types/%d", id), http.StatusSeeOther)
+}
+
+// deleteAssetTypeHandler removes an asset type no asset is of.
+func (s *server) deleteAssetTypeHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	err = s.assetTypes.Delete(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if errors.Is(err, errAssetTypeInUse) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Printf("Error deleting asset type: %v\n", err)
+		http.Error(w, "Error deleting asset type", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/asset-types", http.StatusSeeOther)
+}
+
+// assetTypeField reads the {id} and {field} route variables, returning the
+// type and the field, which is nil when the route has no {field}. It
+// answers the request with 404 Not Found and returns false if either does
+// not exist.
+func (s *server) assetTypeField(w http.ResponseWriter, r *http.Request) (*AssetType, *AssetField, bool) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return nil, nil, false
+	}
+	t, err := s.assetTypes.Get(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return nil, nil, false
+	} else if err != nil {
+		log.Printf("Error fetching asset type: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	v, ok := mux.Vars(r)["field"]
+	if !ok {
+		return t, nil, true
+	}
+	fieldID, _ := strconv.Atoi(v)
+	for i := range t.Fields {
+		if t.Fields[i].ID == fieldID {
+			return t, &t.Fields[i], true
+		}
+	}
+	http.NotFound(w, r)
+	return nil, nil, false
+}
+
+// createAssetFieldHandler adds a custom field to an asset type.
+func (s *server) createAssetFieldHandler(w http.ResponseWriter, r *http.Request) {
+	t, _, ok := s.assetTypeField(w, r)
+	if !ok {
+		return
+	}
+	f, err := parseAssetFieldForm(r, "")
+	if err == nil {
+		err = checkAssetField(t, 0, f)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.TypeID = t.ID
+	if _, err := s.assetTypes.CreateField(r.Context(), f); err != nil {
+		log.Printf("Error inserting asset field: %v\n", err)
+		http.Error(w, "Error saving field", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/asset-types/%d", t.ID), http.StatusSeeOther)
+}
+
+// updateAssetFieldHandler saves a custom field's edit form. Changes that
+// values assets already have would not satisfy are rejected with 409
+// Conflict.
+func (s *server) updateAssetFieldHandler(w http.ResponseWriter, r *http.Request) {
+	t, field, ok := s.assetTypeField(w, r)
+	if !ok {
+		return
+	}
+	f, err := parseAssetFieldForm(r, field.Kind)
+	if err == nil {
+		err = checkAssetField(t, field.ID, f)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.TypeID = t.ID
+	err = s.assetTypes.UpdateField(r.Context(), field.ID, f)
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if errors.Is(err, errFieldValuesConflict) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	} else if err != nil {
+		log.Printf("Error updating asset field: %v\n", err)
+		http.Error(w, "Error saving field", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/asset-types/%d", t.ID), http.StatusSeeOther)
+}
+
+// deleteAssetFieldHandler removes a custom field and every asset's value
+// for it.
+func (s *server) deleteAssetFieldHandler(w http.ResponseWriter, r *http.Request) {
+	t, field, ok := s.assetTypeField(w, r)
+	if !ok {
+		return
+	}
+	err := s.assetTypes.DeleteField(r.Context(), t.ID, field.ID)
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		log.Printf("Error deleting asset field: %v\n", err)
+		http.Error(w, "Error deleting field", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/asset-types/%d", t.ID), http.StatusSeeOther)
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// testHardwareType has a field of every kind.
+var testHardwareType = AssetType{
+	Name: "Hardware",
+	Fields: []AssetField{
+		{Name: "ram_gb", Label: "RAM (GB)", Kind: FieldNumber},
+		{Name: "purchased", Label: "Purchased", Kind: FieldDate},
+		{Name: "os", Label: "OS", Kind: FieldEnum, Options: []string{"Windows", "macOS", "Linux"}},
+		{Name: "encrypted", Label: "Encrypted", Kind: FieldBoolean},
+		{Name: "owner", Label: "Owner", Kind: FieldText},
+	},
+}
+
+func TestParseCondition(t *testing.T) {
+	tests := []struct {
+		expr  string
+		field string
+		op    string
+		value string
+		err   error
+	}{
+		{expr: "ram_gb>=16", field: "ram_gb", op: ">=", value: "16"},
+		{expr: "RAM_GB < 8.50", field: "ram_gb", op: "<", value: "8.5"},
+		{expr: "ram_gb=lots", err: errInvalidFieldValue},
+		{expr: "purchased>2024-01-01", field: "purchased", op: ">", value: "2024-01-01"},
+		{expr: "purchased<=01/02/2024", err: errInvalidFieldValue},
+		{expr: "os=linux", field: "os", op: "=", value: "Linux"},
+		{expr: "os!=BeOS", err: errInvalidFieldValue},
+		{expr: "os>Linux", err: errInvalidCondition},
+		{expr: "encrypted=yes", field: "encrypted", op: "=", value: "true"},
+		{expr: "encrypted!=0", field: "encrypted", op: "!=", value: "false"},
+		{expr: "owner=Ada Lovelace", field: "owner", op: "=", value: "Ada Lovelace"},
+		{expr: "owner<Ada", err: errInvalidCondition},
+		{expr: "owner=", field: "owner", op: "=", value: ""},
+		{expr: "ram_gb>", err: errInvalidCondition},
+		{expr: "colour=red", err: errInvalidCondition},
+		{expr: "ram_gb", err: errInvalidCondition},
+	}
+	for _, tt := range tests {
+		c, err := testHardwareType.parseCondition(tt.expr)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("parseCondition(%q) error = %v, want %v", tt.expr, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseCondition(%q): %v", tt.expr, err)
+			continue
+		}
+		if c.Field.Name != tt.field || c.Op != tt.op || c.Value != tt.value {
+			t.Errorf("parseCondition(%q) = %s %s %q, want %s %s %q", tt.expr, c.Field.Name, c.Op, c.Value, tt.field, tt.op, tt.value)
+		}
+	}
+}
+
+// testHardwareAssets are assets of testHardwareType, by name.
+var testHardwareAssets = []Asset{
+	{Name: "big", Fields: []AssetFieldValue{
+		{Name: "ram_gb", Value: "64"}, {Name: "purchased", Value: "2024-06-01"}, {Name: "os", Value: "Linux"},
+		{Name: "encrypted", Value: "true"}, {Name: "owner", Value: "Ada Lovelace"},
+	}},
+	{Name: "small", Fields: []AssetFieldValue{
+		{Name: "ram_gb", Value: "8"}, {Name: "purchased", Value: "2021-02-15"}, {Name: "os", Value: "Windows"},
+		{Name: "encrypted", Value: "false"},
+	}},
+	{Name: "bare"},
+}
+
+// testConditionMatches maps conditions to the testHardwareAssets meeting
+// them.
+var testConditionMatches = []struct {
+	expr string
+	want []string
+}{
+	{"ram_gb>16", []string{"big"}},
+	{"ram_gb<=8", []string{"small"}},
+	{"ram_gb=64.0", []string{"big"}},
+	{"ram_gb!=64", []string{"small"}},
+	{"ram_gb>9", []string{"big"}},
+	{"ram_gb=", []string{"bare"}},
+	{"purchased<2022-01-01", []string{"small"}},
+	{"purchased>=2021-02-15", []string{"big", "small"}},
+	{"os=windows", []string{"small"}},
+	{"os!=Windows", []string{"big"}},
+	{"encrypted=no", []string{"small"}},
+	{"encrypted!=", []string{"big", "small"}},
+	{"owner=ada lovelace", []string{"big"}},
+	{"owner!=Grace", []string{"big"}},
+	{"owner=", []string{"small", "bare"}},
+}
+
+func TestConditionMatch(t *testing.T) {
+	for _, tt := range testConditionMatches {
+		c, err := testHardwareType.parseCondition(tt.expr)
+		if err != nil {
+			t.Fatalf("parseCondition(%q): %v", tt.expr, err)
+		}
+		var got []string
+		for _, a := range testHardwareAssets {
+			if c.match(a) {
+				got = append(got, a.Name)
+			}
+		}
+		if !sameNames(got, tt.want) {
+			t.Errorf("%s matched %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+// TestListOfTypeSQL checks that the database applies conditions as match
+// does.
+func TestListOfTypeSQL(t *testing.T) {
+	s := newSQLTestServer(t, nil)
+	ctx := context.Background()
+
+	typeID, err := s.assetTypes.Create(ctx, testHardwareType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherID, err := s.assetTypes.Create(ctx, AssetType{Name: "Other", Fields: []AssetField{{Name: "ram_gb", Label: "RAM", Kind: FieldText}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range testHardwareAssets {
+		a.TypeID, a.State = typeID, AssetDeployed
+		if _, err := s.assets.Create(ctx, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Another type's values are not numbers and must not be compared.
+	other := Asset{Name: "other", TypeID: otherID, State: AssetDeployed, Fields: []AssetFieldValue{{Name: "ram_gb", Value: "lots"}}}
+	if _, err := s.assets.Create(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range testConditionMatches {
+		_, assets, err := s.assetsOfType(ctx, "hardware", []string{tt.expr})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.expr, err)
+		}
+		var got []string
+		for _, a := range assets {
+			got = append(got, a.Name)
+		}
+		if !sameNames(got, tt.want) {
+			t.Errorf("%s returned %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+
+	_, assets, err := s.assetsOfType(ctx, "Hardware", []string{"ram_gb>=8", "os=linux"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(assets) != 1 || assets[0].Name != "big" || assets[0].Field("owner") != "Ada Lovelace" {
+		t.Errorf("two conditions returned %+v, want big with its fields", assets)
+	}
+}
+
+// sameNames reports whether two lists hold the same names in the same
+// order.
+func sameNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestUpdateFieldRevalidatesValues(t *testing.T) {
+	for name, s := range map[string]func(t *testing.T) *server{
+		"sql":    func(t *testing.T) *server { return newSQLTestServer(t, nil) },
+		"memory": func(t *testing.T) *server { return newMemTestServer() },
+	} {
+		t.Run(name, func(t *testing.T) { testUpdateFieldRevalidates(t, s(t)) })
+	}
+}
+
+func testUpdateFieldRevalidates(t *testing.T, s *server) {
+	ctx := context.Background()
+	typeID, err := s.assetTypes.Create(ctx, testHardwareType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	typ, err := s.assetTypes.Get(ctx, typeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	osField, _ := typ.field("os")
+	assets := []Asset{
+		{Name: "laptop", TypeID: typeID, State: AssetDeployed, Fields: []AssetFieldValue{{Name: "os", Value: "Linux"}}},
+		{Name: "spare", TypeID: typeID, State: AssetInStock},
+	}
+	for _, a := range assets {
+		if _, err := s.assets.Create(ctx, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, change := range []struct {
+		name     string
+		options  []string
+		required bool
+	}{
+		{"dropping an option in use", []string{"Windows", "macOS"}, false},
+		{"requiring a field an asset lacks", osField.Options, true},
+	} {
+		f := osField
+		f.Options, f.Required = change.options, change.required
+		if err := s.assetTypes.UpdateField(ctx, osField.ID, f); !errors.Is(err, errFieldValuesConflict) {
+			t.Errorf("%s: err = %v, want errFieldValuesConflict", change.name, err)
+		}
+	}
+	if typ, err = s.assetTypes.Get(ctx, typeID); err != nil {
+		t.Fatal(err)
+	}
+	if f, _ := typ.field("os"); len(f.Options) != 3 || f.Required {
+		t.Errorf("a rejected change was kept: %+v", f)
+	}
+
+	// Respelling an option respells the values stored with it.
+	f := osField
+	f.Options = []string{"Windows", "macOS", "linux"}
+	if err := s.assetTypes.UpdateField(ctx, osField.ID, f); err != nil {
+		t.Fatal(err)
+	}
+	_, found, err := s.assetsOfType(ctx, "Hardware", []string{"os=linux"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].Field("os") != "linux" {
+		t.Errorf("after respelling the option: %+v, want laptop with os linux", found)
+	}
+}
+
+func TestAssetTypeNamesUniqueIgnoringCase(t *testing.T) {
+	for name, s := range map[string]func(t *testing.T) *server{
+		"sql":    func(t *testing.T) *server { return newSQLTestServer(t, nil) },
+		"memory": func(t *testing.T) *server { return newMemTestServer() },
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := s(t)
+			ctx := context.Background()
+			if _, err := s.assetTypes.Create(ctx, AssetType{Name: "Laptop"}); err != nil {
+				t.Fatal(err)
+			}
+			// The handlers check first, but a concurrent request can slip
+			// past the check; the store itself must refuse the name.
+			if _, err := s.assetTypes.Create(ctx, AssetType{Name: "LAPTOP"}); !errors.Is(err, errInvalidAssetType) {
+				t.Errorf("Create of a name differing in case: err = %v, want errInvalidAssetType", err)
+			}
+			id, err := s.assetTypes.Create(ctx, AssetType{Name: "Monitor"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.assetTypes.Update(ctx, id, AssetType{Name: "laptop"}); !errors.Is(err, errInvalidAssetType) {
+				t.Errorf("rename to a name differing in case: err = %v, want errInvalidAssetType", err)
+			}
+			if err := s.assetTypes.Update(ctx, id, AssetType{Name: "MONITOR"}); err != nil {
+				t.Errorf("recasing a type's own name: %v", err)
+			}
+		})
+	}
+}
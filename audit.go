@@ -0,0 +1,1398 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Audited entity types.
+const (
+	AuditAsset             = "asset"
+	AuditLicense           = "license"
+	AuditLicenseAssignment = "license_assignment"
+	AuditSoftware          = "software"
+	AuditRisk              = "risk"
+	AuditFOIRequest        = "foi_request"
+	AuditSavedReport       = "saved_report"
+	AuditReportRun         = "report_run"
+	AuditWebhook           = "webhook"
+	AuditUser              = "user"
+	AuditPerson            = "person"
+	AuditDepartment        = "department"
+	AuditCheckout          = "checkout"
+	AuditLocation          = "location"
+	AuditAssetType         = "asset_type"
+)
+
+// auditEntities lists the entity types offered by the audit log filter.
+var auditEntities = []string{
+	AuditAsset, AuditLicense, AuditLicenseAssignment, AuditSoftware, AuditRisk,
+	AuditFOIRequest, AuditSavedReport, AuditReportRun, AuditWebhook, AuditUser,
+	AuditPerson, AuditDepartment, AuditCheckout, AuditLocation, AuditAssetType,
+}
+
+// Audited actions besides the workflow specific ones such as transitions.
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// auditSystemActor is recorded for changes made outside a user's request,
+// such as seeding and command line imports.
+const auditSystemActor = "system"
+
+const (
+	// auditPageSize is how many entries the audit log page shows at once.
+	auditPageSize = 50
+	// auditAPIMaxLimit caps the limit accepted by the audit API.
+	auditAPIMaxLimit = 500
+)
+
+// AuditEntry is one change in the append-only audit log. Each entry's hash
+// covers its contents and the hash of the entry before it, so editing or
+// removing an entry breaks the chain from that point on.
+type AuditEntry struct {
+	ID         int
+	OccurredAt time.Time
+	Actor      string
+	Entity     string
+	EntityID   int
+	Action     string
+	// Before and After are JSON snapshots of the entity. Before is empty
+	// for creations and After for deletions.
+	Before   string
+	After    string
+	PrevHash string
+	Hash     string
+}
+
+// computeHash returns the hex SHA-256 of the entry chained to PrevHash.
+// Fields are separated by NUL bytes so that values cannot run together.
+func (e AuditEntry) computeHash() string {
+	h := sha256.New()
+	for _, field := range []string{
+		e.PrevHash,
+		e.OccurredAt.UTC().Format(time.RFC3339),
+		e.Actor,
+		e.Entity,
+		strconv.Itoa(e.EntityID),
+		e.Action,
+		e.Before,
+		e.After,
+	} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// AuditChange is one field that differs between an entry's snapshots.
+type AuditChange struct {
+	Field  string
+	Before string
+	After  string
+}
+
+// Changes lists the fields that differ between the before and after
+// snapshots, in field order. Snapshots that are not JSON objects are
+// returned whole as a single change.
+func (e AuditEntry) Changes() []AuditChange {
+	var before, after map[string]interface{}
+	if (e.Before != "" && json.Unmarshal([]byte(e.Before), &before) != nil) ||
+		(e.After != "" && json.Unmarshal([]byte(e.After), &after) != nil) {
+		return []AuditChange{{Before: e.Before, After: e.After}}
+	}
+
+	fields := make(map[string]bool)
+	for k := range before {
+		fields[k] = true
+	}
+	for k := range after {
+		fields[k] = true
+	}
+	names := make([]string, 0, len(fields))
+	for k := range fields {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var changes []AuditChange
+	for _, k := range names {
+		b, a := auditValue(before[k]), auditValue(after[k])
+		if b != a {
+			changes = append(changes, AuditChange{Field: k, Before: b, After: a})
+		}
+	}
+	return changes
+}
+
+// auditValue renders a snapshot field for display.
+func auditValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+// Link returns the page showing the entity, or "" if it has none or was
+// deleted.
+func (e AuditEntry) Link() string {
+	if e.Action == AuditDelete {
+		return ""
+	}
+	switch e.Entity {
+	case AuditAsset:
+		return fmt.Sprintf("/assets/%d", e.EntityID)
+	case AuditLicense:
+		return fmt.Sprintf("/licenses/%d/edit", e.EntityID)
+	case AuditRisk:
+		return fmt.Sprintf("/risks/%d", e.EntityID)
+	case AuditFOIRequest:
+		return fmt.Sprintf("/foi/%d", e.EntityID)
+	case AuditReportRun:
+		return fmt.Sprintf("/reports/runs/%d", e.EntityID)
+	}
+	return ""
+}
+
+// AuditFilter narrows an audit log search. Zero fields match everything.
+type AuditFilter struct {
+	Entity   string
+	EntityID int
+	Actor    string
+	Action   string
+	// Query matches text anywhere in the before or after snapshots.
+	Query string
+	// From and To are dates; entries on both days are included.
+	From   time.Time
+	To     time.Time
+	Limit  int
+	Offset int
+}
+
+// parseAuditFilter reads a filter from the query string, returning an
+// error naming the first invalid parameter.
+func parseAuditFilter(q url.Values) (AuditFilter, error) {
+	f := AuditFilter{
+		Entity: strings.TrimSpace(q.Get("entity")),
+		Actor:  strings.TrimSpace(q.Get("actor")),
+		Action: strings.TrimSpace(q.Get("action")),
+		Query:  strings.TrimSpace(q.Get("q")),
+	}
+	var err error
+	for _, p := range []struct {
+		name string
+		dest *int
+	}{{"entity_id", &f.EntityID}, {"offset", &f.Offset}, {"limit", &f.Limit}} {
+		if v := q.Get(p.name); v != "" {
+			if *p.dest, err = strconv.Atoi(v); err != nil || *p.dest < 0 {
+				return f, fmt.Errorf("invalid %s %q", p.name, v)
+			}
+		}
+	}
+	for _, p := range []struct {
+		name string
+		dest *time.Time
+	}{{"from", &f.From}, {"to", &f.To}} {
+		if v := q.Get(p.name); v != "" {
+			if *p.dest, err = time.Parse(dateLayout, v); err != nil {
+				return f, fmt.Errorf("invalid %s date %q, expected YYYY-MM-DD", p.name, v)
+			}
+		}
+	}
+	return f, nil
+}
+
+// PrevURL returns the audit log page before this one.
+func (f AuditFilter) PrevURL() string {
+	if f.Offset <= f.Limit {
+		return f.pageURL(0)
+	}
+	return f.pageURL(f.Offset - f.Limit)
+}
+
+// NextURL returns the audit log page after this one.
+func (f AuditFilter) NextURL() string {
+	return f.pageURL(f.Offset + f.Limit)
+}
+
+// pageURL returns the audit log page for the filter at another offset.
+func (f AuditFilter) pageURL(offset int) string {
+	q := url.Values{}
+	for k, v := range map[string]string{"entity": f.Entity, "actor": f.Actor, "action": f.Action, "q": f.Query} {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	if f.EntityID != 0 {
+		q.Set("entity_id", strconv.Itoa(f.EntityID))
+	}
+	if !f.From.IsZero() {
+		q.Set("from", f.From.Format(dateLayout))
+	}
+	if !f.To.IsZero() {
+		q.Set("to", f.To.Format(dateLayout))
+	}
+	if offset > 0 {
+		q.Set("offset", strconv.Itoa(offset))
+	}
+	if len(q) == 0 {
+		return "/audit"
+	}
+	return "/audit?" + q.Encode()
+}
+
+// auditActor names who is making a change: the signed in user, or the
+// system outside of a request.
+func auditActor(ctx context.Context) string {
+	if u, ok := ctx.Value(userContextKey).(*User); ok && u != nil {
+		return u.Username
+	}
+	return auditSystemActor
+}
+
+// auditTrail records changes to the audit log.
+type auditTrail struct {
+	log AuditRepository
+	// tx runs each change in one transaction with the entries recording
+	// it, so that neither is kept without the other.
+	tx Transactor
+	// mu serialises the audited changes made by this process, so that the
+	// snapshots taken for one change never take in part of another.
+	mu sync.Mutex
+}
+
+// auditingKey marks a context inside auditTrail.atomic.
+type auditingKey struct{}
+
+// atomic runs fn in a transaction, holding the trail's lock. The changes
+// fn makes and the entries it records are committed together or not at
+// all. Calls nested inside fn join the transaction already running.
+func (t *auditTrail) atomic(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(auditingKey{}) != nil {
+		return fn(ctx)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.tx.InTx(context.WithValue(ctx, auditingKey{}, true), fn)
+}
+
+// record appends an entry for a change. before and after are marshalled to
+// JSON; pass nil for the side that does not exist. Call it inside atomic,
+// with the context that made the change, so the entry commits with it.
+func (t *auditTrail) record(ctx context.Context, entity string, id int, action string, before, after interface{}) error {
+	e := AuditEntry{
+		OccurredAt: time.Now().UTC().Truncate(time.Second),
+		Actor:      auditActor(ctx),
+		Entity:     entity,
+		EntityID:   id,
+		Action:     action,
+	}
+	for _, s := range []struct {
+		v    interface{}
+		dest *string
+	}{{before, &e.Before}, {after, &e.After}} {
+		if s.v == nil {
+			continue
+		}
+		b, err := json.Marshal(s.v)
+		if err != nil {
+			return fmt.Errorf("error encoding audit snapshot: %w", err)
+		}
+		*s.dest = string(b)
+	}
+	if err := t.log.Append(ctx, &e); err != nil {
+		return fmt.Errorf("error recording audit entry: %w", err)
+	}
+	return nil
+}
+
+// auditSnapshot returns the state of an entity as recorded in the trail.
+type auditSnapshot func(ctx context.Context) (interface{}, error)
+
+// sameSnapshot reports whether two snapshots record the same state.
+func sameSnapshot(a, b interface{}) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return string(x) == string(y)
+}
+
+// create records the creation of an entity: op stores it and returns its
+// id, and after snapshots it once stored.
+func (t *auditTrail) create(ctx context.Context, entity, action string, op func(ctx context.Context) (int, error), after func(ctx context.Context, id int) (interface{}, error)) (int, error) {
+	var id int
+	err := t.atomic(ctx, func(ctx context.Context) error {
+		var err error
+		if id, err = op(ctx); err != nil {
+			return err
+		}
+		snapshot, err := after(ctx, id)
+		if err != nil {
+			return err
+		}
+		return t.record(ctx, entity, id, action, nil, snapshot)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// change records an operation on an existing entity, taking snapshots
+// with get before and, unless it is a deletion, after op runs. Nothing is
+// recorded if op fails or leaves the entity unchanged. cascades snapshot
+// the other records op may change as a side effect, each of which gets an
+// entry of its own.
+func (t *auditTrail) change(ctx context.Context, entity string, id int, action string, get auditSnapshot, op func(ctx context.Context) error, cascades ...auditCascade) error {
+	return t.atomic(ctx, func(ctx context.Context) error {
+		before, err := get(ctx)
+		if err != nil {
+			return err
+		}
+		related := make([]map[int]interface{}, len(cascades))
+		for i, c := range cascades {
+			if related[i], err = c.list(ctx); err != nil {
+				return err
+			}
+		}
+		if err := op(ctx); err != nil {
+			return err
+		}
+		if action == AuditDelete {
+			err = t.record(ctx, entity, id, action, before, nil)
+		} else {
+			var after interface{}
+			if after, err = get(ctx); err == nil && !sameSnapshot(before, after) {
+				err = t.record(ctx, entity, id, action, before, after)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		for i, c := range cascades {
+			if err := t.cascaded(ctx, c, related[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// auditCascade is a set of records of one entity that a change to another
+// may alter, such as the seat assignments released by deleting an asset.
+type auditCascade struct {
+	entity string
+	// action is recorded for the records the change alters; those it
+	// removes are recorded as deletions.
+	action string
+	// list snapshots the records by id.
+	list func(ctx context.Context) (map[int]interface{}, error)
+}
+
+// cascaded records what a change did to a cascade's records, given their
+// snapshots from before it.
+func (t *auditTrail) cascaded(ctx context.Context, c auditCascade, before map[int]interface{}) error {
+	after, err := c.list(ctx)
+	if err != nil {
+		return err
+	}
+	ids := make([]int, 0, len(before))
+	for id := range before {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		a, ok := after[id]
+		switch {
+		case !ok:
+			err = t.record(ctx, c.entity, id, AuditDelete, before[id], nil)
+		case !sameSnapshot(before[id], a):
+			err = t.record(ctx, c.entity, id, c.action, before[id], a)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assignmentCascade covers the seat assignments returned by list.
+func assignmentCascade(list func(ctx context.Context) ([]LicenseAssignment, error)) auditCascade {
+	return auditCascade{entity: AuditLicenseAssignment, action: AuditUpdate, list: func(ctx context.Context) (map[int]interface{}, error) {
+		assignments, err := list(ctx)
+		if err != nil {
+			return nil, err
+		}
+		out := make(map[int]interface{}, len(assignments))
+		for _, a := range assignments {
+			out[a.ID] = toAPIAssignment(a)
+		}
+		return out, nil
+	}}
+}
+
+// custodyCascade covers the checkouts returned by list, recording changes
+// to them as action.
+func custodyCascade(action string, list func(ctx context.Context) ([]Checkout, error)) auditCascade {
+	return auditCascade{entity: AuditCheckout, action: action, list: func(ctx context.Context) (map[int]interface{}, error) {
+		checkouts, err := list(ctx)
+		if err != nil {
+			return nil, err
+		}
+		out := make(map[int]interface{}, len(checkouts))
+		for _, c := range checkouts {
+			out[c.ID] = toAPICheckout(c)
+		}
+		return out, nil
+	}}
+}
+
+// softwareCascade covers the installations returned by list.
+func softwareCascade(list func(ctx context.Context) ([]InstalledSoftware, error)) auditCascade {
+	return auditCascade{entity: AuditSoftware, action: AuditUpdate, list: func(ctx context.Context) (map[int]interface{}, error) {
+		installed, err := list(ctx)
+		if err != nil {
+			return nil, err
+		}
+		out := make(map[int]interface{}, len(installed))
+		for _, sw := range installed {
+			out[sw.ID] = toAPISoftware(sw)
+		}
+		return out, nil
+	}}
+}
+
+// riskCascade covers the risks linked reports true for.
+func riskCascade(risks RiskRepository, linked func(Risk) bool) auditCascade {
+	return auditCascade{entity: AuditRisk, action: AuditUpdate, list: func(ctx context.Context) (map[int]interface{}, error) {
+		all, err := risks.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		out := make(map[int]interface{})
+		for _, risk := range all {
+			if linked(risk) {
+				out[risk.ID] = toAPIRisk(risk)
+			}
+		}
+		return out, nil
+	}}
+}
+
+// foiCascade covers the FOI requests linked reports true for.
+func foiCascade(foi FOIRepository, linked func(FOIRequest) bool) auditCascade {
+	return auditCascade{entity: AuditFOIRequest, action: AuditUpdate, list: func(ctx context.Context) (map[int]interface{}, error) {
+		all, err := foi.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		out := make(map[int]interface{})
+		for _, f := range all {
+			if linked(f) {
+				out[f.ID] = toAPIFOIRequest(f)
+			}
+		}
+		return out, nil
+	}}
+}
+
+// assetCascade covers the assets keep reports true for.
+func assetCascade(assets AssetRepository, keep func(Asset) bool) auditCascade {
+	return auditCascade{entity: AuditAsset, action: AuditUpdate, list: func(ctx context.Context) (map[int]interface{}, error) {
+		all, err := assets.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		out := make(map[int]interface{})
+		for _, a := range all {
+			if keep(a) {
+				out[a.ID] = toAPIAsset(a)
+			}
+		}
+		return out, nil
+	}}
+}
+
+// personCascade covers the people keep reports true for.
+func personCascade(people PeopleRepository, keep func(Person) bool) auditCascade {
+	return auditCascade{entity: AuditPerson, action: AuditUpdate, list: func(ctx context.Context) (map[int]interface{}, error) {
+		all, err := people.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		out := make(map[int]interface{})
+		for _, p := range all {
+			if keep(p) {
+				out[p.ID] = toAPIPerson(p)
+			}
+		}
+		return out, nil
+	}}
+}
+
+// auditRepositories wraps the server's repositories in the decorators
+// that record their changes in trail. The decorators read the other,
+// undecorated repositories to record what a change cascades to.
+func (s *server) auditRepositories(trail *auditTrail) {
+	assets, licenses, software, people, risks, foi := s.assets, s.licenses, s.software, s.people, s.risks, s.foi
+	s.assets = auditedAssets{AssetRepository: assets, trail: trail,
+		licenses: licenses, software: software, people: people, risks: risks, foi: foi}
+	s.licenses = auditedLicenses{LicenseRepository: licenses, trail: trail, risks: risks, foi: foi}
+	s.software = auditedSoftware{software, trail}
+	s.risks = auditedRisks{risks, trail}
+	s.foi = auditedFOI{foi, trail}
+	s.reports = auditedReports{s.reports, trail}
+	s.webhooks = auditedWebhooks{s.webhooks, trail}
+	s.people = auditedPeople{PeopleRepository: people, trail: trail, licenses: licenses}
+	s.locations = auditedLocations{s.locations, trail}
+	s.assetTypes = auditedAssetTypes{AssetTypeRepository: s.assetTypes, trail: trail, assets: assets}
+	s.users = auditedUsers{s.users, trail}
+	s.auditLog, s.audit = trail.log, trail
+}
+
+// AuditVerification is the result of checking the audit log's hash chain.
+type AuditVerification struct {
+	Entries int
+	Intact  bool
+	// BrokenAt is the id of the first entry that fails the check, and
+	// Problem says why.
+	BrokenAt int
+	Problem  string
+	// Head is the hash of the latest entry. A copy kept elsewhere also
+	// reveals entries removed from the end of the log.
+	Head      string
+	CheckedAt time.Time
+}
+
+// verifyAuditChain recomputes every entry's hash in order, stopping at the
+// first entry that does not match or does not follow its predecessor.
+func verifyAuditChain(ctx context.Context, log AuditRepository) (AuditVerification, error) {
+	v := AuditVerification{Intact: true, CheckedAt: time.Now().UTC().Truncate(time.Second)}
+	err := log.Walk(ctx, func(e AuditEntry) error {
+		v.Entries++
+		if v.Intact {
+			switch {
+			case e.PrevHash != v.Head:
+				v.Intact, v.BrokenAt, v.Problem = false, e.ID, "entry does not follow the previous entry; entries may have been removed or reordered"
+			case e.Hash != e.computeHash():
+				v.Intact, v.BrokenAt, v.Problem = false, e.ID, "entry contents do not match its hash; the entry has been modified"
+			}
+		}
+		v.Head = e.Hash
+		return nil
+	})
+	return v, err
+}
+
+// auditedAssets records asset changes in the audit trail.
+type auditedAssets struct {
+	AssetRepository
+	trail *auditTrail
+	// Deleting an asset releases its seats, custody and software and
+	// unlinks it from risks and FOI requests; those changes are recorded
+	// through the repositories below that are set.
+	licenses LicenseRepository
+	software SoftwareRepository
+	people   PeopleRepository
+	risks    RiskRepository
+	foi      FOIRepository
+}
+
+func (r auditedAssets) snapshot(id int) auditSnapshot {
+	return func(ctx context.Context) (interface{}, error) {
+		a, err := r.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return toAPIAsset(*a), nil
+	}
+}
+
+// releases lists the records deleting asset id changes besides the asset.
+func (r auditedAssets) releases(id int) []auditCascade {
+	var cascades []auditCascade
+	if r.licenses != nil {
+		cascades = append(cascades, assignmentCascade(func(ctx context.Context) ([]LicenseAssignment, error) {
+			return r.licenses.AssetAssignments(ctx, id)
+		}))
+	}
+	if r.people != nil {
+		cascades = append(cascades, custodyCascade("check_in", func(ctx context.Context) ([]Checkout, error) {
+			return r.people.Custody(ctx, id)
+		}))
+	}
+	if r.software != nil {
+		cascades = append(cascades, softwareCascade(func(ctx context.Context) ([]InstalledSoftware, error) {
+			return r.software.List(ctx, id)
+		}))
+	}
+	if r.risks != nil {
+		cascades = append(cascades, riskCascade(r.risks, func(risk Risk) bool { return containsID(risk.AssetIDs, id) }))
+	}
+	if r.foi != nil {
+		cascades = append(cascades, foiCascade(r.foi, func(f FOIRequest) bool { return containsID(f.AssetIDs, id) }))
+	}
+	return cascades
+}
+
+func (r auditedAssets) Create(ctx context.Context, a Asset) (int, error) {
+	return r.trail.create(ctx, AuditAsset, AuditCreate, func(ctx context.Context) (int, error) {
+		return r.AssetRepository.Create(ctx, a)
+	}, func(ctx context.Context, id int) (interface{}, error) {
+		a.ID = id
+		return toAPIAsset(a), nil
+	})
+}
+
+func (r auditedAssets) CreateMany(ctx context.Context, assets []Asset) ([]int, error) {
+	var ids []int
+	err := r.trail.atomic(ctx, func(ctx context.Context) error {
+		var err error
+		if ids, err = r.AssetRepository.CreateMany(ctx, assets); err != nil {
+			return err
+		}
+		for i, a := range assets {
+			a.ID = ids[i]
+			if err := r.trail.record(ctx, AuditAsset, a.ID, AuditCreate, nil, toAPIAsset(a)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (r auditedAssets) Update(ctx context.Context, id int, a Asset) error {
+	return r.trail.change(ctx, AuditAsset, id, AuditUpdate, r.snapshot(id), func(ctx context.Context) error {
+		return r.AssetRepository.Update(ctx, id, a)
+	})
+}
+
+func (r auditedAssets) Delete(ctx context.Context, id int) error {
+	return r.trail.change(ctx, AuditAsset, id, AuditDelete, r.snapshot(id), func(ctx context.Context) error {
+		return r.AssetRepository.Delete(ctx, id)
+	}, r.releases(id)...)
+}
+
+func (r auditedAssets) Transition(ctx context.Context, id int, state AssetState, reason string) (*AssetStateChange, error) {
+	var change *AssetStateChange
+	err := r.trail.change(ctx, AuditAsset, id, "transition", r.snapshot(id), func(ctx context.Context) error {
+		var err error
+		change, err = r.AssetRepository.Transition(ctx, id, state, reason)
+		return err
+	})
+	return change, err
+}
+
+// auditedLicenses records license and seat assignment changes in the audit
+// trail.
+type auditedLicenses struct {
+	LicenseRepository
+	trail *auditTrail
+	// Deleting a license unlinks it from risks and FOI requests; those
+	// changes are recorded through the repositories below that are set.
+	risks RiskRepository
+	foi   FOIRepository
+}
+
+func (r auditedLicenses) snapshot(id int) auditSnapshot {
+	return func(ctx context.Context) (interface{}, error) {
+		l, err := r.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return toAPILicense(*l), nil
+	}
+}
+
+// releases lists the records deleting license id changes besides the
+// license.
+func (r auditedLicenses) releases(id int) []auditCascade {
+	cascades := []auditCascade{assignmentCascade(func(ctx context.Context) ([]LicenseAssignment, error) {
+		return r.Assignments(ctx, id)
+	})}
+	if r.risks != nil {
+		cascades = append(cascades, riskCascade(r.risks, func(risk Risk) bool { return containsID(risk.LicenseIDs, id) }))
+	}
+	if r.foi != nil {
+		cascades = append(cascades, foiCascade(r.foi, func(f FOIRequest) bool { return containsID(f.LicenseIDs, id) }))
+	}
+	return cascades
+}
+
+func (r auditedLicenses) Create(ctx context.Context, l License) (int, error) {
+	return r.trail.create(ctx, AuditLicense, AuditCreate, func(ctx context.Context) (int, error) {
+		return r.LicenseRepository.Create(ctx, l)
+	}, func(ctx context.Context, id int) (interface{}, error) {
+		l.ID = id
+		return toAPILicense(l), nil
+	})
+}
+
+func (r auditedLicenses) CreateMany(ctx context.Context, licenses []License) ([]int, error) {
+	var ids []int
+	err := r.trail.atomic(ctx, func(ctx context.Context) error {
+		var err error
+		if ids, err = r.LicenseRepository.CreateMany(ctx, licenses); err != nil {
+			return err
+		}
+		for i, l := range licenses {
+			l.ID = ids[i]
+			if err := r.trail.record(ctx, AuditLicense, l.ID, AuditCreate, nil, toAPILicense(l)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (r auditedLicenses) Update(ctx context.Context, id int, l License) error {
+	return r.trail.change(ctx, AuditLicense, id, AuditUpdate, r.snapshot(id), func(ctx context.Context) error {
+		return r.LicenseRepository.Update(ctx, id, l)
+	})
+}
+
+func (r auditedLicenses) Delete(ctx context.Context, id int) error {
+	return r.trail.change(ctx, AuditLicense, id, AuditDelete, r.snapshot(id), func(ctx context.Context) error {
+		return r.LicenseRepository.Delete(ctx, id)
+	}, r.releases(id)...)
+}
+
+func (r auditedLicenses) Assign(ctx context.Context, a LicenseAssignment) (int, error) {
+	return r.trail.create(ctx, AuditLicenseAssignment, AuditCreate, func(ctx context.Context) (int, error) {
+		return r.LicenseRepository.Assign(ctx, a)
+	}, func(ctx context.Context, id int) (interface{}, error) {
+		a.ID = id
+		return toAPIAssignment(a), nil
+	})
+}
+
+func (r auditedLicenses) Unassign(ctx context.Context, licenseID, assignmentID int) error {
+	get := func(ctx context.Context) (interface{}, error) {
+		assignments, err := r.Assignments(ctx, licenseID)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range assignments {
+			if a.ID == assignmentID {
+				return toAPIAssignment(a), nil
+			}
+		}
+		return nil, ErrNotFound
+	}
+	return r.trail.change(ctx, AuditLicenseAssignment, assignmentID, AuditDelete, get, func(ctx context.Context) error {
+		return r.LicenseRepository.Unassign(ctx, licenseID, assignmentID)
+	})
+}
+
+// auditedSoftware records software inventory changes in the audit trail.
+type auditedSoftware struct {
+	SoftwareRepository
+	trail *auditTrail
+}
+
+// auditSoftwareReport is the software an asset has reported by one source,
+// leaving out ids and sighting times that change on every report.
+type auditSoftwareReport struct {
+	Source   string              `json:"source"`
+	Software []auditSoftwareItem `json:"software"`
+}
+
+type auditSoftwareItem struct {
+	Product   string `json:"product"`
+	Publisher string `json:"publisher,omitempty"`
+	Version   string `json:"version,omitempty"`
+}
+
+func (r auditedSoftware) Add(ctx context.Context, sw InstalledSoftware) (int, error) {
+	return r.trail.create(ctx, AuditSoftware, AuditCreate, func(ctx context.Context) (int, error) {
+		return r.SoftwareRepository.Add(ctx, sw)
+	}, func(ctx context.Context, id int) (interface{}, error) {
+		sw.ID = id
+		return toAPISoftware(sw), nil
+	})
+}
+
+func (r auditedSoftware) Remove(ctx context.Context, assetID, id int) error {
+	get := func(ctx context.Context) (interface{}, error) {
+		installed, err := r.List(ctx, assetID)
+		if err != nil {
+			return nil, err
+		}
+		for _, sw := range installed {
+			if sw.ID == id {
+				return toAPISoftware(sw), nil
+			}
+		}
+		return nil, ErrNotFound
+	}
+	return r.trail.change(ctx, AuditSoftware, id, AuditDelete, get, func(ctx context.Context) error {
+		return r.SoftwareRepository.Remove(ctx, assetID, id)
+	})
+}
+
+// ReplaceSource is recorded against the asset, as one entry listing the
+// software reported before and after.
+func (r auditedSoftware) ReplaceSource(ctx context.Context, assetID int, source string, items []InstalledSoftware) error {
+	get := func(ctx context.Context) (interface{}, error) {
+		installed, err := r.List(ctx, assetID)
+		if err != nil {
+			return nil, err
+		}
+		out := auditSoftwareReport{Source: source, Software: []auditSoftwareItem{}}
+		for _, sw := range installed {
+			if sw.Source == source {
+				out.Software = append(out.Software, auditSoftwareItem{Product: sw.Product, Publisher: sw.Publisher, Version: sw.Version})
+			}
+		}
+		return out, nil
+	}
+	return r.trail.change(ctx, AuditAsset, assetID, "replace_software", get, func(ctx context.Context) error {
+		return r.SoftwareRepository.ReplaceSource(ctx, assetID, source, items)
+	})
+}
+
+// auditedRisks records risk register changes in the audit trail.
+type auditedRisks struct {
+	RiskRepository
+	trail *auditTrail
+}
+
+func (r auditedRisks) snapshot(id int) auditSnapshot {
+	return func(ctx context.Context) (interface{}, error) {
+		risk, err := r.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return toAPIRisk(*risk), nil
+	}
+}
+
+func (r auditedRisks) Create(ctx context.Context, risk Risk) (int, error) {
+	return r.trail.create(ctx, AuditRisk, AuditCreate, func(ctx context.Context) (int, error) {
+		return r.RiskRepository.Create(ctx, risk)
+	}, func(ctx context.Context, id int) (interface{}, error) {
+		return r.snapshot(id)(ctx)
+	})
+}
+
+func (r auditedRisks) Update(ctx context.Context, id int, risk Risk) error {
+	return r.trail.change(ctx, AuditRisk, id, AuditUpdate, r.snapshot(id), func(ctx context.Context) error {
+		return r.RiskRepository.Update(ctx, id, risk)
+	})
+}
+
+func (r auditedRisks) Delete(ctx context.Context, id int) error {
+	return r.trail.change(ctx, AuditRisk, id, AuditDelete, r.snapshot(id), func(ctx context.Context) error {
+		return r.RiskRepository.Delete(ctx, id)
+	})
+}
+
+func (r auditedRisks) Transition(ctx context.Context, id int, status RiskStatus) error {
+	return r.trail.change(ctx, AuditRisk, id, "transition", r.snapshot(id), func(ctx context.Context) error {
+		return r.RiskRepository.Transition(ctx, id, status)
+	})
+}
+
+func (r auditedRisks) AddMitigation(ctx context.Context, m RiskMitigation) (int, error) {
+	var id int
+	err := r.trail.change(ctx, AuditRisk, m.RiskID, "add_mitigation", r.snapshot(m.RiskID), func(ctx context.Context) error {
+		var err error
+		id, err = r.RiskRepository.AddMitigation(ctx, m)
+		return err
+	})
+	return id, err
+}
+
+func (r auditedRisks) CompleteMitigation(ctx context.Context, riskID, mitigationID int) error {
+	return r.trail.change(ctx, AuditRisk, riskID, "complete_mitigation", r.snapshot(riskID), func(ctx context.Context) error {
+		return r.RiskRepository.CompleteMitigation(ctx, riskID, mitigationID)
+	})
+}
+
+// auditedFOI records FOI request changes in the audit trail.
+type auditedFOI struct {
+	FOIRepository
+	trail *auditTrail
+}
+
+func (r auditedFOI) snapshot(id int) auditSnapshot {
+	return func(ctx context.Context) (interface{}, error) {
+		f, err := r.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return toAPIFOIRequest(*f), nil
+	}
+}
+
+func (r auditedFOI) Create(ctx context.Context, f FOIRequest) (int, error) {
+	return r.trail.create(ctx, AuditFOIRequest, AuditCreate, func(ctx context.Context) (int, error) {
+		return r.FOIRepository.Create(ctx, f)
+	}, func(ctx context.Context, id int) (interface{}, error) {
+		return r.snapshot(id)(ctx)
+	})
+}
+
+func (r auditedFOI) Update(ctx context.Context, id int, f FOIRequest) error {
+	return r.trail.change(ctx, AuditFOIRequest, id, AuditUpdate, r.snapshot(id), func(ctx context.Context) error {
+		return r.FOIRepository.Update(ctx, id, f)
+	})
+}
+
+func (r auditedFOI) Delete(ctx context.Context, id int) error {
+	return r.trail.change(ctx, AuditFOIRequest, id, AuditDelete, r.snapshot(id), func(ctx context.Context) error {
+		return r.FOIRepository.Delete(ctx, id)
+	})
+}
+
+func (r auditedFOI) Transition(ctx context.Context, id int, status FOIStatus) error {
+	return r.trail.change(ctx, AuditFOIRequest, id, "transition", r.snapshot(id), func(ctx context.Context) error {
+		return r.FOIRepository.Transition(ctx, id, status)
+	})
+}
+
+// auditedReports records saved reports and report runs in the audit
+// trail. Run results are left out; the run itself keeps them.
+type auditedReports struct {
+	ReportRepository
+	trail *auditTrail
+}
+
+func (r auditedReports) CreateSaved(ctx context.Context, sr SavedReport) (int, error) {
+	return r.trail.create(ctx, AuditSavedReport, AuditCreate, func(ctx context.Context) (int, error) {
+		return r.ReportRepository.CreateSaved(ctx, sr)
+	}, func(ctx context.Context, id int) (interface{}, error) {
+		sr.ID = id
+		return toAPISavedReport(sr), nil
+	})
+}
+
+func (r auditedReports) DeleteSaved(ctx context.Context, id int) error {
+	get := func(ctx context.Context) (interface{}, error) {
+		sr, err := r.GetSaved(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return toAPISavedReport(*sr), nil
+	}
+	return r.trail.change(ctx, AuditSavedReport, id, AuditDelete, get, func(ctx context.Context) error {
+		return r.ReportRepository.DeleteSaved(ctx, id)
+	})
+}
+
+func (r auditedReports) CreateRun(ctx context.Context, run ReportRun) (int, error) {
+	return r.trail.create(ctx, AuditReportRun, AuditCreate, func(ctx context.Context) (int, error) {
+		return r.ReportRepository.CreateRun(ctx, run)
+	}, func(ctx context.Context, id int) (interface{}, error) {
+		run.ID = id
+		run.Result = nil
+		return toAPIReportRun(run), nil
+	})
+}
+
+// auditedWebhooks records webhook subscriptions in the audit trail. The
+// signing secret is never recorded, and deliveries are not audited.
+type auditedWebhooks struct {
+	WebhookRepository
+	trail *auditTrail
+}
+
+// auditWebhook is a webhook as recorded in the audit trail.
+type auditWebhook struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+func (r auditedWebhooks) Create(ctx context.Context, h Webhook) (int, error) {
+	return r.trail.create(ctx, AuditWebhook, AuditCreate, func(ctx context.Context) (int, error) {
+		return r.WebhookRepository.Create(ctx, h)
+	}, func(ctx context.Context, id int) (interface{}, error) {
+		return auditWebhook{URL: h.URL, Events: h.Events}, nil
+	})
+}
+
+func (r auditedWebhooks) Delete(ctx context.Context, id int) error {
+	get := func(ctx context.Context) (interface{}, error) {
+		h, err := r.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return auditWebhook{URL: h.URL, Events: h.Events}, nil
+	}
+	return r.trail.change(ctx, AuditWebhook, id, AuditDelete, get, func(ctx context.Context) error {
+		return r.WebhookRepository.Delete(ctx, id)
+	})
+}
+
+// auditedPeople records changes to the people directory and to asset
+// custody in the audit trail.
+type auditedPeople struct {
+	PeopleRepository
+	trail *auditTrail
+	// licenses, if set, records the seat assignments unlinked from a
+	// person removed from the directory.
+	licenses LicenseRepository
+}
+
+func (r auditedPeople) snapshot(id int) auditSnapshot {
+	return func(ctx context.Context) (interface{}, error) {
+		p, err := r.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return toAPIPerson(*p), nil
+	}
+}
+
+func (r auditedPeople) CreateDepartment(ctx context.Context, name string) (int, error) {
+	return r.trail.create(ctx, AuditDepartment, AuditCreate, func(ctx context.Context) (int, error) {
+		return r.PeopleRepository.CreateDepartment(ctx, name)
+	}, func(ctx context.Context, id int) (interface{}, error) {
+		return apiDepartment{ID: id, Name: name}, nil
+	})
+}
+
+// DeleteDepartment also records its members leaving it.
+func (r auditedPeople) DeleteDepartment(ctx context.Context, id int) error {
+	get := func(ctx context.Context) (interface{}, error) {
+		departments, err := r.Departments(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range departments {
+			if d.ID == id {
+				return apiDepartment{ID: d.ID, Name: d.Name}, nil
+			}
+		}
+		return nil, ErrNotFound
+	}
+	members := personCascade(r.PeopleRepository, func(p Person) bool { return p.DepartmentID == id })
+	return r.trail.change(ctx, AuditDepartment, id, AuditDelete, get, func(ctx context.Context) error {
+		return r.PeopleRepository.DeleteDepartment(ctx, id)
+	}, members)
+}
+
+func (r auditedPeople) Create(ctx context.Context, p Person) (int, error) {
+	return r.trail.create(ctx, AuditPerson, AuditCreate, func(ctx context.Context) (int, error) {
+		return r.PeopleRepository.Create(ctx, p)
+	}, func(ctx context.Context, id int) (interface{}, error) {
+		return r.snapshot(id)(ctx)
+	})
+}
+
+// Update also records the renaming of the person's open checkouts.
+func (r auditedPeople) Update(ctx context.Context, id int, p Person) error {
+	held := custodyCascade(AuditUpdate, func(ctx context.Context) ([]Checkout, error) {
+		return r.Held(ctx, id)
+	})
+	return r.trail.change(ctx, AuditPerson, id, AuditUpdate, r.snapshot(id), func(ctx context.Context) error {
+		return r.PeopleRepository.Update(ctx, id, p)
+	}, held)
+}
+
+// seats covers the seat assignments linked to person id. Removing the
+// person unlinks them, so once listed they are looked up again through
+// their licenses.
+func (r auditedPeople) seats(id int) auditCascade {
+	var held []LicenseAssignment
+	listed := false
+	return assignmentCascade(func(ctx context.Context) ([]LicenseAssignment, error) {
+		if !listed {
+			var err error
+			held, err = r.licenses.PersonAssignments(ctx, id)
+			listed = err == nil
+			return held, err
+		}
+		var out []LicenseAssignment
+		seen := map[int]bool{}
+		for _, h := range held {
+			if seen[h.LicenseID] {
+				continue
+			}
+			seen[h.LicenseID] = true
+			assignments, err := r.licenses.Assignments(ctx, h.LicenseID)
+			if err != nil {
+				return nil, err
+			}
+			for _, a := range assignments {
+				for _, h := range held {
+					if a.ID == h.ID {
+						out = append(out, a)
+					}
+				}
+			}
+		}
+		return out, nil
+	})
+}
+
+func (r auditedPeople) Delete(ctx context.Context, id int) error {
+	var cascades []auditCascade
+	if r.licenses != nil {
+		cascades = append(cascades, r.seats(id))
+	}
+	return r.trail.change(ctx, AuditPerson, id, AuditDelete, r.snapshot(id), func(ctx context.Context) error {
+		return r.PeopleRepository.Delete(ctx, id)
+	}, cascades...)
+}
+
+func (r auditedPeople) CheckOut(ctx context.Context, c Checkout) (int, error) {
+	return r.trail.create(ctx, AuditCheckout, "check_out", func(ctx context.Context) (int, error) {
+		return r.PeopleRepository.CheckOut(ctx, c)
+	}, func(ctx context.Context, id int) (interface{}, error) {
+		c.ID = id
+		return toAPICheckout(c), nil
+	})
+}
+
+func (r auditedPeople) CheckIn(ctx context.Context, assetID int, by, notes string) (*Checkout, error) {
+	var c *Checkout
+	err := r.trail.atomic(ctx, func(ctx context.Context) error {
+		var err error
+		if c, err = r.PeopleRepository.CheckIn(ctx, assetID, by, notes); err != nil {
+			return err
+		}
+		before := *c
+		before.CheckedInAt, before.CheckedInBy, before.ReturnNotes = time.Time{}, "", ""
+		return r.trail.record(ctx, AuditCheckout, c.ID, "check_in", toAPICheckout(before), toAPICheckout(*c))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// auditedLocations records changes to the locations hierarchy in the
+// audit trail. Assets moving between locations are recorded as changes to
+// the assets.
+type auditedLocations struct {
+	LocationRepository
+	trail *auditTrail
+}
+
+// auditLocation is a location as recorded in the audit trail, without the
+// asset counts that change as assets move.
+type auditLocation struct {
+	ID       int          `json:"id"`
+	ParentID int          `json:"parent_id,omitempty"`
+	Kind     LocationKind `json:"kind"`
+	Name     string       `json:"name"`
+	Path     string       `json:"path"`
+}
+
+func (r auditedLocations) snapshot(id int) auditSnapshot {
+	return func(ctx context.Context) (interface{}, error) {
+		l, err := r.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return auditLocation{ID: l.ID, ParentID: l.ParentID, Kind: l.Kind, Name: l.Name, Path: l.Path}, nil
+	}
+}
+
+func (r auditedLocations) Create(ctx context.Context, l Location) (int, error) {
+	return r.trail.create(ctx, AuditLocation, AuditCreate, func(ctx context.Context) (int, error) {
+		return r.LocationRepository.Create(ctx, l)
+	}, func(ctx context.Context, id int) (interface{}, error) {
+		return r.snapshot(id)(ctx)
+	})
+}
+
+func (r auditedLocations) Update(ctx context.Context, id int, l Location) error {
+	return r.trail.change(ctx, AuditLocation, id, AuditUpdate, r.snapshot(id), func(ctx context.Context) error {
+		return r.LocationRepository.Update(ctx, id, l)
+	})
+}
+
+func (r auditedLocations) Delete(ctx context.Context, id int) error {
+	return r.trail.change(ctx, AuditLocation, id, AuditDelete, r.snapshot(id), func(ctx context.Context) error {
+		return r.LocationRepository.Delete(ctx, id)
+	})
+}
+
+// auditedAssetTypes records changes to asset types in the audit trail.
+// Adding, changing and removing a custom field is recorded as an update of
+// its type.
+type auditedAssetTypes struct {
+	AssetTypeRepository
+	trail *auditTrail
+	// assets, if set, records the values removed with a field as updates
+	// of the assets that held them.
+	assets AssetRepository
+}
+
+// auditAssetType is an asset type as recorded in the audit trail, without
+// the asset count.
+type auditAssetType struct {
+	ID     int             `json:"id"`
+	Name   string          `json:"name"`
+	Fields []apiAssetField `json:"fields"`
+}
+
+func (r auditedAssetTypes) snapshot(id int) auditSnapshot {
+	return func(ctx context.Context) (interface{}, error) {
+		t, err := r.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return auditAssetType{ID: t.ID, Name: t.Name, Fields: toAPIAssetType(*t).Fields}, nil
+	}
+}
+
+func (r auditedAssetTypes) Create(ctx context.Context, t AssetType) (int, error) {
+	return r.trail.create(ctx, AuditAssetType, AuditCreate, func(ctx context.Context) (int, error) {
+		return r.AssetTypeRepository.Create(ctx, t)
+	}, func(ctx context.Context, id int) (interface{}, error) {
+		return r.snapshot(id)(ctx)
+	})
+}
+
+func (r auditedAssetTypes) Update(ctx context.Context, id int, t AssetType) error {
+	return r.trail.change(ctx, AuditAssetType, id, AuditUpdate, r.snapshot(id), func(ctx context.Context) error {
+		return r.AssetTypeRepository.Update(ctx, id, t)
+	})
+}
+
+func (r auditedAssetTypes) Delete(ctx context.Context, id int) error {
+	return r.trail.change(ctx, AuditAssetType, id, AuditDelete, r.snapshot(id), func(ctx context.Context) error {
+		return r.AssetTypeRepository.Delete(ctx, id)
+	})
+}
+
+func (r auditedAssetTypes) CreateField(ctx context.Context, f AssetField) (int, error) {
+	var id int
+	err := r.trail.change(ctx, AuditAssetType, f.TypeID, AuditUpdate, r.snapshot(f.TypeID), func(ctx context.Context) error {
+		var err error
+		id, err = r.AssetTypeRepository.CreateField(ctx, f)
+		return err
+	})
+	return id, err
+}
+
+func (r auditedAssetTypes) UpdateField(ctx context.Context, id int, f AssetField) error {
+	return r.trail.change(ctx, AuditAssetType, f.TypeID, AuditUpdate, r.snapshot(f.TypeID), func(ctx context.Context) error {
+		return r.AssetTypeRepository.UpdateField(ctx, id, f)
+	})
+}
+
+func (r auditedAssetTypes) DeleteField(ctx context.Context, typeID, id int) error {
+	var cascades []auditCascade
+	if r.assets != nil {
+		cascades = append(cascades, assetCascade(r.assets, func(a Asset) bool { return a.TypeID == typeID }))
+	}
+	return r.trail.change(ctx, AuditAssetType, typeID, AuditUpdate, r.snapshot(typeID), func(ctx context.Context) error {
+		return r.AssetTypeRepository.DeleteField(ctx, typeID, id)
+	}, cascades...)
+}
+
+// auditUser is a user account as recorded in the audit trail.
+type auditUser struct {
+	Username string `json:"username"`
+	Role     Role   `json:"role"`
+}
+
+// auditedUsers records changes to user accounts in the audit trail.
+// Sessions are not recorded.
+type auditedUsers struct {
+	UserRepository
+	trail *auditTrail
+}
+
+func (r auditedUsers) snapshot(id int) auditSnapshot {
+	return func(ctx context.Context) (interface{}, error) {
+		u, err := r.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return auditUser{Username: u.Username, Role: u.Role}, nil
+	}
+}
+
+func (r auditedUsers) Create(ctx context.Context, username, passwordHash string, role Role) (int, error) {
+	return r.trail.create(ctx, AuditUser, AuditCreate, func(ctx context.Context) (int, error) {
+		return r.UserRepository.Create(ctx, username, passwordHash, role)
+	}, func(ctx context.Context, id int) (interface{}, error) {
+		return r.snapshot(id)(ctx)
+	})
+}
+
+func (r auditedUsers) SetRole(ctx context.Context, id int, role Role) error {
+	return r.trail.change(ctx, AuditUser, id, AuditUpdate, r.snapshot(id), func(ctx context.Context) error {
+		return r.UserRepository.SetRole(ctx, id, role)
+	})
+}
+
+// auditHandler shows the audit log, filtered and paged by the query string.
+func (s *server) auditHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAuditFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.renderAuditLog(w, r, filter, nil)
+}
+
+// auditVerifyHandler checks the hash chain and shows the result above the
+// latest entries.
+func (s *server) auditVerifyHandler(w http.ResponseWriter, r *http.Request) {
+	v, err := verifyAuditChain(r.Context(), s.auditLog)
+	if err != nil {
+		log.Printf("Error verifying audit log: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	s.renderAuditLog(w, r, AuditFilter{}, &v)
+}
+
+// renderAuditLog renders a page of entries matching the filter.
+func (s *server) renderAuditLog(w http.ResponseWriter, r *http.Request, filter AuditFilter, v *AuditVerification) {
+	data, err := s.getPageData(r.Context())
+	if err != nil {
+		log.Printf("Error fetching page data: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	// Fetch one extra entry to tell whether there is a next page.
+	filter.Limit = auditPageSize + 1
+	entries, err := s.auditLog.Search(r.Context(), filter)
+	if err != nil {
+		log.Printf("Error searching audit log: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	filter.Limit = auditPageSize
+	data.AuditMore = len(entries) > auditPageSize
+	if data.AuditMore {
+		entries = entries[:auditPageSize]
+	}
+	data.AuditEntries = entries
+	data.AuditFilter = &filter
+	data.AuditEntities = auditEntities
+	data.AuditVerification = v
+	renderTemplate(w, r, data)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// failingAuditLog refuses every entry, as a full disk or a lost connection
+// would.
+type failingAuditLog struct {
+	AuditRepository
+}
+
+func (failingAuditLog) Append(ctx context.Context, e *AuditEntry) error {
+	return errors.New("audit log unavailable")
+}
+
+// newSQLTestServer returns a server over a fresh SQLite database with its
+// changes recorded in log, or the real audit log if log is nil.
+func newSQLTestServer(t *testing.T, log AuditRepository) *server {
+	t.Helper()
+	db := openTestDB(t)
+	if log == nil {
+		log = newSQLAuditRepository(db)
+	}
+	s := &server{
+		assets:     newSQLAssetRepository(db),
+		licenses:   newSQLLicenseRepository(db),
+		software:   newSQLSoftwareRepository(db),
+		risks:      newSQLRiskRepository(db),
+		foi:        newSQLFOIRepository(db),
+		reports:    newSQLReportRepository(db),
+		webhooks:   newSQLWebhookRepository(db),
+		people:     newSQLPeopleRepository(db),
+		locations:  newSQLLocationRepository(db),
+		assetTypes: newSQLAssetTypeRepository(db),
+		users:      newSQLUserRepository(db),
+	}
+	s.auditRepositories(&auditTrail{log: log, tx: db})
+	return s
+}
+
+func TestAuditFailureRollsBackChange(t *testing.T) {
+	s := newSQLTestServer(t, failingAuditLog{})
+	ctx := context.Background()
+
+	if _, err := s.licenses.Create(ctx, License{Name: "Office", Vendor: "Contoso", Quantity: 5, Metric: MetricPerDevice}); err == nil {
+		t.Fatal("Create succeeded without an audit entry")
+	}
+	count, err := s.licenses.Count(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("license kept after its audit entry failed: count = %d", count)
+	}
+}
+
+func TestAuditAssetDeleteRecordsCascades(t *testing.T) {
+	s := newSQLTestServer(t, nil)
+	ctx := context.Background()
+
+	assetID, err := s.assets.Create(ctx, Asset{Name: "Laptop 1", State: AssetDeployed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	licenseID, err := s.licenses.Create(ctx, License{Name: "Office", Vendor: "Contoso", Quantity: 5, Metric: MetricPerDevice})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assignmentID, err := s.licenses.Assign(ctx, LicenseAssignment{LicenseID: licenseID, AssetID: assetID, Quantity: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	personID, err := s.people.Create(ctx, Person{Name: "Ada", Email: "ada@example.org"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkoutID, err := s.people.CheckOut(ctx, Checkout{AssetID: assetID, PersonID: personID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.assets.Delete(ctx, assetID); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := s.auditLog.Search(ctx, AuditFilter{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		entity string
+		id     int
+		action string
+	}{
+		{AuditCheckout, checkoutID, "check_in"},
+		{AuditLicenseAssignment, assignmentID, AuditDelete},
+		{AuditAsset, assetID, AuditDelete},
+	}
+	if len(entries) < len(want) {
+		t.Fatalf("got %d entries, want at least %d", len(entries), len(want))
+	}
+	for i, w := range want {
+		e := entries[i]
+		if e.Entity != w.entity || e.EntityID != w.id || e.Action != w.action {
+			t.Errorf("entry %d = %s %d %s, want %s %d %s", i, e.Entity, e.EntityID, e.Action, w.entity, w.id, w.action)
+		}
+	}
+}
+
+func TestAuditPersonDeleteKeepsSeats(t *testing.T) {
+	s := newSQLTestServer(t, nil)
+	ctx := context.Background()
+
+	licenseID, err := s.licenses.Create(ctx, License{Name: "IDE", Vendor: "JetBrains", Quantity: 5, Metric: MetricPerUser})
+	if err != nil {
+		t.Fatal(err)
+	}
+	personID, err := s.people.Create(ctx, Person{Name: "Grace", Email: "grace@example.org"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assignmentID, err := s.licenses.Assign(ctx, LicenseAssignment{LicenseID: licenseID, PersonID: personID, Quantity: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.people.Delete(ctx, personID); err != nil {
+		t.Fatal(err)
+	}
+
+	assignments, err := s.licenses.Assignments(ctx, licenseID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(assignments) != 1 || assignments[0].PersonID != 0 || assignments[0].Holder() != "Grace" {
+		t.Fatalf("assignments after removing the person = %+v, want one held by Grace", assignments)
+	}
+	entries, err := s.auditLog.Search(ctx, AuditFilter{Entity: AuditLicenseAssignment, EntityID: assignmentID, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Action != AuditUpdate {
+		t.Errorf("got %d entries for the assignment, latest %+v; want its create and an update", len(entries), entries)
+	}
+}
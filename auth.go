@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// sessionCookieName is the name of the cookie carrying the session token.
+const sessionCookieName = "slam_session"
+
+// sessionLifetime is how long a session stays valid after login.
+const sessionLifetime = 12 * time.Hour
+
+// User is a person who can sign in to the application.
+type User struct {
+	ID       int
+	Username string
+	Role     Role
+}
+
+// dummyHash is compared against when a username does not exist.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("slam-dummy-password"), bcrypt.DefaultCost)
+
+type contextKey string
+
+const userContextKey contextKey = "user"
+
+// currentUser returns the signed-in user attached to the request, if any.
+func currentUser(r *http.Request) *User {
+	u, _ := r.Context().Value(userContextKey).(*User)
+	return u
+}
+
+// hashToken returns the hex SHA-256 of a session token. Only the hash is
+// stored so a copy of the database cannot be used to hijack sessions.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newToken returns a random URL-safe session token.
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// errUsernameTaken reports a username another user already has.
+var errUsernameTaken = errors.New("username is already taken")
+
+// createUser stores a user with a bcrypt hash of the given password. It
+// returns errUsernameTaken if the username is in use.
+func (s *server) createUser(ctx context.Context, username, password string, role Role) (int, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return 0, err
+	}
+	return s.users.Create(ctx, username, string(hash), role)
+}
+
+// authenticate checks a username and password, returning the user on success.
+func (s *server) authenticate(ctx context.Context, username, password string) (*User, error) {
+	u, hash, err := s.users.Credentials(ctx, username)
+	if errors.Is(err, ErrNotFound) {
+		// Compare against a dummy hash so unknown users take as long as known ones.
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return nil, nil
+	}
+	return u, nil
+}
+
+// createSession stores a new session for the user and returns its token.
+// Expired sessions are purged at the same time.
+func (s *server) createSession(ctx context.Context, userID int) (string, time.Time, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expires := time.Now().UTC().Add(sessionLifetime)
+	if err := s.users.CreateSession(ctx, hashToken(token), userID, expires); err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expires, nil
+}
+
+// sessionUser returns the user owning a valid, unexpired session token, or
+// nil if there is none.
+func (s *server) sessionUser(ctx context.Context, token string) (*User, error) {
+	u, err := s.users.SessionUser(ctx, hashToken(token))
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	return u, err
+}
+
+// seedAdminUser creates an initial "admin" account when no users exist.
+// The password is taken from SLAM_ADMIN_PASSWORD or generated and logged once.
+func (s *server) seedAdminUser(ctx context.Context) {
+	count, err := s.users.Count(ctx)
+	if err != nil || count > 0 {
+		return
+	}
+
+	password := os.Getenv("SLAM_ADMIN_PASSWORD")
+	generated := password == ""
+	if generated {
+		token, err := newToken()
+		if err != nil {
+			log.Printf("Error generating admin password: %v\n", err)
+			return
+		}
+		password = token[:16]
+	}
+
+	if _, err := s.createUser(ctx, "admin", password, RoleAdmin); err != nil {
+		log.Printf("Error creating admin user: %v\n", err)
+		return
+	}
+	if generated {
+		log.Printf("Created initial user \"admin\" with password %q (set SLAM_ADMIN_PASSWORD to choose it).\n", password)
+	} else {
+		log.Println("Created initial user \"admin\" from SLAM_ADMIN_PASSWORD.")
+	}
+}
+
+// isSecureRequest reports whether the request reached us over HTTPS, either
+// directly or through a TLS-terminating proxy.
+func isSecureRequest(r *http.Request) bool {
+	return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+// setSessionCookie writes the session cookie to the response.
+func setSessionCookie(w http.ResponseWriter, r *http.Request, token string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   isSecureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// clearSessionCookie expires the session cookie in the browser.
+func clearSessionCookie(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   isSecureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// publicPaths are reachable without signing in.
+var publicPaths = map[string]bool{
+	"/login": true,
+}
+
+// requireAuth is router middleware that attaches the signed-in user to the
+// request context and rejects anonymous requests. Browsers are redirected to
+// the login page; API clients receive a 401 JSON error.
+func (s *server) requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if publicPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var user *User
+		if c, err := r.Cookie(sessionCookieName); err == nil && c.Value != "" {
+			user, err = s.sessionUser(r.Context(), c.Value)
+			if err != nil {
+				log.Printf("Error loading session: %v\n", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if user == nil {
+			if strings.HasPrefix(r.URL.Path, "/api/") {
+				writeAPIError(w, http.StatusUnauthorized, "unauthenticated", "sign in to use the API")
+				return
+			}
+			http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), userContextKey, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// safeNext returns target if it is a local path, otherwise "/".
+func safeNext(target string) string {
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") {
+		return "/"
+	}
+	return target
+}
+
+// loginPageData is the data rendered by the login template.
+type loginPageData struct {
+	Username string
+	Next     string
+	Error    string
+}
+
+const loginContent = `
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Sign in - Software Licence & Asset Management</title>
+    <script src="https://cdn.tailwindcss.com"></script>
+    <style>
+        @import url('https://fonts.googleapis.com/css2?family=Inter:wght@400;500;600;700&display=swap');
+        body {
+            font-family: 'Inter', sans-serif;
+            background-color: #f3f4f6;
+        }
+    </style>
+</head>
+<body class="bg-gray-100 flex items-center justify-center min-h-screen">
+    <div class="bg-white p-8 rounded-2xl shadow-xl w-full max-w-md">
+        <h2 class="text-3xl font-bold text-gray-800 mb-6">Sign in to SL&AM</h2>
+        {{if .Error}}
+        <p class="mb-4 py-2 px-4 rounded-md text-sm text-red-700 bg-red-100">{{.Error}}</p>
+        {{end}}
+        <form action="/login" method="post" class="space-y-4">
+            <input type="hidden" name="next" value="{{.Next}}">
+            <div>
+                <label for="username" class="block text-sm font-medium text-gray-700">Username</label>
+                <input type="text" name="username" id="username" value="{{.Username}}" required autofocus autocomplete="username" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+            </div>
+            <div>
+                <label for="password" class="block text-sm font-medium text-gray-700">Password</label>
+                <input type="password" name="password" id="password" required autocomplete="current-password" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+            </div>
+            <button type="submit" class="w-full flex justify-center py-2 px-4 border border-transparent rounded-md shadow-sm text-sm font-medium text-white bg-blue-600 hover:bg-blue-700 focus:outline-none focus:ring-2 focus:ring-offset-2 focus:ring-blue-500 transition-colors duration-300">
+                Sign in
+            </button>
+        </form>
+    </div>
+</body>
+</html>
+`
+
+var loginTemplate = template.Must(template.New("login").Parse(loginContent))
+
+// loginHandler shows the login form and signs users in.
+func (s *server) loginHandler(w http.ResponseWriter, r *http.Request) {
+	data := loginPageData{Next: safeNext(r.FormValue("next"))}
+
+	if r.Method == http.MethodPost {
+		data.Username = strings.TrimSpace(r.FormValue("username"))
+		user, err := s.authenticate(r.Context(), data.Username, r.FormValue("password"))
+		if err != nil {
+			log.Printf("Error authenticating user: %v\n", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if user != nil {
+			token, expires, err := s.createSession(r.Context(), user.ID)
+			if err != nil {
+				log.Printf("Error creating session: %v\n", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			setSessionCookie(w, r, token, expires)
+			http.Redirect(w, r, data.Next, http.StatusSeeOther)
+			return
+		}
+		data.Error = "Invalid username or password."
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+
+	if err := loginTemplate.Execute(w, data); err != nil {
+		log.Printf("Error executing login template: %v\n", err)
+	}
+}
+
+// logoutHandler ends the current session.
+func (s *server) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie(sessionCookieName); err == nil {
+		if err := s.users.DeleteSession(r.Context(), hashToken(c.Value)); err != nil {
+			log.Printf("Error deleting session: %v\n", err)
+		}
+	}
+	clearSessionCookie(w, r)
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
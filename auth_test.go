@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSessions(t *testing.T) {
+	tests := []struct {
+		name string
+		// open returns the repository under test, and whether it still
+		// holds a session, expired or not.
+		open func(t *testing.T) (UserRepository, func(tokenHash string) bool)
+	}{
+		{"sql", func(t *testing.T) (UserRepository, func(string) bool) {
+			db := openTestDB(t)
+			return newSQLUserRepository(db), func(tokenHash string) bool {
+				var count int
+				if err := db.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM sessions WHERE token_hash = ?", tokenHash).Scan(&count); err != nil {
+					t.Fatal(err)
+				}
+				return count > 0
+			}
+		}},
+		{"memory", func(t *testing.T) (UserRepository, func(string) bool) {
+			users := newMemUserRepository()
+			return users, func(tokenHash string) bool {
+				_, ok := users.sessions[tokenHash]
+				return ok
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, stored := tt.open(t)
+			testSessions(t, &server{users: users}, stored)
+		})
+	}
+}
+
+func testSessions(t *testing.T, s *server, stored func(tokenHash string) bool) {
+	ctx := context.Background()
+	id, err := s.createUser(ctx, "ada", "correct horse", RoleAuditor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.createUser(ctx, "ada", "another horse", RoleViewer); err != errUsernameTaken {
+		t.Errorf("createUser of a taken username: err = %v, want errUsernameTaken", err)
+	}
+
+	if u, err := s.authenticate(ctx, "ada", "wrong"); err != nil || u != nil {
+		t.Errorf("authenticate with a wrong password = %v, %v; want nil, nil", u, err)
+	}
+	if u, err := s.authenticate(ctx, "grace", "correct horse"); err != nil || u != nil {
+		t.Errorf("authenticate of an unknown user = %v, %v; want nil, nil", u, err)
+	}
+	u, err := s.authenticate(ctx, "ada", "correct horse")
+	if err != nil || u == nil || u.ID != id || u.Role != RoleAuditor {
+		t.Fatalf("authenticate = %+v, %v", u, err)
+	}
+
+	token, expires, err := s.createSession(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Until(expires); d <= sessionLifetime-time.Minute || d > sessionLifetime {
+		t.Errorf("session expires in %v, want %v", d, sessionLifetime)
+	}
+	if u, err := s.sessionUser(ctx, token); err != nil || u == nil || u.Username != "ada" {
+		t.Fatalf("sessionUser = %+v, %v", u, err)
+	}
+
+	// A session that has run out no longer signs in, and is purged by the
+	// next login.
+	if err := s.users.CreateSession(ctx, hashToken("stale"), id, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if u, err := s.sessionUser(ctx, "stale"); err != nil || u != nil {
+		t.Errorf("sessionUser of an expired session = %+v, %v; want nil, nil", u, err)
+	}
+	if _, _, err := s.createSession(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if stored(hashToken("stale")) {
+		t.Error("login left an expired session in place")
+	}
+
+	if err := s.users.DeleteSession(ctx, hashToken(token)); err != nil {
+		t.Fatal(err)
+	}
+	if u, err := s.sessionUser(ctx, token); err != nil || u != nil {
+		t.Errorf("sessionUser after logout = %+v, %v; want nil, nil", u, err)
+	}
+}
+
+func TestSessionUserCancelledContext(t *testing.T) {
+	s := &server{users: newSQLUserRepository(openTestDB(t))}
+	ctx := context.Background()
+	id, err := s.createUser(ctx, "ada", "correct horse", RoleAuditor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := s.createSession(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := s.sessionUser(cancelled, token); err == nil {
+		t.Error("sessionUser ignored a cancelled request context")
+	}
+}
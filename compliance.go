@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Position is the outcome of reconciling a product's entitlements against
+// its usage.
+type Position string
+
+const (
+	PositionCompliant     Position = "compliant"
+	PositionUnderLicensed Position = "under_licensed"
+	PositionOverLicensed  Position = "over_licensed"
+)
+
+// Label returns a human readable name for the position.
+func (p Position) Label() string {
+	switch p {
+	case PositionUnderLicensed:
+		return "Under-licensed"
+	case PositionOverLicensed:
+		return "Over-licensed"
+	default:
+		return "Compliant"
+	}
+}
+
+// ProductPosition is the effective license position of one product under
+// the licenses counted in one metric.
+type ProductPosition struct {
+	Product string
+	Metric  LicenseMetric
+	// Licenses are the names of the licenses covering the product.
+	Licenses []string
+	// Entitled is the number of seats held under active licenses.
+	// Unlimited is set instead when an active site license covers the product.
+	Entitled  int
+	Unlimited bool
+	// Installations counts the distinct assets the product is installed on;
+	// Assigned counts the seats handed out through license assignments, in
+	// the metric's units.
+	Installations int
+	Assigned      int
+	// Demand is the number of seats needed, see metricDemand.
+	Demand   int
+	Position Position
+	// Shortfall is the number of seats missing, Surplus the number unused.
+	Shortfall int
+	Surplus   int
+	// Exposure is the cost of buying the missing seats at UnitCost.
+	UnitCost float64
+	Exposure float64
+}
+
+// ComplianceReport is the result of a reconciliation run.
+type ComplianceReport struct {
+	GeneratedAt   time.Time
+	Products      []ProductPosition
+	Compliant     int
+	UnderLicensed int
+	OverLicensed  int
+	TotalExposure float64
+}
+
+// licenseCounts reports whether a license contributes entitlements on the
+// given day: it must not be expired or cancelled.
+func licenseCounts(l License, today time.Time) bool {
+	switch l.Status {
+	case "expired", "cancelled":
+		return false
+	}
+	return l.ExpiryDate.IsZero() || !l.ExpiryDate.Before(today)
+}
+
+// productKey normalises a product name for matching: case and repeated
+// whitespace are ignored.
+func productKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// productMatches reports whether an installed product is covered by a
+// license for key. An installation matches when its name equals the key or
+// extends it with further words, so "AutoCAD" covers "AutoCAD 2024".
+func productMatches(installed, key string) bool {
+	installed = productKey(installed)
+	return installed == key || strings.HasPrefix(installed, key+" ")
+}
+
+// metricDemand returns the seats needed under a metric: a per-device
+// license needs one per asset the product is installed on, and per-user,
+// concurrent and per-core licenses need the seats, users or cores assigned,
+// but never fewer than one per installation, so unassigned installations
+// still need covering. Site licenses are unlimited, so installations are
+// reported for information only.
+func metricDemand(m LicenseMetric, installations, assigned int) int {
+	switch m {
+	case MetricPerDevice, MetricSite:
+		return installations
+	default:
+		if installations > assigned {
+			return installations
+		}
+		return assigned
+	}
+}
+
+// positionKey identifies the position of a product under one metric.
+type positionKey struct {
+	product string
+	metric  LicenseMetric
+}
+
+// reconcile compares license entitlements against installations and seat
+// assignments and produces the effective license position of every
+// licensed product, separately for each metric its licenses count in.
+// Products without any license are not reported, and an active site
+// license covers every position of its product.
+func reconcile(licenses []License, software []InstalledSoftware, now time.Time) ComplianceReport {
+	report := ComplianceReport{GeneratedAt: now}
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+
+	positions := make(map[positionKey]*ProductPosition)
+	var keys []positionKey
+	site := make(map[string]bool)
+	perDevice := make(map[string]bool)
+	for _, l := range licenses {
+		key := positionKey{productKey(l.CoveredProduct()), l.Metric}
+		p, ok := positions[key]
+		if !ok {
+			p = &ProductPosition{Product: l.CoveredProduct(), Metric: l.Metric}
+			positions[key] = p
+			keys = append(keys, key)
+		}
+		p.Licenses = append(p.Licenses, l.Name)
+		if l.Metric == MetricPerDevice {
+			perDevice[key.product] = true
+		}
+		// Assigned seats count even on lapsed licenses: the usage still
+		// needs covering.
+		p.Assigned += l.Consumed
+		// Lapsed licenses still price the seats needed to renew them.
+		if l.UnitCost > p.UnitCost {
+			p.UnitCost = l.UnitCost
+		}
+		if !licenseCounts(l, today) {
+			continue
+		}
+		if l.Metric.Unlimited() {
+			site[key.product] = true
+		} else {
+			p.Entitled += l.Quantity
+		}
+	}
+
+	for _, key := range keys {
+		assets := make(map[int]bool)
+		for _, sw := range software {
+			if productMatches(sw.Product, key.product) {
+				assets[sw.AssetID] = true
+			}
+		}
+		p := positions[key]
+		p.Installations = len(assets)
+		// Installations are covered by the product's per-device licenses
+		// when it has any, so its other positions only count assignments.
+		installations := p.Installations
+		if p.Metric != MetricPerDevice && perDevice[key.product] {
+			installations = 0
+		}
+		p.Demand = metricDemand(p.Metric, installations, p.Assigned)
+		p.Unlimited = site[key.product]
+
+		switch {
+		case p.Unlimited || p.Demand == p.Entitled:
+			p.Position = PositionCompliant
+			report.Compliant++
+		case p.Demand > p.Entitled:
+			p.Position = PositionUnderLicensed
+			p.Shortfall = p.Demand - p.Entitled
+			p.Exposure = float64(p.Shortfall) * p.UnitCost
+			report.UnderLicensed++
+			report.TotalExposure += p.Exposure
+		default:
+			p.Position = PositionOverLicensed
+			p.Surplus = p.Entitled - p.Demand
+			report.OverLicensed++
+		}
+		report.Products = append(report.Products, *p)
+	}
+
+	// Worst first: the largest exposure, then the largest shortfall.
+	sort.SliceStable(report.Products, func(i, j int) bool {
+		a, b := report.Products[i], report.Products[j]
+		if a.Exposure != b.Exposure {
+			return a.Exposure > b.Exposure
+		}
+		if a.Shortfall != b.Shortfall {
+			return a.Shortfall > b.Shortfall
+		}
+		if a.Product != b.Product {
+			return a.Product < b.Product
+		}
+		return a.Metric < b.Metric
+	})
+	return report
+}
+
+// runCompliance loads the data and reconciles it.
+func (s *server) runCompliance(ctx context.Context) (ComplianceReport, error) {
+	licenses, err := s.licenses.List(ctx)
+	if err != nil {
+		return ComplianceReport{}, err
+	}
+	software, err := s.software.All(ctx)
+	if err != nil {
+		return ComplianceReport{}, fmt.Errorf("error fetching installed software: %w", err)
+	}
+	return reconcile(licenses, software, time.Now().UTC().Truncate(time.Second)), nil
+}
+
+// complianceHandler runs a reconciliation and shows the Compliance Audits page.
+func (s *server) complianceHandler(w http.ResponseWriter, r *http.Request) {
+	data, err := s.getPageData(r.Context())
+	if err != nil {
+		log.Printf("Error fetching page data: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	report, err := s.runCompliance(r.Context())
+	if err != nil {
+		log.Printf("Error running compliance report: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	data.Compliance = &report
+	renderTemplate(w, r, data)
+}
+
+// printComplianceReport writes the report as an aligned text table.
+func printComplianceReport(out io.Writer, report ComplianceReport) error {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PRODUCT\tMETRIC\tPOSITION\tENTITLED\tINSTALLED\tASSIGNED\tSHORTFALL\tSURPLUS\tEXPOSURE")
+	for _, p := range report.Products {
+		entitled := fmt.Sprint(p.Entitled)
+		if p.Unlimited {
+			entitled = "unlimited"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\t%d\t%d\t%.2f\n",
+			p.Product, p.Metric.Label(), p.Position.Label(), entitled, p.Installations, p.Assigned, p.Shortfall, p.Surplus, p.Exposure)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(out, "\n%d compliant, %d under-licensed, %d over-licensed; total exposure %.2f\n",
+		report.Compliant, report.UnderLicensed, report.OverLicensed, report.TotalExposure)
+	return err
+}
+
+// runComplianceCommand implements "slam compliance [json]". It exits with
+// status 1 when any product is under-licensed so it can gate scripts.
+func runComplianceCommand(s *server, args []string) {
+	if len(args) > 1 || (len(args) == 1 && args[0] != "json") {
+		fmt.Fprintln(os.Stderr, "usage: slam compliance [json]")
+		os.Exit(2)
+	}
+	report, err := s.runCompliance(context.Background())
+	if err != nil {
+		log.Fatalf("Compliance error: %v\n", err)
+	}
+
+	if len(args) == 1 {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(toAPICompliance(report))
+	} else {
+		err = printComplianceReport(os.Stdout, report)
+	}
+	if err != nil {
+		log.Fatalf("Compliance error: %v\n", err)
+	}
+	if report.UnderLicensed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReconcileMetrics(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	// Two hosts have the product installed.
+	software := []InstalledSoftware{
+		{AssetID: 1, Product: "Database Server"},
+		{AssetID: 2, Product: "Database Server 2024"},
+		{AssetID: 2, Product: "Database Server"},
+	}
+	tests := []struct {
+		name      string
+		licenses  []License
+		demand    int
+		position  Position
+		shortfall int
+		surplus   int
+	}{
+		{
+			name:     "per device counts installations",
+			licenses: []License{{Name: "Database Server", Metric: MetricPerDevice, Quantity: 2, Consumed: 5}},
+			demand:   2,
+			position: PositionCompliant,
+		},
+		{
+			name:      "per device short of installations",
+			licenses:  []License{{Name: "Database Server", Metric: MetricPerDevice, Quantity: 1}},
+			demand:    2,
+			position:  PositionUnderLicensed,
+			shortfall: 1,
+		},
+		{
+			name:     "per user counts assigned seats",
+			licenses: []License{{Name: "Database Server", Metric: MetricPerUser, Quantity: 10, Consumed: 4}},
+			demand:   4,
+			position: PositionOverLicensed,
+			surplus:  6,
+		},
+		{
+			name:      "concurrent counts assigned seats",
+			licenses:  []License{{Name: "Database Server", Metric: MetricConcurrent, Quantity: 3, Consumed: 5}},
+			demand:    5,
+			position:  PositionUnderLicensed,
+			shortfall: 2,
+		},
+		{
+			name:      "per user counts unassigned installations",
+			licenses:  []License{{Name: "Database Server", Metric: MetricPerUser}},
+			demand:    2,
+			position:  PositionUnderLicensed,
+			shortfall: 2,
+		},
+		{
+			name:      "concurrent with fewer assignments than installations",
+			licenses:  []License{{Name: "Database Server", Metric: MetricConcurrent, Quantity: 1, Consumed: 1}},
+			demand:    2,
+			position:  PositionUnderLicensed,
+			shortfall: 1,
+		},
+		{
+			name:     "per core counts assigned cores",
+			licenses: []License{{Name: "Database Server", Metric: MetricPerCore, Quantity: 64, Consumed: 64}},
+			demand:   64,
+			position: PositionCompliant,
+		},
+		{
+			name:     "site is unlimited",
+			licenses: []License{{Name: "Database Server", Metric: MetricSite, Quantity: 0}},
+			demand:   2,
+			position: PositionCompliant,
+		},
+		{
+			name:      "lapsed site license",
+			licenses:  []License{{Name: "Database Server", Metric: MetricSite, Status: "expired"}},
+			demand:    2,
+			position:  PositionUnderLicensed,
+			shortfall: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := reconcile(tt.licenses, software, now)
+			if len(report.Products) != 1 {
+				t.Fatalf("got %d positions, want 1", len(report.Products))
+			}
+			p := report.Products[0]
+			if p.Demand != tt.demand || p.Position != tt.position || p.Shortfall != tt.shortfall || p.Surplus != tt.surplus {
+				t.Errorf("demand %d, %s, shortfall %d, surplus %d; want demand %d, %s, shortfall %d, surplus %d",
+					p.Demand, p.Position, p.Shortfall, p.Surplus, tt.demand, tt.position, tt.shortfall, tt.surplus)
+			}
+		})
+	}
+}
+
+func TestReconcileSeparatesMetrics(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	software := []InstalledSoftware{{AssetID: 1, Product: "Editor"}, {AssetID: 2, Product: "Editor"}}
+	licenses := []License{
+		{Name: "Editor", Metric: MetricPerDevice, Quantity: 2},
+		{Name: "Editor", Metric: MetricPerUser, Quantity: 1, Consumed: 3},
+	}
+	report := reconcile(licenses, software, now)
+	if len(report.Products) != 2 {
+		t.Fatalf("got %d positions, want one per metric", len(report.Products))
+	}
+	if report.Compliant != 1 || report.UnderLicensed != 1 {
+		t.Errorf("got %d compliant and %d under-licensed, want 1 of each", report.Compliant, report.UnderLicensed)
+	}
+
+	// The per-device license covers the installations, so the per-user
+	// position only needs its assigned seat.
+	report = reconcile([]License{licenses[0], {Name: "Editor", Metric: MetricPerUser, Quantity: 1, Consumed: 1}}, software, now)
+	if report.Compliant != 2 {
+		t.Errorf("installations counted against both metrics: %+v", report.Products)
+	}
+
+	licenses = append(licenses, License{Name: "Editor", Metric: MetricSite})
+	report = reconcile(licenses, software, now)
+	if report.Compliant != len(report.Products) {
+		t.Errorf("a site license left %d of %d positions non-compliant", len(report.Products)-report.Compliant, len(report.Products))
+	}
+}
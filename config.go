@@ -0,0 +1,371 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/mail"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds every runtime setting. Values are resolved in this order,
+// later sources overriding earlier ones:
+//
+//  1. built-in defaults (defaultConfig)
+//  2. the YAML file named by -config or SLAM_CONFIG, if any
+//  3. SLAM_* environment variables
+//  4. command-line flags
+//
+// See config.example.yaml for a documented sample file.
+type Config struct {
+	Server   ServerConfig   `yaml:"server"`
+	Database DatabaseConfig `yaml:"database"`
+	Expiry   ExpiryConfig   `yaml:"expiry"`
+	Seed     SeedConfig     `yaml:"seed"`
+	Log      LogConfig      `yaml:"log"`
+	FOI      FOIConfig      `yaml:"foi"`
+	Notify   NotifyConfig   `yaml:"notify"`
+}
+
+// ServerConfig configures the HTTP listener.
+type ServerConfig struct {
+	// Addr is the host:port to listen on (SLAM_ADDR, -addr).
+	Addr string `yaml:"addr"`
+}
+
+// DatabaseConfig selects the storage backend.
+type DatabaseConfig struct {
+	// Driver is sqlite, postgres or mysql (SLAM_DB_DRIVER, -db-driver).
+	Driver string `yaml:"driver"`
+	// DSN is the driver-specific connection string; empty uses the
+	// driver's default (SLAM_DB_DSN, -db-dsn).
+	DSN string `yaml:"dsn"`
+}
+
+// ExpiryConfig controls when licenses are reported as expiring.
+type ExpiryConfig struct {
+	// WarningDays is how far ahead the dashboard looks for expiring
+	// licenses (SLAM_EXPIRY_WARNING_DAYS, -expiry-days).
+	WarningDays int `yaml:"warning_days"`
+}
+
+// SeedConfig controls the sample data loaded into an empty database.
+type SeedConfig struct {
+	// Enabled loads sample assets and licenses when none exist
+	// (SLAM_SEED, -seed).
+	Enabled bool `yaml:"enabled"`
+}
+
+// LogConfig controls logging output.
+type LogConfig struct {
+	// File receives log output instead of stderr when set
+	// (SLAM_LOG_FILE, -log-file).
+	File string `yaml:"file"`
+	// Requests logs one line per HTTP request (SLAM_LOG_REQUESTS, -log-requests).
+	Requests bool `yaml:"requests"`
+}
+
+// FOIConfig controls how Freedom of Information deadlines are calculated.
+type FOIConfig struct {
+	// DeadlineDays is the statutory response time in working days
+	// (SLAM_FOI_DEADLINE_DAYS, -foi-deadline-days).
+	DeadlineDays int `yaml:"deadline_days"`
+	// Holidays are YYYY-MM-DD dates that are not working days
+	// (SLAM_FOI_HOLIDAYS, comma separated).
+	Holidays []string `yaml:"holidays"`
+}
+
+// NotifyConfig controls the scheduled license expiry emails.
+type NotifyConfig struct {
+	// Enabled starts the notification scheduler with the server
+	// (SLAM_NOTIFY_ENABLED, -notify).
+	Enabled bool `yaml:"enabled"`
+	// Interval is how often the scheduler looks for licenses to notify
+	// about, such as "1h" (SLAM_NOTIFY_INTERVAL).
+	Interval time.Duration `yaml:"interval"`
+	// Thresholds are the days before expiry at which a notice is sent. A
+	// final notice is always sent on expiry (SLAM_NOTIFY_THRESHOLDS, comma
+	// separated).
+	Thresholds []int `yaml:"thresholds"`
+	// DefaultRecipient receives notices for licenses without an owner
+	// email (SLAM_NOTIFY_DEFAULT_RECIPIENT).
+	DefaultRecipient string `yaml:"default_recipient"`
+	// EscalateTo also receives notices sent EscalateDays or fewer days
+	// before expiry, and on expiry (SLAM_NOTIFY_ESCALATE_TO, comma separated).
+	EscalateTo []string `yaml:"escalate_to"`
+	// EscalateDays is the threshold at which notices are escalated
+	// (SLAM_NOTIFY_ESCALATE_DAYS).
+	EscalateDays int        `yaml:"escalate_days"`
+	SMTP         SMTPConfig `yaml:"smtp"`
+}
+
+// SMTPConfig names the mail server used for notifications.
+type SMTPConfig struct {
+	// Host and Port locate the server (SLAM_SMTP_HOST, SLAM_SMTP_PORT).
+	Host string `yaml:"host"`
+	Port int    `yaml:"port"`
+	// Username and Password enable PLAIN authentication when set
+	// (SLAM_SMTP_USERNAME, SLAM_SMTP_PASSWORD).
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// From is the sender address (SLAM_SMTP_FROM).
+	From string `yaml:"from"`
+}
+
+// defaultConfig returns the settings used when nothing else is configured.
+func defaultConfig() Config {
+	return Config{
+		Server:   ServerConfig{Addr: ":8080"},
+		Database: DatabaseConfig{Driver: "sqlite"},
+		Expiry:   ExpiryConfig{WarningDays: 30},
+		Seed:     SeedConfig{Enabled: true},
+		FOI:      FOIConfig{DeadlineDays: 20},
+		Notify: NotifyConfig{
+			Interval:     time.Hour,
+			Thresholds:   []int{90, 60, 30, 7},
+			EscalateDays: 7,
+			SMTP:         SMTPConfig{Port: 25},
+		},
+	}
+}
+
+// loadConfig resolves the configuration from defaults, the config file, the
+// environment and the given command-line arguments. It returns the remaining
+// non-flag arguments, such as a "migrate" subcommand.
+func loadConfig(args []string) (Config, []string, error) {
+	cfg := defaultConfig()
+
+	fs := flag.NewFlagSet("slam", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv("SLAM_CONFIG"), "path to a YAML config file")
+	addr := fs.String("addr", "", "HTTP listen address")
+	dbDriver := fs.String("db-driver", "", "database driver: sqlite, postgres or mysql")
+	dbDSN := fs.String("db-dsn", "", "database connection string")
+	expiryDays := fs.Int("expiry-days", 0, "days ahead to report licenses as expiring")
+	seed := fs.Bool("seed", false, "load sample data into an empty database")
+	logFile := fs.String("log-file", "", "write logs to this file instead of stderr")
+	logRequests := fs.Bool("log-requests", false, "log every HTTP request")
+	foiDeadlineDays := fs.Int("foi-deadline-days", 0, "working days allowed to answer an FOI request")
+	notify := fs.Bool("notify", false, "email license expiry notifications on a schedule")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: slam [flags] [migrate status|up|down [steps] | compliance [json] | notify]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, nil, err
+	}
+
+	if *configPath != "" {
+		if err := cfg.loadFile(*configPath); err != nil {
+			return cfg, nil, err
+		}
+	}
+	if err := cfg.loadEnv(); err != nil {
+		return cfg, nil, err
+	}
+
+	// Only flags given on the command line override other sources.
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "addr":
+			cfg.Server.Addr = *addr
+		case "db-driver":
+			cfg.Database.Driver = *dbDriver
+		case "db-dsn":
+			cfg.Database.DSN = *dbDSN
+		case "expiry-days":
+			cfg.Expiry.WarningDays = *expiryDays
+		case "seed":
+			cfg.Seed.Enabled = *seed
+		case "log-file":
+			cfg.Log.File = *logFile
+		case "log-requests":
+			cfg.Log.Requests = *logRequests
+		case "foi-deadline-days":
+			cfg.FOI.DeadlineDays = *foiDeadlineDays
+		case "notify":
+			cfg.Notify.Enabled = *notify
+		}
+	})
+
+	cfg.Database.Driver = strings.ToLower(cfg.Database.Driver)
+	if err := cfg.validate(); err != nil {
+		return cfg, nil, err
+	}
+	return cfg, fs.Args(), nil
+}
+
+// loadFile overlays the settings in a YAML file. Unknown keys are rejected
+// so that typos do not silently fall back to defaults.
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && err != io.EOF {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadEnv overlays settings from SLAM_* environment variables.
+func (c *Config) loadEnv() error {
+	var errs []string
+	str := func(name string, dst *string) {
+		if v, ok := os.LookupEnv(name); ok {
+			*dst = v
+		}
+	}
+	num := func(name string, dst *int) {
+		if v, ok := os.LookupEnv(name); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %q is not a whole number", name, v))
+				return
+			}
+			*dst = n
+		}
+	}
+	list := func(name string, dst *[]string) {
+		if v, ok := os.LookupEnv(name); ok {
+			*dst = nil
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					*dst = append(*dst, item)
+				}
+			}
+		}
+	}
+	boolean := func(name string, dst *bool) {
+		if v, ok := os.LookupEnv(name); ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %q is not true or false", name, v))
+				return
+			}
+			*dst = b
+		}
+	}
+
+	str("SLAM_ADDR", &c.Server.Addr)
+	str("SLAM_DB_DRIVER", &c.Database.Driver)
+	str("SLAM_DB_DSN", &c.Database.DSN)
+	num("SLAM_EXPIRY_WARNING_DAYS", &c.Expiry.WarningDays)
+	boolean("SLAM_SEED", &c.Seed.Enabled)
+	str("SLAM_LOG_FILE", &c.Log.File)
+	boolean("SLAM_LOG_REQUESTS", &c.Log.Requests)
+	num("SLAM_FOI_DEADLINE_DAYS", &c.FOI.DeadlineDays)
+	list("SLAM_FOI_HOLIDAYS", &c.FOI.Holidays)
+	boolean("SLAM_NOTIFY_ENABLED", &c.Notify.Enabled)
+	if v, ok := os.LookupEnv("SLAM_NOTIFY_INTERVAL"); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("SLAM_NOTIFY_INTERVAL: %q is not a duration such as 1h", v))
+		} else {
+			c.Notify.Interval = d
+		}
+	}
+	if v, ok := os.LookupEnv("SLAM_NOTIFY_THRESHOLDS"); ok {
+		var days []string
+		list("SLAM_NOTIFY_THRESHOLDS", &days)
+		c.Notify.Thresholds = nil
+		for _, day := range days {
+			n, err := strconv.Atoi(day)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("SLAM_NOTIFY_THRESHOLDS: %q is not a list of whole numbers", v))
+				break
+			}
+			c.Notify.Thresholds = append(c.Notify.Thresholds, n)
+		}
+	}
+	str("SLAM_NOTIFY_DEFAULT_RECIPIENT", &c.Notify.DefaultRecipient)
+	list("SLAM_NOTIFY_ESCALATE_TO", &c.Notify.EscalateTo)
+	num("SLAM_NOTIFY_ESCALATE_DAYS", &c.Notify.EscalateDays)
+	str("SLAM_SMTP_HOST", &c.Notify.SMTP.Host)
+	num("SLAM_SMTP_PORT", &c.Notify.SMTP.Port)
+	str("SLAM_SMTP_USERNAME", &c.Notify.SMTP.Username)
+	str("SLAM_SMTP_PASSWORD", &c.Notify.SMTP.Password)
+	str("SLAM_SMTP_FROM", &c.Notify.SMTP.From)
+
+	if len(errs) > 0 {
+		return errors.New("invalid environment: " + strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// validate checks the resolved configuration and reports every problem at once.
+func (c *Config) validate() error {
+	var errs []string
+	if c.Server.Addr == "" {
+		errs = append(errs, "server.addr must not be empty")
+	}
+	if _, ok := dialects[c.Database.Driver]; !ok {
+		errs = append(errs, fmt.Sprintf("database.driver %q is not supported (use sqlite, postgres or mysql)", c.Database.Driver))
+	}
+	if c.Expiry.WarningDays < 1 || c.Expiry.WarningDays > 3650 {
+		errs = append(errs, fmt.Sprintf("expiry.warning_days must be between 1 and 3650, got %d", c.Expiry.WarningDays))
+	}
+	if c.FOI.DeadlineDays < 1 || c.FOI.DeadlineDays > 365 {
+		errs = append(errs, fmt.Sprintf("foi.deadline_days must be between 1 and 365, got %d", c.FOI.DeadlineDays))
+	}
+	for _, day := range c.FOI.Holidays {
+		if _, err := time.Parse(dateLayout, day); err != nil {
+			errs = append(errs, fmt.Sprintf("foi.holidays: %q is not a YYYY-MM-DD date", day))
+		}
+	}
+	errs = append(errs, c.Notify.validate()...)
+	if len(errs) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// validate checks the notification settings. The mail server is only
+// required once notifications are enabled.
+func (n *NotifyConfig) validate() []string {
+	var errs []string
+	if n.Interval < time.Minute {
+		errs = append(errs, fmt.Sprintf("notify.interval must be at least 1m, got %s", n.Interval))
+	}
+	for _, days := range n.Thresholds {
+		if days < 1 || days > 3650 {
+			errs = append(errs, fmt.Sprintf("notify.thresholds must be between 1 and 3650 days, got %d", days))
+		}
+	}
+	if n.EscalateDays < 0 {
+		errs = append(errs, fmt.Sprintf("notify.escalate_days must not be negative, got %d", n.EscalateDays))
+	}
+	if n.DefaultRecipient != "" {
+		if _, err := parseEmail(n.DefaultRecipient); err != nil {
+			errs = append(errs, "notify.default_recipient: "+err.Error())
+		}
+	}
+	for _, addr := range n.EscalateTo {
+		if _, err := parseEmail(addr); err != nil {
+			errs = append(errs, "notify.escalate_to: "+err.Error())
+		}
+	}
+	if n.SMTP.Port < 1 || n.SMTP.Port > 65535 {
+		errs = append(errs, fmt.Sprintf("notify.smtp.port must be between 1 and 65535, got %d", n.SMTP.Port))
+	}
+	if n.Enabled && n.SMTP.Host == "" {
+		errs = append(errs, "notify.smtp.host is required when notifications are enabled")
+	}
+	if n.SMTP.From != "" {
+		if _, err := mail.ParseAddress(n.SMTP.From); err != nil {
+			errs = append(errs, fmt.Sprintf("notify.smtp.from: invalid email address %q", n.SMTP.From))
+		}
+	} else if n.Enabled {
+		errs = append(errs, "notify.smtp.from is required when notifications are enabled")
+	}
+	return errs
+}
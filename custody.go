@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Checkout records an asset being in a person's custody. It is open until
+// the asset is checked back in.
+type Checkout struct {
+	ID        int
+	AssetID   int
+	AssetName string
+	// PersonID is zero once the person has been removed from the
+	// directory; PersonName keeps who held the asset.
+	PersonID     int
+	PersonName   string
+	CheckedOutAt time.Time
+	CheckedOutBy string
+	// ExpectedReturn is optional, for assets handed out indefinitely.
+	ExpectedReturn time.Time
+	Notes          string
+	CheckedInAt    time.Time
+	CheckedInBy    string
+	ReturnNotes    string
+}
+
+// Open reports whether the asset has not been checked back in.
+func (c Checkout) Open() bool {
+	return c.CheckedInAt.IsZero()
+}
+
+// Overdue reports whether an open checkout is past its expected return
+// date.
+func (c Checkout) Overdue() bool {
+	return c.Open() && !c.ExpectedReturn.IsZero() && c.ExpectedReturn.Before(today())
+}
+
+// errCustody reports a check-out or check-in that the asset's custody does
+// not allow, such as checking out an asset someone already holds.
+var errCustody = errors.New("custody change not allowed")
+
+// CanCheckOut reports whether an asset in state s may be handed to someone.
+func (s AssetState) CanCheckOut() bool {
+	return s == AssetInStock || s == AssetDeployed
+}
+
+// newCheckout validates the details of a check-out. The expected return
+// date, if given, may not be in the past.
+func newCheckout(personID int, expectedReturn, notes string) (Checkout, error) {
+	c := Checkout{PersonID: personID, Notes: strings.TrimSpace(notes)}
+	if personID <= 0 {
+		return c, errors.New("a person is required")
+	}
+	if v := strings.TrimSpace(expectedReturn); v != "" {
+		var err error
+		if c.ExpectedReturn, err = time.Parse(dateLayout, v); err != nil {
+			return c, fmt.Errorf("invalid expected return date %q", v)
+		}
+		if c.ExpectedReturn.Before(today()) {
+			return c, errors.New("the expected return date cannot be in the past")
+		}
+	}
+	return c, nil
+}
+
+// checkOut hands an asset to the person in c. It returns ErrNotFound for a
+// missing asset, an error wrapping errInvalidPerson for a missing person
+// and one wrapping errCustody if the asset cannot be checked out.
+func (s *server) checkOut(ctx context.Context, assetID int, c Checkout) (*Checkout, error) {
+	asset, err := s.assets.Get(ctx, assetID)
+	if err != nil {
+		return nil, err
+	}
+	if !asset.State.CanCheckOut() {
+		return nil, fmt.Errorf("%w: asset %d is %s", errCustody, assetID, strings.ToLower(asset.State.Label()))
+	}
+	p, err := s.people.Get(ctx, c.PersonID)
+	if errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("%w: person %d does not exist", errInvalidPerson, c.PersonID)
+	} else if err != nil {
+		return nil, err
+	}
+	c.AssetID, c.AssetName = assetID, asset.Name
+	c.PersonName = p.Name
+	c.CheckedOutAt = time.Now().UTC().Truncate(time.Second)
+	c.CheckedOutBy = auditActor(ctx)
+	if c.ID, err = s.people.CheckOut(ctx, c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// checkoutHandler checks an asset out to a person from the asset's page.
+func (s *server) checkoutHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	personID, err := strconv.Atoi(r.FormValue("person-id"))
+	if err != nil {
+		http.Error(w, "a person is required", http.StatusBadRequest)
+		return
+	}
+	c, err := newCheckout(personID, r.FormValue("expected-return"), r.FormValue("notes"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	checkout, err := s.checkOut(r.Context(), id, c)
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if errors.Is(err, errCustody) || errors.Is(err, errInvalidPerson) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Printf("Error checking out asset: %v\n", err)
+		http.Error(w, "Error saving checkout", http.StatusInternalServerError)
+		return
+	}
+	s.events.Emit(r.Context(), EventAssetCheckedOut, toAPICheckout(*checkout))
+	http.Redirect(w, r, fmt.Sprintf("/assets/%d", id), http.StatusSeeOther)
+}
+
+// checkinHandler checks an asset back in from the asset's page.
+func (s *server) checkinHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	checkout, err := s.people.CheckIn(r.Context(), id, auditActor(r.Context()), strings.TrimSpace(r.FormValue("return-notes")))
+	if errors.Is(err, errCustody) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Printf("Error checking in asset: %v\n", err)
+		http.Error(w, "Error saving checkout", http.StatusInternalServerError)
+		return
+	}
+	s.events.Emit(r.Context(), EventAssetCheckedIn, toAPICheckout(*checkout))
+	http.Redirect(w, r, fmt.Sprintf("/assets/%d", id), http.StatusSeeOther)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCheckOutConcurrent(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	assets, people := newSQLAssetRepository(db), newSQLPeopleRepository(db)
+
+	assetID, err := assets.Create(ctx, Asset{Name: "Laptop 1", State: AssetDeployed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var personIDs []int
+	for _, name := range []string{"Ada", "Grace", "Edsger", "Barbara"} {
+		id, err := people.Create(ctx, Person{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		personIDs = append(personIDs, id)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(personIDs))
+	for i, personID := range personIDs {
+		wg.Add(1)
+		go func(i, personID int) {
+			defer wg.Done()
+			_, errs[i] = people.CheckOut(ctx, Checkout{AssetID: assetID, PersonID: personID, PersonName: "p", CheckedOutAt: time.Now()})
+		}(i, personID)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+		}
+	}
+	if succeeded == 0 {
+		t.Fatalf("no checkout succeeded: %v", errs)
+	}
+	open, err := people.Custody(ctx, assetID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	held := 0
+	for _, c := range open {
+		if c.Open() {
+			held++
+		}
+	}
+	if held != 1 || succeeded != 1 {
+		t.Errorf("%d checkouts succeeded leaving %d open, want 1", succeeded, held)
+	}
+
+	if _, err := people.CheckOut(ctx, Checkout{AssetID: assetID + 1, PersonName: "p", CheckedOutAt: time.Now()}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("checkout of a missing asset: got %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// timestampLayout is the format used when writing TIMESTAMP/DATETIME values.
+// Every supported backend accepts it and it sorts correctly as text in SQLite.
+const timestampLayout = "2006-01-02 15:04:05"
+
+// dialect captures the SQL differences between the supported backends.
+type dialect struct {
+	// Name is the database.driver setting that selects this dialect.
+	Name string
+	// Label is shown on the Settings page.
+	Label string
+	// Driver is the database/sql driver name.
+	Driver string
+	// DefaultDSN is used when database.dsn is not set.
+	DefaultDSN string
+	// prepareDSN, if set, adjusts every DSN before it is opened.
+	prepareDSN func(dsn string) (string, error)
+	// transactionalDDL reports whether schema changes roll back with the
+	// transaction they run in. MySQL commits each one implicitly.
+	transactionalDDL bool
+	// numberedParams rewrites ? placeholders to $1, $2, ... (PostgreSQL).
+	numberedParams bool
+	// returningID fetches new ids with RETURNING instead of LastInsertId.
+	returningID bool
+	// ddl maps the tokens used in migrations to column definitions.
+	ddl map[string]string
+	// dropIndexOnTable names the table when dropping an index (MySQL).
+	dropIndexOnTable bool
+	// today and dateOffset render the current date and a date N days away.
+	today      string
+	dateOffset func(days int) string
+	// number is the type numbers stored as text are cast to for comparison.
+	number string
+}
+
+var dialects = map[string]*dialect{
+	"sqlite": {
+		Name:             "sqlite",
+		Label:            "SQLite",
+		Driver:           "sqlite",
+		DefaultDSN:       "./slam.db",
+		transactionalDDL: true,
+		ddl: map[string]string{
+			"{{serial}}":    "INTEGER PRIMARY KEY AUTOINCREMENT",
+			"{{timestamp}}": "DATETIME",
+			"{{longtext}}":  "TEXT",
+		},
+		today:  "date('now')",
+		number: "REAL",
+		dateOffset: func(days int) string {
+			return fmt.Sprintf("date('now', '%+d days')", days)
+		},
+	},
+	"postgres": {
+		Name:             "postgres",
+		Label:            "PostgreSQL",
+		Driver:           "postgres",
+		DefaultDSN:       "postgres://localhost/slam?sslmode=disable",
+		numberedParams:   true,
+		returningID:      true,
+		transactionalDDL: true,
+		ddl: map[string]string{
+			"{{serial}}":    "SERIAL PRIMARY KEY",
+			"{{timestamp}}": "TIMESTAMP",
+			"{{longtext}}":  "TEXT",
+		},
+		today:  "CURRENT_DATE",
+		number: "DOUBLE PRECISION",
+		dateOffset: func(days int) string {
+			return fmt.Sprintf("(CURRENT_DATE + %d)", days)
+		},
+	},
+	"mysql": {
+		Name:       "mysql",
+		Label:      "MySQL",
+		Driver:     "mysql",
+		DefaultDSN: "slam:slam@tcp(localhost:3306)/slam",
+		prepareDSN: mysqlDSN,
+		// MySQL names the table when dropping an index.
+		dropIndexOnTable: true,
+		ddl: map[string]string{
+			"{{serial}}":    "INTEGER PRIMARY KEY AUTO_INCREMENT",
+			"{{timestamp}}": "DATETIME",
+			"{{longtext}}":  "LONGTEXT",
+		},
+		today:  "CURDATE()",
+		number: "DECIMAL(65,30)",
+		dateOffset: func(days int) string {
+			return fmt.Sprintf("DATE_ADD(CURDATE(), INTERVAL %d DAY)", days)
+		},
+	},
+}
+
+// mysqlDSN turns on clientFoundRows, so that an UPDATE reports the rows it
+// matched rather than only those it changed. Otherwise saving a record
+// unchanged would look like it no longer exists, see checkAffected.
+func mysqlDSN(dsn string) (string, error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "", fmt.Errorf("invalid MySQL DSN: %w", err)
+	}
+	cfg.ClientFoundRows = true
+	return cfg.FormatDSN(), nil
+}
+
+// databaseBackend describes a backend shown on the Settings page.
+type databaseBackend struct {
+	Name, Label, Description string
+}
+
+// Supported reports whether the backend has a dialect and can be selected.
+func (b databaseBackend) Supported() bool {
+	_, ok := dialects[b.Name]
+	return ok
+}
+
+// databaseBackends lists every backend shown on the Settings page. Those
+// without a dialect are advertised but not yet supported.
+var databaseBackends = []databaseBackend{
+	{"postgres", "PostgreSQL", "A powerful, open-source relational database system."},
+	{"oracle", "Oracle Database", "A widely used enterprise-grade relational database."},
+	{"mysql", "MySQL", "The world's most popular open source database."},
+	{"sqlserver", "SQL Server", "Microsoft's relational database management system."},
+	{"sqlite", "SQLite", "A lightweight, file-based database perfect for local use."},
+}
+
+// dbConn wraps *sql.DB so that queries written with ? placeholders and the
+// migration DDL tokens run unchanged on every supported backend.
+type dbConn struct {
+	*sql.DB
+	dialect *dialect
+}
+
+// dbTx is a transaction on a dbConn with the same placeholder handling.
+type dbTx struct {
+	*sql.Tx
+	dialect *dialect
+	// joined marks a transaction begun while the context already carried
+	// one from InTx. It is that transaction, and committing or rolling it
+	// back is left to InTx.
+	joined bool
+}
+
+// txContextKey is the context key of the transaction started by InTx.
+type txContextKey struct{}
+
+// txFrom returns the transaction started by InTx that ctx carries, if any.
+func txFrom(ctx context.Context) *dbTx {
+	tx, _ := ctx.Value(txContextKey{}).(*dbTx)
+	return tx
+}
+
+// openDatabase opens a connection for the named backend.
+func openDatabase(name, dsn string) (*dbConn, error) {
+	d, ok := dialects[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported database driver %q", name)
+	}
+	if dsn == "" {
+		dsn = d.DefaultDSN
+	}
+	if d.prepareDSN != nil {
+		var err error
+		if dsn, err = d.prepareDSN(dsn); err != nil {
+			return nil, err
+		}
+	}
+	conn, err := sql.Open(d.Driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.PingContext(context.Background()); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("cannot connect to %s database: %w", d.Label, err)
+	}
+	return &dbConn{DB: conn, dialect: d}, nil
+}
+
+// rebind rewrites ? placeholders for dialects that number their parameters.
+// Question marks inside quoted literals are left alone.
+func (d *dialect) rebind(query string) string {
+	if !d.numberedParams || !strings.Contains(query, "?") {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	inQuote := false
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			inQuote = !inQuote
+		case r == '?' && !inQuote:
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// dropIndexToken matches the {{drop_index <index> <table>}} migration token.
+var dropIndexToken = regexp.MustCompile(`\{\{drop_index (\w+) (\w+)\}\}`)
+
+// expandDDL replaces the migration tokens with this dialect's column types
+// and statements.
+func (d *dialect) expandDDL(script string) string {
+	for token, def := range d.ddl {
+		script = strings.ReplaceAll(script, token, def)
+	}
+	dropIndex := "DROP INDEX $1"
+	if d.dropIndexOnTable {
+		dropIndex = "DROP INDEX $1 ON $2"
+	}
+	return dropIndexToken.ReplaceAllString(script, dropIndex)
+}
+
+// isUniqueViolation reports whether err is a unique constraint violation
+// from any of the supported drivers.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	var mysqlErr *mysql.MySQLError
+	switch {
+	case errors.As(err, &pqErr):
+		return pqErr.Code == "23505"
+	case errors.As(err, &mysqlErr):
+		return mysqlErr.Number == 1062
+	}
+	// SQLite only tells constraints apart in the message.
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+// Today returns the SQL expression for the current date.
+func (d *dialect) Today() string { return d.today }
+
+// DateOffset returns the SQL expression for the date days from today;
+// negative values reach into the past.
+func (d *dialect) DateOffset(days int) string { return d.dateOffset(days) }
+
+// The query methods of dbConn run in the transaction ctx carries, if any,
+// so that repositories called from within InTx join it without knowing.
+
+func (c *dbConn) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if tx := txFrom(ctx); tx != nil {
+		return tx.ExecContext(ctx, query, args...)
+	}
+	return c.DB.ExecContext(ctx, c.dialect.rebind(query), args...)
+}
+
+func (c *dbConn) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if tx := txFrom(ctx); tx != nil {
+		return tx.QueryContext(ctx, query, args...)
+	}
+	return c.DB.QueryContext(ctx, c.dialect.rebind(query), args...)
+}
+
+func (c *dbConn) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	if tx := txFrom(ctx); tx != nil {
+		return tx.QueryRowContext(ctx, query, args...)
+	}
+	return c.DB.QueryRowContext(ctx, c.dialect.rebind(query), args...)
+}
+
+// BeginTx starts a transaction, or joins the one ctx carries.
+func (c *dbConn) BeginTx(ctx context.Context, opts *sql.TxOptions) (*dbTx, error) {
+	if tx := txFrom(ctx); tx != nil {
+		return &dbTx{Tx: tx.Tx, dialect: tx.dialect, joined: true}, nil
+	}
+	tx, err := c.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &dbTx{Tx: tx, dialect: c.dialect}, nil
+}
+
+// InTx runs fn in a transaction carried by the context passed to it, and
+// commits it if fn succeeds. Every query fn makes through the connection
+// with that context runs in the transaction, so the changes of several
+// repository calls are kept or rolled back together. If ctx already
+// carries a transaction, fn joins it.
+func (c *dbConn) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if txFrom(ctx) != nil {
+		return fn(ctx)
+	}
+	tx, err := c.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(context.WithValue(ctx, txContextKey{}, tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// InsertContext runs an INSERT and returns the id of the new row.
+func (c *dbConn) InsertContext(ctx context.Context, query string, args ...interface{}) (int, error) {
+	if tx := txFrom(ctx); tx != nil {
+		return tx.InsertContext(ctx, query, args...)
+	}
+	return insertID(ctx, c.dialect, c.DB, query, args...)
+}
+
+// Commit commits the transaction, unless it joined one from InTx.
+func (t *dbTx) Commit() error {
+	if t.joined {
+		return nil
+	}
+	return t.Tx.Commit()
+}
+
+// Rollback rolls the transaction back, unless it joined one from InTx;
+// that one is rolled back when the error that stopped this one reaches
+// InTx.
+func (t *dbTx) Rollback() error {
+	if t.joined {
+		return nil
+	}
+	return t.Tx.Rollback()
+}
+
+func (t *dbTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return t.Tx.ExecContext(ctx, t.dialect.rebind(query), args...)
+}
+
+func (t *dbTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return t.Tx.QueryContext(ctx, t.dialect.rebind(query), args...)
+}
+
+func (t *dbTx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return t.Tx.QueryRowContext(ctx, t.dialect.rebind(query), args...)
+}
+
+// InsertContext runs an INSERT inside the transaction and returns the new id.
+func (t *dbTx) InsertContext(ctx context.Context, query string, args ...interface{}) (int, error) {
+	return insertID(ctx, t.dialect, t.Tx, query, args...)
+}
+
+// sqlRunner is the subset of *sql.DB and *sql.Tx used by insertID.
+type sqlRunner interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func insertID(ctx context.Context, d *dialect, runner sqlRunner, query string, args ...interface{}) (int, error) {
+	if d.returningID {
+		var id int
+		err := runner.QueryRowContext(ctx, d.rebind(query)+" RETURNING id", args...).Scan(&id)
+		return id, err
+	}
+	res, err := runner.ExecContext(ctx, d.rebind(query), args...)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+// parseTimestamp reads a TIMESTAMP/DATETIME column, which drivers return
+// either in timestampLayout or, when scanned from a time.Time, as RFC 3339.
+func parseTimestamp(s string) time.Time {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t
+	}
+	t, _ := time.Parse(timestampLayout, s)
+	return t
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMySQLDSNFoundRows(t *testing.T) {
+	for _, dsn := range []string{
+		dialects["mysql"].DefaultDSN,
+		"app:secret@tcp(db.internal:3306)/assets?parseTime=true",
+		"app:secret@tcp(db.internal:3306)/assets?clientFoundRows=false",
+	} {
+		got, err := mysqlDSN(dsn)
+		if err != nil {
+			t.Fatalf("mysqlDSN(%q): %v", dsn, err)
+		}
+		if !strings.Contains(got, "clientFoundRows=true") {
+			t.Errorf("mysqlDSN(%q) = %q, want clientFoundRows=true", dsn, got)
+		}
+	}
+	if _, err := mysqlDSN("not a dsn"); err == nil {
+		t.Error("mysqlDSN accepted an invalid DSN")
+	}
+}
+
+func TestRebind(t *testing.T) {
+	d := dialects["postgres"]
+	got := d.rebind("SELECT * FROM t WHERE a = ? AND b = '?' AND c = ?")
+	want := "SELECT * FROM t WHERE a = $1 AND b = '?' AND c = $2"
+	if got != want {
+		t.Errorf("rebind = %q, want %q", got, want)
+	}
+	if q := "SELECT ?"; dialects["sqlite"].rebind(q) != q {
+		t.Error("sqlite rebind changed the query")
+	}
+}
+
+// openTestDB returns a migrated SQLite database in a temporary directory,
+// open for the length of the test.
+func openTestDB(t *testing.T) *dbConn {
+	t.Helper()
+	conn, err := openDatabase("sqlite", filepath.Join(t.TempDir(), "slam.db"))
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	prev := db
+	db = conn
+	t.Cleanup(func() {
+		conn.Close()
+		db = prev
+	})
+	if err := migrateUp(); err != nil {
+		t.Fatalf("migrating database: %v", err)
+	}
+	return conn
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Export formats accepted by the ?format= query parameter.
+const (
+	formatJSON = "json"
+	formatCSV  = "csv"
+	formatXLSX = "xlsx"
+)
+
+// exportSheet is the worksheet name used in XLSX exports.
+const exportSheet = "Sheet1"
+
+// utf8BOM prefixes CSV exports so spreadsheet programs read them as UTF-8
+// rather than the system code page.
+const utf8BOM = "\ufeff"
+
+// exportTable is a list prepared for download. Cells may be strings, ints,
+// float64s or time.Time dates; a zero time is written as an empty cell.
+type exportTable struct {
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// exportFormat returns the format requested with ?format=, defaulting to
+// def, or an error naming the accepted values.
+func exportFormat(r *http.Request, def string, accepted ...string) (string, error) {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		return def, nil
+	}
+	for _, f := range accepted {
+		if format == f {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported format %q, expected one of %s", format, strings.Join(accepted, ", "))
+}
+
+// exportFilename returns the download name for an export taken today.
+func exportFilename(base, format string) string {
+	return fmt.Sprintf("%s-%s.%s", base, today().Format(dateLayout), format)
+}
+
+// writeExport sends the table as a CSV or XLSX attachment.
+func writeExport(w http.ResponseWriter, format, filename string, t exportTable) {
+	switch format {
+	case formatXLSX:
+		f, err := t.xlsx()
+		if err != nil {
+			log.Printf("Error building XLSX export: %v\n", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		if err := f.Write(w); err != nil {
+			log.Printf("Error writing XLSX export: %v\n", err)
+		}
+	default:
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		if err := t.writeCSV(w); err != nil {
+			log.Printf("Error writing CSV export: %v\n", err)
+		}
+	}
+}
+
+// writeCSV writes the table with a header row. Dates use dateLayout.
+func (t exportTable) writeCSV(w io.Writer) error {
+	if _, err := w.Write([]byte(utf8BOM)); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	cw.Write(t.Columns)
+	record := make([]string, len(t.Columns))
+	for _, row := range t.Rows {
+		for i, cell := range row {
+			record[i] = formatCell(cell)
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// formatCell renders a cell for CSV. Text that a spreadsheet would run as
+// a formula is prefixed with an apostrophe.
+func formatCell(cell interface{}) string {
+	switch v := cell.(type) {
+	case nil:
+		return ""
+	case string:
+		if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+			return "'" + v
+		}
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Format(dateLayout)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// xlsx builds a workbook with a bold, frozen header row. Numbers and dates
+// are stored as typed cells so they sort and sum correctly.
+func (t exportTable) xlsx() (*excelize.File, error) {
+	f := excelize.NewFile()
+	header, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return nil, err
+	}
+	dateFormat := "yyyy-mm-dd"
+	date, err := f.NewStyle(&excelize.Style{CustomNumFmt: &dateFormat})
+	if err != nil {
+		return nil, err
+	}
+
+	sw, err := f.NewStreamWriter(exportSheet)
+	if err != nil {
+		return nil, err
+	}
+	if err := sw.SetPanes(&excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"}); err != nil {
+		return nil, err
+	}
+	cols := make([]interface{}, len(t.Columns))
+	for i, c := range t.Columns {
+		cols[i] = excelize.Cell{StyleID: header, Value: c}
+	}
+	if err := sw.SetRow("A1", cols); err != nil {
+		return nil, err
+	}
+	for n, row := range t.Rows {
+		cells := make([]interface{}, len(row))
+		for i, cell := range row {
+			switch v := cell.(type) {
+			case time.Time:
+				if v.IsZero() {
+					cells[i] = nil
+				} else {
+					cells[i] = excelize.Cell{StyleID: date, Value: v}
+				}
+			default:
+				cells[i] = v
+			}
+		}
+		axis, _ := excelize.CoordinatesToCellName(1, n+2)
+		if err := sw.SetRow(axis, cells); err != nil {
+			return nil, err
+		}
+	}
+	if err := sw.Flush(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// assetsTable lays out the asset register for export. The custom fields
+// of the assets' types follow the standard columns, one column per field
+// name, with values in the form the import accepts.
+func assetsTable(assets []Asset) exportTable {
+	t := exportTable{Columns: []string{"ID", "Name", "Type", "Location", "Manufacturer", "Model", "Serial Number", "Asset Tag",
+		"Purchase Date", "Purchase Cost", "Supplier", "Order Reference", "Warranty End", "State"}}
+	var fields []string
+	seen := make(map[string]bool)
+	for _, a := range assets {
+		for _, v := range a.Fields {
+			if !seen[v.Name] {
+				seen[v.Name] = true
+				fields = append(fields, v.Name)
+				t.Columns = append(t.Columns, v.Label)
+			}
+		}
+	}
+	for _, a := range assets {
+		row := []interface{}{
+			a.ID, a.Name, a.AssetType, a.Location, a.Manufacturer, a.Model, a.SerialNumber, a.AssetTag,
+			a.PurchaseDate, a.PurchaseCost, a.Supplier, a.OrderReference, a.WarrantyEnd, a.State.Label(),
+		}
+		for _, name := range fields {
+			row = append(row, a.Field(name))
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	return t
+}
+
+// licensesTable lays out the licenses for export.
+func licensesTable(licenses []License) exportTable {
+	t := exportTable{Columns: []string{"ID", "Name", "Vendor", "Product", "Status", "Metric", "Quantity", "Consumed", "Available", "Unit Cost", "Expiry Date", "Renewal Date", "Owner Email"}}
+	for _, l := range licenses {
+		var available interface{} = l.Available()
+		if l.Metric.Unlimited() {
+			available = "unlimited"
+		}
+		t.Rows = append(t.Rows, []interface{}{
+			l.ID, l.Name, l.Vendor, l.Product, l.Status, l.Metric.Label(),
+			l.Quantity, l.Consumed, available, l.UnitCost, l.ExpiryDate, l.RenewalDate, l.OwnerEmail,
+		})
+	}
+	return t
+}
+
+// reportResultTable lays out a stored report result for export. Results
+// hold strings, so cells that look like whole numbers, decimals or dates
+// are converted back for XLSX.
+func reportResultTable(result *ReportResult) exportTable {
+	t := exportTable{Columns: result.Columns}
+	for _, row := range result.Rows {
+		cells := make([]interface{}, len(row))
+		for i, cell := range row {
+			cells[i] = typedCell(cell)
+		}
+		t.Rows = append(t.Rows, cells)
+	}
+	return t
+}
+
+// typedCell converts a report cell to a number or date where it is
+// unambiguous. Values with leading zeros, such as asset tags, stay text.
+func typedCell(s string) interface{} {
+	if d, err := time.Parse(dateLayout, s); err == nil {
+		return d
+	}
+	if s == "" || (len(s) > 1 && s[0] == '0' && s[1] != '.') {
+		return s
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		return n
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && !strings.ContainsAny(s, "eEnN") {
+		return f
+	}
+	return s
+}
+
+// exportAssetsHandler downloads the asset register as CSV or XLSX.
+func (s *server) exportAssetsHandler(w http.ResponseWriter, r *http.Request) {
+	format, err := exportFormat(r, formatCSV, formatCSV, formatXLSX)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	assets, err := s.assets.List(r.Context())
+	if err != nil {
+		log.Printf("Error fetching assets: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	writeExport(w, format, exportFilename("assets", format), assetsTable(assets))
+}
+
+// exportLicensesHandler downloads the licenses as CSV or XLSX.
+func (s *server) exportLicensesHandler(w http.ResponseWriter, r *http.Request) {
+	format, err := exportFormat(r, formatCSV, formatCSV, formatXLSX)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	licenses, err := s.licenses.List(r.Context())
+	if err != nil {
+		log.Printf("Error fetching licenses: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	writeExport(w, format, exportFilename("licenses", format), licensesTable(licenses))
+}
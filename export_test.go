@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestFormatCell(t *testing.T) {
+	tests := []struct {
+		cell interface{}
+		want string
+	}{
+		{nil, ""},
+		{"Laptop", "Laptop"},
+		{"=SUM(A1:A2)", "'=SUM(A1:A2)"},
+		{"+44 20 7946 0000", "'+44 20 7946 0000"},
+		{"-1", "'-1"},
+		{"@cmd", "'@cmd"},
+		{42, "42"},
+		{1299.5, "1299.5"},
+		{time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC), "2025-03-31"},
+		{time.Time{}, ""},
+	}
+	for _, tt := range tests {
+		if got := formatCell(tt.cell); got != tt.want {
+			t.Errorf("formatCell(%#v) = %q, want %q", tt.cell, got, tt.want)
+		}
+	}
+}
+
+func TestTypedCell(t *testing.T) {
+	tests := []struct {
+		in   string
+		want interface{}
+	}{
+		{"", ""},
+		{"12", 12},
+		{"0", 0},
+		{"0.5", 0.5},
+		{"1299.99", 1299.99},
+		{"007", "007"},
+		{"1e3", "1e3"},
+		{"NaN", "NaN"},
+		{"2025-03-31", time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)},
+		{"Office", "Office"},
+	}
+	for _, tt := range tests {
+		if got := typedCell(tt.in); got != tt.want {
+			t.Errorf("typedCell(%q) = %#v, want %#v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestExportFormat(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{"", formatCSV, false},
+		{"?format=xlsx", formatXLSX, false},
+		{"?format=XLSX", formatXLSX, false},
+		{"?format=pdf", "", true},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/assets/export"+tt.query, nil)
+		got, err := exportFormat(r, formatCSV, formatCSV, formatXLSX)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("exportFormat(%q) = %q, %v; want %q, error %v", tt.query, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	table := exportTable{
+		Columns: []string{"Name", "Cost", "Bought"},
+		Rows: [][]interface{}{
+			{"Laptop, 14\"", 999.5, time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)},
+			{"=HYPERLINK(\"x\")", 0, time.Time{}},
+		},
+	}
+	var buf bytes.Buffer
+	if err := table.writeCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), utf8BOM) {
+		t.Fatal("CSV export does not start with a UTF-8 byte order mark")
+	}
+	records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(buf.String(), utf8BOM))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"Name", "Cost", "Bought"},
+		{"Laptop, 14\"", "999.5", "2025-01-02"},
+		{"'=HYPERLINK(\"x\")", "0", ""},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("got %d records, want %d: %q", len(records), len(want), records)
+	}
+	for i := range want {
+		if strings.Join(records[i], "|") != strings.Join(want[i], "|") {
+			t.Errorf("record %d = %q, want %q", i, records[i], want[i])
+		}
+	}
+}
+
+func TestXLSXKeepsTypes(t *testing.T) {
+	table := exportTable{
+		Columns: []string{"Name", "Quantity", "Expiry Date"},
+		Rows:    [][]interface{}{{"Office", 25, time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC)}},
+	}
+	f, err := table.xlsx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	read, err := excelize.OpenReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer read.Close()
+	for cell, want := range map[string]string{"A1": "Name", "A2": "Office", "B2": "25", "C2": "2025-06-30"} {
+		got, err := read.GetCellValue(exportSheet, cell)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s = %q, want %q", cell, got, want)
+		}
+	}
+	if typ, err := read.GetCellType(exportSheet, "B2"); err != nil || typ == excelize.CellTypeSharedString || typ == excelize.CellTypeInlineString {
+		t.Errorf("quantity stored as text (type %v, err %v)", typ, err)
+	}
+}
+
+func TestLicensesTableAvailable(t *testing.T) {
+	table := licensesTable([]License{
+		{ID: 1, Name: "Office", Metric: MetricPerUser, Quantity: 10, Consumed: 4},
+		{ID: 2, Name: "Campus", Metric: MetricSite, Quantity: 1, Consumed: 900},
+	})
+	col := -1
+	for i, c := range table.Columns {
+		if c == "Available" {
+			col = i
+		}
+	}
+	if col < 0 {
+		t.Fatalf("no Available column in %q", table.Columns)
+	}
+	if got := table.Rows[0][col]; got != 6 {
+		t.Errorf("available seats = %#v, want 6", got)
+	}
+	if got := table.Rows[1][col]; got != "unlimited" {
+		t.Errorf("available seats on a site license = %#v, want \"unlimited\"", got)
+	}
+}
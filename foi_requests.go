@@ -0,0 +1,409 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FOIStatus is a stage of the Freedom of Information request workflow.
+type FOIStatus string
+
+const (
+	FOIReceived   FOIStatus = "received"
+	FOIInProgress FOIStatus = "in_progress"
+	FOIOnHold     FOIStatus = "on_hold"
+	FOIResponded  FOIStatus = "responded"
+	FOIClosed     FOIStatus = "closed"
+	FOIWithdrawn  FOIStatus = "withdrawn"
+)
+
+// foiTransitions lists the statuses each status may move to. A responded
+// request can be reopened, e.g. for an internal review.
+var foiTransitions = map[FOIStatus][]FOIStatus{
+	FOIReceived:   {FOIInProgress, FOIWithdrawn},
+	FOIInProgress: {FOIOnHold, FOIResponded, FOIWithdrawn},
+	FOIOnHold:     {FOIInProgress, FOIWithdrawn},
+	FOIResponded:  {FOIClosed, FOIInProgress},
+	FOIClosed:     {},
+	FOIWithdrawn:  {},
+}
+
+// openFOIStatuses are the statuses in which the statutory clock is running.
+var openFOIStatuses = []FOIStatus{FOIReceived, FOIInProgress, FOIOnHold}
+
+// Label returns a human readable name for the status.
+func (s FOIStatus) Label() string {
+	switch s {
+	case FOIInProgress:
+		return "In progress"
+	case FOIOnHold:
+		return "On hold"
+	case "":
+		return ""
+	default:
+		return strings.ToUpper(string(s[:1])) + string(s[1:])
+	}
+}
+
+// Next returns the statuses the request may move to from s.
+func (s FOIStatus) Next() []FOIStatus {
+	return foiTransitions[s]
+}
+
+// CanMoveTo reports whether the workflow allows moving from s to next.
+func (s FOIStatus) CanMoveTo(next FOIStatus) bool {
+	for _, allowed := range foiTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Open reports whether the request still awaits a response.
+func (s FOIStatus) Open() bool {
+	for _, open := range openFOIStatuses {
+		if s == open {
+			return true
+		}
+	}
+	return false
+}
+
+// FOIRequest is a Freedom of Information case.
+type FOIRequest struct {
+	ID           int
+	Requester    string
+	Contact      string
+	Subject      string
+	Details      string
+	ReceivedDate time.Time
+	// DueDate is the statutory deadline, calculated from ReceivedDate when
+	// the request is saved.
+	DueDate    time.Time
+	Assignee   string
+	Status     FOIStatus
+	AssetIDs   []int
+	LicenseIDs []int
+	// ReportRunIDs are report runs produced for the request, such as the
+	// data disclosed in the response.
+	ReportRunIDs []int
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// Reference is the case number quoted to requesters.
+func (f FOIRequest) Reference() string {
+	return fmt.Sprintf("FOI-%d-%04d", f.ReceivedDate.Year(), f.ID)
+}
+
+// Overdue reports whether an open request has passed its deadline.
+func (f FOIRequest) Overdue() bool {
+	return f.Status.Open() && f.DueDate.Before(today())
+}
+
+// LinksAsset reports whether the request concerns the asset.
+func (f FOIRequest) LinksAsset(id int) bool {
+	return containsID(f.AssetIDs, id)
+}
+
+// LinksLicense reports whether the request concerns the license.
+func (f FOIRequest) LinksLicense(id int) bool {
+	return containsID(f.LicenseIDs, id)
+}
+
+// LinksReportRun reports whether the report run was produced for the request.
+func (f FOIRequest) LinksReportRun(id int) bool {
+	return containsID(f.ReportRunIDs, id)
+}
+
+// today returns the current UTC date at midnight.
+func today() time.Time {
+	y, m, d := time.Now().UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// foiCalendar calculates statutory deadlines in working days.
+type foiCalendar struct {
+	deadlineDays int
+	holidays     map[string]bool
+}
+
+// newFOICalendar builds a calendar from the FOI configuration, which has
+// already been validated.
+func newFOICalendar(cfg FOIConfig) foiCalendar {
+	c := foiCalendar{deadlineDays: cfg.DeadlineDays, holidays: make(map[string]bool)}
+	for _, day := range cfg.Holidays {
+		c.holidays[day] = true
+	}
+	return c
+}
+
+// workingDay reports whether t is neither a weekend nor a holiday.
+func (c foiCalendar) workingDay(t time.Time) bool {
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+	return !c.holidays[t.Format(dateLayout)]
+}
+
+// Deadline returns the date the response is due for a request received on
+// the given date. The first working day after receipt is day one.
+func (c foiCalendar) Deadline(received time.Time) time.Time {
+	due := received
+	for n := 0; n < c.deadlineDays; {
+		due = due.AddDate(0, 0, 1)
+		if c.workingDay(due) {
+			n++
+		}
+	}
+	return due
+}
+
+// Holidays returns the configured holidays in date order.
+func (c foiCalendar) Holidays() []string {
+	days := make([]string, 0, len(c.holidays))
+	for day := range c.holidays {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+	return days
+}
+
+// DeadlineDays returns the statutory response time in working days.
+func (c foiCalendar) DeadlineDays() int {
+	return c.deadlineDays
+}
+
+// validateFOIRequest checks a request before it is stored.
+func validateFOIRequest(f FOIRequest) error {
+	if f.Requester == "" {
+		return errors.New("requester is required")
+	}
+	if f.Subject == "" {
+		return errors.New("subject is required")
+	}
+	if f.ReceivedDate.IsZero() {
+		return errors.New("received date is required")
+	}
+	if f.ReceivedDate.After(today()) {
+		return errors.New("received date cannot be in the future")
+	}
+	if _, ok := foiTransitions[f.Status]; !ok {
+		return fmt.Errorf("unknown FOI status %q", f.Status)
+	}
+	return nil
+}
+
+// parseFOIForm reads the FOI request form fields. New requests start as
+// received; the status of an existing request changes through the workflow.
+func parseFOIForm(r *http.Request) (FOIRequest, error) {
+	if err := r.ParseForm(); err != nil {
+		return FOIRequest{}, err
+	}
+	f := FOIRequest{
+		Requester: strings.TrimSpace(r.FormValue("requester")),
+		Contact:   strings.TrimSpace(r.FormValue("contact")),
+		Subject:   strings.TrimSpace(r.FormValue("subject")),
+		Details:   strings.TrimSpace(r.FormValue("details")),
+		Assignee:  strings.TrimSpace(r.FormValue("assignee")),
+		Status:    FOIReceived,
+	}
+	var err error
+	if v := r.FormValue("received-date"); v != "" {
+		if f.ReceivedDate, err = time.Parse(dateLayout, v); err != nil {
+			return f, fmt.Errorf("invalid received date %q", v)
+		}
+	}
+	if f.AssetIDs, err = parseIDs(r.Form["asset-ids"]); err != nil {
+		return f, err
+	}
+	if f.LicenseIDs, err = parseIDs(r.Form["license-ids"]); err != nil {
+		return f, err
+	}
+	if f.ReportRunIDs, err = parseIDs(r.Form["report-run-ids"]); err != nil {
+		return f, err
+	}
+	return f, validateFOIRequest(f)
+}
+
+// foiHandler handles the FOI requests page and new request submissions.
+func (s *server) foiHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		f, err := parseFOIForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.checkLinks(r.Context(), f.AssetIDs, f.LicenseIDs); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.checkReportRuns(r.Context(), f.ReportRunIDs); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.DueDate = s.foiCalendar.Deadline(f.ReceivedDate)
+
+		if _, err := s.foi.Create(r.Context(), f); err != nil {
+			log.Printf("Error inserting FOI request: %v\n", err)
+			http.Error(w, "Error saving FOI request", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/foi", http.StatusSeeOther)
+		return
+	}
+
+	data, err := s.getPageData(r.Context())
+	if err != nil {
+		log.Printf("Error fetching page data: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if data.FOIRequests, err = s.foi.List(r.Context()); err != nil {
+		log.Printf("Error fetching FOI requests: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if data.ReportRuns, err = s.foiReportRuns(r, nil); err != nil {
+		log.Printf("Error fetching report runs: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	renderTemplate(w, r, data)
+}
+
+// editFOIHandler shows an FOI request for editing and saves changes to it.
+func (s *server) editFOIHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	existing, err := s.foi.Get(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		log.Printf("Error fetching FOI request: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		f, err := parseFOIForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.checkLinks(r.Context(), f.AssetIDs, f.LicenseIDs); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.checkReportRuns(r.Context(), f.ReportRunIDs); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.Status = existing.Status
+		f.DueDate = s.foiCalendar.Deadline(f.ReceivedDate)
+
+		if err := s.foi.Update(r.Context(), id, f); err != nil {
+			log.Printf("Error updating FOI request: %v\n", err)
+			http.Error(w, "Error saving FOI request", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("/foi/%d", id), http.StatusSeeOther)
+		return
+	}
+
+	data, err := s.getPageData(r.Context())
+	if err != nil {
+		log.Printf("Error fetching page data: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if data.FOIRequests, err = s.foi.List(r.Context()); err != nil {
+		log.Printf("Error fetching FOI requests: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if data.ReportRuns, err = s.foiReportRuns(r, existing.ReportRunIDs); err != nil {
+		log.Printf("Error fetching report runs: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	data.EditFOI = existing
+	renderTemplate(w, r, data)
+}
+
+// foiReportRuns returns the report runs offered by the FOI request form:
+// the recent runs the user may see plus any older runs already linked, so
+// saving the form does not drop them.
+func (s *server) foiReportRuns(r *http.Request, linked []int) ([]ReportRun, error) {
+	runs, err := s.visibleReportRuns(r)
+	if err != nil {
+		return nil, err
+	}
+	listed := make([]int, 0, len(runs))
+	for _, run := range runs {
+		listed = append(listed, run.ID)
+	}
+	for _, id := range linked {
+		if containsID(listed, id) {
+			continue
+		}
+		run, err := s.reports.GetRun(r.Context(), id)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		run.Result = nil
+		runs = append(runs, *run)
+	}
+	return runs, nil
+}
+
+// foiStatusHandler moves an FOI request to another stage of the workflow.
+func (s *server) foiStatusHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	err = s.foi.Transition(r.Context(), id, FOIStatus(r.FormValue("status")))
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if errors.Is(err, errInvalidTransition) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Printf("Error changing FOI request status: %v\n", err)
+		http.Error(w, "Error saving FOI request", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/foi/%d", id), http.StatusSeeOther)
+}
+
+// deleteFOIHandler removes an FOI request.
+func (s *server) deleteFOIHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	err = s.foi.Delete(r.Context(), id)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		log.Printf("Error deleting FOI request: %v\n", err)
+		http.Error(w, "Error deleting FOI request", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/foi", http.StatusSeeOther)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFOIDeadline(t *testing.T) {
+	tests := []struct {
+		name     string
+		days     int
+		holidays []string
+		received string
+		want     string
+	}{
+		{"twenty working days", 20, nil, "2024-01-01", "2024-01-29"},
+		{"received on a Friday", 1, nil, "2024-01-05", "2024-01-08"},
+		{"received on a Saturday", 1, nil, "2024-01-06", "2024-01-08"},
+		{"holiday after a weekend", 1, []string{"2024-01-08"}, "2024-01-05", "2024-01-09"},
+		{"holidays extend the deadline", 20, []string{"2024-01-02", "2024-01-15"}, "2024-01-01", "2024-01-31"},
+		{"holiday on a weekend", 1, []string{"2024-01-06"}, "2024-01-05", "2024-01-08"},
+	}
+	for _, tt := range tests {
+		c := newFOICalendar(FOIConfig{DeadlineDays: tt.days, Holidays: tt.holidays})
+		received, err := time.Parse(dateLayout, tt.received)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.Deadline(received).Format(dateLayout); got != tt.want {
+			t.Errorf("%s: Deadline(%s) = %s, want %s", tt.name, tt.received, got, tt.want)
+		}
+	}
+}
+
+func TestFOICalendarHolidaysSorted(t *testing.T) {
+	c := newFOICalendar(FOIConfig{DeadlineDays: 20, Holidays: []string{"2024-12-25", "2024-01-01", "2024-05-06"}})
+	got := c.Holidays()
+	want := []string{"2024-01-01", "2024-05-06", "2024-12-25"}
+	if len(got) != len(want) {
+		t.Fatalf("Holidays() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Holidays() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestFOIStatusWorkflow(t *testing.T) {
+	tests := []struct {
+		from, to FOIStatus
+		want     bool
+	}{
+		{FOIReceived, FOIInProgress, true},
+		{FOIReceived, FOIResponded, false},
+		{FOIInProgress, FOIOnHold, true},
+		{FOIOnHold, FOIResponded, false},
+		{FOIResponded, FOIInProgress, true},
+		{FOIClosed, FOIInProgress, false},
+		{FOIWithdrawn, FOIReceived, false},
+	}
+	for _, tt := range tests {
+		if got := tt.from.CanMoveTo(tt.to); got != tt.want {
+			t.Errorf("%s.CanMoveTo(%s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+	for _, s := range []FOIStatus{FOIReceived, FOIInProgress, FOIOnHold} {
+		if !s.Open() {
+			t.Errorf("%s.Open() = false, want true", s)
+		}
+	}
+	for _, s := range []FOIStatus{FOIResponded, FOIClosed, FOIWithdrawn} {
+		if s.Open() {
+			t.Errorf("%s.Open() = true, want false", s)
+		}
+	}
+}
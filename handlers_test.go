@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newMemTestServer returns a server over the in-memory repositories, with
+// its changes recorded in an in-memory audit log.
+func newMemTestServer() *server {
+	assets := newMemAssetRepository()
+	s := &server{
+		assets:        assets,
+		licenses:      newMemLicenseRepository(),
+		software:      newMemSoftwareRepository(),
+		risks:         newMemRiskRepository(),
+		foi:           newMemFOIRepository(),
+		reports:       newMemReportRepository(),
+		notifications: newMemNotificationRepository(),
+		webhooks:      newMemWebhookRepository(),
+		people:        newMemPeopleRepository(),
+		locations:     newMemLocationRepository(assets),
+		assetTypes:    newMemAssetTypeRepository(assets),
+		users:         newMemUserRepository(),
+	}
+	s.auditRepositories(&auditTrail{log: newMemAuditRepository(), tx: memTransactor{}})
+	return s
+}
+
+// signedIn returns the server's routes with every request made by a user
+// holding role, in place of a session cookie.
+func signedIn(s *server, role Role) http.Handler {
+	return s.router(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := &User{ID: 1, Username: "tester", Role: role}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userContextKey, user)))
+		})
+	})
+}
+
+// send serves a request with a form or JSON body; body is sent as a form
+// when it is url.Values and as JSON when it is a string.
+func send(h http.Handler, method, path string, body interface{}) *httptest.ResponseRecorder {
+	var r io.Reader
+	contentType := ""
+	switch b := body.(type) {
+	case url.Values:
+		r, contentType = strings.NewReader(b.Encode()), "application/x-www-form-urlencoded"
+	case string:
+		r, contentType = strings.NewReader(b), "application/json"
+	}
+	req := httptest.NewRequest(method, path, r)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestLicenseHandlersCRUD(t *testing.T) {
+	s := newMemTestServer()
+	h := signedIn(s, RoleLicenseManager)
+	ctx := context.Background()
+
+	rec := send(h, http.MethodPost, "/licenses", url.Values{"name": {"Office"}, "vendor": {"Contoso"}, "quantity": {"10"}, "expiry-date": {"2030-01-31"}})
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("create: status %d, want %d: %s", rec.Code, http.StatusSeeOther, rec.Body)
+	}
+	licenses, err := s.licenses.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(licenses) != 1 || licenses[0].Name != "Office" || licenses[0].Quantity != 10 || licenses[0].Metric != MetricPerUser {
+		t.Fatalf("licenses after create = %+v", licenses)
+	}
+	id := licenses[0].ID
+
+	if rec := send(h, http.MethodPost, "/licenses", url.Values{"vendor": {"Contoso"}}); rec.Code != http.StatusBadRequest {
+		t.Errorf("create without a name: status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if rec := send(h, http.MethodGet, "/licenses", nil); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Office") {
+		t.Errorf("list: status %d, license missing from the page", rec.Code)
+	}
+	if rec := send(h, http.MethodGet, "/licenses/"+strconv.Itoa(id)+"/edit", nil); rec.Code != http.StatusOK {
+		t.Errorf("edit form: status %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if rec := send(h, http.MethodGet, "/licenses/999/edit", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("edit form for a missing license: status %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	rec = send(h, http.MethodPost, "/licenses/"+strconv.Itoa(id)+"/edit", url.Values{"name": {"Office 365"}, "vendor": {"Contoso"}, "quantity": {"25"}, "metric": {string(MetricPerDevice)}})
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("update: status %d, want %d: %s", rec.Code, http.StatusSeeOther, rec.Body)
+	}
+	l, err := s.licenses.Get(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Name != "Office 365" || l.Quantity != 25 || l.Metric != MetricPerDevice {
+		t.Errorf("license after update = %+v", l)
+	}
+	if rec := send(h, http.MethodPost, "/licenses/999/edit", url.Values{"name": {"Missing"}}); rec.Code != http.StatusNotFound {
+		t.Errorf("update of a missing license: status %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	if rec := send(h, http.MethodPost, "/licenses/"+strconv.Itoa(id)+"/delete", nil); rec.Code != http.StatusSeeOther {
+		t.Fatalf("delete: status %d, want %d: %s", rec.Code, http.StatusSeeOther, rec.Body)
+	}
+	if _, err := s.licenses.Get(ctx, id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after delete: err = %v, want ErrNotFound", err)
+	}
+
+	entries, err := s.auditLog.Search(ctx, AuditFilter{Entity: AuditLicense, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("got %d audit entries for the license, want 3", len(entries))
+	}
+}
+
+func TestAssetHandlersCRUD(t *testing.T) {
+	s := newMemTestServer()
+	h := signedIn(s, RoleAssetManager)
+	ctx := context.Background()
+
+	rec := send(h, http.MethodPost, "/assets", url.Values{"name": {"Laptop 1"}, "serial-number": {"SN-1"}, "purchase-cost": {"999.50"}})
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("create: status %d, want %d: %s", rec.Code, http.StatusSeeOther, rec.Body)
+	}
+	assets, err := s.assets.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(assets) != 1 || assets[0].Name != "Laptop 1" || assets[0].PurchaseCost != 999.50 {
+		t.Fatalf("assets after create = %+v", assets)
+	}
+	id := assets[0].ID
+
+	if rec := send(h, http.MethodPost, "/assets", url.Values{"serial-number": {"SN-2"}}); rec.Code != http.StatusBadRequest {
+		t.Errorf("create without a name: status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if rec := send(h, http.MethodGet, "/assets", nil); rec.Code != http.StatusOK {
+		t.Errorf("list: status %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if rec := send(h, http.MethodGet, "/assets/"+strconv.Itoa(id), nil); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Laptop 1") {
+		t.Errorf("detail: status %d, asset missing from the page", rec.Code)
+	}
+	if rec := send(h, http.MethodGet, "/assets/999", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("detail of a missing asset: status %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	// Forms reach the PUT and DELETE routes through methodOverride.
+	rec = send(h, http.MethodPost, "/assets/"+strconv.Itoa(id), url.Values{"_method": {"PUT"}, "name": {"Laptop 1b"}, "serial-number": {"SN-1"}, "model": {"X1"}})
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("update: status %d, want %d: %s", rec.Code, http.StatusSeeOther, rec.Body)
+	}
+	a, err := s.assets.Get(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Name != "Laptop 1b" || a.Model != "X1" {
+		t.Errorf("asset after update = %+v", a)
+	}
+
+	if rec := send(h, http.MethodPost, "/assets/"+strconv.Itoa(id), url.Values{"_method": {"DELETE"}}); rec.Code != http.StatusSeeOther {
+		t.Fatalf("delete: status %d, want %d: %s", rec.Code, http.StatusSeeOther, rec.Body)
+	}
+	if _, err := s.assets.Get(ctx, id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after delete: err = %v, want ErrNotFound", err)
+	}
+	if rec := send(h, http.MethodPost, "/assets/"+strconv.Itoa(id), url.Values{"_method": {"DELETE"}}); rec.Code != http.StatusNotFound {
+		t.Errorf("second delete: status %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestAPIStatusCodes(t *testing.T) {
+	h := signedIn(newMemTestServer(), RoleAdmin)
+
+	// The steps run in order against one server, so later steps see the
+	// records created by earlier ones.
+	steps := []struct {
+		method, path, body string
+		want               int
+		code               string
+	}{
+		{http.MethodPost, "/api/v1/licenses", `{"name": "Office", "vendor": "Contoso", "quantity": 5}`, http.StatusCreated, ""},
+		{http.MethodGet, "/api/v1/licenses", "", http.StatusOK, ""},
+		{http.MethodGet, "/api/v1/licenses/1", "", http.StatusOK, ""},
+		{http.MethodPatch, "/api/v1/licenses/1", `{"quantity": 8}`, http.StatusOK, ""},
+		{http.MethodPut, "/api/v1/licenses/1", `{"name": ""}`, http.StatusUnprocessableEntity, "validation_failed"},
+		{http.MethodPost, "/api/v1/licenses", `{"name": `, http.StatusBadRequest, "invalid_json"},
+		{http.MethodPost, "/api/v1/licenses", `{"name": "Office", "seats": 5}`, http.StatusBadRequest, "invalid_json"},
+		{http.MethodPost, "/api/v1/licenses", `{"vendor": "Contoso"}`, http.StatusUnprocessableEntity, "validation_failed"},
+		{http.MethodPost, "/api/v1/licenses/1/assignments", `{"person_id": 42}`, http.StatusUnprocessableEntity, "validation_failed"},
+		{http.MethodGet, "/api/v1/licenses/99", "", http.StatusNotFound, "not_found"},
+		{http.MethodDelete, "/api/v1/licenses/1", "", http.StatusNoContent, ""},
+		{http.MethodGet, "/api/v1/licenses/1", "", http.StatusNotFound, "not_found"},
+		{http.MethodDelete, "/api/v1/licenses/1", "", http.StatusNotFound, "not_found"},
+
+		{http.MethodPost, "/api/v1/assets", `{"name": "Laptop 1", "serial_number": "SN-1"}`, http.StatusCreated, ""},
+		{http.MethodGet, "/api/v1/assets/1", "", http.StatusOK, ""},
+		{http.MethodPatch, "/api/v1/assets/1", `{"model": "X1"}`, http.StatusOK, ""},
+		{http.MethodPost, "/api/v1/assets", `{"model": "X1"}`, http.StatusUnprocessableEntity, "validation_failed"},
+		{http.MethodGet, "/api/v1/assets/99", "", http.StatusNotFound, "not_found"},
+		{http.MethodDelete, "/api/v1/assets/1", "", http.StatusNoContent, ""},
+		{http.MethodGet, "/api/v1/assets/1", "", http.StatusNotFound, "not_found"},
+
+		{http.MethodGet, "/api/v1/widgets", "", http.StatusNotFound, "not_found"},
+	}
+	for _, st := range steps {
+		var body interface{}
+		if st.body != "" {
+			body = st.body
+		}
+		rec := send(h, st.method, st.path, body)
+		if rec.Code != st.want {
+			t.Errorf("%s %s: status %d, want %d: %s", st.method, st.path, rec.Code, st.want, rec.Body)
+			continue
+		}
+		if st.code == "" {
+			continue
+		}
+		var e apiError
+		if err := json.NewDecoder(rec.Body).Decode(&e); err != nil {
+			t.Errorf("%s %s: decoding error body: %v", st.method, st.path, err)
+			continue
+		}
+		if e.Error.Code != st.code || e.Error.Status != st.want {
+			t.Errorf("%s %s: error = %+v, want code %q", st.method, st.path, e.Error, st.code)
+		}
+	}
+}
+
+func TestAuthorizeDenials(t *testing.T) {
+	tests := []struct {
+		role         Role
+		method, path string
+		want         int
+	}{
+		{RoleViewer, http.MethodGet, "/api/v1/assets", http.StatusOK},
+		{RoleViewer, http.MethodPost, "/api/v1/assets", http.StatusForbidden},
+		{RoleViewer, http.MethodDelete, "/api/v1/assets/1", http.StatusForbidden},
+		{RoleViewer, http.MethodPost, "/api/v1/licenses", http.StatusForbidden},
+		{RoleViewer, http.MethodGet, "/api/v1/audit", http.StatusForbidden},
+		{RoleViewer, http.MethodGet, "/api/v1/foi", http.StatusForbidden},
+		{RoleLicenseManager, http.MethodPost, "/api/v1/assets", http.StatusForbidden},
+		{RoleLicenseManager, http.MethodDelete, "/api/v1/assets/1", http.StatusForbidden},
+		{RoleAssetManager, http.MethodPost, "/api/v1/licenses", http.StatusForbidden},
+		{RoleAssetManager, http.MethodDelete, "/api/v1/licenses/1", http.StatusForbidden},
+		{RoleAssetManager, http.MethodGet, "/api/v1/compliance", http.StatusForbidden},
+		{RoleViewer, http.MethodGet, "/api/v1/compliance", http.StatusForbidden},
+		{RoleLicenseManager, http.MethodGet, "/api/v1/compliance", http.StatusOK},
+		{RoleAuditor, http.MethodGet, "/api/v1/compliance", http.StatusOK},
+		{RoleLicenseManager, http.MethodGet, "/api/v1/audit", http.StatusForbidden},
+		{RoleAuditor, http.MethodGet, "/api/v1/audit", http.StatusOK},
+		{RoleAuditor, http.MethodPost, "/api/v1/licenses", http.StatusForbidden},
+		{RoleFOIOfficer, http.MethodGet, "/api/v1/foi", http.StatusOK},
+		{RoleFOIOfficer, http.MethodGet, "/api/v1/audit", http.StatusForbidden},
+
+		{RoleViewer, http.MethodGet, "/licenses", http.StatusOK},
+		{RoleViewer, http.MethodPost, "/licenses", http.StatusForbidden},
+		{RoleViewer, http.MethodGet, "/licenses/1/edit", http.StatusForbidden},
+		{RoleAssetManager, http.MethodPost, "/licenses/1/delete", http.StatusForbidden},
+		{RoleLicenseManager, http.MethodGet, "/compliance", http.StatusOK},
+		{RoleViewer, http.MethodGet, "/compliance", http.StatusForbidden},
+		{RoleLicenseManager, http.MethodPost, "/assets", http.StatusForbidden},
+		{RoleLicenseManager, http.MethodDelete, "/assets/1", http.StatusForbidden},
+		{RoleAuditor, http.MethodPost, "/users", http.StatusForbidden},
+		{RoleAssetManager, http.MethodPost, "/settings/webhooks", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		s := newMemTestServer()
+		ctx := context.Background()
+		if _, err := s.assets.Create(ctx, Asset{Name: "Laptop 1", State: AssetDeployed}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.licenses.Create(ctx, License{Name: "Office", Quantity: 5, Metric: MetricPerUser}); err != nil {
+			t.Fatal(err)
+		}
+
+		rec := send(signedIn(s, tt.role), tt.method, tt.path, url.Values{"name": {"Changed"}})
+		if rec.Code != tt.want {
+			t.Errorf("%s %s %s: status %d, want %d: %s", tt.role, tt.method, tt.path, rec.Code, tt.want, rec.Body)
+			continue
+		}
+		if tt.want != http.StatusForbidden {
+			continue
+		}
+		if strings.HasPrefix(tt.path, "/api/") {
+			var e apiError
+			if err := json.NewDecoder(rec.Body).Decode(&e); err != nil || e.Error.Code != "forbidden" {
+				t.Errorf("%s %s %s: body %q is not a forbidden API error", tt.role, tt.method, tt.path, rec.Body)
+			}
+		}
+		assets, _ := s.assets.Count(ctx)
+		licenses, _ := s.licenses.Count(ctx)
+		if assets != 1 || licenses != 1 {
+			t.Errorf("%s %s %s: denied request changed the data: %d assets, %d licenses", tt.role, tt.method, tt.path, assets, licenses)
+		}
+	}
+}
+
+func TestAPIUpdateAssetFieldConflict(t *testing.T) {
+	s := newMemTestServer()
+	ctx := context.Background()
+	typeID, err := s.assetTypes.Create(ctx, AssetType{Name: "Monitor", Fields: []AssetField{{Name: "size", Label: "Size", Kind: FieldText}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.assets.Create(ctx, Asset{Name: "screen", TypeID: typeID, State: AssetInStock}); err != nil {
+		t.Fatal(err)
+	}
+	typ, err := s.assetTypes.Get(ctx, typeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := fmt.Sprintf("/api/v1/asset-types/%d/fields/%d", typeID, typ.Fields[0].ID)
+	rec := send(signedIn(s, RoleAssetManager), http.MethodPatch, path, `{"required": true}`)
+	if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), "field_values_conflict") {
+		t.Errorf("requiring a field an asset lacks: status %d: %s; want 409 field_values_conflict", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,538 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// importMaxBytes caps the size of an uploaded CSV file.
+const importMaxBytes = 5 << 20
+
+// importMaxRows caps the number of data rows in one import.
+const importMaxRows = 5000
+
+// importDateLayouts are the date formats accepted in CSV files. Dates are
+// shown as month/day/year on the pages, so that order is accepted too.
+var importDateLayouts = []string{dateLayout, "01/02/2006", "1/2/2006"}
+
+// importField is a record field a CSV column can be mapped to. Name matches
+// the field name used by the corresponding HTML form.
+type importField struct {
+	Name     string
+	Label    string
+	Required bool
+	Date     bool
+	// Aliases are other header names recognised for the field.
+	Aliases []string
+}
+
+// importSpec describes how to import one kind of record.
+type importSpec struct {
+	Kind   string
+	Label  string
+	Fields []importField
+	// extraFields, if set, returns fields defined at run time, which are
+	// offered after Fields.
+	extraFields func(ctx context.Context, s *server) ([]importField, error)
+	// parse builds a record from one row's field values and returns the keys
+	// used to detect duplicates; a record matching any key is a duplicate.
+	parse func(ctx context.Context, s *server, get func(field string) string) (record interface{}, keys []string, err error)
+	// existingKeys returns the duplicate keys of the records already stored.
+	existingKeys func(ctx context.Context, s *server) (map[string]bool, error)
+	// commit stores every record in a single transaction.
+	commit func(ctx context.Context, s *server, records []interface{}) error
+}
+
+// withExtraFields returns the spec with its extra fields added, or the spec
+// itself when it has none.
+func (spec *importSpec) withExtraFields(ctx context.Context, s *server) (*importSpec, error) {
+	if spec.extraFields == nil {
+		return spec, nil
+	}
+	extra, err := spec.extraFields(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	withExtra := *spec
+	withExtra.Fields = append(append([]importField(nil), spec.Fields...), extra...)
+	return &withExtra, nil
+}
+
+// importKey builds a case-insensitive duplicate key from field values.
+func importKey(values ...string) string {
+	for i, v := range values {
+		values[i] = productKey(v)
+	}
+	return strings.Join(values, "\x00")
+}
+
+// assetImportKeys identifies an asset by its serial number and asset tag,
+// or by its name, type and location when it has neither.
+func assetImportKeys(a Asset) []string {
+	var keys []string
+	if a.SerialNumber != "" {
+		keys = append(keys, importKey("serial", a.SerialNumber))
+	}
+	if a.AssetTag != "" {
+		keys = append(keys, importKey("tag", a.AssetTag))
+	}
+	if len(keys) == 0 {
+		keys = append(keys, importKey(a.Name, a.AssetType, a.Location))
+	}
+	return keys
+}
+
+var assetImport = importSpec{
+	Kind:  "assets",
+	Label: "Assets",
+	Fields: []importField{
+		{Name: "name", Label: "Asset Name", Required: true, Aliases: []string{"asset", "asset name"}},
+		{Name: "asset-type", Label: "Asset Type", Aliases: []string{"type", "category"}},
+		{Name: "location", Label: "Location", Aliases: []string{"site"}},
+		{Name: "manufacturer", Label: "Manufacturer", Aliases: []string{"make", "brand", "vendor"}},
+		{Name: "model", Label: "Model", Aliases: []string{"model name"}},
+		{Name: "serial-number", Label: "Serial Number", Aliases: []string{"serial", "serial no", "s/n"}},
+		{Name: "asset-tag", Label: "Asset Tag", Aliases: []string{"tag", "tag number"}},
+		{Name: "purchase-date", Label: "Purchase Date", Date: true, Aliases: []string{"purchased", "date purchased"}},
+		{Name: "purchase-cost", Label: "Purchase Cost", Aliases: []string{"cost", "price"}},
+		{Name: "supplier", Label: "Supplier", Aliases: []string{"reseller"}},
+		{Name: "order-reference", Label: "Order Reference", Aliases: []string{"order", "order number", "po number", "purchase order"}},
+		{Name: "warranty-end", Label: "Warranty End", Date: true, Aliases: []string{"warranty", "warranty expiry", "warranty end date"}},
+		{Name: "state", Label: "State", Aliases: []string{"status", "lifecycle state"}},
+	},
+	// Each custom field of the asset types can be imported as a column
+	// named field-<name>; a row's values are checked against its type.
+	// Fields of different types sharing a name share a column.
+	extraFields: func(ctx context.Context, s *server) ([]importField, error) {
+		types, err := s.assetTypes.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var fields []importField
+		index := make(map[string]int)
+		for _, t := range types {
+			for _, f := range t.Fields {
+				if i, ok := index[f.Name]; ok {
+					fields[i].Aliases = append(fields[i].Aliases, f.Label)
+					continue
+				}
+				index[f.Name] = len(fields)
+				fields = append(fields, importField{Name: "field-" + f.Name, Label: f.Label, Date: f.Kind == FieldDate, Aliases: []string{f.Name}})
+			}
+		}
+		return fields, nil
+	},
+	parse: func(ctx context.Context, s *server, get func(string) string) (interface{}, []string, error) {
+		a, err := parseAssetFields(get)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := s.resolveLocation(ctx, &a); err != nil {
+			return nil, nil, err
+		}
+		if err := s.resolveAssetType(ctx, &a, func(name string) string { return get("field-" + name) }); err != nil {
+			return nil, nil, err
+		}
+		return a, assetImportKeys(a), nil
+	},
+	existingKeys: func(ctx context.Context, s *server) (map[string]bool, error) {
+		assets, err := s.assets.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		keys := make(map[string]bool, len(assets))
+		for _, a := range assets {
+			for _, key := range assetImportKeys(a) {
+				keys[key] = true
+			}
+		}
+		return keys, nil
+	},
+	commit: func(ctx context.Context, s *server, records []interface{}) error {
+		assets := make([]Asset, len(records))
+		for i, rec := range records {
+			assets[i] = rec.(Asset)
+		}
+		ids, err := s.assets.CreateMany(ctx, assets)
+		if err != nil {
+			return err
+		}
+		for i, a := range assets {
+			a.ID = ids[i]
+			s.events.Emit(ctx, EventAssetCreated, toAPIAsset(a))
+		}
+		return nil
+	},
+}
+
+var licenseImport = importSpec{
+	Kind:  "licenses",
+	Label: "Licenses",
+	Fields: []importField{
+		{Name: "name", Label: "License Name", Required: true, Aliases: []string{"license", "license name"}},
+		{Name: "vendor", Label: "Vendor", Aliases: []string{"publisher", "supplier", "manufacturer"}},
+		{Name: "product", Label: "Product", Aliases: []string{"software"}},
+		{Name: "status", Label: "Status"},
+		{Name: "metric", Label: "Metric", Aliases: []string{"license metric", "license type"}},
+		{Name: "quantity", Label: "Quantity", Aliases: []string{"seats", "qty"}},
+		{Name: "unit-cost", Label: "Unit Cost", Aliases: []string{"cost", "price", "unit price"}},
+		{Name: "expiry-date", Label: "Expiry Date", Date: true, Aliases: []string{"expiry", "expires", "end date"}},
+		{Name: "renewal-date", Label: "Renewal Date", Date: true, Aliases: []string{"renewal"}},
+		{Name: "owner-email", Label: "Owner Email", Aliases: []string{"owner", "email"}},
+	},
+	parse: func(ctx context.Context, s *server, get func(string) string) (interface{}, []string, error) {
+		// Accept metric labels such as "Per device" as well as values.
+		metric := get("metric")
+		for _, m := range licenseMetrics {
+			if strings.EqualFold(strings.TrimSpace(metric), m.Label()) {
+				metric = string(m)
+			}
+		}
+		l, err := parseLicenseFields(func(field string) string {
+			if field == "metric" {
+				return strings.ToLower(metric)
+			}
+			return get(field)
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		if l.Status == "" {
+			l.Status = "active"
+		}
+		return l, []string{importKey(l.Name, l.Vendor)}, nil
+	},
+	existingKeys: func(ctx context.Context, s *server) (map[string]bool, error) {
+		licenses, err := s.licenses.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		keys := make(map[string]bool, len(licenses))
+		for _, l := range licenses {
+			keys[importKey(l.Name, l.Vendor)] = true
+		}
+		return keys, nil
+	},
+	commit: func(ctx context.Context, s *server, records []interface{}) error {
+		licenses := make([]License, len(records))
+		for i, rec := range records {
+			licenses[i] = rec.(License)
+		}
+		_, err := s.licenses.CreateMany(ctx, licenses)
+		return err
+	},
+}
+
+// ImportRow is one data row of an import preview.
+type ImportRow struct {
+	// Line is the row's line number in the file, counting the header as 1.
+	Line   int
+	Values []string
+	Errors []string
+}
+
+// ImportPreview is the result of validating a CSV file without storing it.
+type ImportPreview struct {
+	Kind   string
+	Label  string
+	Fields []importField
+	// Headers are the file's column names and Mapping the field each column
+	// is imported into; an empty mapping ignores the column.
+	Headers []string
+	Mapping []string
+	Rows    []ImportRow
+	// CSV is the uploaded file, carried through the preview so the import
+	// can be committed without uploading it again.
+	CSV string
+	// Err is a problem with the file as a whole.
+	Err     string
+	Invalid int
+
+	records []interface{}
+}
+
+// CanCommit reports whether every row is valid and there is something to
+// import.
+func (p *ImportPreview) CanCommit() bool {
+	return p.Err == "" && p.Invalid == 0 && len(p.Rows) > 0
+}
+
+// Valid returns the number of rows that can be imported.
+func (p *ImportPreview) Valid() int {
+	return len(p.Rows) - p.Invalid
+}
+
+// MappedTo returns the field column i is mapped to, for the template.
+func (p *ImportPreview) MappedTo(i int) string {
+	if i < len(p.Mapping) {
+		return p.Mapping[i]
+	}
+	return ""
+}
+
+// normaliseHeader folds a column header or field name for matching.
+func normaliseHeader(h string) string {
+	h = strings.NewReplacer("_", " ", "-", " ").Replace(strings.ToLower(h))
+	return strings.Join(strings.Fields(h), " ")
+}
+
+// autoMap maps each header to the field whose name, label or alias it
+// matches. Each field is used at most once.
+func (spec *importSpec) autoMap(headers []string) []string {
+	mapping := make([]string, len(headers))
+	used := make(map[string]bool)
+	for i, h := range headers {
+		h = normaliseHeader(h)
+		for _, f := range spec.Fields {
+			if used[f.Name] {
+				continue
+			}
+			names := append([]string{f.Name, f.Label}, f.Aliases...)
+			for _, name := range names {
+				if normaliseHeader(name) == h {
+					mapping[i] = f.Name
+					used[f.Name] = true
+					break
+				}
+			}
+			if mapping[i] != "" {
+				break
+			}
+		}
+	}
+	return mapping
+}
+
+func (spec *importSpec) field(name string) (importField, bool) {
+	for _, f := range spec.Fields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return importField{}, false
+}
+
+// normaliseDate rewrites a date in any accepted layout as dateLayout,
+// leaving unrecognised values for the field parser to reject.
+func normaliseDate(v string) string {
+	v = strings.TrimSpace(v)
+	for _, layout := range importDateLayouts {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t.Format(dateLayout)
+		}
+	}
+	return v
+}
+
+// preview parses and validates a CSV file. mapping may be nil to map the
+// columns from their headers.
+func (s *server) previewImport(ctx context.Context, spec *importSpec, data string, mapping []string) *ImportPreview {
+	p := &ImportPreview{Kind: spec.Kind, Label: spec.Label, Fields: spec.Fields, CSV: data}
+
+	cr := csv.NewReader(strings.NewReader(strings.TrimPrefix(data, utf8BOM)))
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	headers, err := cr.Read()
+	if err == io.EOF {
+		p.Err = "the file is empty"
+		return p
+	} else if err != nil {
+		p.Err = err.Error()
+		return p
+	}
+	p.Headers = headers
+
+	if mapping == nil {
+		mapping = spec.autoMap(headers)
+	}
+	p.Mapping = make([]string, len(headers))
+	seen := make(map[string]bool)
+	for i := range headers {
+		if i >= len(mapping) || mapping[i] == "" {
+			continue
+		}
+		if _, ok := spec.field(mapping[i]); !ok {
+			p.Err = fmt.Sprintf("unknown field %q", mapping[i])
+			return p
+		}
+		if seen[mapping[i]] {
+			p.Err = fmt.Sprintf("more than one column is mapped to %q", mapping[i])
+			return p
+		}
+		seen[mapping[i]] = true
+		p.Mapping[i] = mapping[i]
+	}
+	for _, f := range spec.Fields {
+		if f.Required && !seen[f.Name] {
+			p.Err = fmt.Sprintf("no column is mapped to the required field %q", f.Label)
+			return p
+		}
+	}
+
+	existing, err := spec.existingKeys(ctx, s)
+	if err != nil {
+		log.Printf("Error fetching existing %s: %v\n", spec.Kind, err)
+		p.Err = "unable to check for duplicates"
+		return p
+	}
+	firstLine := make(map[string]int)
+
+	for {
+		values, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			p.Err = err.Error()
+			return p
+		}
+		line, _ := cr.FieldPos(0)
+		if len(p.Rows) == importMaxRows {
+			p.Err = fmt.Sprintf("the file has more than %d rows", importMaxRows)
+			return p
+		}
+		if strings.TrimSpace(strings.Join(values, "")) == "" {
+			continue
+		}
+
+		row := ImportRow{Line: line, Values: values}
+		if len(values) != len(headers) {
+			row.Errors = append(row.Errors, fmt.Sprintf("expected %d columns, found %d", len(headers), len(values)))
+		}
+		fields := make(map[string]string)
+		for i, v := range values {
+			if i < len(p.Mapping) && p.Mapping[i] != "" {
+				if f, _ := spec.field(p.Mapping[i]); f.Date {
+					v = normaliseDate(v)
+				}
+				fields[p.Mapping[i]] = v
+			}
+		}
+		record, keys, err := spec.parse(ctx, s, func(field string) string { return fields[field] })
+		if err != nil {
+			row.Errors = append(row.Errors, err.Error())
+		} else if dup := duplicateError(keys, existing, firstLine); dup != "" {
+			row.Errors = append(row.Errors, dup)
+		} else {
+			for _, key := range keys {
+				firstLine[key] = line
+			}
+		}
+
+		if len(row.Errors) > 0 {
+			p.Invalid++
+		} else {
+			p.records = append(p.records, record)
+		}
+		p.Rows = append(p.Rows, row)
+	}
+	if len(p.Rows) == 0 {
+		p.Err = "the file has no data rows"
+	}
+	return p
+}
+
+// duplicateError describes the first key already used by an existing record
+// or an earlier row, or returns "" if the keys are new.
+func duplicateError(keys []string, existing map[string]bool, firstLine map[string]int) string {
+	for _, key := range keys {
+		if existing[key] {
+			return "duplicate of an existing record"
+		}
+		if first, ok := firstLine[key]; ok {
+			return fmt.Sprintf("duplicate of line %d", first)
+		}
+	}
+	return ""
+}
+
+// readImportUpload returns the CSV submitted with the import form: a new
+// upload, or the file carried through an earlier preview.
+func readImportUpload(w http.ResponseWriter, r *http.Request) (string, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, importMaxBytes+1<<20)
+	if err := r.ParseMultipartForm(importMaxBytes); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		return "", err
+	}
+	file, _, err := r.FormFile("file")
+	if errors.Is(err, http.ErrMissingFile) || errors.Is(err, http.ErrNotMultipart) {
+		return r.FormValue("csv"), nil
+	} else if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	b, err := io.ReadAll(io.LimitReader(file, importMaxBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(b) > importMaxBytes {
+		return "", fmt.Errorf("the file is larger than %d MB", importMaxBytes>>20)
+	}
+	return string(b), nil
+}
+
+// importHandler returns the handler for an import page. GET shows the upload
+// form; POST previews the file, and with action=commit stores it when every
+// row is valid.
+func (s *server) importHandler(base *importSpec) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		spec, err := base.withExtraFields(r.Context(), s)
+		if err != nil {
+			log.Printf("Error fetching %s import fields: %v\n", base.Kind, err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		preview := &ImportPreview{Kind: spec.Kind, Label: spec.Label, Fields: spec.Fields}
+
+		if r.Method == http.MethodPost {
+			data, err := readImportUpload(w, r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			// Columns mapped on a previous preview are posted as map-0, map-1...
+			var mapping []string
+			if _, ok := r.Form["map-0"]; ok {
+				for i := 0; ; i++ {
+					v, ok := r.Form[fmt.Sprintf("map-%d", i)]
+					if !ok {
+						break
+					}
+					mapping = append(mapping, v[0])
+				}
+			}
+			preview = s.previewImport(r.Context(), spec, data, mapping)
+
+			if r.FormValue("action") == "commit" && preview.CanCommit() {
+				err := spec.commit(r.Context(), s, preview.records)
+				if errors.Is(err, errDuplicateAsset) {
+					// A deleted asset can still hold a serial number or tag.
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				} else if err != nil {
+					log.Printf("Error importing %s: %v\n", spec.Kind, err)
+					http.Error(w, "Error importing "+spec.Kind, http.StatusInternalServerError)
+					return
+				}
+				log.Printf("Imported %d %s\n", len(preview.records), spec.Kind)
+				http.Redirect(w, r, "/"+spec.Kind, http.StatusSeeOther)
+				return
+			}
+		}
+
+		data, err := s.getPageData(r.Context())
+		if err != nil {
+			log.Printf("Error fetching page data: %v\n", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		data.Import = preview
+		renderTemplate(w, r, data)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestImportAutoMap(t *testing.T) {
+	headers := []string{"License", "Publisher", "Seats", "Expires", "Notes", "license_name"}
+	got := licenseImport.autoMap(headers)
+	want := []string{"name", "vendor", "quantity", "expiry-date", "", ""}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("column %q mapped to %q, want %q", headers[i], got[i], want[i])
+		}
+	}
+}
+
+func TestNormaliseDate(t *testing.T) {
+	tests := map[string]string{
+		"2025-03-31":   "2025-03-31",
+		"03/31/2025":   "2025-03-31",
+		"3/1/2025":     "2025-03-01",
+		" 2025-03-31 ": "2025-03-31",
+		"31/03/2025":   "31/03/2025",
+		"soon":         "soon",
+	}
+	for in, want := range tests {
+		if got := normaliseDate(in); got != want {
+			t.Errorf("normaliseDate(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestPreviewLicenseImport(t *testing.T) {
+	s := &server{licenses: newMemLicenseRepository(License{ID: 1, Name: "Office", Vendor: "Contoso", Metric: MetricPerUser})}
+	data := utf8BOM + "Name,Vendor,Metric,Seats,Expiry Date\n" +
+		"Visio,Contoso,Per device,10,12/31/2025\n" +
+		"office,CONTOSO,per_user,5,\n" +
+		",Contoso,per_user,5,\n" +
+		"Project,Contoso,per_user,many,\n" +
+		"\n" +
+		"Visio,contoso,per_user,2,\n"
+
+	p := s.previewImport(context.Background(), &licenseImport, data, nil)
+	if p.Err != "" {
+		t.Fatalf("preview error: %s", p.Err)
+	}
+	if p.CanCommit() {
+		t.Error("preview with invalid rows can be committed")
+	}
+	wantErrors := []struct {
+		line int
+		err  string
+	}{
+		{2, ""},
+		{3, "duplicate of an existing record"},
+		{4, "license name is required"},
+		{5, "invalid quantity"},
+		{7, "duplicate of line 2"},
+	}
+	if len(p.Rows) != len(wantErrors) {
+		t.Fatalf("got %d rows, want %d", len(p.Rows), len(wantErrors))
+	}
+	for i, want := range wantErrors {
+		row := p.Rows[i]
+		if row.Line != want.line {
+			t.Errorf("row %d: line %d, want %d", i, row.Line, want.line)
+		}
+		got := strings.Join(row.Errors, "; ")
+		if (want.err == "" && got != "") || !strings.Contains(got, want.err) {
+			t.Errorf("line %d: errors %q, want %q", row.Line, got, want.err)
+		}
+	}
+	if p.Invalid != 4 || p.Valid() != 1 {
+		t.Errorf("Invalid = %d, Valid = %d; want 4 and 1", p.Invalid, p.Valid())
+	}
+	l := p.records[0].(License)
+	if l.Metric != MetricPerDevice || l.Quantity != 10 || l.ExpiryDate.Format(dateLayout) != "2025-12-31" || l.Status != "active" {
+		t.Errorf("imported license = %+v", l)
+	}
+}
+
+func TestPreviewImportFileErrors(t *testing.T) {
+	s := &server{licenses: newMemLicenseRepository()}
+	tests := []struct {
+		name    string
+		data    string
+		mapping []string
+		want    string
+	}{
+		{"empty file", "", nil, "the file is empty"},
+		{"header only", "Name,Vendor\n", nil, "the file has no data rows"},
+		{"required field unmapped", "Vendor\nContoso\n", nil, "required field"},
+		{"column mapped twice", "A,B\nx,y\n", []string{"name", "name"}, "more than one column"},
+		{"unknown field", "A\nx\n", []string{"colour"}, "unknown field"},
+	}
+	for _, tt := range tests {
+		p := s.previewImport(context.Background(), &licenseImport, tt.data, tt.mapping)
+		if !strings.Contains(p.Err, tt.want) {
+			t.Errorf("%s: Err = %q, want it to mention %q", tt.name, p.Err, tt.want)
+		}
+		if p.CanCommit() {
+			t.Errorf("%s: preview can be committed", tt.name)
+		}
+	}
+}
+
+func TestImportHandlerCommit(t *testing.T) {
+	licenses := newMemLicenseRepository()
+	s := &server{licenses: licenses}
+	h := s.importHandler(&licenseImport)
+
+	// Columns the headers do not name can be mapped by hand.
+	form := url.Values{"csv": {"Product name,Count\nVisio,10\nProject,5\n"}, "map-0": {"name"}, "map-1": {"quantity"}, "action": {"commit"}}
+	req := httptest.NewRequest(http.MethodPost, "/licenses/import", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	h(rec, req)
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("commit: status %d, want %d: %s", rec.Code, http.StatusSeeOther, rec.Body)
+	}
+	stored, err := licenses.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 2 {
+		t.Fatalf("got %d licenses, want 2", len(stored))
+	}
+	for _, l := range stored {
+		if (l.Name == "Visio" && l.Quantity != 10) || (l.Name == "Project" && l.Quantity != 5) {
+			t.Errorf("imported license = %+v", l)
+		}
+	}
+}
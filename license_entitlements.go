@@ -0,0 +1,205 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// LicenseMetric is the unit in which a license's entitlement is counted.
+type LicenseMetric string
+
+const (
+	MetricPerUser    LicenseMetric = "per_user"
+	MetricPerDevice  LicenseMetric = "per_device"
+	MetricPerCore    LicenseMetric = "per_core"
+	MetricConcurrent LicenseMetric = "concurrent"
+	MetricSite       LicenseMetric = "site"
+)
+
+// licenseMetrics lists every metric in the order offered by the license form.
+var licenseMetrics = []LicenseMetric{MetricPerUser, MetricPerDevice, MetricPerCore, MetricConcurrent, MetricSite}
+
+// Label returns a human readable name for the metric.
+func (m LicenseMetric) Label() string {
+	switch m {
+	case MetricPerDevice:
+		return "Per device"
+	case MetricPerCore:
+		return "Per core"
+	case MetricConcurrent:
+		return "Concurrent users"
+	case MetricSite:
+		return "Site"
+	default:
+		return "Per user"
+	}
+}
+
+// Unlimited reports whether the metric grants unlimited use, so seat counts
+// do not apply.
+func (m LicenseMetric) Unlimited() bool {
+	return m == MetricSite
+}
+
+// validMetric reports whether m is one of the known metrics.
+func validMetric(m LicenseMetric) bool {
+	for _, known := range licenseMetrics {
+		if m == known {
+			return true
+		}
+	}
+	return false
+}
+
+// LicenseAssignment consumes seats of a license for an asset, a person, or
+// both. Quantity is the number of units consumed, e.g. cores for a per-core
+// license.
+type LicenseAssignment struct {
+	ID        int
+	LicenseID int
+	AssetID   int
+	AssetName string
+	// PersonID links a person in the directory and is zero otherwise.
+	// Assignee is free text for anyone else, such as a team.
+	PersonID   int
+	PersonName string
+	Assignee   string
+	Quantity   int
+	AssignedAt time.Time
+}
+
+// Holder names whoever the seats are assigned to, preferring the linked
+// person.
+func (a LicenseAssignment) Holder() string {
+	if a.PersonName != "" {
+		return a.PersonName
+	}
+	return a.Assignee
+}
+
+// Available returns the number of purchased seats not yet consumed. It is
+// negative when the license is over-allocated.
+func (l License) Available() int {
+	return l.Quantity - l.Consumed
+}
+
+// OverAllocated reports whether more seats are consumed than were purchased.
+func (l License) OverAllocated() bool {
+	return !l.Metric.Unlimited() && l.Consumed > l.Quantity
+}
+
+// validateAssignment checks an assignment before it is stored.
+func validateAssignment(a LicenseAssignment) error {
+	if a.AssetID == 0 && a.PersonID == 0 && a.Assignee == "" {
+		return errors.New("an assignment needs an asset, a person or an assignee")
+	}
+	if a.Quantity < 1 {
+		return errors.New("quantity must be at least 1")
+	}
+	return nil
+}
+
+// parseAssignmentForm reads the assignment form fields.
+func parseAssignmentForm(r *http.Request, licenseID int) (LicenseAssignment, error) {
+	a := LicenseAssignment{
+		LicenseID: licenseID,
+		Assignee:  strings.TrimSpace(r.FormValue("assignee")),
+		Quantity:  1,
+	}
+	if v := r.FormValue("asset-id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return a, fmt.Errorf("invalid asset %q", v)
+		}
+		a.AssetID = id
+	}
+	if v := r.FormValue("person-id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return a, fmt.Errorf("invalid person %q", v)
+		}
+		a.PersonID = id
+	}
+	if v := r.FormValue("quantity"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return a, fmt.Errorf("invalid quantity %q", v)
+		}
+		a.Quantity = n
+	}
+	return a, validateAssignment(a)
+}
+
+// addAssignmentHandler assigns seats of a license to an asset or person.
+func (s *server) addAssignmentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	a, err := parseAssignmentForm(r, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if a.AssetID != 0 {
+		if _, err := s.assets.Get(r.Context(), a.AssetID); errors.Is(err, ErrNotFound) {
+			http.Error(w, fmt.Sprintf("asset %d does not exist", a.AssetID), http.StatusBadRequest)
+			return
+		} else if err != nil {
+			log.Printf("Error fetching asset: %v\n", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+	if a.PersonID != 0 {
+		if _, err := s.people.Get(r.Context(), a.PersonID); errors.Is(err, ErrNotFound) {
+			http.Error(w, fmt.Sprintf("person %d does not exist", a.PersonID), http.StatusBadRequest)
+			return
+		} else if err != nil {
+			log.Printf("Error fetching person: %v\n", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	_, err = s.licenses.Assign(r.Context(), a)
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		log.Printf("Error assigning license: %v\n", err)
+		http.Error(w, "Error saving assignment", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/licenses/%d/edit", id), http.StatusSeeOther)
+}
+
+// deleteAssignmentHandler releases the seats held by an assignment.
+func (s *server) deleteAssignmentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	assignmentID, err := strconv.Atoi(mux.Vars(r)["assignment"])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	err = s.licenses.Unassign(r.Context(), id, assignmentID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		log.Printf("Error removing license assignment: %v\n", err)
+		http.Error(w, "Error removing assignment", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/licenses/%d/edit", id), http.StatusSeeOther)
+}
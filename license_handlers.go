@@ -0,0 +1,203 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateLayout is the format used for DATE columns and HTML date inputs.
+const dateLayout = "2006-01-02"
+
+// License mirrors a row of the licenses table.
+type License struct {
+	ID          int
+	Name        string
+	Vendor      string
+	ExpiryDate  time.Time
+	RenewalDate time.Time
+	Status      string
+	// Quantity is the number of seats purchased, counted in Metric units.
+	Quantity int
+	Metric   LicenseMetric
+	// Consumed is the number of seats taken by assignments.
+	Consumed int
+	// Product is the installed software the license covers, matched
+	// against asset inventories. It defaults to Name when empty.
+	Product string
+	// UnitCost is the price of one seat, used to value shortfalls.
+	UnitCost float64
+	// OwnerEmail receives the license's expiry notifications.
+	OwnerEmail string
+}
+
+// CoveredProduct returns the product name used for compliance matching.
+func (l License) CoveredProduct() string {
+	if l.Product != "" {
+		return l.Product
+	}
+	return l.Name
+}
+
+// licenseStatuses lists the values offered by the license form.
+var licenseStatuses = []string{"active", "pending renewal", "expired", "cancelled"}
+
+// parseLicenseForm reads and validates the license form fields.
+func parseLicenseForm(r *http.Request) (License, error) {
+	return parseLicenseFields(r.FormValue)
+}
+
+// parseLicenseFields validates license fields named as in the license form.
+// get returns the value of a field; bulk imports supply CSV columns.
+func parseLicenseFields(get func(field string) string) (License, error) {
+	l := License{
+		Name:    strings.TrimSpace(get("name")),
+		Vendor:  strings.TrimSpace(get("vendor")),
+		Status:  strings.TrimSpace(get("status")),
+		Metric:  LicenseMetric(strings.TrimSpace(get("metric"))),
+		Product: strings.TrimSpace(get("product")),
+	}
+	if l.Name == "" {
+		return l, fmt.Errorf("license name is required")
+	}
+	if l.Metric == "" {
+		l.Metric = MetricPerUser
+	} else if !validMetric(l.Metric) {
+		return l, fmt.Errorf("unknown license metric %q", l.Metric)
+	}
+
+	var err error
+	if v := strings.TrimSpace(get("quantity")); v != "" {
+		if l.Quantity, err = strconv.Atoi(v); err != nil || l.Quantity < 0 {
+			return l, fmt.Errorf("invalid quantity %q", v)
+		}
+	}
+	if v := strings.TrimSpace(get("unit-cost")); v != "" {
+		if l.UnitCost, err = strconv.ParseFloat(v, 64); err != nil || l.UnitCost < 0 {
+			return l, fmt.Errorf("invalid unit cost %q", v)
+		}
+	}
+	if l.OwnerEmail, err = parseEmail(get("owner-email")); err != nil {
+		return l, err
+	}
+	if v := strings.TrimSpace(get("expiry-date")); v != "" {
+		if l.ExpiryDate, err = time.Parse(dateLayout, v); err != nil {
+			return l, fmt.Errorf("invalid expiry date %q", v)
+		}
+	}
+	if v := strings.TrimSpace(get("renewal-date")); v != "" {
+		if l.RenewalDate, err = time.Parse(dateLayout, v); err != nil {
+			return l, fmt.Errorf("invalid renewal date %q", v)
+		}
+	}
+	return l, nil
+}
+
+// licensesHandler handles the license renewals page and new license submissions.
+func (s *server) licensesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		l, err := parseLicenseForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		_, err = s.licenses.Create(r.Context(), l)
+		if err != nil {
+			log.Printf("Error inserting license: %v\n", err)
+			http.Error(w, "Error saving license", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/licenses", http.StatusSeeOther)
+		return
+	}
+
+	data, err := s.getPageData(r.Context())
+	if err != nil {
+		log.Printf("Error fetching page data: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	renderTemplate(w, r, data)
+}
+
+// editLicenseHandler shows the edit form for a license and saves changes to it.
+func (s *server) editLicenseHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		l, err := parseLicenseForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = s.licenses.Update(r.Context(), id, l)
+		if errors.Is(err, ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		} else if err != nil {
+			log.Printf("Error updating license: %v\n", err)
+			http.Error(w, "Error saving license", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/licenses", http.StatusSeeOther)
+		return
+	}
+
+	license, err := s.licenses.Get(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		log.Printf("Error fetching license: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := s.getPageData(r.Context())
+	if err != nil {
+		log.Printf("Error fetching page data: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	data.EditLicense = license
+	data.Assignments, err = s.licenses.Assignments(r.Context(), id)
+	if err != nil {
+		log.Printf("Error fetching license assignments: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	data.People, err = s.people.List(r.Context())
+	if err != nil {
+		log.Printf("Error fetching people: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	renderTemplate(w, r, data)
+}
+
+// deleteLicenseHandler removes a license.
+func (s *server) deleteLicenseHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	err = s.licenses.Delete(r.Context(), id)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		log.Printf("Error deleting license: %v\n", err)
+		http.Error(w, "Error deleting license", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/licenses", http.StatusSeeOther)
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocationKind is the level of a location in the site, building, floor,
+// room hierarchy.
+type LocationKind string
+
+const (
+	LocationSite     LocationKind = "site"
+	LocationBuilding LocationKind = "building"
+	LocationFloor    LocationKind = "floor"
+	LocationRoom     LocationKind = "room"
+)
+
+// locationKinds lists the kinds from the broadest to the narrowest.
+var locationKinds = []LocationKind{LocationSite, LocationBuilding, LocationFloor, LocationRoom}
+
+// Label returns the kind's display name.
+func (k LocationKind) Label() string {
+	if k == "" {
+		return ""
+	}
+	return strings.ToUpper(string(k[:1])) + string(k[1:])
+}
+
+// level returns the kind's position in locationKinds, or -1 for an
+// unknown kind.
+func (k LocationKind) level() int {
+	for i, kind := range locationKinds {
+		if kind == k {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseLocationKind validates a location kind.
+func parseLocationKind(v string) (LocationKind, error) {
+	k := LocationKind(strings.ToLower(strings.TrimSpace(v)))
+	if k.level() < 0 {
+		return k, fmt.Errorf("invalid location kind %q", v)
+	}
+	return k, nil
+}
+
+// locationPathSeparator joins the names in a location's path.
+const locationPathSeparator = " / "
+
+// Location is a site, building, floor or room assets can be kept in.
+type Location struct {
+	ID int
+	// ParentID is zero for sites.
+	ParentID int
+	Kind     LocationKind
+	Name     string
+	// Path joins the names of the location and those it is inside, from
+	// the site down, e.g. "Head Office / North Wing / Floor 2 / 2.14".
+	Path  string
+	Depth int
+	// Assets counts the assets kept directly at the location and Total
+	// those anywhere inside it too.
+	Assets int
+	Total  int
+}
+
+// Indent returns the left padding, in rem, that shows the location's
+// depth in the locations table.
+func (l Location) Indent() float64 {
+	return 1.5 * float64(l.Depth+1)
+}
+
+// AssetMove records an asset being moved between locations. The paths are
+// kept as they were at the time, so the history survives locations being
+// renamed or removed.
+type AssetMove struct {
+	ID      int
+	AssetID int
+	// FromID is zero when the asset had no location.
+	FromID int
+	From   string
+	// ToID is zero when the asset's location was cleared.
+	ToID    int
+	To      string
+	MovedAt time.Time
+	MovedBy string
+}
+
+// errInvalidLocation reports a location that does not fit the hierarchy,
+// or an asset location that does not exist.
+var errInvalidLocation = errors.New("invalid location")
+
+// errLocationInUse reports a location that cannot be deleted because
+// assets or other locations are inside it.
+var errLocationInUse = errors.New("location in use")
+
+// buildLocationTree orders locations depth first, siblings by name, and
+// fills in their Path, Depth and Total from their ParentID and Assets.
+// Locations whose parent is missing are treated as top-level.
+func buildLocationTree(locations []Location) []Location {
+	known := make(map[int]bool, len(locations))
+	for _, l := range locations {
+		known[l.ID] = true
+	}
+	children := make(map[int][]Location)
+	for _, l := range locations {
+		parent := l.ParentID
+		if !known[parent] {
+			parent = 0
+		}
+		children[parent] = append(children[parent], l)
+	}
+	for _, siblings := range children {
+		sort.Slice(siblings, func(i, j int) bool {
+			a, b := strings.ToLower(siblings[i].Name), strings.ToLower(siblings[j].Name)
+			if a != b {
+				return a < b
+			}
+			return siblings[i].ID < siblings[j].ID
+		})
+	}
+
+	tree := make([]Location, 0, len(locations))
+	var walk func(parentID int, path string, depth int) int
+	walk = func(parentID int, path string, depth int) int {
+		total := 0
+		for _, l := range children[parentID] {
+			l.Path, l.Depth = l.Name, depth
+			if path != "" {
+				l.Path = path + locationPathSeparator + l.Name
+			}
+			i := len(tree)
+			tree = append(tree, l)
+			tree[i].Total = l.Assets + walk(l.ID, l.Path, depth+1)
+			total += tree[i].Total
+		}
+		return total
+	}
+	walk(0, "", 0)
+	return tree
+}
+
+// locationSubtree returns the ids of the location and every location
+// inside it, given locations in tree order.
+func locationSubtree(locations []Location, id int) map[int]bool {
+	ids := map[int]bool{id: true}
+	for _, l := range locations {
+		if ids[l.ParentID] {
+			ids[l.ID] = true
+		}
+	}
+	return ids
+}
+
+// normaliseLocationPath folds a path typed as "Site/Building" or
+// "Site > Building" for comparison with Location.Path, ignoring case.
+func normaliseLocationPath(path string) string {
+	parts := strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '>' })
+	for i, p := range parts {
+		parts[i] = strings.ToLower(strings.Join(strings.Fields(p), " "))
+	}
+	return strings.Join(parts, locationPathSeparator)
+}
+
+// findLocation looks a location up by id or, when id is zero, by its full
+// path or a name only one location has, ignoring case.
+func findLocation(locations []Location, id int, ref string) (*Location, error) {
+	if id != 0 {
+		for i := range locations {
+			if locations[i].ID == id {
+				return &locations[i], nil
+			}
+		}
+		return nil, fmt.Errorf("%w: location %d does not exist", errInvalidLocation, id)
+	}
+	want := normaliseLocationPath(ref)
+	var named []*Location
+	for i := range locations {
+		if normaliseLocationPath(locations[i].Path) == want {
+			return &locations[i], nil
+		}
+		if strings.EqualFold(locations[i].Name, strings.TrimSpace(ref)) {
+			named = append(named, &locations[i])
+		}
+	}
+	switch len(named) {
+	case 0:
+		return nil, fmt.Errorf("%w: unknown location %q", errInvalidLocation, ref)
+	case 1:
+		return named[0], nil
+	}
+	return nil, fmt.Errorf("%w: %q matches more than one location; give its full path", errInvalidLocation, ref)
+}
+
+// resolveLocation sets the asset's location from its LocationID or, when
+// that is zero, from Location, a path or name as accepted by findLocation.
+// Location is then set to the full path. An asset with neither has no
+// location.
+func (s *server) resolveLocation(ctx context.Context, a *Asset) error {
+	if a.LocationID == 0 && strings.TrimSpace(a.Location) == "" {
+		a.Location = ""
+		return nil
+	}
+	locations, err := s.locations.List(ctx)
+	if err != nil {
+		return err
+	}
+	l, err := findLocation(locations, a.LocationID, a.Location)
+	if err != nil {
+		return err
+	}
+	a.LocationID, a.Location = l.ID, l.Path
+	return nil
+}
+
+// parseLocationForm reads and validates the location form fields.
+func parseLocationForm(r *http.Request) (Location, error) {
+	l := Location{Name: strings.TrimSpace(r.FormValue("name"))}
+	if l.Name == "" {
+		return l, errors.New("a location name is required")
+	}
+	var err error
+	if l.Kind, err = parseLocationKind(r.FormValue("kind")); err != nil {
+		return l, err
+	}
+	if v := r.FormValue("parent-id"); v != "" {
+		if l.ParentID, err = strconv.Atoi(v); err != nil {
+			return l, fmt.Errorf("invalid parent location %q", v)
+		}
+	}
+	return l, nil
+}
+
+// checkLocation verifies that a location fits the hierarchy: sites are at
+// the top, every other location is inside one of a broader kind, anything
+// inside it stays of a narrower kind and no sibling has the same name,
+// ignoring case. id is zero for a new location.
+func (s *server) checkLocation(ctx context.Context, id int, l Location) error {
+	locations, err := s.locations.List(ctx)
+	if err != nil {
+		return err
+	}
+	if l.Kind == LocationSite && l.ParentID != 0 {
+		return fmt.Errorf("%w: a site cannot be inside another location", errInvalidLocation)
+	}
+	if l.Kind != LocationSite && l.ParentID == 0 {
+		return fmt.Errorf("%w: a %s must be inside another location", errInvalidLocation, l.Kind)
+	}
+	if id != 0 && l.ParentID == id {
+		return fmt.Errorf("%w: a location cannot be inside itself", errInvalidLocation)
+	}
+	if l.ParentID != 0 {
+		parent, err := findLocation(locations, l.ParentID, "")
+		if err != nil {
+			return err
+		}
+		if parent.Kind.level() >= l.Kind.level() {
+			return fmt.Errorf("%w: a %s cannot be inside a %s", errInvalidLocation, l.Kind, parent.Kind)
+		}
+	}
+	for _, other := range locations {
+		if other.ID == id {
+			continue
+		}
+		if id != 0 && other.ParentID == id && other.Kind.level() <= l.Kind.level() {
+			return fmt.Errorf("%w: %s %s is inside it", errInvalidLocation, other.Kind, other.Path)
+		}
+		if other.ParentID == l.ParentID && strings.EqualFold(other.Name, l.Name) {
+			return fmt.Errorf("%w: %s already exists", errInvalidLocation, other.Path)
+		}
+	}
+	return nil
+}
+
+// locationsHandler handles the locations page and new location submissions.
+func (s *server) locationsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		l, err := parseLocationForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.checkLocation(r.Context(), 0, l); errors.Is(err, errInvalidLocation) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if err != nil {
+			log.Printf("Error checking location: %v\n", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if _, err := s.locations.Create(r.Context(), l); err != nil {
+			log.Printf("Error inserting location: %v\n", err)
+			http.Error(w, "Error saving location", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/locations", http.StatusSeeOther)
+		return
+	}
+
+	data, err := s.getPageData(r.Context())
+	if err != nil {
+		log.Printf("Error fetching page data: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	renderTemplate(w, r, data)
+}
+
+// locationHandler shows a location with the assets anywhere inside it.
+func (s *server) locationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	data, err := s.getPageData(r.Context())
+	if err != nil {
+		log.Printf("Error fetching page data: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	data.Location, err = findLocation(data.Locations, id, "")
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	inside := locationSubtree(data.Locations, id)
+	for _, a := range data.Assets {
+		if inside[a.LocationID] {
+			data.LocationAssets = append(data.LocationAssets, a)
+		}
+	}
+	sort.SliceStable(data.LocationAssets, func(i, j int) bool { return data.LocationAssets[i].Location < data.LocationAssets[j].Location })
+	renderTemplate(w, r, data)
+}
+
+// updateLocationHandler saves the edit form on a location's page.
+func (s *server) updateLocationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	l, err := parseLocationForm(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.checkLocation(r.Context(), id, l); errors.Is(err, errInvalidLocation) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Printf("Error checking location: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = s.locations.Update(r.Context(), id, l)
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		log.Printf("Error updating location: %v\n", err)
+		http.Error(w, "Error saving location", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/locations/%d", id), http.StatusSeeOther)
+}
+
+// deleteLocationHandler removes an empty location.
+func (s *server) deleteLocationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	err = s.locations.Delete(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if errors.Is(err, errLocationInUse) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Printf("Error deleting location: %v\n", err)
+		http.Error(w, "Error deleting location", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/locations", http.StatusSeeOther)
+}
@@ -2,11 +2,14 @@ package main
 
 import (
 	"context"
-	"database/sql"
+	"flag"
 	"fmt"
 	"html/template"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -14,14 +17,41 @@ import (
 )
 
 // A global variable to hold our database connection.
-var db *sql.DB
-
-// Define structs for data from the database.
-type License struct {
-	ID         int
-	Name       string
-	Vendor     string
-	ExpiryDate time.Time
+var db *dbConn
+
+// server holds the dependencies shared by the HTTP handlers. Handlers reach
+// storage only through the repositories so they can run against fakes.
+type server struct {
+	assets   AssetRepository
+	licenses LicenseRepository
+	software SoftwareRepository
+	risks    RiskRepository
+	foi      FOIRepository
+	reports  ReportRepository
+	// notifications is the log of license expiry emails sent.
+	notifications NotificationRepository
+	// notify holds the expiry notification settings, for display.
+	notify   NotifyConfig
+	webhooks WebhookRepository
+	people   PeopleRepository
+	// locations is the hierarchy of sites, buildings, floors and rooms.
+	locations LocationRepository
+	// assetTypes are the asset categories and their custom fields.
+	assetTypes AssetTypeRepository
+	// users are the accounts that can sign in, with their sessions.
+	users UserRepository
+	// events delivers webhook events; it is nil outside the server.
+	events *webhookDispatcher
+	// auditLog is the hash chained log of changes, and audit records
+	// changes made outside the repositories, such as to users.
+	auditLog AuditRepository
+	audit    *auditTrail
+	// foiCalendar calculates FOI response deadlines.
+	foiCalendar foiCalendar
+	// backend names the database in use, for the Settings page.
+	backend string
+	// expiryDays is how far ahead licenses count as expiring soon.
+	expiryDays int
 }
 
 // A generic struct to hold all the data for the template
@@ -29,8 +59,68 @@ type PageData struct {
 	TotalAssets      int
 	TotalLicenses    int
 	ExpiringSoon     int
+	HighRiskItems    int
+	OverdueFOI       int
 	UpcomingLicenses []License
 	Assets           []Asset
+	Asset            *Asset
+	AssetLicenses    []AssetLicense
+	AssetHistory     []AuditEntry
+	Software         []InstalledSoftware
+	Licenses         []License
+	EditLicense      *License
+	LicenseStatuses  []string
+	LicenseMetrics   []LicenseMetric
+	Assignments      []LicenseAssignment
+	Compliance       *ComplianceReport
+	Risks            []Risk
+	EditRisk         *Risk
+	RiskScale        []int
+	FOIRequests      []FOIRequest
+	EditFOI          *FOIRequest
+	FOIDeadlineDays  int
+	FOIHolidays      []string
+	// ReportDefinitions are the built-in reports the user may run.
+	ReportDefinitions []ReportDefinition
+	SavedReports      []SavedReport
+	ReportRuns        []ReportRun
+	ReportRun         *ReportRun
+	Import            *ImportPreview
+	Notifications     []ExpiryNotification
+	Notify            *NotifyConfig
+	Webhooks          []Webhook
+	WebhookDeliveries []WebhookDelivery
+	WebhookEvents     []string
+	AuditEntries      []AuditEntry
+	AuditFilter       *AuditFilter
+	// AuditMore reports whether there are entries after this page.
+	AuditMore         bool
+	AuditEntities     []string
+	AuditVerification *AuditVerification
+	// AssetStateCounts are the dashboard's counts of assets per state.
+	AssetStateCounts  []AssetStateCount
+	AssetStateChanges []AssetStateChange
+	NewAssetStates    []AssetState
+	People            []Person
+	Person            *Person
+	Departments       []Department
+	Checkouts         []Checkout
+	AssetCustody      []Checkout
+	OpenCheckouts     map[int]*Checkout
+	Locations         []Location
+	Location          *Location
+	LocationKinds     []LocationKind
+	LocationAssets    []Asset
+	AssetMoves        []AssetMove
+	AssetTypes        []AssetType
+	AssetType         *AssetType
+	FieldKinds        []FieldKind
+	TypeAssets        []Asset
+	CurrentUser       *User
+	Users             []User
+	Roles             []Role
+	DatabaseBackend   string
+	DatabaseBackends  []databaseBackend
 }
 
 const dashboardContent = `
@@ -66,13 +156,35 @@ const dashboardContent = `
                 <ul id="main-nav" class="space-y-4">
                     <li><a href="/" class="block py-2 px-4 rounded-lg text-gray-600 font-medium hover:bg-gray-200 transition-colors duration-200">Dashboard</a></li>
                     <li><a href="/assets" class="block py-2 px-4 rounded-lg text-gray-600 hover:bg-gray-200 transition-colors duration-200">Asset Register</a></li>
-                    <li><a href="#compliance-audits" class="block py-2 px-4 rounded-lg text-gray-600 hover:bg-gray-200 transition-colors duration-200">Compliance Audits</a></li>
-                    <li><a href="#license-renewals" class="block py-2 px-4 rounded-lg text-gray-600 hover:bg-gray-200 transition-colors duration-200">License Renewals</a></li>
-                    <li><a href="#risk-register" class="block py-2 px-4 rounded-lg text-gray-600 hover:bg-gray-200 transition-colors duration-200">Risk Register</a></li>
-                    <li><a href="#report-execution" class="block py-2 px-4 rounded-lg text-gray-600 hover:bg-gray-200 transition-colors duration-200">Report Execution</a></li>
-                    <li><a href="#foi-requests" class="block py-2 px-4 rounded-lg text-gray-600 hover:bg-gray-200 transition-colors duration-200">FOI Requests</a></li>
-                    <li><a href="#settings" class="block py-2 px-4 rounded-lg text-gray-600 hover:bg-gray-200 transition-colors duration-200">Settings</a></li>
+                    <li><a href="/locations" class="block py-2 px-4 rounded-lg text-gray-600 hover:bg-gray-200 transition-colors duration-200">Locations</a></li>
+                    <li><a href="/asset-types" class="block py-2 px-4 rounded-lg text-gray-600 hover:bg-gray-200 transition-colors duration-200">Asset Types</a></li>
+                    <li><a href="/people" class="block py-2 px-4 rounded-lg text-gray-600 hover:bg-gray-200 transition-colors duration-200">People</a></li>
+                    <li><a href="/my-assets" class="block py-2 px-4 rounded-lg text-gray-600 hover:bg-gray-200 transition-colors duration-200">My Assets</a></li>
+                    {{if .Can "compliance:view"}}
+                    <li><a href="/compliance" class="block py-2 px-4 rounded-lg text-gray-600 hover:bg-gray-200 transition-colors duration-200">Compliance Audits</a></li>
+                    {{end}}
+                    {{if .Can "audit:view"}}
+                    <li><a href="/audit" class="block py-2 px-4 rounded-lg text-gray-600 hover:bg-gray-200 transition-colors duration-200">Audit Log</a></li>
+                    {{end}}
+                    <li><a href="/licenses" class="block py-2 px-4 rounded-lg text-gray-600 hover:bg-gray-200 transition-colors duration-200">License Renewals</a></li>
+                    <li><a href="/risks" class="block py-2 px-4 rounded-lg text-gray-600 hover:bg-gray-200 transition-colors duration-200">Risk Register</a></li>
+                    <li><a href="/reports" class="block py-2 px-4 rounded-lg text-gray-600 hover:bg-gray-200 transition-colors duration-200">Report Execution</a></li>
+                    {{if .Can "foi:view"}}
+                    <li><a href="/foi" class="block py-2 px-4 rounded-lg text-gray-600 hover:bg-gray-200 transition-colors duration-200">FOI Requests</a></li>
+                    {{end}}
+                    <li><a href="/settings" class="block py-2 px-4 rounded-lg text-gray-600 hover:bg-gray-200 transition-colors duration-200">Settings</a></li>
+                    {{if .Can "users:manage"}}
+                    <li><a href="/users" class="block py-2 px-4 rounded-lg text-gray-600 hover:bg-gray-200 transition-colors duration-200">Users</a></li>
+                    {{end}}
                 </ul>
+                {{with .CurrentUser}}
+                <div class="mt-8 pt-4 border-t border-gray-200 text-sm text-gray-600">
+                    <p class="mb-2">Signed in as <span class="font-medium text-gray-800">{{.Username}}</span> ({{.Role.Label}})</p>
+                    <form action="/logout" method="post">
+                        <button type="submit" class="text-blue-600 hover:underline">Sign out</button>
+                    </form>
+                </div>
+                {{end}}
             </div>
             <!-- Main Content Area -->
             <div class="md:w-3/4 p-6">
@@ -87,129 +199,2366 @@ const dashboardContent = `
                                 {{range .UpcomingLicenses}}
                                 <li>{{.Name}} license expires on {{.ExpiryDate.Format "01/02/2006"}}</li>
                                 {{else}}
-                                <li>No upcoming license expiries.</li>
+                                <li>No upcoming license expiries.</li>
+                                {{end}}
+                            </ul>
+                        </div>
+                        <!-- Key Information Card -->
+                        <div class="bg-gray-50 p-6 rounded-2xl shadow-sm border border-gray-200">
+                            <h3 class="text-xl font-semibold text-gray-700 mb-4">Key Information</h3>
+                            <ul class="list-disc list-inside space-y-2 text-gray-600">
+                                <li>**Total Assets:** {{.TotalAssets}}</li>
+                                <li>**Total Licenses:** {{.TotalLicenses}}</li>
+                                <li>**Expiring Soon:** {{.ExpiringSoon}}</li>
+                                <li>**High-Risk Items:** {{.HighRiskItems}}</li>
+                                {{if .Can "foi:view"}}
+                                <li>**Overdue FOI Requests:** {{if .OverdueFOI}}<a href="/foi" class="text-red-600 font-medium hover:underline">{{.OverdueFOI}}</a>{{else}}0{{end}}</li>
+                                {{end}}
+                            </ul>
+                        </div>
+                        <!-- Asset Lifecycle Card -->
+                        <div class="bg-gray-50 p-6 rounded-2xl shadow-sm border border-gray-200">
+                            <h3 class="text-xl font-semibold text-gray-700 mb-4">Asset Lifecycle</h3>
+                            <ul class="space-y-2 text-gray-600">
+                                {{range .AssetStateCounts}}
+                                <li class="flex justify-between">
+                                    <span>{{.State.Label}}</span>
+                                    <span class="{{if not .Count}}text-gray-400{{end}}">{{.Count}}</span>
+                                </li>
+                                {{end}}
+                            </ul>
+                        </div>
+                        <!-- License Seats Card -->
+                        <div class="bg-gray-50 p-6 rounded-2xl shadow-sm border border-gray-200">
+                            <h3 class="text-xl font-semibold text-gray-700 mb-4">License Seats</h3>
+                            <ul class="space-y-2 text-gray-600">
+                                {{range .Licenses}}
+                                <li class="flex justify-between">
+                                    <span>{{.Name}}</span>
+                                    {{if .Metric.Unlimited}}
+                                    <span class="text-gray-500">{{.Consumed}} used (site)</span>
+                                    {{else}}
+                                    <span class="{{if .OverAllocated}}text-red-600 font-medium{{end}}">{{.Consumed}} / {{.Quantity}}</span>
+                                    {{end}}
+                                </li>
+                                {{else}}
+                                <li>No licenses recorded.</li>
+                                {{end}}
+                            </ul>
+                        </div>
+                        <!-- Quick Actions Card -->
+                        <div class="bg-gray-50 p-6 rounded-2xl shadow-sm border border-gray-200">
+                            <h3 class="text-xl font-semibold text-gray-700 mb-4">Quick Actions</h3>
+                            <ul class="space-y-2">
+                                {{if .Can "assets:manage"}}
+                                <li><a href="/assets" class="block py-2 px-4 rounded-md text-sm font-medium text-blue-600 bg-blue-100 hover:bg-blue-200 transition-colors duration-200">Add New Asset</a></li>
+                                {{end}}
+                                {{if .Can "compliance:view"}}
+                                <li><a href="/compliance" class="block py-2 px-4 rounded-md text-sm font-medium text-green-600 bg-green-100 hover:bg-green-200 transition-colors duration-200">Run Compliance Report</a></li>
+                                {{end}}
+                                <li><a href="/risks" class="block py-2 px-4 rounded-md text-sm font-medium text-red-600 bg-red-100 hover:bg-red-200 transition-colors duration-200">Review High-Risk Items</a></li>
+                            </ul>
+                        </div>
+                    </div>
+                </div>
+
+                <!-- Asset Register Page -->
+                <div id="assets-page" class="placeholder-page">
+                    <h2 class="text-3xl font-bold text-gray-800 mb-4">Asset Register</h2>
+                    <p class="text-gray-600 mb-6">Add and view a detailed list of all software and hardware assets.</p>
+
+                    <!-- Add New Asset Form -->
+                    {{if .Can "assets:manage"}}
+                    <div class="bg-gray-50 p-6 rounded-2xl shadow-sm border border-gray-200 mb-6">
+                        <h3 class="text-xl font-semibold text-gray-700 mb-4">Add New Asset</h3>
+                        <form action="/assets" method="post" class="space-y-4">
+                            <div>
+                                <label for="name" class="block text-sm font-medium text-gray-700">Asset Name</label>
+                                <input type="text" name="name" id="name" required class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="asset-type" class="block text-sm font-medium text-gray-700">Asset Type</label>
+                                <select name="type-id" id="asset-type" data-asset-types class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                                    <option value="">None</option>
+                                    {{range .AssetTypes}}
+                                    <option value="{{.ID}}">{{.Name}}</option>
+                                    {{end}}
+                                </select>
+                            </div>
+                            {{range .AssetTypes}}
+                            <div data-asset-type="{{.ID}}" class="space-y-4 hidden">
+                                {{range .Fields}}
+                                <div>
+                                    <label for="field-{{.ID}}" class="block text-sm font-medium text-gray-700">{{.Label}}{{if .Required}} *{{end}}</label>
+                                    {{if eq .Kind "enum"}}
+                                    <select name="field-{{.Name}}" id="field-{{.ID}}" disabled {{if .Required}}required{{end}} class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                                        <option value="">{{if .Required}}Choose...{{else}}None{{end}}</option>
+                                        {{range .Options}}
+                                        <option value="{{.}}" >{{.}}</option>
+                                        {{end}}
+                                    </select>
+                                    {{else if eq .Kind "boolean"}}
+                                    <select name="field-{{.Name}}" id="field-{{.ID}}" disabled {{if .Required}}required{{end}} class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                                        <option value="">{{if .Required}}Choose...{{else}}Not recorded{{end}}</option>
+                                        <option value="true" >Yes</option>
+                                        <option value="false" >No</option>
+                                    </select>
+                                    {{else}}
+                                    <input type="{{.InputType}}" name="field-{{.Name}}" id="field-{{.ID}}" {{if eq .Kind "number"}}step="any"{{end}} disabled {{if .Required}}required{{end}} class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                                    {{end}}
+                                </div>
+                                {{end}}
+                            </div>
+                            {{end}}
+                            <div>
+                                <label for="location" class="block text-sm font-medium text-gray-700">Location</label>
+                                <select name="location-id" id="location" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                                    <option value="">None</option>
+                                    {{range .Locations}}
+                                    <option value="{{.ID}}">{{.Path}}</option>
+                                    {{end}}
+                                </select>
+                            </div>
+                            <div>
+                                <label for="state" class="block text-sm font-medium text-gray-700">State</label>
+                                <select name="state" id="state" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                                    {{range .NewAssetStates}}
+                                    <option value="{{.}}" {{if eq . "in_stock"}}selected{{end}}>{{.Label}}</option>
+                                    {{end}}
+                                </select>
+                            </div>
+                            <div>
+                                <label for="manufacturer" class="block text-sm font-medium text-gray-700">Manufacturer</label>
+                                <input type="text" name="manufacturer" id="manufacturer" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="model" class="block text-sm font-medium text-gray-700">Model</label>
+                                <input type="text" name="model" id="model" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="serial-number" class="block text-sm font-medium text-gray-700">Serial Number</label>
+                                <input type="text" name="serial-number" id="serial-number" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="asset-tag" class="block text-sm font-medium text-gray-700">Asset Tag</label>
+                                <input type="text" name="asset-tag" id="asset-tag" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="purchase-date" class="block text-sm font-medium text-gray-700">Purchase Date</label>
+                                <input type="date" name="purchase-date" id="purchase-date" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="purchase-cost" class="block text-sm font-medium text-gray-700">Purchase Cost</label>
+                                <input type="number" min="0" step="0.01" name="purchase-cost" id="purchase-cost" value="0.00" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="supplier" class="block text-sm font-medium text-gray-700">Supplier</label>
+                                <input type="text" name="supplier" id="supplier" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="order-reference" class="block text-sm font-medium text-gray-700">Order Reference</label>
+                                <input type="text" name="order-reference" id="order-reference" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="warranty-end" class="block text-sm font-medium text-gray-700">Warranty End</label>
+                                <input type="date" name="warranty-end" id="warranty-end" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <button type="submit" class="w-full flex justify-center py-2 px-4 border border-transparent rounded-md shadow-sm text-sm font-medium text-white bg-blue-600 hover:bg-blue-700 focus:outline-none focus:ring-2 focus:ring-offset-2 focus:ring-blue-500 transition-colors duration-300">
+                                Save Asset
+                            </button>
+                        </form>
+                    </div>
+                    {{end}}
+
+                    <!-- Assets Table -->
+                    <div class="bg-white p-6 rounded-2xl shadow-sm border border-gray-200 overflow-x-auto">
+                        <div class="flex justify-between items-center mb-4">
+                            <h3 class="text-xl font-semibold text-gray-700">Current Assets</h3>
+                            <div class="space-x-3 text-sm">
+                                {{if .Can "assets:manage"}}<a href="/assets/import" class="text-blue-600 hover:underline">Import CSV</a>{{end}}
+                                <a href="/assets/export?format=csv" class="text-blue-600 hover:underline">Export CSV</a>
+                                <a href="/assets/export?format=xlsx" class="text-blue-600 hover:underline">Export Excel</a>
+                            </div>
+                        </div>
+                        <table class="min-w-full divide-y divide-gray-200">
+                            <thead class="bg-gray-50">
+                                <tr>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Asset Name</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Type</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Location</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Manufacturer / Model</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Serial Number</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Asset Tag</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">State</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Checked Out To</th>
+                                </tr>
+                            </thead>
+                            <tbody class="bg-white divide-y divide-gray-200">
+                                {{range .Assets}}
+                                <tr>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm font-medium text-gray-900"><a href="/assets/{{.ID}}" class="text-blue-600 hover:underline">{{.Name}}</a></td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{if .TypeID}}<a href="/asset-types/{{.TypeID}}" class="text-blue-600 hover:underline">{{.AssetType}}</a>{{end}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{if .LocationID}}<a href="/locations/{{.LocationID}}" class="text-blue-600 hover:underline">{{.Location}}</a>{{end}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.Manufacturer}} {{.Model}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.SerialNumber}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.AssetTag}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.State.Label}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{with index $.OpenCheckouts .ID}}{{.PersonName}}{{if .Overdue}} <span class="text-red-600">(overdue)</span>{{end}}{{end}}</td>
+                                </tr>
+                                {{end}}
+                            </tbody>
+                        </table>
+                    </div>
+                </div>
+
+                <!-- Asset Detail Page -->
+                <div id="asset-detail-page" class="placeholder-page">
+                    {{with .Asset}}
+                    <a href="/assets" class="text-sm text-blue-600 hover:underline">&larr; Back to assets</a>
+                    <h2 class="text-3xl font-bold text-gray-800 mt-2 mb-4">{{.Name}}</h2>
+                    <dl class="grid grid-cols-1 md:grid-cols-4 gap-4 bg-gray-50 p-6 rounded-2xl shadow-sm border border-gray-200 mb-6">
+                        <div>
+                            <dt class="text-sm font-medium text-gray-500">Asset ID</dt>
+                            <dd class="text-gray-900">{{.ID}}</dd>
+                        </div>
+                        <div>
+                            <dt class="text-sm font-medium text-gray-500">Type</dt>
+                            <dd class="text-gray-900">{{if .TypeID}}<a href="/asset-types/{{.TypeID}}" class="text-blue-600 hover:underline">{{.AssetType}}</a>{{end}}</dd>
+                        </div>
+                        <div>
+                            <dt class="text-sm font-medium text-gray-500">State</dt>
+                            <dd class="text-gray-900">{{.State.Label}}</dd>
+                        </div>
+                        <div>
+                            <dt class="text-sm font-medium text-gray-500">Location</dt>
+                            <dd class="text-gray-900">{{if .LocationID}}<a href="/locations/{{.LocationID}}" class="text-blue-600 hover:underline">{{.Location}}</a>{{end}}</dd>
+                        </div>
+                        <div>
+                            <dt class="text-sm font-medium text-gray-500">Manufacturer</dt>
+                            <dd class="text-gray-900">{{.Manufacturer}}</dd>
+                        </div>
+                        <div>
+                            <dt class="text-sm font-medium text-gray-500">Model</dt>
+                            <dd class="text-gray-900">{{.Model}}</dd>
+                        </div>
+                        <div>
+                            <dt class="text-sm font-medium text-gray-500">Serial Number</dt>
+                            <dd class="text-gray-900">{{.SerialNumber}}</dd>
+                        </div>
+                        <div>
+                            <dt class="text-sm font-medium text-gray-500">Asset Tag</dt>
+                            <dd class="text-gray-900">{{.AssetTag}}</dd>
+                        </div>
+                        <div>
+                            <dt class="text-sm font-medium text-gray-500">Purchase Date</dt>
+                            <dd class="text-gray-900">{{if not .PurchaseDate.IsZero}}{{.PurchaseDate.Format "01/02/2006"}}{{end}}</dd>
+                        </div>
+                        <div>
+                            <dt class="text-sm font-medium text-gray-500">Purchase Cost</dt>
+                            <dd class="text-gray-900">{{if .PurchaseCost}}{{printf "%.2f" .PurchaseCost}}{{end}}</dd>
+                        </div>
+                        <div>
+                            <dt class="text-sm font-medium text-gray-500">Supplier</dt>
+                            <dd class="text-gray-900">{{.Supplier}}</dd>
+                        </div>
+                        <div>
+                            <dt class="text-sm font-medium text-gray-500">Order Reference</dt>
+                            <dd class="text-gray-900">{{.OrderReference}}</dd>
+                        </div>
+                        <div>
+                            <dt class="text-sm font-medium text-gray-500">Warranty End</dt>
+                            <dd class="text-gray-900">{{if not .WarrantyEnd.IsZero}}{{.WarrantyEnd.Format "01/02/2006"}}{{end}}</dd>
+                        </div>
+                        <div>
+                            <dt class="text-sm font-medium text-gray-500">Installed Products</dt>
+                            <dd class="text-gray-900">{{len $.Software}}</dd>
+                        </div>
+                        {{range .Fields}}
+                        <div>
+                            <dt class="text-sm font-medium text-gray-500">{{.Label}}</dt>
+                            <dd class="text-gray-900">{{.Display}}</dd>
+                        </div>
+                        {{end}}
+                    </dl>
+
+                    <!-- Lifecycle -->
+                    <div class="bg-white p-6 rounded-2xl shadow-sm border border-gray-200 overflow-x-auto mb-6">
+                        <h3 class="text-xl font-semibold text-gray-700 mb-4">Lifecycle</h3>
+                        <table class="min-w-full divide-y divide-gray-200 mb-4">
+                            <thead class="bg-gray-50">
+                                <tr>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Changed</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">From</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">To</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Reason</th>
+                                </tr>
+                            </thead>
+                            <tbody class="bg-white divide-y divide-gray-200">
+                                {{range $.AssetStateChanges}}
+                                <tr>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.ChangedAt.Format "01/02/2006 15:04"}} UTC</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{if .From}}{{.From.Label}}{{else}}Recorded{{end}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm font-medium text-gray-900">{{.To.Label}}</td>
+                                    <td class="px-6 py-4 text-sm text-gray-500">{{.Reason}}</td>
+                                </tr>
+                                {{end}}
+                            </tbody>
+                        </table>
+                        {{if and ($.Can "assets:manage") .State.Next}}
+                        <form action="/assets/{{.ID}}/state" method="post" class="grid grid-cols-1 md:grid-cols-3 gap-4 items-end">
+                            <div>
+                                <label for="asset-state" class="block text-sm font-medium text-gray-700">Move to</label>
+                                <select name="state" id="asset-state" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                                    {{range .State.Next}}
+                                    <option value="{{.}}">{{.Label}}{{if .RequiresReason}} (reason required){{end}}</option>
+                                    {{end}}
+                                </select>
+                            </div>
+                            <div>
+                                <label for="asset-state-reason" class="block text-sm font-medium text-gray-700">Reason</label>
+                                <input type="text" name="reason" id="asset-state-reason" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <button type="submit" class="py-2 px-4 rounded-md shadow-sm text-sm font-medium text-white bg-blue-600 hover:bg-blue-700">Change State</button>
+                        </form>
+                        {{end}}
+                    </div>
+
+                    <!-- Custody -->
+                    <div class="bg-white p-6 rounded-2xl shadow-sm border border-gray-200 overflow-x-auto mb-6">
+                        <h3 class="text-xl font-semibold text-gray-700 mb-4">Custody</h3>
+                        {{with index $.OpenCheckouts .ID}}
+                        <p class="text-gray-700 mb-4">Checked out to <a href="/people/{{.PersonID}}" class="text-blue-600 hover:underline">{{.PersonName}}</a> since {{.CheckedOutAt.Format "01/02/2006"}}{{if not .ExpectedReturn.IsZero}}, expected back {{.ExpectedReturn.Format "01/02/2006"}}{{if .Overdue}} <span class="px-2 inline-flex text-xs leading-5 font-semibold rounded-full bg-red-100 text-red-800">Overdue</span>{{end}}{{end}}.</p>
+                        {{if $.Can "assets:manage"}}
+                        <form action="/assets/{{.AssetID}}/checkin" method="post" class="grid grid-cols-1 md:grid-cols-3 gap-4 items-end mb-4">
+                            <div class="md:col-span-2">
+                                <label for="return-notes" class="block text-sm font-medium text-gray-700">Return Notes</label>
+                                <input type="text" name="return-notes" id="return-notes" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <button type="submit" class="py-2 px-4 rounded-md shadow-sm text-sm font-medium text-white bg-blue-600 hover:bg-blue-700">Check In</button>
+                        </form>
+                        {{end}}
+                        {{else}}
+                        <p class="text-gray-700 mb-4">Not checked out.</p>
+                        {{if and ($.Can "assets:manage") .State.CanCheckOut $.People}}
+                        <form action="/assets/{{.ID}}/checkout" method="post" class="grid grid-cols-1 md:grid-cols-4 gap-4 items-end mb-4">
+                            <div>
+                                <label for="checkout-person" class="block text-sm font-medium text-gray-700">Person</label>
+                                <select name="person-id" id="checkout-person" required class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                                    {{range $.People}}
+                                    <option value="{{.ID}}">{{.Name}}{{if .Department}} ({{.Department}}){{end}}</option>
+                                    {{end}}
+                                </select>
+                            </div>
+                            <div>
+                                <label for="expected-return" class="block text-sm font-medium text-gray-700">Expected Return</label>
+                                <input type="date" name="expected-return" id="expected-return" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="checkout-notes" class="block text-sm font-medium text-gray-700">Notes</label>
+                                <input type="text" name="notes" id="checkout-notes" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <button type="submit" class="py-2 px-4 rounded-md shadow-sm text-sm font-medium text-white bg-blue-600 hover:bg-blue-700">Check Out</button>
+                        </form>
+                        {{end}}
+                        {{end}}
+                        <table class="min-w-full divide-y divide-gray-200">
+                            <thead class="bg-gray-50">
+                                <tr>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Person</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Checked Out</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Expected Return</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Checked In</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Notes</th>
+                                </tr>
+                            </thead>
+                            <tbody class="bg-white divide-y divide-gray-200">
+                                {{range $.AssetCustody}}
+                                <tr>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm font-medium text-gray-900">{{if .PersonID}}<a href="/people/{{.PersonID}}" class="text-blue-600 hover:underline">{{.PersonName}}</a>{{else}}{{.PersonName}}{{end}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.CheckedOutAt.Format "01/02/2006 15:04"}} UTC{{if .CheckedOutBy}} by {{.CheckedOutBy}}{{end}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{if not .ExpectedReturn.IsZero}}{{.ExpectedReturn.Format "01/02/2006"}}{{end}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{if .Open}}Checked out{{else}}{{.CheckedInAt.Format "01/02/2006 15:04"}} UTC{{if .CheckedInBy}} by {{.CheckedInBy}}{{end}}{{end}}</td>
+                                    <td class="px-6 py-4 text-sm text-gray-500">{{.Notes}}{{if and .Notes .ReturnNotes}}; {{end}}{{if .ReturnNotes}}returned: {{.ReturnNotes}}{{end}}</td>
+                                </tr>
+                                {{else}}
+                                <tr>
+                                    <td colspan="5" class="px-6 py-4 text-sm text-gray-500">This asset has never been checked out.</td>
+                                </tr>
+                                {{end}}
+                            </tbody>
+                        </table>
+                    </div>
+
+                    <!-- Location History -->
+                    <div class="bg-white p-6 rounded-2xl shadow-sm border border-gray-200 overflow-x-auto mb-6">
+                        <h3 class="text-xl font-semibold text-gray-700 mb-4">Location History</h3>
+                        <table class="min-w-full divide-y divide-gray-200">
+                            <thead class="bg-gray-50">
+                                <tr>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Moved</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">From</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">To</th>
+                                </tr>
+                            </thead>
+                            <tbody class="bg-white divide-y divide-gray-200">
+                                {{range $.AssetMoves}}
+                                <tr>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.MovedAt.Format "01/02/2006 15:04"}} UTC{{if .MovedBy}} by {{.MovedBy}}{{end}}</td>
+                                    <td class="px-6 py-4 text-sm text-gray-500">{{if .FromID}}{{.From}}{{else if .From}}{{.From}} (deleted){{else}}No location{{end}}</td>
+                                    <td class="px-6 py-4 text-sm font-medium text-gray-900">{{if .ToID}}<a href="/locations/{{.ToID}}" class="text-blue-600 hover:underline">{{.To}}</a>{{else if .To}}{{.To}} (deleted){{else}}No location{{end}}</td>
+                                </tr>
+                                {{else}}
+                                <tr>
+                                    <td colspan="3" class="px-6 py-4 text-sm text-gray-500">This asset has never had a location.</td>
+                                </tr>
+                                {{end}}
+                            </tbody>
+                        </table>
+                    </div>
+
+                    <!-- Related Licenses -->
+                    <div class="bg-white p-6 rounded-2xl shadow-sm border border-gray-200 overflow-x-auto mb-6">
+                        <h3 class="text-xl font-semibold text-gray-700 mb-4">Related Licenses</h3>
+                        <table class="min-w-full divide-y divide-gray-200">
+                            <thead class="bg-gray-50">
+                                <tr>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">License</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Status</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Expiry Date</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Seats Assigned</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Covers Installed</th>
+                                </tr>
+                            </thead>
+                            <tbody class="bg-white divide-y divide-gray-200">
+                                {{range $.AssetLicenses}}
+                                <tr>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm font-medium text-gray-900">{{if $.Can "licenses:manage"}}<a href="/licenses/{{.License.ID}}/edit" class="text-blue-600 hover:underline">{{.License.Name}}</a>{{else}}{{.License.Name}}{{end}}{{if .License.Vendor}} <span class="text-gray-500">({{.License.Vendor}})</span>{{end}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.License.Status}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{if not .License.ExpiryDate.IsZero}}{{.License.ExpiryDate.Format "01/02/2006"}}{{end}}</td>
+                                    <td class="px-6 py-4 text-sm text-gray-500">{{range $i, $a := .Assignments}}{{if $i}}, {{end}}{{$a.Quantity}}{{with $a.Holder}} for {{.}}{{end}}{{end}}</td>
+                                    <td class="px-6 py-4 text-sm text-gray-500">{{range $i, $p := .Products}}{{if $i}}, {{end}}{{$p}}{{end}}</td>
+                                </tr>
+                                {{else}}
+                                <tr>
+                                    <td colspan="5" class="px-6 py-4 text-sm text-gray-500">No licenses are assigned to this asset or cover its software.</td>
+                                </tr>
+                                {{end}}
+                            </tbody>
+                        </table>
+                    </div>
+
+                    <!-- Installed Software -->
+                    <div class="bg-white p-6 rounded-2xl shadow-sm border border-gray-200 overflow-x-auto mb-6">
+                        <h3 class="text-xl font-semibold text-gray-700 mb-4">Installed Software</h3>
+                        <table class="min-w-full divide-y divide-gray-200">
+                            <thead class="bg-gray-50">
+                                <tr>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Product</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Publisher</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Version</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Installed</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Source</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Last Seen</th>
+                                    <th scope="col" class="px-6 py-3"></th>
+                                </tr>
+                            </thead>
+                            <tbody class="bg-white divide-y divide-gray-200">
+                                {{range $.Software}}
+                                <tr>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm font-medium text-gray-900">{{.Product}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.Publisher}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.Version}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{if not .InstallDate.IsZero}}{{.InstallDate.Format "01/02/2006"}}{{end}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.Source}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.LastSeen.Format "01/02/2006 15:04"}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-right">
+                                        {{if $.Can "assets:manage"}}
+                                        <form action="/assets/{{.AssetID}}/software/{{.ID}}/delete" method="post" onsubmit="return confirm('Remove this software record?');">
+                                            <button type="submit" class="text-red-600 hover:underline">Remove</button>
+                                        </form>
+                                        {{end}}
+                                    </td>
+                                </tr>
+                                {{else}}
+                                <tr>
+                                    <td colspan="7" class="px-6 py-4 text-sm text-gray-500">No software recorded for this asset.</td>
+                                </tr>
+                                {{end}}
+                            </tbody>
+                        </table>
+                    </div>
+
+                    {{if $.Can "assets:manage"}}
+                    <div class="bg-gray-50 p-6 rounded-2xl shadow-sm border border-gray-200">
+                        <h3 class="text-xl font-semibold text-gray-700 mb-4">Add Installed Software</h3>
+                        <form action="/assets/{{.ID}}/software" method="post" class="grid grid-cols-1 md:grid-cols-5 gap-4 items-end">
+                            <div>
+                                <label for="product" class="block text-sm font-medium text-gray-700">Product</label>
+                                <input type="text" name="product" id="product" required class="mt-1 block w-full rounded-md border-gray-300 shadow-sm sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="publisher" class="block text-sm font-medium text-gray-700">Publisher</label>
+                                <input type="text" name="publisher" id="publisher" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="version" class="block text-sm font-medium text-gray-700">Version</label>
+                                <input type="text" name="version" id="version" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="install-date" class="block text-sm font-medium text-gray-700">Install Date</label>
+                                <input type="date" name="install-date" id="install-date" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm sm:text-sm">
+                            </div>
+                            <button type="submit" class="py-2 px-4 rounded-md shadow-sm text-sm font-medium text-white bg-blue-600 hover:bg-blue-700">Add</button>
+                        </form>
+                        <p class="text-xs text-gray-500 mt-3">Discovery tools can report inventory with <code>POST /api/v1/assets/{{.ID}}/software</code>.</p>
+                    </div>
+
+                    <!-- Edit Asset -->
+                    <div class="bg-gray-50 p-6 rounded-2xl shadow-sm border border-gray-200 mt-6">
+                        <h3 class="text-xl font-semibold text-gray-700 mb-4">Edit Asset</h3>
+                        <form action="/assets/{{.ID}}" method="post" class="space-y-4">
+                            <input type="hidden" name="_method" value="PUT">
+                            <div>
+                                <label for="edit-asset-name" class="block text-sm font-medium text-gray-700">Asset Name</label>
+                                <input type="text" name="name" id="edit-asset-name" value="{{.Name}}" required class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="edit-asset-type" class="block text-sm font-medium text-gray-700">Asset Type</label>
+                                <select name="type-id" id="edit-asset-type" data-asset-types class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                                    <option value="">None</option>
+                                    {{$asset := .}}
+                                    {{range $.AssetTypes}}
+                                    <option value="{{.ID}}" {{if eq .ID $asset.TypeID}}selected{{end}}>{{.Name}}</option>
+                                    {{end}}
+                                </select>
+                            </div>
+                            {{range $.AssetTypes}}
+                            <div data-asset-type="{{.ID}}" class="space-y-4 hidden">
+                                {{range .Fields}}
+                                {{$value := $asset.Field .Name}}
+                                <div>
+                                    <label for="edit-field-{{.ID}}" class="block text-sm font-medium text-gray-700">{{.Label}}{{if .Required}} *{{end}}</label>
+                                    {{if eq .Kind "enum"}}
+                                    <select name="field-{{.Name}}" id="edit-field-{{.ID}}" disabled {{if .Required}}required{{end}} class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                                        <option value="">{{if .Required}}Choose...{{else}}None{{end}}</option>
+                                        {{range .Options}}
+                                        <option value="{{.}}" {{if eq $value .}}selected{{end}}>{{.}}</option>
+                                        {{end}}
+                                    </select>
+                                    {{else if eq .Kind "boolean"}}
+                                    <select name="field-{{.Name}}" id="edit-field-{{.ID}}" disabled {{if .Required}}required{{end}} class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                                        <option value="">{{if .Required}}Choose...{{else}}Not recorded{{end}}</option>
+                                        <option value="true" {{if eq $value "true"}}selected{{end}}>Yes</option>
+                                        <option value="false" {{if eq $value "false"}}selected{{end}}>No</option>
+                                    </select>
+                                    {{else}}
+                                    <input type="{{.InputType}}" name="field-{{.Name}}" id="edit-field-{{.ID}}" value="{{$value}}" {{if eq .Kind "number"}}step="any"{{end}} disabled {{if .Required}}required{{end}} class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                                    {{end}}
+                                </div>
+                                {{end}}
+                            </div>
+                            {{end}}
+                            <div>
+                                <label for="edit-asset-location" class="block text-sm font-medium text-gray-700">Location</label>
+                                <select name="location-id" id="edit-asset-location" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                                    <option value="">None</option>
+                                    {{$location := .LocationID}}
+                                    {{range $.Locations}}
+                                    <option value="{{.ID}}" {{if eq .ID $location}}selected{{end}}>{{.Path}}</option>
+                                    {{end}}
+                                </select>
+                            </div>
+                            <div>
+                                <label for="edit-asset-manufacturer" class="block text-sm font-medium text-gray-700">Manufacturer</label>
+                                <input type="text" name="manufacturer" id="edit-asset-manufacturer" value="{{.Manufacturer}}" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="edit-asset-model" class="block text-sm font-medium text-gray-700">Model</label>
+                                <input type="text" name="model" id="edit-asset-model" value="{{.Model}}" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="edit-asset-serial-number" class="block text-sm font-medium text-gray-700">Serial Number</label>
+                                <input type="text" name="serial-number" id="edit-asset-serial-number" value="{{.SerialNumber}}" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="edit-asset-asset-tag" class="block text-sm font-medium text-gray-700">Asset Tag</label>
+                                <input type="text" name="asset-tag" id="edit-asset-asset-tag" value="{{.AssetTag}}" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="edit-asset-purchase-date" class="block text-sm font-medium text-gray-700">Purchase Date</label>
+                                <input type="date" name="purchase-date" id="edit-asset-purchase-date" value="{{if not .PurchaseDate.IsZero}}{{.PurchaseDate.Format "2006-01-02"}}{{end}}" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="edit-asset-purchase-cost" class="block text-sm font-medium text-gray-700">Purchase Cost</label>
+                                <input type="number" min="0" step="0.01" name="purchase-cost" id="edit-asset-purchase-cost" value="{{printf "%.2f" .PurchaseCost}}" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="edit-asset-supplier" class="block text-sm font-medium text-gray-700">Supplier</label>
+                                <input type="text" name="supplier" id="edit-asset-supplier" value="{{.Supplier}}" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="edit-asset-order-reference" class="block text-sm font-medium text-gray-700">Order Reference</label>
+                                <input type="text" name="order-reference" id="edit-asset-order-reference" value="{{.OrderReference}}" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="edit-asset-warranty-end" class="block text-sm font-medium text-gray-700">Warranty End</label>
+                                <input type="date" name="warranty-end" id="edit-asset-warranty-end" value="{{if not .WarrantyEnd.IsZero}}{{.WarrantyEnd.Format "2006-01-02"}}{{end}}" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <button type="submit" class="py-2 px-4 rounded-md shadow-sm text-sm font-medium text-white bg-blue-600 hover:bg-blue-700">Save Changes</button>
+                        </form>
+                    </div>
+                    {{end}}
+
+                    <!-- Asset History -->
+                    <div class="bg-white p-6 rounded-2xl shadow-sm border border-gray-200 overflow-x-auto mt-6">
+                        <h3 class="text-xl font-semibold text-gray-700 mb-4">History</h3>
+                        <ul class="divide-y divide-gray-200">
+                            {{range $.AssetHistory}}
+                            <li class="py-2 text-sm">
+                                <p class="text-gray-900"><span class="font-medium">{{.Action}}</span> by {{.Actor}} <span class="text-gray-500">on {{.OccurredAt.Format "01/02/2006 15:04"}} UTC</span></p>
+                                {{if ne .Action "create"}}
+                                <ul class="mt-1 text-gray-500">
+                                    {{range .Changes}}
+                                    <li class="break-all">{{if .Field}}{{.Field}}: {{end}}{{if .Before}}<span class="line-through text-red-600">{{.Before}}</span>{{end}}{{if and .Before .After}} &rarr; {{end}}{{if .After}}<span class="text-green-700">{{.After}}</span>{{end}}</li>
+                                    {{end}}
+                                </ul>
+                                {{end}}
+                            </li>
+                            {{else}}
+                            <li class="py-2 text-sm text-gray-500">No changes have been recorded.</li>
+                            {{end}}
+                        </ul>
+                        {{if $.Can "audit:view"}}<a href="/audit?entity=asset&amp;entity_id={{.ID}}" class="inline-block mt-3 text-sm text-blue-600 hover:underline">Open in audit log</a>{{end}}
+                    </div>
+
+                    {{if $.Can "assets:manage"}}
+                    <!-- Delete Asset -->
+                    <div class="bg-red-50 p-6 rounded-2xl shadow-sm border border-red-200 mt-6">
+                        <h3 class="text-xl font-semibold text-red-700 mb-2">Delete Asset</h3>
+                        <p class="text-sm text-red-700 mb-4">The asset is removed from the register, checked back in if anyone holds it, its license seats are released and its software inventory is dropped. Its record and history are kept for the audit trail.</p>
+                        <form action="/assets/{{.ID}}" method="post" onsubmit="return confirm('Delete {{.Name}}? Its license seats will be released and its software inventory dropped.');">
+                            <input type="hidden" name="_method" value="DELETE">
+                            <button type="submit" class="py-2 px-4 rounded-md shadow-sm text-sm font-medium text-white bg-red-600 hover:bg-red-700">Delete Asset</button>
+                        </form>
+                    </div>
+                    {{end}}
+                    {{end}}
+                </div>
+
+                <!-- Compliance Audits Page -->
+                <div id="compliance-audits-page" class="placeholder-page">
+                    <h2 class="text-3xl font-bold text-gray-800 mb-4">Compliance Audits</h2>
+                    <p class="text-gray-600 mb-6">Reconcile license entitlements against installed software and seat assignments to find the effective license position of every product.</p>
+                    {{with .Compliance}}
+                    <div class="grid grid-cols-1 md:grid-cols-4 gap-6 mb-6">
+                        <div class="bg-green-50 p-6 rounded-2xl shadow-sm border border-green-200">
+                            <p class="text-sm text-green-700">Compliant</p>
+                            <p class="text-3xl font-bold text-green-800">{{.Compliant}}</p>
+                        </div>
+                        <div class="bg-red-50 p-6 rounded-2xl shadow-sm border border-red-200">
+                            <p class="text-sm text-red-700">Under-licensed</p>
+                            <p class="text-3xl font-bold text-red-800">{{.UnderLicensed}}</p>
+                        </div>
+                        <div class="bg-yellow-50 p-6 rounded-2xl shadow-sm border border-yellow-200">
+                            <p class="text-sm text-yellow-700">Over-licensed</p>
+                            <p class="text-3xl font-bold text-yellow-800">{{.OverLicensed}}</p>
+                        </div>
+                        <div class="bg-gray-50 p-6 rounded-2xl shadow-sm border border-gray-200">
+                            <p class="text-sm text-gray-600">Financial Exposure</p>
+                            <p class="text-3xl font-bold text-gray-800">{{printf "%.2f" .TotalExposure}}</p>
+                        </div>
+                    </div>
+
+                    <div class="bg-white p-6 rounded-2xl shadow-sm border border-gray-200 overflow-x-auto">
+                        <div class="flex justify-between items-center mb-4">
+                            <h3 class="text-xl font-semibold text-gray-700">Effective License Position</h3>
+                            <a href="/compliance" class="py-2 px-4 rounded-md text-sm font-medium text-white bg-blue-600 hover:bg-blue-700">Run Again</a>
+                        </div>
+                        <table class="min-w-full divide-y divide-gray-200">
+                            <thead class="bg-gray-50">
+                                <tr>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Product</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Position</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Entitled</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Installed</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Assigned</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Shortfall</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Surplus</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Exposure</th>
+                                </tr>
+                            </thead>
+                            <tbody class="bg-white divide-y divide-gray-200">
+                                {{range .Products}}
+                                <tr>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm font-medium text-gray-900">{{.Product}}<div class="text-xs font-normal text-gray-500">{{.Metric.Label}}</div></td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm">
+                                        <span class="px-2 inline-flex text-xs leading-5 font-semibold rounded-full {{if eq .Position "under_licensed"}}bg-red-100 text-red-800{{else if eq .Position "over_licensed"}}bg-yellow-100 text-yellow-800{{else}}bg-green-100 text-green-800{{end}}">{{.Position.Label}}</span>
+                                    </td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{if .Unlimited}}Unlimited{{else}}{{.Entitled}}{{end}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.Installations}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.Assigned}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.Shortfall}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.Surplus}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm {{if .Exposure}}text-red-600 font-medium{{else}}text-gray-500{{end}}">{{printf "%.2f" .Exposure}}</td>
+                                </tr>
+                                {{else}}
+                                <tr>
+                                    <td colspan="8" class="px-6 py-4 text-sm text-gray-500">No licenses to reconcile.</td>
+                                </tr>
+                                {{end}}
+                            </tbody>
+                        </table>
+                        <p class="text-xs text-gray-500 mt-3">Generated {{.GeneratedAt.Format "01/02/2006 15:04"}} UTC. Demand is the installations for per-device licenses and the assigned seats, users or cores for the others, but at least one per installation not covered by a per-device license; expired and cancelled licenses grant no entitlement.</p>
+                    </div>
+                    {{end}}
+                </div>
+                <!-- License Renewals Page -->
+                <div id="license-renewals-page" class="placeholder-page">
+                    <h2 class="text-3xl font-bold text-gray-800 mb-4">License Renewals</h2>
+                    <p class="text-gray-600 mb-6">Add, update and remove software licenses and track their renewals and expiries.</p>
+
+                    <!-- Add / Edit License Form -->
+                    {{if .Can "licenses:manage"}}
+                    <div class="bg-gray-50 p-6 rounded-2xl shadow-sm border border-gray-200 mb-6">
+                        {{with .EditLicense}}
+                        <h3 class="text-xl font-semibold text-gray-700 mb-4">Edit License</h3>
+                        <form action="/licenses/{{.ID}}/edit" method="post" class="space-y-4">
+                        {{else}}
+                        <h3 class="text-xl font-semibold text-gray-700 mb-4">Add New License</h3>
+                        <form action="/licenses" method="post" class="space-y-4">
+                        {{end}}
+                            <div>
+                                <label for="license-name" class="block text-sm font-medium text-gray-700">License Name</label>
+                                <input type="text" name="name" id="license-name" required value="{{with .EditLicense}}{{.Name}}{{end}}" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="vendor" class="block text-sm font-medium text-gray-700">Vendor</label>
+                                <input type="text" name="vendor" id="vendor" value="{{with .EditLicense}}{{.Vendor}}{{end}}" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="expiry-date" class="block text-sm font-medium text-gray-700">Expiry Date</label>
+                                <input type="date" name="expiry-date" id="expiry-date" value="{{with .EditLicense}}{{if not .ExpiryDate.IsZero}}{{.ExpiryDate.Format "2006-01-02"}}{{end}}{{end}}" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="renewal-date" class="block text-sm font-medium text-gray-700">Renewal Date</label>
+                                <input type="date" name="renewal-date" id="renewal-date" value="{{with .EditLicense}}{{if not .RenewalDate.IsZero}}{{.RenewalDate.Format "2006-01-02"}}{{end}}{{end}}" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="status" class="block text-sm font-medium text-gray-700">Status</label>
+                                <select name="status" id="status" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                                    {{$current := ""}}{{with .EditLicense}}{{$current = .Status}}{{end}}
+                                    {{range .LicenseStatuses}}
+                                    <option value="{{.}}" {{if eq . $current}}selected{{end}}>{{.}}</option>
+                                    {{end}}
+                                </select>
+                            </div>
+                            <div>
+                                <label for="license-quantity" class="block text-sm font-medium text-gray-700">Seats Purchased</label>
+                                <input type="number" min="0" name="quantity" id="license-quantity" value="{{with .EditLicense}}{{.Quantity}}{{else}}0{{end}}" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="license-product" class="block text-sm font-medium text-gray-700">Covered Product</label>
+                                <input type="text" name="product" id="license-product" placeholder="Defaults to the license name" value="{{with .EditLicense}}{{.Product}}{{end}}" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="unit-cost" class="block text-sm font-medium text-gray-700">Unit Cost</label>
+                                <input type="number" min="0" step="0.01" name="unit-cost" id="unit-cost" value="{{with .EditLicense}}{{printf "%.2f" .UnitCost}}{{else}}0.00{{end}}" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="license-owner-email" class="block text-sm font-medium text-gray-700">Owner Email</label>
+                                <input type="email" name="owner-email" id="license-owner-email" placeholder="Receives expiry notifications" value="{{with .EditLicense}}{{.OwnerEmail}}{{end}}" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="metric" class="block text-sm font-medium text-gray-700">License Metric</label>
+                                <select name="metric" id="metric" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                                    {{$metric := ""}}{{with .EditLicense}}{{$metric = .Metric}}{{end}}
+                                    {{range .LicenseMetrics}}
+                                    <option value="{{.}}" {{if eq . $metric}}selected{{end}}>{{.Label}}</option>
+                                    {{end}}
+                                </select>
+                            </div>
+                            <button type="submit" class="w-full flex justify-center py-2 px-4 border border-transparent rounded-md shadow-sm text-sm font-medium text-white bg-blue-600 hover:bg-blue-700 focus:outline-none focus:ring-2 focus:ring-offset-2 focus:ring-blue-500 transition-colors duration-300">
+                                Save License
+                            </button>
+                            {{if .EditLicense}}
+                            <a href="/licenses" class="block text-center text-sm text-gray-600 hover:underline">Cancel</a>
+                            {{end}}
+                        </form>
+                    </div>
+
+                    <!-- Seat Assignments -->
+                    {{with .EditLicense}}
+                    <div class="bg-gray-50 p-6 rounded-2xl shadow-sm border border-gray-200 mb-6">
+                        <h3 class="text-xl font-semibold text-gray-700 mb-2">Seat Assignments</h3>
+                        <p class="text-sm text-gray-600 mb-4">
+                            {{.Metric.Label}}:
+                            {{if .Metric.Unlimited}}{{.Consumed}} used, unlimited seats.
+                            {{else}}<span class="{{if .OverAllocated}}text-red-600 font-medium{{end}}">{{.Consumed}} of {{.Quantity}} seats used</span>{{if .OverAllocated}} &mdash; over-allocated{{end}}.
+                            {{end}}
+                        </p>
+                        <table class="min-w-full divide-y divide-gray-200 mb-4">
+                            <thead class="bg-gray-100">
+                                <tr>
+                                    <th scope="col" class="px-4 py-2 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Asset</th>
+                                    <th scope="col" class="px-4 py-2 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Assignee</th>
+                                    <th scope="col" class="px-4 py-2 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Seats</th>
+                                    <th scope="col" class="px-4 py-2 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Assigned</th>
+                                    <th scope="col" class="px-4 py-2"></th>
+                                </tr>
+                            </thead>
+                            <tbody class="divide-y divide-gray-200">
+                                {{range $.Assignments}}
+                                <tr>
+                                    <td class="px-4 py-2 text-sm text-gray-900">{{.AssetName}}</td>
+                                    <td class="px-4 py-2 text-sm text-gray-500">{{if .PersonID}}<a href="/people/{{.PersonID}}" class="text-blue-600 hover:underline">{{.PersonName}}</a>{{else}}{{.Assignee}}{{end}}</td>
+                                    <td class="px-4 py-2 text-sm text-gray-500">{{.Quantity}}</td>
+                                    <td class="px-4 py-2 text-sm text-gray-500">{{.AssignedAt.Format "01/02/2006"}}</td>
+                                    <td class="px-4 py-2 text-sm text-right">
+                                        <form action="/licenses/{{.LicenseID}}/assignments/{{.ID}}/delete" method="post" onsubmit="return confirm('Remove this assignment?');">
+                                            <button type="submit" class="text-red-600 hover:underline">Remove</button>
+                                        </form>
+                                    </td>
+                                </tr>
+                                {{else}}
+                                <tr>
+                                    <td colspan="5" class="px-4 py-2 text-sm text-gray-500">No seats assigned.</td>
+                                </tr>
+                                {{end}}
+                            </tbody>
+                        </table>
+                        <form action="/licenses/{{.ID}}/assignments" method="post" class="grid grid-cols-1 md:grid-cols-5 gap-4 items-end">
+                            <div>
+                                <label for="assign-asset" class="block text-sm font-medium text-gray-700">Asset</label>
+                                <select name="asset-id" id="assign-asset" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm sm:text-sm">
+                                    <option value="">(none)</option>
+                                    {{range $.Assets}}
+                                    <option value="{{.ID}}">{{.Name}}</option>
+                                    {{end}}
+                                </select>
+                            </div>
+                            <div>
+                                <label for="assign-person" class="block text-sm font-medium text-gray-700">Person</label>
+                                <select name="person-id" id="assign-person" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm sm:text-sm">
+                                    <option value="">(none)</option>
+                                    {{range $.People}}
+                                    <option value="{{.ID}}">{{.Name}}</option>
+                                    {{end}}
+                                </select>
+                            </div>
+                            <div>
+                                <label for="assignee" class="block text-sm font-medium text-gray-700">Other assignee</label>
+                                <input type="text" name="assignee" id="assignee" placeholder="Team or outside person" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="assign-quantity" class="block text-sm font-medium text-gray-700">Seats</label>
+                                <input type="number" min="1" name="quantity" id="assign-quantity" value="1" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm sm:text-sm">
+                            </div>
+                            <button type="submit" class="py-2 px-4 rounded-md shadow-sm text-sm font-medium text-white bg-blue-600 hover:bg-blue-700">Assign</button>
+                        </form>
+                    </div>
+                    {{end}}
+                    {{end}}
+
+                    <!-- Licenses Table -->
+                    <div class="bg-white p-6 rounded-2xl shadow-sm border border-gray-200 overflow-x-auto">
+                        <div class="flex justify-between items-center mb-4">
+                            <h3 class="text-xl font-semibold text-gray-700">Current Licenses</h3>
+                            <div class="space-x-3 text-sm">
+                                {{if .Can "licenses:manage"}}<a href="/licenses/notifications" class="text-blue-600 hover:underline">Expiry Notifications</a>
+                                <a href="/licenses/import" class="text-blue-600 hover:underline">Import CSV</a>{{end}}
+                                <a href="/licenses/export?format=csv" class="text-blue-600 hover:underline">Export CSV</a>
+                                <a href="/licenses/export?format=xlsx" class="text-blue-600 hover:underline">Export Excel</a>
+                            </div>
+                        </div>
+                        <table class="min-w-full divide-y divide-gray-200">
+                            <thead class="bg-gray-50">
+                                <tr>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">License</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Vendor</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Expiry</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Renewal</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Status</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Seats</th>
+                                    <th scope="col" class="px-6 py-3"></th>
+                                </tr>
+                            </thead>
+                            <tbody class="bg-white divide-y divide-gray-200">
+                                {{range .Licenses}}
+                                <tr>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm font-medium text-gray-900">{{.Name}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.Vendor}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{if not .ExpiryDate.IsZero}}{{.ExpiryDate.Format "01/02/2006"}}{{end}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{if not .RenewalDate.IsZero}}{{.RenewalDate.Format "01/02/2006"}}{{end}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.Status}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm {{if .OverAllocated}}text-red-600 font-medium{{else}}text-gray-500{{end}}">{{if .Metric.Unlimited}}{{.Consumed}} (site){{else}}{{.Consumed}} / {{.Quantity}}{{end}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-right space-x-2">
+                                        {{if $.Can "licenses:manage"}}
+                                        <a href="/licenses/{{.ID}}/edit" class="text-blue-600 hover:underline">Edit</a>
+                                        <form action="/licenses/{{.ID}}/delete" method="post" class="inline" onsubmit="return confirm('Delete this license?');">
+                                            <button type="submit" class="text-red-600 hover:underline">Delete</button>
+                                        </form>
+                                        {{end}}
+                                    </td>
+                                </tr>
+                                {{else}}
+                                <tr>
+                                    <td colspan="7" class="px-6 py-4 text-sm text-gray-500">No licenses recorded.</td>
+                                </tr>
+                                {{end}}
+                            </tbody>
+                        </table>
+                    </div>
+                </div>
+                <!-- Notifications Page -->
+                <div id="notifications-page" class="placeholder-page">
+                    <h2 class="text-3xl font-bold text-gray-800 mb-4">Expiry Notifications</h2>
+                    {{with .Notify}}
+                    <p class="text-gray-600 mb-6">License owners are emailed {{range $i, $d := .Thresholds}}{{if $i}}, {{end}}{{$d}}{{end}} days before a license expires and again on expiry. Each notice goes out once per license, expiry date and recipient; updating the expiry date starts the schedule again. Licenses without an owner email are reported to {{if .DefaultRecipient}}{{.DefaultRecipient}}{{else}}nobody{{end}}{{if .EscalateTo}}, and notices from {{.EscalateDays}} days before expiry are also sent to {{range $i, $a := .EscalateTo}}{{if $i}}, {{end}}{{$a}}{{end}}{{end}}.</p>
+                    <p class="mb-6 py-2 px-4 rounded-md text-sm {{if .Enabled}}text-green-700 bg-green-100{{else}}text-gray-700 bg-gray-100{{end}}">
+                        {{if .Enabled}}The scheduler checks every {{.Interval}} and sends through {{.SMTP.Host}}.{{else}}The scheduler is off. Set <code>notify.enabled</code> to start it, or run <code>slam notify</code> to send due notices once.{{end}}
+                    </p>
+                    {{end}}
+                    <div class="bg-white p-6 rounded-2xl shadow-sm border border-gray-200 overflow-x-auto">
+                        <div class="flex justify-between items-center mb-4">
+                            <h3 class="text-xl font-semibold text-gray-700">Sent Log</h3>
+                            <a href="/licenses" class="text-sm text-blue-600 hover:underline">&larr; Back to licenses</a>
+                        </div>
+                        <table class="min-w-full divide-y divide-gray-200">
+                            <thead class="bg-gray-50">
+                                <tr>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Sent</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">License</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Expiry Date</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Notice</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Recipient</th>
+                                </tr>
+                            </thead>
+                            <tbody class="bg-white divide-y divide-gray-200">
+                                {{range .Notifications}}
+                                <tr>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.SentAt.Format "01/02/2006 15:04"}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm font-medium text-gray-900">{{if .LicenseID}}<a href="/licenses/{{.LicenseID}}" class="text-blue-600 hover:underline">{{.LicenseName}}</a>{{else}}{{.LicenseName}} (deleted){{end}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.ExpiryDate.Format "01/02/2006"}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.StageLabel}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.Recipient}}</td>
+                                </tr>
+                                {{else}}
+                                <tr>
+                                    <td colspan="5" class="px-6 py-4 text-sm text-gray-500">No notifications have been sent.</td>
+                                </tr>
+                                {{end}}
+                            </tbody>
+                        </table>
+                    </div>
+                </div>
+                <!-- Import Page -->
+                <div id="import-page" class="placeholder-page">
+                    {{with .Import}}
+                    <h2 class="text-3xl font-bold text-gray-800 mb-4">Import {{.Label}}</h2>
+                    <p class="text-gray-600 mb-6">Upload a CSV file with a header row. Columns are matched to fields by their headers; check the mapping and the preview, then import. Nothing is saved unless every row is valid, and then all rows are saved together.</p>
+                    {{if eq .Kind "assets"}}<p class="text-gray-600 mb-6">Locations must already exist and are given by path, such as "Head Office / North Wing / Floor 2", or by a name only one location has. Asset types must already exist too, and each type's custom fields can be given in columns headed by the field's label or name; they are checked against the row's type.</p>{{end}}
+
+                    <!-- Upload Form -->
+                    <div class="bg-gray-50 p-6 rounded-2xl shadow-sm border border-gray-200 mb-6">
+                        <a href="/{{.Kind}}" class="text-sm text-blue-600 hover:underline">&larr; Back to {{.Kind}}</a>
+                        <form action="/{{.Kind}}/import" method="post" enctype="multipart/form-data" class="mt-4 space-y-4">
+                            <div>
+                                <label for="import-file" class="block text-sm font-medium text-gray-700">CSV File</label>
+                                <input type="file" name="file" id="import-file" accept=".csv,text/csv" required class="mt-1 block w-full text-sm">
+                            </div>
+                            <p class="text-sm text-gray-500">Fields: {{range $i, $f := .Fields}}{{if $i}}, {{end}}{{$f.Label}}{{if $f.Required}} (required){{end}}{{end}}. Dates may be written as YYYY-MM-DD or MM/DD/YYYY.</p>
+                            <button type="submit" class="py-2 px-4 rounded-md shadow-sm text-sm font-medium text-white bg-blue-600 hover:bg-blue-700">Preview Import</button>
+                        </form>
+                    </div>
+
+                    {{if .Err}}
+                    <p class="mb-6 py-2 px-4 rounded-md text-sm text-red-700 bg-red-100">{{.Err}}</p>
+                    {{end}}
+
+                    {{if .Headers}}
+                    <!-- Import Preview -->
+                    <div class="bg-white p-6 rounded-2xl shadow-sm border border-gray-200 overflow-x-auto">
+                        <form action="/{{.Kind}}/import" method="post">
+                            <textarea name="csv" class="hidden">{{.CSV}}</textarea>
+                            <div class="flex justify-between items-center mb-4">
+                                <h3 class="text-xl font-semibold text-gray-700">Preview: {{.Valid}} of {{len .Rows}} rows valid</h3>
+                                <div class="flex gap-2">
+                                    <button type="submit" name="action" value="preview" class="py-2 px-4 rounded-md text-sm font-medium text-blue-700 bg-blue-100 hover:bg-blue-200">Re-check</button>
+                                    <button type="submit" name="action" value="commit" {{if not .CanCommit}}disabled{{end}} class="py-2 px-4 rounded-md shadow-sm text-sm font-medium text-white bg-blue-600 hover:bg-blue-700 disabled:opacity-50">Import {{.Valid}} Rows</button>
+                                </div>
+                            </div>
+                            <table class="min-w-full divide-y divide-gray-200">
+                                <thead class="bg-gray-50">
+                                    <tr>
+                                        <th scope="col" class="px-4 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Line</th>
+                                        {{range $i, $h := .Headers}}
+                                        <th scope="col" class="px-4 py-3 text-left text-xs font-medium text-gray-500">
+                                            <span class="uppercase tracking-wider">{{$h}}</span>
+                                            <select name="map-{{$i}}" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm text-xs">
+                                                <option value="">Ignore</option>
+                                                {{range $.Import.Fields}}<option value="{{.Name}}" {{if eq .Name ($.Import.MappedTo $i)}}selected{{end}}>{{.Label}}</option>{{end}}
+                                            </select>
+                                        </th>
+                                        {{end}}
+                                        <th scope="col" class="px-4 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Problems</th>
+                                    </tr>
+                                </thead>
+                                <tbody class="bg-white divide-y divide-gray-200">
+                                    {{range .Rows}}
+                                    <tr class="{{if .Errors}}bg-red-50{{end}}">
+                                        <td class="px-4 py-2 whitespace-nowrap text-sm text-gray-500">{{.Line}}</td>
+                                        {{range .Values}}<td class="px-4 py-2 whitespace-nowrap text-sm text-gray-700">{{.}}</td>{{end}}
+                                        <td class="px-4 py-2 text-sm {{if .Errors}}text-red-700{{else}}text-green-700{{end}}">{{range $i, $e := .Errors}}{{if $i}}; {{end}}{{$e}}{{else}}OK{{end}}</td>
+                                    </tr>
+                                    {{end}}
+                                </tbody>
+                            </table>
+                        </form>
+                    </div>
+                    {{end}}
+                    {{end}}
+                </div>
+                <!-- Risk Register Page -->
+                <div id="risk-register-page" class="placeholder-page">
+                    <h2 class="text-3xl font-bold text-gray-800 mb-4">Risk Register</h2>
+                    <p class="text-gray-600 mb-6">Record key risks with their likelihood and impact, owners, mitigations and review dates, and link them to the assets and licenses they affect.</p>
+
+                    {{with .EditRisk}}
+                    <!-- Risk Detail -->
+                    <div class="bg-white p-6 rounded-2xl shadow-sm border border-gray-200 mb-6">
+                        <a href="/risks" class="text-sm text-blue-600 hover:underline">&larr; Back to the register</a>
+                        <div class="flex justify-between items-start mt-2 mb-4">
+                            <div>
+                                <h3 class="text-2xl font-semibold text-gray-800">{{.Title}}</h3>
+                                <p class="text-sm text-gray-500">Owner: {{if .Owner}}{{.Owner}}{{else}}unassigned{{end}} &middot; Updated {{.UpdatedAt.Format "01/02/2006 15:04"}}</p>
+                            </div>
+                            <div class="text-right">
+                                <span class="px-2 inline-flex text-xs leading-5 font-semibold rounded-full {{if .High}}bg-red-100 text-red-800{{else}}bg-gray-100 text-gray-800{{end}}">{{.Level}} &middot; {{.Score}}</span>
+                                <p class="text-sm text-gray-600 mt-1">Status: <strong>{{.Status.Label}}</strong></p>
+                            </div>
+                        </div>
+                        {{if .Description}}<p class="text-gray-700 mb-4 whitespace-pre-line">{{.Description}}</p>{{end}}
+                        {{if $.Can "risks:manage"}}
+                        <div class="flex flex-wrap gap-2 mb-4">
+                            {{$id := .ID}}
+                            {{range .Status.Next}}
+                            <form action="/risks/{{$id}}/status" method="post">
+                                <input type="hidden" name="status" value="{{.}}">
+                                <button type="submit" class="py-1 px-3 rounded-md text-sm font-medium text-blue-700 bg-blue-100 hover:bg-blue-200">Move to {{.Label}}</button>
+                            </form>
+                            {{end}}
+                        </div>
+                        {{end}}
+
+                        <h4 class="text-lg font-semibold text-gray-700 mb-2">Mitigations</h4>
+                        <ul class="space-y-2 mb-4">
+                            {{range .Mitigations}}
+                            <li class="flex justify-between items-center text-sm">
+                                <span class="{{if .Done}}line-through text-gray-400{{else}}text-gray-700{{end}}">{{.Action}}{{if not .DueDate.IsZero}} (due {{.DueDate.Format "01/02/2006"}}){{end}}</span>
+                                {{if .Done}}
+                                <span class="text-green-700">Completed {{.CompletedAt.Format "01/02/2006"}}</span>
+                                {{else if $.Can "risks:manage"}}
+                                <form action="/risks/{{.RiskID}}/mitigations/{{.ID}}/complete" method="post">
+                                    <button type="submit" class="text-green-700 hover:underline">Mark complete</button>
+                                </form>
+                                {{end}}
+                            </li>
+                            {{else}}
+                            <li class="text-sm text-gray-500">No mitigations recorded.</li>
+                            {{end}}
+                        </ul>
+                        {{if $.Can "risks:manage"}}
+                        <form action="/risks/{{.ID}}/mitigations" method="post" class="grid grid-cols-1 md:grid-cols-3 gap-4 items-end">
+                            <div class="md:col-span-1">
+                                <label for="mitigation-action" class="block text-sm font-medium text-gray-700">Action</label>
+                                <input type="text" name="action" id="mitigation-action" required class="mt-1 block w-full rounded-md border-gray-300 shadow-sm sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="mitigation-due" class="block text-sm font-medium text-gray-700">Due Date</label>
+                                <input type="date" name="due-date" id="mitigation-due" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm sm:text-sm">
+                            </div>
+                            <button type="submit" class="py-2 px-4 rounded-md shadow-sm text-sm font-medium text-white bg-blue-600 hover:bg-blue-700">Add Mitigation</button>
+                        </form>
+                        {{end}}
+                    </div>
+                    {{end}}
+
+                    <!-- Add / Edit Risk Form -->
+                    {{if .Can "risks:manage"}}
+                    <div class="bg-gray-50 p-6 rounded-2xl shadow-sm border border-gray-200 mb-6">
+                        {{with .EditRisk}}
+                        <h3 class="text-xl font-semibold text-gray-700 mb-4">Edit Risk</h3>
+                        <form action="/risks/{{.ID}}" method="post" class="space-y-4">
+                        {{else}}
+                        <h3 class="text-xl font-semibold text-gray-700 mb-4">Add New Risk</h3>
+                        <form action="/risks" method="post" class="space-y-4">
+                        {{end}}
+                            <div>
+                                <label for="risk-title" class="block text-sm font-medium text-gray-700">Title</label>
+                                <input type="text" name="title" id="risk-title" required value="{{with .EditRisk}}{{.Title}}{{end}}" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="risk-description" class="block text-sm font-medium text-gray-700">Description</label>
+                                <textarea name="description" id="risk-description" rows="3" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">{{with .EditRisk}}{{.Description}}{{end}}</textarea>
+                            </div>
+                            <div class="grid grid-cols-1 md:grid-cols-4 gap-4">
+                                <div>
+                                    <label for="likelihood" class="block text-sm font-medium text-gray-700">Likelihood (1-5)</label>
+                                    <select name="likelihood" id="likelihood" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm sm:text-sm">
+                                        {{$likelihood := 3}}{{with .EditRisk}}{{$likelihood = .Likelihood}}{{end}}
+                                        {{range .RiskScale}}<option value="{{.}}" {{if eq . $likelihood}}selected{{end}}>{{.}}</option>{{end}}
+                                    </select>
+                                </div>
+                                <div>
+                                    <label for="impact" class="block text-sm font-medium text-gray-700">Impact (1-5)</label>
+                                    <select name="impact" id="impact" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm sm:text-sm">
+                                        {{$impact := 3}}{{with .EditRisk}}{{$impact = .Impact}}{{end}}
+                                        {{range .RiskScale}}<option value="{{.}}" {{if eq . $impact}}selected{{end}}>{{.}}</option>{{end}}
+                                    </select>
+                                </div>
+                                <div>
+                                    <label for="risk-owner" class="block text-sm font-medium text-gray-700">Owner</label>
+                                    <input type="text" name="owner" id="risk-owner" value="{{with .EditRisk}}{{.Owner}}{{end}}" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm sm:text-sm">
+                                </div>
+                                <div>
+                                    <label for="review-date" class="block text-sm font-medium text-gray-700">Review Date</label>
+                                    <input type="date" name="review-date" id="review-date" value="{{with .EditRisk}}{{if not .ReviewDate.IsZero}}{{.ReviewDate.Format "2006-01-02"}}{{end}}{{end}}" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm sm:text-sm">
+                                </div>
+                            </div>
+                            <div class="grid grid-cols-1 md:grid-cols-2 gap-4">
+                                <div>
+                                    <label for="risk-assets" class="block text-sm font-medium text-gray-700">Affected Assets</label>
+                                    <select name="asset-ids" id="risk-assets" multiple size="4" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm sm:text-sm">
+                                        {{range .Assets}}<option value="{{.ID}}" {{if $.EditRisk}}{{if $.EditRisk.LinksAsset .ID}}selected{{end}}{{end}}>{{.Name}}</option>{{end}}
+                                    </select>
+                                </div>
+                                <div>
+                                    <label for="risk-licenses" class="block text-sm font-medium text-gray-700">Affected Licenses</label>
+                                    <select name="license-ids" id="risk-licenses" multiple size="4" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm sm:text-sm">
+                                        {{range .Licenses}}<option value="{{.ID}}" {{if $.EditRisk}}{{if $.EditRisk.LinksLicense .ID}}selected{{end}}{{end}}>{{.Name}}</option>{{end}}
+                                    </select>
+                                </div>
+                            </div>
+                            <button type="submit" class="w-full flex justify-center py-2 px-4 border border-transparent rounded-md shadow-sm text-sm font-medium text-white bg-blue-600 hover:bg-blue-700 focus:outline-none focus:ring-2 focus:ring-offset-2 focus:ring-blue-500 transition-colors duration-300">
+                                Save Risk
+                            </button>
+                        </form>
+                        {{with .EditRisk}}
+                        <form action="/risks/{{.ID}}/delete" method="post" class="mt-4" onsubmit="return confirm('Delete this risk?');">
+                            <button type="submit" class="w-full text-center text-sm text-red-600 hover:underline">Delete Risk</button>
+                        </form>
+                        {{end}}
+                    </div>
+                    {{end}}
+
+                    <!-- Risks Table -->
+                    <div class="bg-white p-6 rounded-2xl shadow-sm border border-gray-200 overflow-x-auto">
+                        <h3 class="text-xl font-semibold text-gray-700 mb-4">Risks</h3>
+                        <table class="min-w-full divide-y divide-gray-200">
+                            <thead class="bg-gray-50">
+                                <tr>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Risk</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Score</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Status</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Owner</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Review Date</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Affects</th>
+                                </tr>
+                            </thead>
+                            <tbody class="bg-white divide-y divide-gray-200">
+                                {{range .Risks}}
+                                <tr>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm font-medium text-gray-900"><a href="/risks/{{.ID}}" class="text-blue-600 hover:underline">{{.Title}}</a></td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm">
+                                        <span class="px-2 inline-flex text-xs leading-5 font-semibold rounded-full {{if .High}}bg-red-100 text-red-800{{else}}bg-gray-100 text-gray-800{{end}}">{{.Score}} {{.Level}}</span>
+                                    </td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.Status.Label}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.Owner}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm {{if .ReviewOverdue}}text-red-600 font-medium{{else}}text-gray-500{{end}}">{{if not .ReviewDate.IsZero}}{{.ReviewDate.Format "01/02/2006"}}{{end}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{len .AssetIDs}} assets, {{len .LicenseIDs}} licenses</td>
+                                </tr>
+                                {{else}}
+                                <tr>
+                                    <td colspan="6" class="px-6 py-4 text-sm text-gray-500">No risks recorded.</td>
+                                </tr>
+                                {{end}}
+                            </tbody>
+                        </table>
+                    </div>
+                </div>
+                <!-- Report Execution Page -->
+                <div id="report-execution-page" class="placeholder-page">
+                    <h2 class="text-3xl font-bold text-gray-800 mb-4">Report Execution</h2>
+                    <p class="text-gray-600 mb-6">Run built-in reports on software and asset data, save them with parameters for reuse, and download the results of past runs.</p>
+
+                    {{with .ReportRun}}
+                    <!-- Report Run Result -->
+                    <div class="bg-white p-6 rounded-2xl shadow-sm border border-gray-200 mb-6 overflow-x-auto">
+                        <a href="/reports" class="text-sm text-blue-600 hover:underline">&larr; Back to reports</a>
+                        <div class="flex justify-between items-start mt-2 mb-4">
+                            <div>
+                                <h3 class="text-2xl font-semibold text-gray-800">{{.Name}}</h3>
+                                <p class="text-sm text-gray-500">Run #{{.ID}} by {{if .RunBy}}{{.RunBy}}{{else}}unknown{{end}} on {{.StartedAt.Format "01/02/2006 15:04"}}{{with .Params.Summary}} &middot; {{.}}{{end}}</p>
+                            </div>
+                            {{if .Succeeded}}
+                            <div class="flex gap-2">
+                                <a href="/reports/runs/{{.ID}}/download?format=csv" class="py-2 px-4 rounded-md text-sm font-medium text-white bg-blue-600 hover:bg-blue-700">Download CSV</a>
+                                <a href="/reports/runs/{{.ID}}/download?format=xlsx" class="py-2 px-4 rounded-md text-sm font-medium text-blue-700 bg-blue-100 hover:bg-blue-200">Download Excel</a>
+                            </div>
+                            {{end}}
+                        </div>
+                        {{if .Succeeded}}
+                        <table class="min-w-full divide-y divide-gray-200">
+                            <thead class="bg-gray-50">
+                                <tr>
+                                    {{range .Result.Columns}}<th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">{{.}}</th>{{end}}
+                                </tr>
+                            </thead>
+                            <tbody class="bg-white divide-y divide-gray-200">
+                                {{range .Result.Rows}}
+                                <tr>
+                                    {{range .}}<td class="px-6 py-4 whitespace-nowrap text-sm text-gray-700">{{.}}</td>{{end}}
+                                </tr>
+                                {{else}}
+                                <tr>
+                                    <td colspan="{{len .Result.Columns}}" class="px-6 py-4 text-sm text-gray-500">The report returned no rows.</td>
+                                </tr>
+                                {{end}}
+                            </tbody>
+                        </table>
+                        {{else}}
+                        <p class="py-2 px-4 rounded-md text-sm text-red-700 bg-red-100">The report failed: {{.Error}}</p>
+                        {{end}}
+                    </div>
+                    {{end}}
+
+                    <!-- Report Catalogue -->
+                    <div class="grid grid-cols-1 md:grid-cols-2 gap-6 mb-6">
+                        {{range .ReportDefinitions}}
+                        <div class="bg-gray-50 p-6 rounded-2xl shadow-sm border border-gray-200">
+                            <h3 class="text-xl font-semibold text-gray-700 mb-1">{{.Name}}</h3>
+                            <p class="text-sm text-gray-600 mb-4">{{.Description}}</p>
+                            <form action="/reports/run" method="post" class="space-y-3">
+                                <input type="hidden" name="report" value="{{.Key}}">
+                                {{$key := .Key}}
+                                {{range .Params}}
+                                <div>
+                                    <label for="{{$key}}-{{.Name}}" class="block text-sm font-medium text-gray-700">{{.Label}}</label>
+                                    <input type="{{.Type}}" name="param-{{.Name}}" id="{{$key}}-{{.Name}}" value="{{.Default}}" {{if eq .Type "number"}}min="0"{{end}} class="mt-1 block w-full rounded-md border-gray-300 shadow-sm sm:text-sm">
+                                </div>
+                                {{end}}
+                                {{if $.Can "reports:manage"}}
+                                <div>
+                                    <label for="{{.Key}}-name" class="block text-sm font-medium text-gray-700">Save As</label>
+                                    <input type="text" name="name" id="{{.Key}}-name" placeholder="Name, to save these parameters" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm sm:text-sm">
+                                </div>
+                                {{end}}
+                                <div class="flex gap-2">
+                                    <button type="submit" class="py-2 px-4 rounded-md shadow-sm text-sm font-medium text-white bg-blue-600 hover:bg-blue-700">Run Report</button>
+                                    {{if $.Can "reports:manage"}}
+                                    <button type="submit" formaction="/reports" class="py-2 px-4 rounded-md text-sm font-medium text-blue-700 bg-blue-100 hover:bg-blue-200">Save Report</button>
+                                    {{end}}
+                                </div>
+                            </form>
+                        </div>
+                        {{end}}
+                    </div>
+
+                    <!-- Saved Reports Table -->
+                    <div class="bg-white p-6 rounded-2xl shadow-sm border border-gray-200 mb-6 overflow-x-auto">
+                        <h3 class="text-xl font-semibold text-gray-700 mb-4">Saved Reports</h3>
+                        <table class="min-w-full divide-y divide-gray-200">
+                            <thead class="bg-gray-50">
+                                <tr>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Name</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Report</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Parameters</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Created By</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Actions</th>
+                                </tr>
+                            </thead>
+                            <tbody class="bg-white divide-y divide-gray-200">
+                                {{range .SavedReports}}
+                                <tr>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm font-medium text-gray-900">{{.Name}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.Definition.Name}}</td>
+                                    <td class="px-6 py-4 text-sm text-gray-500">{{.Params.Summary}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.CreatedBy}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm space-x-3">
+                                        <form action="/reports/saved/{{.ID}}/run" method="post" class="inline">
+                                            <button type="submit" class="text-blue-600 hover:underline">Run</button>
+                                        </form>
+                                        {{if $.Can "reports:manage"}}
+                                        <form action="/reports/saved/{{.ID}}/delete" method="post" class="inline" onsubmit="return confirm('Delete this saved report? Its past runs are kept.');">
+                                            <button type="submit" class="text-red-600 hover:underline">Delete</button>
+                                        </form>
+                                        {{end}}
+                                    </td>
+                                </tr>
+                                {{else}}
+                                <tr>
+                                    <td colspan="5" class="px-6 py-4 text-sm text-gray-500">No saved reports.</td>
+                                </tr>
+                                {{end}}
+                            </tbody>
+                        </table>
+                    </div>
+
+                    <!-- Run History Table -->
+                    <div class="bg-white p-6 rounded-2xl shadow-sm border border-gray-200 overflow-x-auto">
+                        <h3 class="text-xl font-semibold text-gray-700 mb-4">Run History</h3>
+                        <table class="min-w-full divide-y divide-gray-200">
+                            <thead class="bg-gray-50">
+                                <tr>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Run</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Report</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Parameters</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Run By</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Started</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Result</th>
+                                </tr>
+                            </thead>
+                            <tbody class="bg-white divide-y divide-gray-200">
+                                {{range .ReportRuns}}
+                                <tr>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm font-medium"><a href="/reports/runs/{{.ID}}" class="text-blue-600 hover:underline">#{{.ID}}</a></td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-900">{{.Name}}</td>
+                                    <td class="px-6 py-4 text-sm text-gray-500">{{.Params.Summary}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.RunBy}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.StartedAt.Format "01/02/2006 15:04"}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm">
+                                        {{if .Succeeded}}
+                                        {{.RowCount}} rows &middot;
+                                        <a href="/reports/runs/{{.ID}}/download?format=csv" class="text-blue-600 hover:underline">CSV</a>
+                                        <a href="/reports/runs/{{.ID}}/download?format=xlsx" class="text-blue-600 hover:underline">Excel</a>
+                                        {{else}}
+                                        <span class="text-red-600">Failed</span>
+                                        {{end}}
+                                    </td>
+                                </tr>
+                                {{else}}
+                                <tr>
+                                    <td colspan="6" class="px-6 py-4 text-sm text-gray-500">No reports have been run yet.</td>
+                                </tr>
+                                {{end}}
+                            </tbody>
+                        </table>
+                    </div>
+                </div>
+                <!-- FOI Requests Page -->
+                <div id="foi-requests-page" class="placeholder-page">
+                    <h2 class="text-3xl font-bold text-gray-800 mb-4">Freedom of Information Requests</h2>
+                    <p class="text-gray-600 mb-6">Track requests for information related to software and assets. Deadlines are {{.FOIDeadlineDays}} working days after receipt, skipping weekends{{if .FOIHolidays}} and {{len .FOIHolidays}} configured holidays{{end}}.</p>
+
+                    {{with .EditFOI}}
+                    <!-- FOI Request Detail -->
+                    <div class="bg-white p-6 rounded-2xl shadow-sm border border-gray-200 mb-6">
+                        <a href="/foi" class="text-sm text-blue-600 hover:underline">&larr; Back to requests</a>
+                        <div class="flex justify-between items-start mt-2 mb-4">
+                            <div>
+                                <h3 class="text-2xl font-semibold text-gray-800">{{.Reference}}: {{.Subject}}</h3>
+                                <p class="text-sm text-gray-500">From {{.Requester}}{{if .Contact}} ({{.Contact}}){{end}} &middot; Assigned to {{if .Assignee}}{{.Assignee}}{{else}}nobody{{end}}</p>
+                            </div>
+                            <div class="text-right">
+                                <p class="text-sm text-gray-600">Status: <strong>{{.Status.Label}}</strong></p>
+                                <p class="text-sm {{if .Overdue}}text-red-600 font-medium{{else}}text-gray-600{{end}}">Due {{.DueDate.Format "01/02/2006"}}{{if .Overdue}} (overdue){{end}}</p>
+                                <p class="text-xs text-gray-500">Received {{.ReceivedDate.Format "01/02/2006"}}</p>
+                            </div>
+                        </div>
+                        {{if .Details}}<p class="text-gray-700 mb-4 whitespace-pre-line">{{.Details}}</p>{{end}}
+                        {{if .ReportRunIDs}}
+                        <p class="text-sm text-gray-600 mb-4">Reports: {{range $i, $run := .ReportRunIDs}}{{if $i}}, {{end}}<a href="/reports/runs/{{$run}}" class="text-blue-600 hover:underline">run #{{$run}}</a>{{end}}</p>
+                        {{end}}
+                        {{if $.Can "foi:manage"}}
+                        <div class="flex flex-wrap gap-2">
+                            {{$id := .ID}}
+                            {{range .Status.Next}}
+                            <form action="/foi/{{$id}}/status" method="post">
+                                <input type="hidden" name="status" value="{{.}}">
+                                <button type="submit" class="py-1 px-3 rounded-md text-sm font-medium text-blue-700 bg-blue-100 hover:bg-blue-200">Move to {{.Label}}</button>
+                            </form>
+                            {{end}}
+                        </div>
+                        {{end}}
+                    </div>
+                    {{end}}
+
+                    <!-- Add / Edit FOI Request Form -->
+                    {{if .Can "foi:manage"}}
+                    <div class="bg-gray-50 p-6 rounded-2xl shadow-sm border border-gray-200 mb-6">
+                        {{with .EditFOI}}
+                        <h3 class="text-xl font-semibold text-gray-700 mb-4">Edit Request</h3>
+                        <form action="/foi/{{.ID}}" method="post" class="space-y-4">
+                        {{else}}
+                        <h3 class="text-xl font-semibold text-gray-700 mb-4">Log New Request</h3>
+                        <form action="/foi" method="post" class="space-y-4">
+                        {{end}}
+                            <div class="grid grid-cols-1 md:grid-cols-2 gap-4">
+                                <div>
+                                    <label for="requester" class="block text-sm font-medium text-gray-700">Requester</label>
+                                    <input type="text" name="requester" id="requester" required value="{{with .EditFOI}}{{.Requester}}{{end}}" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm sm:text-sm">
+                                </div>
+                                <div>
+                                    <label for="contact" class="block text-sm font-medium text-gray-700">Contact Details</label>
+                                    <input type="text" name="contact" id="contact" value="{{with .EditFOI}}{{.Contact}}{{end}}" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm sm:text-sm">
+                                </div>
+                            </div>
+                            <div>
+                                <label for="subject" class="block text-sm font-medium text-gray-700">Subject</label>
+                                <input type="text" name="subject" id="subject" required value="{{with .EditFOI}}{{.Subject}}{{end}}" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="details" class="block text-sm font-medium text-gray-700">Information Requested</label>
+                                <textarea name="details" id="details" rows="3" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm sm:text-sm">{{with .EditFOI}}{{.Details}}{{end}}</textarea>
+                            </div>
+                            <div class="grid grid-cols-1 md:grid-cols-2 gap-4">
+                                <div>
+                                    <label for="received-date" class="block text-sm font-medium text-gray-700">Received Date</label>
+                                    <input type="date" name="received-date" id="received-date" required value="{{with .EditFOI}}{{.ReceivedDate.Format "2006-01-02"}}{{end}}" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm sm:text-sm">
+                                </div>
+                                <div>
+                                    <label for="foi-assignee" class="block text-sm font-medium text-gray-700">Assignee</label>
+                                    <input type="text" name="assignee" id="foi-assignee" value="{{with .EditFOI}}{{.Assignee}}{{end}}" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm sm:text-sm">
+                                </div>
+                            </div>
+                            <div class="grid grid-cols-1 md:grid-cols-2 gap-4">
+                                <div>
+                                    <label for="foi-assets" class="block text-sm font-medium text-gray-700">Related Assets</label>
+                                    <select name="asset-ids" id="foi-assets" multiple size="4" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm sm:text-sm">
+                                        {{range .Assets}}<option value="{{.ID}}" {{if $.EditFOI}}{{if $.EditFOI.LinksAsset .ID}}selected{{end}}{{end}}>{{.Name}}</option>{{end}}
+                                    </select>
+                                </div>
+                                <div>
+                                    <label for="foi-licenses" class="block text-sm font-medium text-gray-700">Related Licenses</label>
+                                    <select name="license-ids" id="foi-licenses" multiple size="4" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm sm:text-sm">
+                                        {{range .Licenses}}<option value="{{.ID}}" {{if $.EditFOI}}{{if $.EditFOI.LinksLicense .ID}}selected{{end}}{{end}}>{{.Name}}</option>{{end}}
+                                    </select>
+                                </div>
+                            </div>
+                            <div>
+                                <label for="foi-reports" class="block text-sm font-medium text-gray-700">Related Report Runs</label>
+                                <select name="report-run-ids" id="foi-reports" multiple size="4" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm sm:text-sm">
+                                    {{range .ReportRuns}}<option value="{{.ID}}" {{if $.EditFOI}}{{if $.EditFOI.LinksReportRun .ID}}selected{{end}}{{end}}>#{{.ID}} {{.Name}} ({{.StartedAt.Format "01/02/2006"}})</option>{{end}}
+                                </select>
+                            </div>
+                            <button type="submit" class="w-full flex justify-center py-2 px-4 border border-transparent rounded-md shadow-sm text-sm font-medium text-white bg-blue-600 hover:bg-blue-700 focus:outline-none focus:ring-2 focus:ring-offset-2 focus:ring-blue-500 transition-colors duration-300">
+                                Save Request
+                            </button>
+                        </form>
+                        {{with .EditFOI}}
+                        <form action="/foi/{{.ID}}/delete" method="post" class="mt-4" onsubmit="return confirm('Delete this FOI request?');">
+                            <button type="submit" class="w-full text-center text-sm text-red-600 hover:underline">Delete Request</button>
+                        </form>
+                        {{end}}
+                    </div>
+                    {{end}}
+
+                    <!-- FOI Requests Table -->
+                    <div class="bg-white p-6 rounded-2xl shadow-sm border border-gray-200 overflow-x-auto">
+                        <h3 class="text-xl font-semibold text-gray-700 mb-4">Requests</h3>
+                        <table class="min-w-full divide-y divide-gray-200">
+                            <thead class="bg-gray-50">
+                                <tr>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Reference</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Subject</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Requester</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Received</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Due</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Assignee</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Status</th>
+                                </tr>
+                            </thead>
+                            <tbody class="bg-white divide-y divide-gray-200">
+                                {{range .FOIRequests}}
+                                <tr>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm font-medium"><a href="/foi/{{.ID}}" class="text-blue-600 hover:underline">{{.Reference}}</a></td>
+                                    <td class="px-6 py-4 text-sm text-gray-900">{{.Subject}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.Requester}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.ReceivedDate.Format "01/02/2006"}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm {{if .Overdue}}text-red-600 font-medium{{else}}text-gray-500{{end}}">{{.DueDate.Format "01/02/2006"}}{{if .Overdue}} (overdue){{end}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.Assignee}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.Status.Label}}</td>
+                                </tr>
+                                {{else}}
+                                <tr>
+                                    <td colspan="7" class="px-6 py-4 text-sm text-gray-500">No FOI requests logged.</td>
+                                </tr>
+                                {{end}}
+                            </tbody>
+                        </table>
+                    </div>
+                </div>
+                <!-- Locations Page -->
+                <div id="locations-page" class="placeholder-page">
+                    <h2 class="text-3xl font-bold text-gray-800 mb-4">Locations</h2>
+                    <p class="text-gray-600 mb-6">The sites, buildings, floors and rooms assets are kept in. Each location counts the assets kept there and, in total, those anywhere inside it.</p>
+
+                    {{if .Can "assets:manage"}}
+                    <div class="bg-gray-50 p-6 rounded-2xl shadow-sm border border-gray-200 mb-6">
+                        <h3 class="text-xl font-semibold text-gray-700 mb-4">Add Location</h3>
+                        <form action="/locations" method="post" class="grid grid-cols-1 md:grid-cols-4 gap-4 items-end">
+                            <div>
+                                <label for="location-name" class="block text-sm font-medium text-gray-700">Name</label>
+                                <input type="text" name="name" id="location-name" required class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="location-kind" class="block text-sm font-medium text-gray-700">Kind</label>
+                                <select name="kind" id="location-kind" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                                    {{range .LocationKinds}}
+                                    <option value="{{.}}">{{.Label}}</option>
+                                    {{end}}
+                                </select>
+                            </div>
+                            <div>
+                                <label for="location-parent" class="block text-sm font-medium text-gray-700">Inside</label>
+                                <select name="parent-id" id="location-parent" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                                    <option value="">Nothing (sites)</option>
+                                    {{range .Locations}}
+                                    {{if ne .Kind "room"}}<option value="{{.ID}}">{{.Path}}</option>{{end}}
+                                    {{end}}
+                                </select>
+                            </div>
+                            <button type="submit" class="py-2 px-4 rounded-md shadow-sm text-sm font-medium text-white bg-blue-600 hover:bg-blue-700">Add Location</button>
+                        </form>
+                    </div>
+                    {{end}}
+
+                    <div class="bg-white p-6 rounded-2xl shadow-sm border border-gray-200 overflow-x-auto">
+                        <table class="min-w-full divide-y divide-gray-200">
+                            <thead class="bg-gray-50">
+                                <tr>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Location</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Kind</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Assets Here</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Total Assets</th>
+                                </tr>
+                            </thead>
+                            <tbody class="bg-white divide-y divide-gray-200">
+                                {{range .Locations}}
+                                <tr>
+                                    <td class="pr-6 py-4 whitespace-nowrap text-sm font-medium text-gray-900" style="padding-left: {{.Indent}}rem"><a href="/locations/{{.ID}}" class="text-blue-600 hover:underline">{{.Name}}</a></td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.Kind.Label}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.Assets}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm font-medium text-gray-900">{{.Total}}</td>
+                                </tr>
+                                {{else}}
+                                <tr>
+                                    <td colspan="4" class="px-6 py-4 text-sm text-gray-500">No locations have been added.</td>
+                                </tr>
+                                {{end}}
+                            </tbody>
+                        </table>
+                    </div>
+                </div>
+
+                <!-- Location Page -->
+                <div id="location-page" class="placeholder-page">
+                    {{with .Location}}
+                    <a href="/locations" class="text-sm text-blue-600 hover:underline">&larr; Back to locations</a>
+                    <h2 class="text-3xl font-bold text-gray-800 mt-2 mb-4">{{.Name}}</h2>
+                    <dl class="grid grid-cols-1 md:grid-cols-4 gap-4 bg-gray-50 p-6 rounded-2xl shadow-sm border border-gray-200 mb-6">
+                        <div>
+                            <dt class="text-sm font-medium text-gray-500">Kind</dt>
+                            <dd class="text-gray-900">{{.Kind.Label}}</dd>
+                        </div>
+                        <div>
+                            <dt class="text-sm font-medium text-gray-500">Path</dt>
+                            <dd class="text-gray-900">{{.Path}}</dd>
+                        </div>
+                        <div>
+                            <dt class="text-sm font-medium text-gray-500">Assets Here</dt>
+                            <dd class="text-gray-900">{{.Assets}}</dd>
+                        </div>
+                        <div>
+                            <dt class="text-sm font-medium text-gray-500">Total Assets</dt>
+                            <dd class="text-gray-900">{{.Total}}</dd>
+                        </div>
+                    </dl>
+                    {{end}}
+
+                    <div class="bg-white p-6 rounded-2xl shadow-sm border border-gray-200 overflow-x-auto mb-6">
+                        <h3 class="text-xl font-semibold text-gray-700 mb-4">Assets</h3>
+                        <table class="min-w-full divide-y divide-gray-200">
+                            <thead class="bg-gray-50">
+                                <tr>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Asset Name</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Type</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Location</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">State</th>
+                                </tr>
+                            </thead>
+                            <tbody class="bg-white divide-y divide-gray-200">
+                                {{range .LocationAssets}}
+                                <tr>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm font-medium text-gray-900"><a href="/assets/{{.ID}}" class="text-blue-600 hover:underline">{{.Name}}</a></td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.AssetType}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.Location}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.State.Label}}</td>
+                                </tr>
+                                {{else}}
+                                <tr>
+                                    <td colspan="4" class="px-6 py-4 text-sm text-gray-500">No assets are kept here.</td>
+                                </tr>
+                                {{end}}
+                            </tbody>
+                        </table>
+                    </div>
+
+                    {{if .Can "assets:manage"}}
+                    {{with .Location}}
+                    <div class="bg-gray-50 p-6 rounded-2xl shadow-sm border border-gray-200 mb-6">
+                        <h3 class="text-xl font-semibold text-gray-700 mb-4">Edit Location</h3>
+                        <form action="/locations/{{.ID}}" method="post" class="space-y-4">
+                            <input type="hidden" name="_method" value="PUT">
+                            <div>
+                                <label for="edit-location-name" class="block text-sm font-medium text-gray-700">Name</label>
+                                <input type="text" name="name" id="edit-location-name" value="{{.Name}}" required class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="edit-location-kind" class="block text-sm font-medium text-gray-700">Kind</label>
+                                <select name="kind" id="edit-location-kind" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                                    {{$kind := .Kind}}
+                                    {{range $.LocationKinds}}
+                                    <option value="{{.}}" {{if eq . $kind}}selected{{end}}>{{.Label}}</option>
+                                    {{end}}
+                                </select>
+                            </div>
+                            <div>
+                                <label for="edit-location-parent" class="block text-sm font-medium text-gray-700">Inside</label>
+                                <select name="parent-id" id="edit-location-parent" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                                    <option value="">Nothing (sites)</option>
+                                    {{$id := .ID}}
+                                    {{$parent := .ParentID}}
+                                    {{range $.Locations}}
+                                    {{if and (ne .ID $id) (ne .Kind "room")}}<option value="{{.ID}}" {{if eq .ID $parent}}selected{{end}}>{{.Path}}</option>{{end}}
+                                    {{end}}
+                                </select>
+                            </div>
+                            <p class="text-sm text-gray-500">Renaming or moving a location changes the path of everything inside it; the assets' location history keeps the paths they had when they moved.</p>
+                            <button type="submit" class="py-2 px-4 rounded-md shadow-sm text-sm font-medium text-white bg-blue-600 hover:bg-blue-700">Save Changes</button>
+                        </form>
+                    </div>
+
+                    <div class="bg-red-50 p-6 rounded-2xl shadow-sm border border-red-200">
+                        <h3 class="text-xl font-semibold text-red-700 mb-2">Delete Location</h3>
+                        <p class="text-sm text-red-700 mb-4">Only empty locations can be deleted: move their assets and the locations inside them elsewhere first.</p>
+                        <form action="/locations/{{.ID}}" method="post" onsubmit="return confirm('Delete {{.Path}}?');">
+                            <input type="hidden" name="_method" value="DELETE">
+                            <button type="submit" class="py-2 px-4 rounded-md shadow-sm text-sm font-medium text-white bg-red-600 hover:bg-red-700">Delete Location</button>
+                        </form>
+                    </div>
+                    {{end}}
+                    {{end}}
+                </div>
+
+                <!-- Asset Types Page -->
+                <div id="asset-types-page" class="placeholder-page">
+                    <h2 class="text-3xl font-bold text-gray-800 mb-4">Asset Types</h2>
+                    <p class="text-gray-600 mb-6">The categories assets are registered under. Each type defines the custom fields its assets record, such as the RAM of a laptop, which are checked when an asset is saved.</p>
+
+                    {{if .Can "assets:manage"}}
+                    <div class="bg-gray-50 p-6 rounded-2xl shadow-sm border border-gray-200 mb-6">
+                        <h3 class="text-xl font-semibold text-gray-700 mb-4">Add Asset Type</h3>
+                        <form action="/asset-types" method="post" class="grid grid-cols-1 md:grid-cols-4 gap-4 items-end">
+                            <div class="md:col-span-3">
+                                <label for="asset-type-name" class="block text-sm font-medium text-gray-700">Name</label>
+                                <input type="text" name="name" id="asset-type-name" required class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <button type="submit" class="py-2 px-4 rounded-md shadow-sm text-sm font-medium text-white bg-blue-600 hover:bg-blue-700">Add Asset Type</button>
+                        </form>
+                    </div>
+                    {{end}}
+
+                    <div class="bg-white p-6 rounded-2xl shadow-sm border border-gray-200 overflow-x-auto">
+                        <table class="min-w-full divide-y divide-gray-200">
+                            <thead class="bg-gray-50">
+                                <tr>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Asset Type</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Custom Fields</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Assets</th>
+                                </tr>
+                            </thead>
+                            <tbody class="bg-white divide-y divide-gray-200">
+                                {{range .AssetTypes}}
+                                <tr>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm font-medium text-gray-900"><a href="/asset-types/{{.ID}}" class="text-blue-600 hover:underline">{{.Name}}</a></td>
+                                    <td class="px-6 py-4 text-sm text-gray-500">{{range $i, $f := .Fields}}{{if $i}}, {{end}}{{$f.Label}}{{end}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm font-medium text-gray-900">{{.Assets}}</td>
+                                </tr>
+                                {{else}}
+                                <tr>
+                                    <td colspan="3" class="px-6 py-4 text-sm text-gray-500">No asset types have been added.</td>
+                                </tr>
+                                {{end}}
+                            </tbody>
+                        </table>
+                    </div>
+                </div>
+
+                <!-- Asset Type Page -->
+                <div id="asset-type-page" class="placeholder-page">
+                    {{with .AssetType}}
+                    <a href="/asset-types" class="text-sm text-blue-600 hover:underline">&larr; Back to asset types</a>
+                    <h2 class="text-3xl font-bold text-gray-800 mt-2 mb-4">{{.Name}}</h2>
+
+                    <div class="bg-white p-6 rounded-2xl shadow-sm border border-gray-200 overflow-x-auto mb-6">
+                        <h3 class="text-xl font-semibold text-gray-700 mb-4">Custom Fields</h3>
+                        <table class="min-w-full divide-y divide-gray-200">
+                            <thead class="bg-gray-50">
+                                <tr>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Label</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Name</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Kind</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Options</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Required</th>
+                                </tr>
+                            </thead>
+                            <tbody class="bg-white divide-y divide-gray-200">
+                                {{range .Fields}}
+                                <tr>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm font-medium text-gray-900">{{.Label}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500"><code>{{.Name}}</code></td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.Kind.Label}}</td>
+                                    <td class="px-6 py-4 text-sm text-gray-500">{{range $i, $o := .Options}}{{if $i}}, {{end}}{{$o}}{{end}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{if .Required}}Yes{{else}}No{{end}}</td>
+                                </tr>
+                                {{else}}
+                                <tr>
+                                    <td colspan="5" class="px-6 py-4 text-sm text-gray-500">{{.Name}} has no custom fields.</td>
+                                </tr>
+                                {{end}}
+                            </tbody>
+                        </table>
+                    </div>
+                    {{end}}
+
+                    <div class="bg-white p-6 rounded-2xl shadow-sm border border-gray-200 overflow-x-auto mb-6">
+                        <h3 class="text-xl font-semibold text-gray-700 mb-4">Assets</h3>
+                        <table class="min-w-full divide-y divide-gray-200">
+                            <thead class="bg-gray-50">
+                                <tr>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Asset Name</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Location</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">State</th>
+                                    {{with .AssetType}}{{range .Fields}}
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">{{.Label}}</th>
+                                    {{end}}{{end}}
+                                </tr>
+                            </thead>
+                            <tbody class="bg-white divide-y divide-gray-200">
+                                {{range .TypeAssets}}
+                                <tr>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm font-medium text-gray-900"><a href="/assets/{{.ID}}" class="text-blue-600 hover:underline">{{.Name}}</a></td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.Location}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.State.Label}}</td>
+                                    {{$asset := .}}
+                                    {{range $field := $.AssetType.Fields}}
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{range $asset.Fields}}{{if eq .Name $field.Name}}{{.Display}}{{end}}{{end}}</td>
+                                    {{end}}
+                                </tr>
+                                {{else}}
+                                <tr>
+                                    <td colspan="3" class="px-6 py-4 text-sm text-gray-500">No assets are of this type.</td>
+                                </tr>
+                                {{end}}
+                            </tbody>
+                        </table>
+                    </div>
+
+                    {{if .Can "assets:manage"}}
+                    {{with .AssetType}}
+                    {{$type := .}}
+                    {{range .Fields}}
+                    <div class="bg-gray-50 p-6 rounded-2xl shadow-sm border border-gray-200 mb-6">
+                        <h3 class="text-xl font-semibold text-gray-700 mb-4">Edit {{.Label}} <span class="text-sm font-normal text-gray-500">({{.Kind.Label}})</span></h3>
+                        <form action="/asset-types/{{$type.ID}}/fields/{{.ID}}" method="post" class="space-y-4">
+                            <input type="hidden" name="_method" value="PUT">
+                            <div>
+                                <label for="edit-field-{{.ID}}-label" class="block text-sm font-medium text-gray-700">Label</label>
+                                <input type="text" name="label" id="edit-field-{{.ID}}-label" value="{{.Label}}" required class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="edit-field-{{.ID}}-name" class="block text-sm font-medium text-gray-700">Name</label>
+                                <input type="text" name="name" id="edit-field-{{.ID}}-name" value="{{.Name}}" pattern="[a-z][a-z0-9_]*" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            {{if eq .Kind "enum"}}
+                            <div>
+                                <label for="edit-field-{{.ID}}-options" class="block text-sm font-medium text-gray-700">Options, one per line</label>
+                                <textarea name="options" id="edit-field-{{.ID}}-options" rows="3" required class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">{{range .Options}}{{.}}
+{{end}}</textarea>
+                            </div>
+                            {{end}}
+                            <label class="flex items-center text-sm text-gray-700"><input type="checkbox" name="required" value="1" {{if .Required}}checked{{end}} class="mr-2">Required</label>
+                            <div class="flex space-x-3">
+                                <button type="submit" class="py-2 px-4 rounded-md shadow-sm text-sm font-medium text-white bg-blue-600 hover:bg-blue-700">Save Field</button>
+                            </div>
+                        </form>
+                        <form action="/asset-types/{{$type.ID}}/fields/{{.ID}}" method="post" class="mt-3" onsubmit="return confirm('Remove {{.Label}}? Every asset\'s value for it is removed too.');">
+                            <input type="hidden" name="_method" value="DELETE">
+                            <button type="submit" class="text-sm text-red-600 hover:underline">Remove field</button>
+                        </form>
+                    </div>
+                    {{end}}
+
+                    <div class="bg-gray-50 p-6 rounded-2xl shadow-sm border border-gray-200 mb-6">
+                        <h3 class="text-xl font-semibold text-gray-700 mb-4">Add Custom Field</h3>
+                        <form action="/asset-types/{{.ID}}/fields" method="post" class="space-y-4">
+                            <div>
+                                <label for="new-field-label" class="block text-sm font-medium text-gray-700">Label</label>
+                                <input type="text" name="label" id="new-field-label" required class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="new-field-name" class="block text-sm font-medium text-gray-700">Name</label>
+                                <input type="text" name="name" id="new-field-name" placeholder="Derived from the label" pattern="[a-z][a-z0-9_]*" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="new-field-kind" class="block text-sm font-medium text-gray-700">Kind</label>
+                                <select name="kind" id="new-field-kind" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                                    {{range $.FieldKinds}}
+                                    <option value="{{.}}">{{.Label}}</option>
+                                    {{end}}
+                                </select>
+                            </div>
+                            <div>
+                                <label for="new-field-options" class="block text-sm font-medium text-gray-700">Options, one per line (choice fields only)</label>
+                                <textarea name="options" id="new-field-options" rows="3" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm"></textarea>
+                            </div>
+                            <label class="flex items-center text-sm text-gray-700"><input type="checkbox" name="required" value="1" class="mr-2">Required</label>
+                            <p class="text-sm text-gray-500">The name is how imports, the API and reports refer to the field. A field's kind cannot be changed once added, as values are stored in its format.</p>
+                            <button type="submit" class="py-2 px-4 rounded-md shadow-sm text-sm font-medium text-white bg-blue-600 hover:bg-blue-700">Add Field</button>
+                        </form>
+                    </div>
+
+                    <div class="bg-gray-50 p-6 rounded-2xl shadow-sm border border-gray-200 mb-6">
+                        <h3 class="text-xl font-semibold text-gray-700 mb-4">Rename Asset Type</h3>
+                        <form action="/asset-types/{{.ID}}" method="post" class="grid grid-cols-1 md:grid-cols-4 gap-4 items-end">
+                            <input type="hidden" name="_method" value="PUT">
+                            <div class="md:col-span-3">
+                                <label for="edit-asset-type-name" class="block text-sm font-medium text-gray-700">Name</label>
+                                <input type="text" name="name" id="edit-asset-type-name" value="{{.Name}}" required class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <button type="submit" class="py-2 px-4 rounded-md shadow-sm text-sm font-medium text-white bg-blue-600 hover:bg-blue-700">Save Changes</button>
+                        </form>
+                    </div>
+
+                    <div class="bg-red-50 p-6 rounded-2xl shadow-sm border border-red-200">
+                        <h3 class="text-xl font-semibold text-red-700 mb-2">Delete Asset Type</h3>
+                        <p class="text-sm text-red-700 mb-4">Only types no asset is of can be deleted: change the type of its assets first.</p>
+                        <form action="/asset-types/{{.ID}}" method="post" onsubmit="return confirm('Delete {{.Name}} and its custom fields?');">
+                            <input type="hidden" name="_method" value="DELETE">
+                            <button type="submit" class="py-2 px-4 rounded-md shadow-sm text-sm font-medium text-white bg-red-600 hover:bg-red-700">Delete Asset Type</button>
+                        </form>
+                    </div>
+                    {{end}}
+                    {{end}}
+                </div>
+
+                <!-- People Page -->
+                <div id="people-page" class="placeholder-page">
+                    <h2 class="text-3xl font-bold text-gray-800 mb-4">People</h2>
+                    <p class="text-gray-600 mb-6">The people and departments assets can be checked out to. Linking a person to a user account lets them see the assets they hold under My Assets.</p>
+
+                    {{if .Can "assets:manage"}}
+                    <div class="grid grid-cols-1 md:grid-cols-2 gap-6 mb-6">
+                        <div class="bg-gray-50 p-6 rounded-2xl shadow-sm border border-gray-200">
+                            <h3 class="text-xl font-semibold text-gray-700 mb-4">Add Person</h3>
+                            <form action="/people" method="post" class="space-y-4">
+                                <div>
+                                    <label for="person-name" class="block text-sm font-medium text-gray-700">Name</label>
+                                    <input type="text" name="name" id="person-name" required class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                                </div>
+                                <div>
+                                    <label for="person-email" class="block text-sm font-medium text-gray-700">Email</label>
+                                    <input type="email" name="email" id="person-email" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                                </div>
+                                <div>
+                                    <label for="person-department" class="block text-sm font-medium text-gray-700">Department</label>
+                                    <select name="department-id" id="person-department" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                                        <option value="">None</option>
+                                        {{range .Departments}}
+                                        <option value="{{.ID}}">{{.Name}}</option>
+                                        {{end}}
+                                    </select>
+                                </div>
+                                <div>
+                                    <label for="person-username" class="block text-sm font-medium text-gray-700">User Account</label>
+                                    <input type="text" name="username" id="person-username" placeholder="Username, if they sign in" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                                </div>
+                                <button type="submit" class="py-2 px-4 rounded-md shadow-sm text-sm font-medium text-white bg-blue-600 hover:bg-blue-700">Save Person</button>
+                            </form>
+                        </div>
+                        <div class="bg-gray-50 p-6 rounded-2xl shadow-sm border border-gray-200">
+                            <h3 class="text-xl font-semibold text-gray-700 mb-4">Departments</h3>
+                            <ul class="divide-y divide-gray-200 mb-4">
+                                {{range .Departments}}
+                                <li class="py-2 text-sm flex justify-between items-center">
+                                    <span class="text-gray-900">{{.Name}} <span class="text-gray-500">({{.Members}})</span></span>
+                                    <form action="/departments/{{.ID}}" method="post" onsubmit="return confirm('Delete the {{.Name}} department? Its members are kept without a department.');">
+                                        <input type="hidden" name="_method" value="DELETE">
+                                        <button type="submit" class="text-red-600 hover:underline">Delete</button>
+                                    </form>
+                                </li>
+                                {{else}}
+                                <li class="py-2 text-sm text-gray-500">No departments yet.</li>
+                                {{end}}
+                            </ul>
+                            <form action="/departments" method="post" class="flex items-end space-x-2">
+                                <div class="flex-1">
+                                    <label for="department-name" class="block text-sm font-medium text-gray-700">New Department</label>
+                                    <input type="text" name="name" id="department-name" required class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                                </div>
+                                <button type="submit" class="py-2 px-4 rounded-md shadow-sm text-sm font-medium text-white bg-blue-600 hover:bg-blue-700">Add</button>
+                            </form>
+                        </div>
+                    </div>
+                    {{end}}
+
+                    <div class="bg-white p-6 rounded-2xl shadow-sm border border-gray-200 overflow-x-auto">
+                        <h3 class="text-xl font-semibold text-gray-700 mb-4">Directory</h3>
+                        <table class="min-w-full divide-y divide-gray-200">
+                            <thead class="bg-gray-50">
+                                <tr>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Name</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Department</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Email</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">User Account</th>
+                                </tr>
+                            </thead>
+                            <tbody class="bg-white divide-y divide-gray-200">
+                                {{range .People}}
+                                <tr>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm font-medium text-gray-900"><a href="/people/{{.ID}}" class="text-blue-600 hover:underline">{{.Name}}</a></td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.Department}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.Email}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.Username}}</td>
+                                </tr>
+                                {{else}}
+                                <tr>
+                                    <td colspan="4" class="px-6 py-4 text-sm text-gray-500">No people have been added.</td>
+                                </tr>
+                                {{end}}
+                            </tbody>
+                        </table>
+                    </div>
+                </div>
+
+                <!-- Person Page -->
+                <div id="person-page" class="placeholder-page">
+                    {{with .Person}}
+                    <a href="/people" class="text-sm text-blue-600 hover:underline">&larr; Back to people</a>
+                    <h2 class="text-3xl font-bold text-gray-800 mt-2 mb-4">{{.Name}}</h2>
+                    <dl class="grid grid-cols-1 md:grid-cols-3 gap-4 bg-gray-50 p-6 rounded-2xl shadow-sm border border-gray-200 mb-6">
+                        <div>
+                            <dt class="text-sm font-medium text-gray-500">Department</dt>
+                            <dd class="text-gray-900">{{.Department}}</dd>
+                        </div>
+                        <div>
+                            <dt class="text-sm font-medium text-gray-500">Email</dt>
+                            <dd class="text-gray-900">{{.Email}}</dd>
+                        </div>
+                        <div>
+                            <dt class="text-sm font-medium text-gray-500">User Account</dt>
+                            <dd class="text-gray-900">{{.Username}}</dd>
+                        </div>
+                    </dl>
+                    {{end}}
+
+                    <div class="bg-white p-6 rounded-2xl shadow-sm border border-gray-200 overflow-x-auto mb-6">
+                        <h3 class="text-xl font-semibold text-gray-700 mb-4">Assets Held</h3>
+                        <table class="min-w-full divide-y divide-gray-200">
+                            <thead class="bg-gray-50">
+                                <tr>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Asset</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Checked Out</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Expected Return</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Notes</th>
+                                </tr>
+                            </thead>
+                            <tbody class="bg-white divide-y divide-gray-200">
+                                {{range .Checkouts}}
+                                <tr>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm font-medium text-gray-900">{{if $.Can "assets:view"}}<a href="/assets/{{.AssetID}}" class="text-blue-600 hover:underline">{{.AssetName}}</a>{{else}}{{.AssetName}}{{end}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.CheckedOutAt.Format "01/02/2006"}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm {{if .Overdue}}text-red-600 font-medium{{else}}text-gray-500{{end}}">{{if not .ExpectedReturn.IsZero}}{{.ExpectedReturn.Format "01/02/2006"}}{{if .Overdue}} (overdue){{end}}{{end}}</td>
+                                    <td class="px-6 py-4 text-sm text-gray-500">{{.Notes}}</td>
+                                </tr>
+                                {{else}}
+                                <tr>
+                                    <td colspan="4" class="px-6 py-4 text-sm text-gray-500">No assets are checked out.</td>
+                                </tr>
+                                {{end}}
+                            </tbody>
+                        </table>
+                    </div>
+
+                    {{if .Can "assets:manage"}}
+                    {{with .Person}}
+                    <div class="bg-gray-50 p-6 rounded-2xl shadow-sm border border-gray-200 mb-6">
+                        <h3 class="text-xl font-semibold text-gray-700 mb-4">Edit Person</h3>
+                        <form action="/people/{{.ID}}" method="post" class="space-y-4">
+                            <input type="hidden" name="_method" value="PUT">
+                            <div>
+                                <label for="edit-person-name" class="block text-sm font-medium text-gray-700">Name</label>
+                                <input type="text" name="name" id="edit-person-name" value="{{.Name}}" required class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="edit-person-email" class="block text-sm font-medium text-gray-700">Email</label>
+                                <input type="email" name="email" id="edit-person-email" value="{{.Email}}" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="edit-person-department" class="block text-sm font-medium text-gray-700">Department</label>
+                                <select name="department-id" id="edit-person-department" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                                    <option value="">None</option>
+                                    {{$department := .DepartmentID}}
+                                    {{range $.Departments}}
+                                    <option value="{{.ID}}" {{if eq .ID $department}}selected{{end}}>{{.Name}}</option>
+                                    {{end}}
+                                </select>
+                            </div>
+                            <div>
+                                <label for="edit-person-username" class="block text-sm font-medium text-gray-700">User Account</label>
+                                <input type="text" name="username" id="edit-person-username" value="{{.Username}}" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <button type="submit" class="py-2 px-4 rounded-md shadow-sm text-sm font-medium text-white bg-blue-600 hover:bg-blue-700">Save Changes</button>
+                        </form>
+                    </div>
+
+                    <div class="bg-red-50 p-6 rounded-2xl shadow-sm border border-red-200">
+                        <h3 class="text-xl font-semibold text-red-700 mb-2">Delete Person</h3>
+                        <p class="text-sm text-red-700 mb-4">People can only be removed once every asset they hold has been checked in. Their name stays in the custody history of the assets they held.</p>
+                        <form action="/people/{{.ID}}" method="post" onsubmit="return confirm('Delete {{.Name}}?');">
+                            <input type="hidden" name="_method" value="DELETE">
+                            <button type="submit" class="py-2 px-4 rounded-md shadow-sm text-sm font-medium text-white bg-red-600 hover:bg-red-700">Delete Person</button>
+                        </form>
+                    </div>
+                    {{end}}
+                    {{end}}
+                </div>
+
+                <!-- My Assets Page -->
+                <div id="my-assets-page" class="placeholder-page">
+                    <h2 class="text-3xl font-bold text-gray-800 mb-4">My Assets</h2>
+                    {{if .Person}}
+                    <p class="text-gray-600 mb-6">The assets checked out to {{.Person.Name}}. Return them by the expected date or ask for the loan to be extended.</p>
+                    <div class="bg-white p-6 rounded-2xl shadow-sm border border-gray-200 overflow-x-auto">
+                        <table class="min-w-full divide-y divide-gray-200">
+                            <thead class="bg-gray-50">
+                                <tr>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Asset</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Checked Out</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Expected Return</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Notes</th>
+                                </tr>
+                            </thead>
+                            <tbody class="bg-white divide-y divide-gray-200">
+                                {{range .Checkouts}}
+                                <tr>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm font-medium text-gray-900">{{if $.Can "assets:view"}}<a href="/assets/{{.AssetID}}" class="text-blue-600 hover:underline">{{.AssetName}}</a>{{else}}{{.AssetName}}{{end}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.CheckedOutAt.Format "01/02/2006"}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm {{if .Overdue}}text-red-600 font-medium{{else}}text-gray-500{{end}}">{{if not .ExpectedReturn.IsZero}}{{.ExpectedReturn.Format "01/02/2006"}}{{if .Overdue}} (overdue){{end}}{{end}}</td>
+                                    <td class="px-6 py-4 text-sm text-gray-500">{{.Notes}}</td>
+                                </tr>
+                                {{else}}
+                                <tr>
+                                    <td colspan="4" class="px-6 py-4 text-sm text-gray-500">No assets are checked out.</td>
+                                </tr>
                                 {{end}}
-                            </ul>
-                        </div>
-                        <!-- Key Information Card -->
-                        <div class="bg-gray-50 p-6 rounded-2xl shadow-sm border border-gray-200">
-                            <h3 class="text-xl font-semibold text-gray-700 mb-4">Key Information</h3>
-                            <ul class="list-disc list-inside space-y-2 text-gray-600">
-                                <li>**Total Assets:** {{.TotalAssets}}</li>
-                                <li>**Total Licenses:** {{.TotalLicenses}}</li>
-                                <li>**Expiring Soon:** {{.ExpiringSoon}}</li>
-                                <li>**High-Risk Items:** 3</li>
-                            </ul>
-                        </div>
-                        <!-- Quick Actions Card -->
-                        <div class="bg-gray-50 p-6 rounded-2xl shadow-sm border border-gray-200">
-                            <h3 class="text-xl font-semibold text-gray-700 mb-4">Quick Actions</h3>
-                            <ul class="space-y-2">
-                                <li><a href="/assets" class="block py-2 px-4 rounded-md text-sm font-medium text-blue-600 bg-blue-100 hover:bg-blue-200 transition-colors duration-200">Add New Asset</a></li>
-                                <li><a href="#" class="block py-2 px-4 rounded-md text-sm font-medium text-green-600 bg-green-100 hover:bg-green-200 transition-colors duration-200">Run Compliance Report</a></li>
-                                <li><a href="#" class="block py-2 px-4 rounded-md text-sm font-medium text-red-600 bg-red-100 hover:bg-red-200 transition-colors duration-200">Review High-Risk Items</a></li>
-                            </ul>
-                        </div>
+                            </tbody>
+                        </table>
                     </div>
+                    {{else}}
+                    <p class="text-gray-600 mb-6">Your user account is not linked to anyone in the people directory, so no assets can be shown. Ask an asset manager to link your username to your entry.</p>
+                    {{end}}
                 </div>
 
-                <!-- Asset Register Page -->
-                <div id="assets-page" class="placeholder-page">
-                    <h2 class="text-3xl font-bold text-gray-800 mb-4">Asset Register</h2>
-                    <p class="text-gray-600 mb-6">Add and view a detailed list of all software and hardware assets.</p>
+                <!-- Users Page -->
+                <div id="users-page" class="placeholder-page">
+                    <h2 class="text-3xl font-bold text-gray-800 mb-4">Users</h2>
+                    <p class="text-gray-600 mb-6">Create accounts and assign the role that controls what each person can do.</p>
 
-                    <!-- Add New Asset Form -->
                     <div class="bg-gray-50 p-6 rounded-2xl shadow-sm border border-gray-200 mb-6">
-                        <h3 class="text-xl font-semibold text-gray-700 mb-4">Add New Asset</h3>
-                        <form action="/assets" method="post" class="space-y-4">
+                        <h3 class="text-xl font-semibold text-gray-700 mb-4">Add New User</h3>
+                        <form action="/users" method="post" class="space-y-4">
                             <div>
-                                <label for="name" class="block text-sm font-medium text-gray-700">Asset Name</label>
-                                <input type="text" name="name" id="name" required class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                                <label for="new-username" class="block text-sm font-medium text-gray-700">Username</label>
+                                <input type="text" name="username" id="new-username" required class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
                             </div>
                             <div>
-                                <label for="asset-type" class="block text-sm font-medium text-gray-700">Asset Type</label>
-                                <input type="text" name="asset-type" id="asset-type" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                                <label for="new-password" class="block text-sm font-medium text-gray-700">Password</label>
+                                <input type="password" name="password" id="new-password" required minlength="8" autocomplete="new-password" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
                             </div>
                             <div>
-                                <label for="location" class="block text-sm font-medium text-gray-700">Location</label>
-                                <input type="text" name="location" id="location" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                                <label for="new-role" class="block text-sm font-medium text-gray-700">Role</label>
+                                <select name="role" id="new-role" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                                    {{range .Roles}}
+                                    <option value="{{.}}">{{.Label}}</option>
+                                    {{end}}
+                                </select>
                             </div>
                             <button type="submit" class="w-full flex justify-center py-2 px-4 border border-transparent rounded-md shadow-sm text-sm font-medium text-white bg-blue-600 hover:bg-blue-700 focus:outline-none focus:ring-2 focus:ring-offset-2 focus:ring-blue-500 transition-colors duration-300">
-                                Save Asset
+                                Save User
                             </button>
                         </form>
                     </div>
 
-                    <!-- Assets Table -->
                     <div class="bg-white p-6 rounded-2xl shadow-sm border border-gray-200 overflow-x-auto">
-                        <h3 class="text-xl font-semibold text-gray-700 mb-4">Current Assets</h3>
+                        <h3 class="text-xl font-semibold text-gray-700 mb-4">Current Users</h3>
                         <table class="min-w-full divide-y divide-gray-200">
                             <thead class="bg-gray-50">
                                 <tr>
-                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Asset Name</th>
-                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Type</th>
-                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Location</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Username</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Role</th>
                                 </tr>
                             </thead>
                             <tbody class="bg-white divide-y divide-gray-200">
-                                {{range .Assets}}
+                                {{range $u := .Users}}
                                 <tr>
-                                    <td class="px-6 py-4 whitespace-nowrap text-sm font-medium text-gray-900">{{.Name}}</td>
-                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.AssetType}}</td>
-                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">{{.Location}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm font-medium text-gray-900">{{$u.Username}}</td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-sm text-gray-500">
+                                        <form action="/users/{{$u.ID}}/role" method="post" class="flex items-center space-x-2">
+                                            <select name="role" class="rounded-md border-gray-300 shadow-sm sm:text-sm">
+                                                {{range $.Roles}}
+                                                <option value="{{.}}" {{if eq . $u.Role}}selected{{end}}>{{.Label}}</option>
+                                                {{end}}
+                                            </select>
+                                            <button type="submit" class="text-blue-600 hover:underline">Update</button>
+                                        </form>
+                                    </td>
                                 </tr>
                                 {{end}}
                             </tbody>
                         </table>
                     </div>
                 </div>
+                <!-- Audit Log Page -->
+                <div id="audit-log-page" class="placeholder-page">
+                    <h2 class="text-3xl font-bold text-gray-800 mb-4">Audit Log</h2>
+                    <p class="text-gray-600 mb-6">Every change to assets, licenses, software, risks, FOI requests, reports, webhooks, users, people and asset custody is recorded with who made it, when, and the values before and after. Entries cannot be edited; each one's hash covers the hash of the entry before it, so any alteration or removal breaks the chain from that point.</p>
 
-                <!-- Other Placeholder Pages -->
-                <div id="compliance-audits-page" class="placeholder-page">
-                    <h2 class="text-3xl font-bold text-gray-800 mb-4">Compliance Audits</h2>
-                    <p class="text-gray-600">This page will provide tools and reports for compliance audits.</p>
-                </div>
-                <div id="license-renewals-page" class="placeholder-page">
-                    <h2 class="text-3xl font-bold text-gray-800 mb-4">License Renewals</h2>
-                    <p class="text-gray-600">This page will show a calendar and list of upcoming license renewals and expiries.</p>
-                </div>
-                <div id="risk-register-page" class="placeholder-page">
-                    <h2 class="text-3xl font-bold text-gray-800 mb-4">Risk Register</h2>
-                    <p class="text-gray-600">This page will show a risk register that will outline all key risks, their mitigations, and ownerships.</p>
-                </div>
-                <div id="report-execution-page" class="placeholder-page">
-                    <h2 class="text-3xl font-bold text-gray-800 mb-4">Report Execution</h2>
-                    <p class="text-gray-600">This page will allow for the execution and generation of various reports on software and asset data.</p>
-                </div>
-                <div id="foi-requests-page" class="placeholder-page">
-                    <h2 class="text-3xl font-bold text-gray-800 mb-4">Freedom of Information Requests</h2>
-                    <p class="text-gray-600">This page will be used to manage and track requests for information related to software and assets.</p>
+                    {{with .AuditVerification}}
+                    <div class="mb-6 py-2 px-4 rounded-md text-sm {{if .Intact}}text-green-700 bg-green-100{{else}}text-red-700 bg-red-100{{end}}">
+                        {{if .Intact}}
+                        <p>Chain intact: all {{.Entries}} entries verified at {{.CheckedAt.Format "01/02/2006 15:04:05"}} UTC.</p>
+                        {{else}}
+                        <p>Chain broken at entry {{.BrokenAt}}: {{.Problem}}. {{.Entries}} entries checked at {{.CheckedAt.Format "01/02/2006 15:04:05"}} UTC.</p>
+                        {{end}}
+                        {{if .Head}}<p class="mt-1">Latest hash <code class="break-all">{{.Head}}</code>; keep a copy to detect entries removed from the end of the log.</p>{{end}}
+                    </div>
+                    {{end}}
+
+                    <!-- Audit Filter -->
+                    {{with .AuditFilter}}
+                    <div class="bg-gray-50 p-6 rounded-2xl shadow-sm border border-gray-200 mb-6">
+                        <form action="/audit" method="get" class="grid grid-cols-1 sm:grid-cols-2 lg:grid-cols-4 gap-4">
+                            <div>
+                                <label for="audit-entity" class="block text-sm font-medium text-gray-700">Entity</label>
+                                <select name="entity" id="audit-entity" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                                    <option value="">Any</option>
+                                    {{$entity := .Entity}}
+                                    {{range $.AuditEntities}}
+                                    <option value="{{.}}" {{if eq . $entity}}selected{{end}}>{{.}}</option>
+                                    {{end}}
+                                </select>
+                            </div>
+                            <div>
+                                <label for="audit-entity-id" class="block text-sm font-medium text-gray-700">Entity ID</label>
+                                <input type="number" name="entity_id" id="audit-entity-id" min="1" value="{{if .EntityID}}{{.EntityID}}{{end}}" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="audit-actor" class="block text-sm font-medium text-gray-700">Actor</label>
+                                <input type="text" name="actor" id="audit-actor" value="{{.Actor}}" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="audit-action" class="block text-sm font-medium text-gray-700">Action</label>
+                                <input type="text" name="action" id="audit-action" value="{{.Action}}" placeholder="create, update, delete..." class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div class="sm:col-span-2">
+                                <label for="audit-q" class="block text-sm font-medium text-gray-700">Values containing</label>
+                                <input type="text" name="q" id="audit-q" value="{{.Query}}" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="audit-from" class="block text-sm font-medium text-gray-700">From</label>
+                                <input type="date" name="from" id="audit-from" value="{{if not .From.IsZero}}{{.From.Format "2006-01-02"}}{{end}}" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="audit-to" class="block text-sm font-medium text-gray-700">To</label>
+                                <input type="date" name="to" id="audit-to" value="{{if not .To.IsZero}}{{.To.Format "2006-01-02"}}{{end}}" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div class="sm:col-span-2 lg:col-span-4 flex items-center gap-4">
+                                <button type="submit" class="py-2 px-4 rounded-md shadow-sm text-sm font-medium text-white bg-blue-600 hover:bg-blue-700">Search</button>
+                                <a href="/audit" class="text-sm text-blue-600 hover:underline">Clear</a>
+                                <a href="/audit/verify" class="ml-auto py-2 px-4 rounded-md text-sm font-medium text-green-600 bg-green-100 hover:bg-green-200">Verify Chain</a>
+                            </div>
+                        </form>
+                    </div>
+                    {{end}}
+
+                    <!-- Audit Entries -->
+                    <div class="bg-white p-6 rounded-2xl shadow-sm border border-gray-200 overflow-x-auto">
+                        <table class="min-w-full divide-y divide-gray-200">
+                            <thead class="bg-gray-50">
+                                <tr>
+                                    <th scope="col" class="px-4 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">When (UTC)</th>
+                                    <th scope="col" class="px-4 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Actor</th>
+                                    <th scope="col" class="px-4 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Entity</th>
+                                    <th scope="col" class="px-4 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Action</th>
+                                    <th scope="col" class="px-4 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Changes</th>
+                                </tr>
+                            </thead>
+                            <tbody class="bg-white divide-y divide-gray-200">
+                                {{range .AuditEntries}}
+                                <tr class="align-top">
+                                    <td class="px-4 py-2 whitespace-nowrap text-sm text-gray-500">{{.OccurredAt.Format "01/02/2006 15:04:05"}}</td>
+                                    <td class="px-4 py-2 whitespace-nowrap text-sm text-gray-900">{{.Actor}}</td>
+                                    <td class="px-4 py-2 whitespace-nowrap text-sm text-gray-500">{{if .Link}}<a href="{{.Link}}" class="text-blue-600 hover:underline">{{.Entity}} #{{.EntityID}}</a>{{else}}{{.Entity}} #{{.EntityID}}{{end}}</td>
+                                    <td class="px-4 py-2 whitespace-nowrap text-sm text-gray-900">{{.Action}}</td>
+                                    <td class="px-4 py-2 text-sm text-gray-500">
+                                        <ul class="space-y-1">
+                                            {{range .Changes}}
+                                            <li class="break-all">{{if .Field}}<span class="font-medium text-gray-700">{{.Field}}</span>: {{end}}{{if .Before}}<span class="line-through text-red-600">{{.Before}}</span>{{end}}{{if and .Before .After}} &rarr; {{end}}{{if .After}}<span class="text-green-700">{{.After}}</span>{{end}}</li>
+                                            {{end}}
+                                        </ul>
+                                        <p class="mt-1 text-xs text-gray-400" title="{{.Hash}}">#{{.ID}} {{printf "%.12s" .Hash}}</p>
+                                    </td>
+                                </tr>
+                                {{else}}
+                                <tr>
+                                    <td colspan="5" class="px-4 py-2 text-sm text-gray-500">No audit entries match.</td>
+                                </tr>
+                                {{end}}
+                            </tbody>
+                        </table>
+                        {{with .AuditFilter}}
+                        <div class="flex justify-between mt-4 text-sm">
+                            {{if .Offset}}<a href="{{.PrevURL}}" class="text-blue-600 hover:underline">&larr; Newer</a>{{else}}<span></span>{{end}}
+                            {{if $.AuditMore}}<a href="{{.NextURL}}" class="text-blue-600 hover:underline">Older &rarr;</a>{{end}}
+                        </div>
+                        {{end}}
+                    </div>
                 </div>
                 <div id="settings-page" class="placeholder-page">
                     <h2 class="text-3xl font-bold text-gray-800 mb-4">Database Settings</h2>
-                    <p class="text-gray-600 mb-6">Select your preferred database type for future development. This is for demonstration purposes only and does not change the live connection.</p>
+                    <p class="text-gray-600 mb-6">The live database is chosen at startup with the <code>database.driver</code> (<code>sqlite</code>, <code>postgres</code> or <code>mysql</code>) and <code>database.dsn</code> settings in the config file, the <code>SLAM_DB_DRIVER</code> and <code>SLAM_DB_DSN</code> environment variables, or the <code>-db-driver</code> and <code>-db-dsn</code> flags. The highlighted backend is the one currently in use.</p>
                     <div class="grid grid-cols-1 sm:grid-cols-2 lg:grid-cols-3 gap-4">
-                        <div class="p-4 bg-gray-50 rounded-lg border border-gray-200 text-center cursor-pointer hover:bg-blue-100 transition-colors duration-200">
-                            <h3 class="font-medium text-lg text-gray-800">PostgreSQL</h3>
-                            <p class="text-sm text-gray-500">A powerful, open-source relational database system.</p>
-                        </div>
-                        <div class="p-4 bg-gray-50 rounded-lg border border-gray-200 text-center cursor-pointer hover:bg-blue-100 transition-colors duration-200">
-                            <h3 class="font-medium text-lg text-gray-800">Oracle Database</h3>
-                            <p class="text-sm text-gray-500">A widely used enterprise-grade relational database.</p>
-                        </div>
-                        <div class="p-4 bg-gray-50 rounded-lg border border-gray-200 text-center cursor-pointer hover:bg-blue-100 transition-colors duration-200">
-                            <h3 class="font-medium text-lg text-gray-800">MySQL</h3>
-                            <p class="text-sm text-gray-500">The world's most popular open source database.</p>
-                        </div>
-                        <div class="p-4 bg-gray-50 rounded-lg border border-gray-200 text-center cursor-pointer hover:bg-blue-100 transition-colors duration-200">
-                            <h3 class="font-medium text-lg text-gray-800">SQL Server</h3>
-                            <p class="text-sm text-gray-500">Microsoft's relational database management system.</p>
-                        </div>
-                        <div class="p-4 bg-gray-50 rounded-lg border border-gray-200 text-center cursor-pointer hover:bg-blue-100 transition-colors duration-200">
-                            <h3 class="font-medium text-lg text-gray-800">SQLite</h3>
-                            <p class="text-sm text-gray-500">A lightweight, file-based database perfect for local use.</p>
+                        {{range .DatabaseBackends}}
+                        <div class="p-4 rounded-lg border text-center {{if eq .Name $.DatabaseBackend}}bg-blue-100 border-blue-500{{else}}bg-gray-50 border-gray-200{{end}}">
+                            <h3 class="font-medium text-lg text-gray-800">{{.Label}}</h3>
+                            <p class="text-sm text-gray-500">{{.Description}}</p>
+                            {{if eq .Name $.DatabaseBackend}}
+                            <p class="mt-2 text-xs font-medium text-blue-700">In use</p>
+                            {{else if not .Supported}}
+                            <p class="mt-2 text-xs font-medium text-gray-400">Not yet supported</p>
+                            {{end}}
                         </div>
+                        {{end}}
+                    </div>
+
+                    {{if .Can "settings:manage"}}
+                    <h2 class="text-3xl font-bold text-gray-800 mt-10 mb-4">Webhooks</h2>
+                    <p class="text-gray-600 mb-6">Each webhook receives a JSON POST for the events it subscribes to. Requests carry <code>X-SLAM-Event</code>, <code>X-SLAM-Delivery</code> and <code>X-SLAM-Timestamp</code> headers and an <code>X-SLAM-Signature</code> of <code>sha256=</code> followed by the hex HMAC-SHA256 of the timestamp, a dot and the body, keyed with the webhook secret. A delivery that does not get a 2xx response is retried with exponential backoff, starting after 30 seconds, up to six attempts in all.</p>
+
+                    <!-- Webhook Form -->
+                    <div class="bg-gray-50 p-6 rounded-2xl shadow-sm border border-gray-200 mb-6">
+                        <h3 class="text-xl font-semibold mb-4 text-gray-700">Add Webhook</h3>
+                        <form action="/settings/webhooks" method="post" class="space-y-4">
+                            <div>
+                                <label for="webhook-url" class="block text-sm font-medium text-gray-700">Payload URL</label>
+                                <input type="url" name="url" id="webhook-url" required placeholder="https://servicedesk.example.org/hooks/slam" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <div>
+                                <label for="webhook-secret" class="block text-sm font-medium text-gray-700">Secret</label>
+                                <input type="text" name="secret" id="webhook-secret" placeholder="Leave blank to generate one" class="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm">
+                            </div>
+                            <fieldset>
+                                <legend class="block text-sm font-medium text-gray-700">Events</legend>
+                                <div class="mt-1 flex flex-wrap gap-4">
+                                    {{range .WebhookEvents}}
+                                    <label class="inline-flex items-center text-sm text-gray-700"><input type="checkbox" name="events" value="{{.}}" class="mr-1 rounded border-gray-300">{{.}}</label>
+                                    {{end}}
+                                </div>
+                            </fieldset>
+                            <button type="submit" class="py-2 px-4 rounded-md shadow-sm text-sm font-medium text-white bg-blue-600 hover:bg-blue-700">Add Webhook</button>
+                        </form>
+                    </div>
+
+                    <!-- Webhooks Table -->
+                    <div class="bg-white p-6 rounded-2xl shadow-sm border border-gray-200 overflow-x-auto mb-6">
+                        <table class="min-w-full divide-y divide-gray-200">
+                            <thead class="bg-gray-50">
+                                <tr>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">URL</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Events</th>
+                                    <th scope="col" class="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Secret</th>
+                                    <th scope="col" class="px-6 py-3"></th>
+                                </tr>
+                            </thead>
+                            <tbody class="bg-white divide-y divide-gray-200">
+                                {{range .Webhooks}}
+                                <tr>
+                                    <td class="px-6 py-4 text-sm font-medium text-gray-900 break-all">{{.URL}}</td>
+                                    <td class="px-6 py-4 text-sm text-gray-500">{{range $i, $e := .Events}}{{if $i}}, {{end}}{{$e}}{{end}}</td>
+                                    <td class="px-6 py-4 text-sm text-gray-500"><code class="break-all">{{.Secret}}</code></td>
+                                    <td class="px-6 py-4 whitespace-nowrap text-right text-sm">
+                                        <form action="/settings/webhooks/{{.ID}}/delete" method="post" onsubmit="return confirm('Delete this webhook and its delivery log?');">
+                                            <button type="submit" class="text-red-600 hover:underline">Delete</button>
+                                        </form>
+                                    </td>
+                                </tr>
+                                {{else}}
+                                <tr>
+                                    <td colspan="4" class="px-6 py-4 text-sm text-gray-500">No webhooks have been added.</td>
+                                </tr>
+                                {{end}}
+                            </tbody>
+                        </table>
+                    </div>
+
+                    <!-- Delivery Log -->
+                    <div class="bg-white p-6 rounded-2xl shadow-sm border border-gray-200 overflow-x-auto">
+                        <h3 class="text-xl font-semibold mb-4 text-gray-700">Delivery Log</h3>
+                        <table class="min-w-full divide-y divide-gray-200">
+                            <thead class="bg-gray-50">
+                                <tr>
+                                    <th scope="col" class="px-4 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Queued</th>
+                                    <th scope="col" class="px-4 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Event</th>
+                                    <th scope="col" class="px-4 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">URL</th>
+                                    <th scope="col" class="px-4 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Status</th>
+                                    <th scope="col" class="px-4 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Attempts</th>
+                                    <th scope="col" class="px-4 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Last Result</th>
+                                    <th scope="col" class="px-4 py-3"></th>
+                                </tr>
+                            </thead>
+                            <tbody class="bg-white divide-y divide-gray-200">
+                                {{range .WebhookDeliveries}}
+                                <tr>
+                                    <td class="px-4 py-2 whitespace-nowrap text-sm text-gray-500">{{.CreatedAt.Format "01/02/2006 15:04:05"}}</td>
+                                    <td class="px-4 py-2 whitespace-nowrap text-sm text-gray-900">{{.Event}}</td>
+                                    <td class="px-4 py-2 text-sm text-gray-500 break-all">{{.WebhookURL}}</td>
+                                    <td class="px-4 py-2 whitespace-nowrap text-sm">
+                                        <span class="px-2 inline-flex text-xs leading-5 font-semibold rounded-full {{if eq .Status "delivered"}}bg-green-100 text-green-800{{else if eq .Status "failed"}}bg-red-100 text-red-800{{else}}bg-yellow-100 text-yellow-800{{end}}">{{.Status}}</span>
+                                    </td>
+                                    <td class="px-4 py-2 whitespace-nowrap text-sm text-gray-500">{{.Attempts}}</td>
+                                    <td class="px-4 py-2 text-sm text-gray-500">
+                                        {{if .ResponseStatus}}HTTP {{.ResponseStatus}}{{end}}{{if .Error}} {{.Error}}{{end}}
+                                        {{if eq .Status "pending"}}{{if .Attempts}}<br>Next attempt {{.NextAttemptAt.Format "15:04:05"}}{{end}}{{else if eq .Status "delivered"}}<br>Delivered {{.DeliveredAt.Format "01/02/2006 15:04:05"}}{{end}}
+                                    </td>
+                                    <td class="px-4 py-2 whitespace-nowrap text-right text-sm">
+                                        {{if eq .Status "failed"}}
+                                        <form action="/settings/deliveries/{{.ID}}/retry" method="post">
+                                            <button type="submit" class="text-blue-600 hover:underline">Retry</button>
+                                        </form>
+                                        {{end}}
+                                    </td>
+                                </tr>
+                                {{else}}
+                                <tr>
+                                    <td colspan="7" class="px-4 py-2 text-sm text-gray-500">No events have been delivered.</td>
+                                </tr>
+                                {{end}}
+                            </tbody>
+                        </table>
                     </div>
+                    {{end}}
                 </div>
             </div>
         </div>
@@ -223,8 +2572,44 @@ const dashboardContent = `
             const path = window.location.pathname;
             let activePageId = 'home-page';
 
-            if (path.startsWith('/assets')) {
+            if (/^\/(assets|licenses)\/import/.test(path)) {
+                activePageId = 'import-page';
+            } else if (/^\/assets\/\d+/.test(path)) {
+                activePageId = 'asset-detail-page';
+            } else if (path.startsWith('/assets')) {
                 activePageId = 'assets-page';
+            } else if (/^\/locations\/\d+/.test(path)) {
+                activePageId = 'location-page';
+            } else if (path.startsWith('/locations')) {
+                activePageId = 'locations-page';
+            } else if (/^\/asset-types\/\d+/.test(path)) {
+                activePageId = 'asset-type-page';
+            } else if (path.startsWith('/asset-types')) {
+                activePageId = 'asset-types-page';
+            } else if (/^\/people\/\d+/.test(path)) {
+                activePageId = 'person-page';
+            } else if (path.startsWith('/people')) {
+                activePageId = 'people-page';
+            } else if (path.startsWith('/my-assets')) {
+                activePageId = 'my-assets-page';
+            } else if (path.startsWith('/licenses/notifications')) {
+                activePageId = 'notifications-page';
+            } else if (path.startsWith('/licenses')) {
+                activePageId = 'license-renewals-page';
+            } else if (path.startsWith('/compliance')) {
+                activePageId = 'compliance-audits-page';
+            } else if (path.startsWith('/audit')) {
+                activePageId = 'audit-log-page';
+            } else if (path.startsWith('/risks')) {
+                activePageId = 'risk-register-page';
+            } else if (path.startsWith('/reports')) {
+                activePageId = 'report-execution-page';
+            } else if (path.startsWith('/foi')) {
+                activePageId = 'foi-requests-page';
+            } else if (path.startsWith('/users')) {
+                activePageId = 'users-page';
+            } else if (path.startsWith('/settings')) {
+                activePageId = 'settings-page';
             }
 
             pages.forEach(page => {
@@ -237,7 +2622,7 @@ const dashboardContent = `
 
             navLinks.forEach(link => {
                 const linkPath = new URL(link.href).pathname;
-                if (linkPath === path) {
+                if (linkPath === path || (linkPath !== '/' && path.startsWith(linkPath + '/'))) {
                     link.classList.remove('text-gray-600', 'hover:bg-gray-200');
                     link.classList.add('bg-blue-500', 'text-white', 'hover:bg-blue-600');
                 } else {
@@ -245,128 +2630,248 @@ const dashboardContent = `
                     link.classList.add('text-gray-600', 'hover:bg-gray-200');
                 }
             });
+
+            // Show the custom fields of the type chosen in an asset form,
+            // disabling the others' so that only its values are posted.
+            document.querySelectorAll('select[data-asset-types]').forEach(select => {
+                const showFields = () => {
+                    select.form.querySelectorAll('[data-asset-type]').forEach(group => {
+                        const shown = group.dataset.assetType === select.value;
+                        group.classList.toggle('hidden', !shown);
+                        group.querySelectorAll('input, select').forEach(input => { input.disabled = !shown; });
+                    });
+                };
+                select.addEventListener('change', showFields);
+                showFields();
+            });
         });
     </script>
 </body>
 </html>
 `
 
-// initDB initializes the SQLite database and creates the necessary tables.
-func initDB() {
-	dbPath := "./slam.db"
+// openDB opens the database selected by the configuration.
+func openDB(cfg DatabaseConfig) {
 	var err error
 
 	// Open the database connection
-	db, err = sql.Open("sqlite", dbPath)
+	db, err = openDatabase(cfg.Driver, cfg.DSN)
 	if err != nil {
 		log.Fatalf("Unable to open database: %v\n", err)
 	}
+}
 
-	// Create tables if they don't exist
-	_, err = db.ExecContext(context.Background(), `
-		CREATE TABLE IF NOT EXISTS licenses (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT NOT NULL,
-			vendor TEXT,
-			expiry_date DATE,
-			renewal_date DATE,
-			status TEXT
-		);
-		CREATE TABLE IF NOT EXISTS assets (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT NOT NULL,
-			asset_type TEXT,
-			location TEXT
-		);
-	`)
-	if err != nil {
-		log.Fatalf("Error creating tables: %v\n", err)
+// initDB opens the database and applies any pending schema migrations.
+func initDB(cfg DatabaseConfig) {
+	openDB(cfg)
+
+	if err := migrateUp(); err != nil {
+		log.Fatalf("Error migrating database: %v\n", err)
 	}
 
-	log.Println("Database initialized successfully.")
+	log.Printf("%s database initialized successfully.\n", db.dialect.Label)
 }
 
-// seedDB populates the database with initial data if tables are empty.
-func seedDB() {
-	var count int
-	err := db.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM assets").Scan(&count)
+// seedDB populates the repositories with initial data if they are empty.
+func seedDB(ctx context.Context, assets AssetRepository, licenses LicenseRepository, locations LocationRepository, assetTypes AssetTypeRepository) {
+	count, err := assets.Count(ctx)
 	if err != nil || count > 0 {
 		return
 	}
 
-	// Seed assets
-	assetsSQL := `
-		INSERT INTO assets (name, asset_type, location) VALUES
-		('Dell XPS 15', 'Laptop', 'Office 1'),
-		('ThinkPad X1 Carbon', 'Laptop', 'Office 2'),
-		('HP ProDesk 400 G7', 'Desktop', 'Office 3');
-	`
-	_, err = db.ExecContext(context.Background(), assetsSQL)
+	// Seed locations
+	rooms, err := seedLocations(ctx, locations)
 	if err != nil {
-		log.Printf("Error seeding assets: %v\n", err)
+		log.Printf("Error seeding locations: %v\n", err)
 	}
 
-	// Seed licenses
-	licensesSQL := `
-		INSERT INTO licenses (name, vendor, expiry_date, status) VALUES
-		('Microsoft Office 365', 'Microsoft', date('now', '+35 days'), 'active'),
-		('Adobe Creative Cloud', 'Adobe', date('now', '+20 days'), 'active'),
-		('Autodesk AutoCAD', 'Autodesk', date('now', '-5 days'), 'expired');
-	`
-	_, err = db.ExecContext(context.Background(), licensesSQL)
+	// Seed asset types
+	types, err := seedAssetTypes(ctx, assetTypes)
 	if err != nil {
-		log.Printf("Error seeding licenses: %v\n", err)
+		log.Printf("Error seeding asset types: %v\n", err)
+	}
+
+	// Seed assets
+	laptopFields := func(ram, os string) []AssetFieldValue {
+		return []AssetFieldValue{
+			{Name: "ram_gb", Label: "RAM (GB)", Kind: FieldNumber, Value: ram},
+			{Name: "operating_system", Label: "Operating System", Kind: FieldEnum, Value: os},
+			{Name: "encrypted", Label: "Disk Encrypted", Kind: FieldBoolean, Value: "true"},
+		}
+	}
+	for _, a := range []Asset{
+		{Name: "Dell XPS 15", AssetType: "Laptop", Fields: laptopFields("32", "Windows"), Location: "Office 1", Manufacturer: "Dell", Model: "XPS 15 9530", SerialNumber: "7H2K9L3", State: AssetDeployed},
+		{Name: "ThinkPad X1 Carbon", AssetType: "Laptop", Fields: laptopFields("16", "Linux"), Location: "Office 2", Manufacturer: "Lenovo", Model: "X1 Carbon Gen 11", SerialNumber: "PF3XQ8TZ", State: AssetDeployed},
+		{Name: "HP ProDesk 400 G7", AssetType: "Desktop", Location: "Office 3", Manufacturer: "HP", Model: "ProDesk 400 G7", SerialNumber: "CZC1234XYZ", State: AssetInStock},
+	} {
+		if t, ok := types[a.AssetType]; ok {
+			a.TypeID = t.ID
+		} else {
+			a.AssetType, a.Fields = "", nil
+		}
+		room := rooms[a.Location]
+		a.LocationID, a.Location = room.ID, room.Path
+		if _, err := assets.Create(ctx, a); err != nil {
+			log.Printf("Error seeding assets: %v\n", err)
+			break
+		}
+	}
+
+	// Seed licenses
+	y, mo, d := time.Now().Date()
+	today := time.Date(y, mo, d, 0, 0, 0, 0, time.UTC)
+	for _, l := range []License{
+		{Name: "Microsoft Office 365", Vendor: "Microsoft", ExpiryDate: today.AddDate(0, 0, 35), Status: "active", Quantity: 50, Metric: MetricPerUser, Product: "Microsoft 365", UnitCost: 12.50},
+		{Name: "Adobe Creative Cloud", Vendor: "Adobe", ExpiryDate: today.AddDate(0, 0, 20), Status: "active", Quantity: 10, Metric: MetricPerUser, UnitCost: 54.99},
+		{Name: "Autodesk AutoCAD", Vendor: "Autodesk", ExpiryDate: today.AddDate(0, 0, -5), Status: "expired", Quantity: 5, Metric: MetricPerDevice, Product: "AutoCAD", UnitCost: 1775},
+	} {
+		if _, err := licenses.Create(ctx, l); err != nil {
+			log.Printf("Error seeding licenses: %v\n", err)
+			break
+		}
 	}
 
 	log.Println("Database seeded with sample data.")
 }
 
+// seedAssetTypes adds the sample assets' types, unless asset types have
+// been set up already, and returns them by name.
+func seedAssetTypes(ctx context.Context, assetTypes AssetTypeRepository) (map[string]AssetType, error) {
+	types := make(map[string]AssetType)
+	if existing, err := assetTypes.List(ctx); err != nil || len(existing) > 0 {
+		return types, err
+	}
+	for _, t := range []AssetType{
+		{Name: "Laptop", Fields: []AssetField{
+			{Name: "ram_gb", Label: "RAM (GB)", Kind: FieldNumber},
+			{Name: "operating_system", Label: "Operating System", Kind: FieldEnum, Options: []string{"Windows", "macOS", "Linux"}, Required: true},
+			{Name: "encrypted", Label: "Disk Encrypted", Kind: FieldBoolean},
+		}},
+		{Name: "Desktop"},
+		{Name: "Monitor", Fields: []AssetField{
+			{Name: "screen_size", Label: "Screen Size (in)", Kind: FieldNumber},
+		}},
+	} {
+		var err error
+		if t.ID, err = assetTypes.Create(ctx, t); err != nil {
+			return types, err
+		}
+		types[t.Name] = t
+	}
+	return types, nil
+}
+
+// seedLocations adds a site down to the rooms the sample assets are kept
+// in, unless locations have been set up already, and returns the rooms by
+// name.
+func seedLocations(ctx context.Context, locations LocationRepository) (map[string]Location, error) {
+	rooms := make(map[string]Location)
+	if existing, err := locations.List(ctx); err != nil || len(existing) > 0 {
+		return rooms, err
+	}
+	parent := Location{}
+	for _, l := range []Location{
+		{Kind: LocationSite, Name: "Head Office"},
+		{Kind: LocationBuilding, Name: "Main Building"},
+		{Kind: LocationFloor, Name: "Floor 1"},
+	} {
+		l.ParentID = parent.ID
+		l.Path = strings.TrimPrefix(parent.Path+locationPathSeparator+l.Name, locationPathSeparator)
+		var err error
+		if l.ID, err = locations.Create(ctx, l); err != nil {
+			return rooms, err
+		}
+		parent = l
+	}
+	for _, name := range []string{"Office 1", "Office 2", "Office 3"} {
+		room := Location{ParentID: parent.ID, Kind: LocationRoom, Name: name, Path: parent.Path + locationPathSeparator + name}
+		var err error
+		if room.ID, err = locations.Create(ctx, room); err != nil {
+			return rooms, err
+		}
+		rooms[name] = room
+	}
+	return rooms, nil
+}
+
 // getPageData fetches all necessary data for the dashboard and assets pages.
-func getPageData() (*PageData, error) {
+func (s *server) getPageData(ctx context.Context) (*PageData, error) {
 	data := &PageData{}
 
 	// Fetch total assets and licenses
-	err := db.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM assets").Scan(&data.TotalAssets)
+	var err error
+	data.TotalAssets, err = s.assets.Count(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching total assets: %w", err)
 	}
 
-	err = db.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM licenses").Scan(&data.TotalLicenses)
+	data.TotalLicenses, err = s.licenses.Count(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching total licenses: %w", err)
 	}
 
 	// Fetch upcoming licenses
-	rows, err := db.QueryContext(context.Background(), "SELECT id, name, vendor, expiry_date FROM licenses WHERE expiry_date BETWEEN date('now') AND date('now', '+30 days')")
+	data.UpcomingLicenses, err = s.licenses.ExpiringWithin(ctx, s.expiryDays)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching upcoming licenses: %w", err)
 	}
-	defer rows.Close()
+	data.ExpiringSoon = len(data.UpcomingLicenses)
+
+	data.HighRiskItems, err = s.risks.CountHigh(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error counting high risks: %w", err)
+	}
+	data.Risks, err = s.risks.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data.RiskScale = []int{1, 2, 3, 4, 5}
 
-	for rows.Next() {
-		var l License
-		var expiryDateStr string
-		if err := rows.Scan(&l.ID, &l.Name, &l.Vendor, &expiryDateStr); err != nil {
-			return nil, fmt.Errorf("error scanning upcoming license: %w", err)
-		}
-		l.ExpiryDate, _ = time.Parse("2006-01-02", expiryDateStr)
-		data.UpcomingLicenses = append(data.UpcomingLicenses, l)
+	data.OverdueFOI, err = s.foi.CountOverdue(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error counting overdue FOI requests: %w", err)
 	}
-	data.ExpiringSoon = len(data.UpcomingLicenses)
+	data.FOIDeadlineDays = s.foiCalendar.DeadlineDays()
+	data.FOIHolidays = s.foiCalendar.Holidays()
+
+	// Fetch all licenses
+	data.Licenses, err = s.licenses.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data.LicenseStatuses = licenseStatuses
+	data.LicenseMetrics = licenseMetrics
+	data.Roles = roles
+	data.DatabaseBackend = s.backend
+	data.DatabaseBackends = databaseBackends
 
 	// Fetch all assets
-	rows, err = db.QueryContext(context.Background(), "SELECT id, name, asset_type, location FROM assets")
+	data.Assets, err = s.assets.List(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("error fetching assets: %w", err)
+		return nil, err
 	}
-	defer rows.Close()
+	data.AssetStateCounts = countAssetStates(data.Assets)
+	data.NewAssetStates = initialAssetStates
 
-	for rows.Next() {
-		var a Asset
-		if err := rows.Scan(&a.ID, &a.Name, &a.AssetType, &a.Location); err != nil {
-			return nil, fmt.Errorf("error scanning asset: %w", err)
-		}
-		data.Assets = append(data.Assets, a)
+	data.Locations, err = s.locations.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching locations: %w", err)
+	}
+	data.LocationKinds = locationKinds
+
+	data.AssetTypes, err = s.assetTypes.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching asset types: %w", err)
+	}
+	data.FieldKinds = fieldKinds
+
+	checkouts, err := s.people.Held(ctx, 0)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching checkouts: %w", err)
+	}
+	data.OpenCheckouts = make(map[int]*Checkout, len(checkouts))
+	for i := range checkouts {
+		data.OpenCheckouts[checkouts[i].AssetID] = &checkouts[i]
 	}
 
 	return data, nil
@@ -374,6 +2879,9 @@ func getPageData() (*PageData, error) {
 
 // renderTemplate is a helper function to parse and execute the embedded template.
 func renderTemplate(w http.ResponseWriter, r *http.Request, data interface{}) {
+	if pd, ok := data.(*PageData); ok {
+		pd.CurrentUser = currentUser(r)
+	}
 	tmpl, err := template.New("dashboard").Parse(dashboardContent)
 	if err != nil {
 		log.Printf("Error parsing template: %v\n", err)
@@ -388,9 +2896,14 @@ func renderTemplate(w http.ResponseWriter, r *http.Request, data interface{}) {
 	}
 }
 
+// routeID extracts the numeric {id} route variable.
+func routeID(r *http.Request) (int, error) {
+	return strconv.Atoi(mux.Vars(r)["id"])
+}
+
 // homeHandler serves the main dashboard page with dynamic data.
-func homeHandler(w http.ResponseWriter, r *http.Request) {
-	data, err := getPageData()
+func (s *server) homeHandler(w http.ResponseWriter, r *http.Request) {
+	data, err := s.getPageData(r.Context())
 	if err != nil {
 		log.Printf("Error fetching page data: %v\n", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -399,34 +2912,222 @@ func homeHandler(w http.ResponseWriter, r *http.Request) {
 	renderTemplate(w, r, data)
 }
 
-// startServer sets up and starts the HTTP server.
-func startServer() {
+// routes builds the router with every page and API endpoint.
+func (s *server) routes() http.Handler {
+	return s.router(s.requireAuth)
+}
+
+// router builds the routes behind auth, the middleware that attaches the
+// signed-in user to each request. Tests supply their own in place of
+// requireAuth so they need no sessions.
+func (s *server) router(auth mux.MiddlewareFunc) http.Handler {
 	router := mux.NewRouter()
+	router.Use(auth)
+
+	// Authentication
+	router.HandleFunc("/login", s.loginHandler).Methods("GET", "POST")
+	router.HandleFunc("/logout", s.logoutHandler).Methods("POST")
 
 	// Define routes for different pages
-	router.HandleFunc("/", homeHandler).Methods("GET")
-	router.HandleFunc("/assets", assetsHandler).Methods("GET", "POST")
+	router.HandleFunc("/", authorize(PermViewDashboard, PermViewDashboard, s.homeHandler)).Methods("GET")
+	router.HandleFunc("/assets", authorize(PermViewAssets, PermManageAssets, s.assetsHandler)).Methods("GET", "POST")
+	router.HandleFunc("/assets/import", authorize(PermManageAssets, PermManageAssets, s.importHandler(&assetImport))).Methods("GET", "POST")
+	router.HandleFunc("/assets/export", authorize(PermViewAssets, PermViewAssets, s.exportAssetsHandler)).Methods("GET")
+	router.HandleFunc("/assets/{id:[0-9]+}", authorize(PermViewAssets, PermManageAssets, s.assetDetailHandler)).Methods("GET")
+	router.HandleFunc("/assets/{id:[0-9]+}", authorize(PermManageAssets, PermManageAssets, s.updateAssetHandler)).Methods("PUT")
+	router.HandleFunc("/assets/{id:[0-9]+}", authorize(PermManageAssets, PermManageAssets, s.deleteAssetHandler)).Methods("DELETE")
+	router.HandleFunc("/assets/{id:[0-9]+}/state", authorize(PermManageAssets, PermManageAssets, s.assetStateHandler)).Methods("POST")
+	router.HandleFunc("/assets/{id:[0-9]+}/checkout", authorize(PermManageAssets, PermManageAssets, s.checkoutHandler)).Methods("POST")
+	router.HandleFunc("/assets/{id:[0-9]+}/checkin", authorize(PermManageAssets, PermManageAssets, s.checkinHandler)).Methods("POST")
+	router.HandleFunc("/assets/{id:[0-9]+}/software", authorize(PermManageAssets, PermManageAssets, s.addSoftwareHandler)).Methods("POST")
+	router.HandleFunc("/assets/{id:[0-9]+}/software/{software:[0-9]+}/delete", authorize(PermManageAssets, PermManageAssets, s.deleteSoftwareHandler)).Methods("POST")
+	router.HandleFunc("/people", authorize(PermViewAssets, PermManageAssets, s.peopleHandler)).Methods("GET", "POST")
+	router.HandleFunc("/people/{id:[0-9]+}", authorize(PermViewAssets, PermManageAssets, s.personHandler)).Methods("GET")
+	router.HandleFunc("/people/{id:[0-9]+}", authorize(PermManageAssets, PermManageAssets, s.updatePersonHandler)).Methods("PUT")
+	router.HandleFunc("/people/{id:[0-9]+}", authorize(PermManageAssets, PermManageAssets, s.deletePersonHandler)).Methods("DELETE")
+	router.HandleFunc("/departments", authorize(PermManageAssets, PermManageAssets, s.departmentsHandler)).Methods("POST")
+	router.HandleFunc("/departments/{id:[0-9]+}", authorize(PermManageAssets, PermManageAssets, s.deleteDepartmentHandler)).Methods("DELETE")
+	router.HandleFunc("/locations", authorize(PermViewAssets, PermManageAssets, s.locationsHandler)).Methods("GET", "POST")
+	router.HandleFunc("/locations/{id:[0-9]+}", authorize(PermViewAssets, PermManageAssets, s.locationHandler)).Methods("GET")
+	router.HandleFunc("/locations/{id:[0-9]+}", authorize(PermManageAssets, PermManageAssets, s.updateLocationHandler)).Methods("PUT")
+	router.HandleFunc("/locations/{id:[0-9]+}", authorize(PermManageAssets, PermManageAssets, s.deleteLocationHandler)).Methods("DELETE")
+	router.HandleFunc("/asset-types", authorize(PermViewAssets, PermManageAssets, s.assetTypesHandler)).Methods("GET", "POST")
+	router.HandleFunc("/asset-types/{id:[0-9]+}", authorize(PermViewAssets, PermManageAssets, s.assetTypeHandler)).Methods("GET")
+	router.HandleFunc("/asset-types/{id:[0-9]+}", authorize(PermManageAssets, PermManageAssets, s.updateAssetTypeHandler)).Methods("PUT")
+	router.HandleFunc("/asset-types/{id:[0-9]+}", authorize(PermManageAssets, PermManageAssets, s.deleteAssetTypeHandler)).Methods("DELETE")
+	router.HandleFunc("/asset-types/{id:[0-9]+}/fields", authorize(PermManageAssets, PermManageAssets, s.createAssetFieldHandler)).Methods("POST")
+	router.HandleFunc("/asset-types/{id:[0-9]+}/fields/{field:[0-9]+}", authorize(PermManageAssets, PermManageAssets, s.updateAssetFieldHandler)).Methods("PUT")
+	router.HandleFunc("/asset-types/{id:[0-9]+}/fields/{field:[0-9]+}", authorize(PermManageAssets, PermManageAssets, s.deleteAssetFieldHandler)).Methods("DELETE")
+	router.HandleFunc("/my-assets", authorize(PermViewDashboard, PermViewDashboard, s.myAssetsHandler)).Methods("GET")
+	router.HandleFunc("/licenses", authorize(PermViewLicenses, PermManageLicenses, s.licensesHandler)).Methods("GET", "POST")
+	router.HandleFunc("/licenses/import", authorize(PermManageLicenses, PermManageLicenses, s.importHandler(&licenseImport))).Methods("GET", "POST")
+	router.HandleFunc("/licenses/export", authorize(PermViewLicenses, PermViewLicenses, s.exportLicensesHandler)).Methods("GET")
+	router.HandleFunc("/licenses/{id:[0-9]+}/edit", authorize(PermManageLicenses, PermManageLicenses, s.editLicenseHandler)).Methods("GET", "POST")
+	router.HandleFunc("/licenses/{id:[0-9]+}/delete", authorize(PermManageLicenses, PermManageLicenses, s.deleteLicenseHandler)).Methods("POST")
+	router.HandleFunc("/licenses/notifications", authorize(PermManageLicenses, PermManageLicenses, s.notificationsHandler)).Methods("GET")
+	router.HandleFunc("/licenses/{id:[0-9]+}/assignments", authorize(PermManageLicenses, PermManageLicenses, s.addAssignmentHandler)).Methods("POST")
+	router.HandleFunc("/licenses/{id:[0-9]+}/assignments/{assignment:[0-9]+}/delete", authorize(PermManageLicenses, PermManageLicenses, s.deleteAssignmentHandler)).Methods("POST")
+	router.HandleFunc("/compliance", authorize(PermViewCompliance, PermViewCompliance, s.complianceHandler)).Methods("GET")
+	router.HandleFunc("/audit", authorize(PermViewAudit, PermViewAudit, s.auditHandler)).Methods("GET")
+	router.HandleFunc("/audit/verify", authorize(PermViewAudit, PermViewAudit, s.auditVerifyHandler)).Methods("GET")
+	router.HandleFunc("/risks", authorize(PermViewRisks, PermManageRisks, s.risksHandler)).Methods("GET", "POST")
+	router.HandleFunc("/risks/{id:[0-9]+}", authorize(PermViewRisks, PermManageRisks, s.editRiskHandler)).Methods("GET", "POST")
+	router.HandleFunc("/risks/{id:[0-9]+}/status", authorize(PermManageRisks, PermManageRisks, s.riskStatusHandler)).Methods("POST")
+	router.HandleFunc("/risks/{id:[0-9]+}/delete", authorize(PermManageRisks, PermManageRisks, s.deleteRiskHandler)).Methods("POST")
+	router.HandleFunc("/risks/{id:[0-9]+}/mitigations", authorize(PermManageRisks, PermManageRisks, s.addMitigationHandler)).Methods("POST")
+	router.HandleFunc("/risks/{id:[0-9]+}/mitigations/{mitigation:[0-9]+}/complete", authorize(PermManageRisks, PermManageRisks, s.completeMitigationHandler)).Methods("POST")
+	router.HandleFunc("/foi", authorize(PermViewFOI, PermManageFOI, s.foiHandler)).Methods("GET", "POST")
+	router.HandleFunc("/foi/{id:[0-9]+}", authorize(PermViewFOI, PermManageFOI, s.editFOIHandler)).Methods("GET", "POST")
+	router.HandleFunc("/foi/{id:[0-9]+}/status", authorize(PermManageFOI, PermManageFOI, s.foiStatusHandler)).Methods("POST")
+	router.HandleFunc("/foi/{id:[0-9]+}/delete", authorize(PermManageFOI, PermManageFOI, s.deleteFOIHandler)).Methods("POST")
+	router.HandleFunc("/reports", authorize(PermViewReports, PermManageReports, s.reportsHandler)).Methods("GET", "POST")
+	router.HandleFunc("/reports/run", authorize(PermViewReports, PermViewReports, s.runReportHandler)).Methods("POST")
+	router.HandleFunc("/reports/saved/{id:[0-9]+}/run", authorize(PermViewReports, PermViewReports, s.runSavedReportHandler)).Methods("POST")
+	router.HandleFunc("/reports/saved/{id:[0-9]+}/delete", authorize(PermManageReports, PermManageReports, s.deleteSavedReportHandler)).Methods("POST")
+	router.HandleFunc("/reports/runs/{id:[0-9]+}", authorize(PermViewReports, PermViewReports, s.reportRunHandler)).Methods("GET")
+	router.HandleFunc("/reports/runs/{id:[0-9]+}/download", authorize(PermViewReports, PermViewReports, s.downloadReportRunHandler)).Methods("GET")
+	router.HandleFunc("/settings", authorize(PermViewDashboard, PermViewDashboard, s.settingsHandler)).Methods("GET")
+	router.HandleFunc("/settings/webhooks", authorize(PermManageSettings, PermManageSettings, s.webhooksHandler)).Methods("POST")
+	router.HandleFunc("/settings/webhooks/{id:[0-9]+}/delete", authorize(PermManageSettings, PermManageSettings, s.deleteWebhookHandler)).Methods("POST")
+	router.HandleFunc("/settings/deliveries/{id:[0-9]+}/retry", authorize(PermManageSettings, PermManageSettings, s.retryDeliveryHandler)).Methods("POST")
+	router.HandleFunc("/users", authorize(PermManageUsers, PermManageUsers, s.usersHandler)).Methods("GET", "POST")
+	router.HandleFunc("/users/{id:[0-9]+}/role", authorize(PermManageUsers, PermManageUsers, s.userRoleHandler)).Methods("POST")
+
+	// JSON API
+	s.registerAPIRoutes(router.PathPrefix("/api/v1").Subrouter())
 
 	// A placeholder handler for other routes
-	router.HandleFunc("/{page}", func(w http.ResponseWriter, r *http.Request) {
-		data, err := getPageData()
-		if err != nil {
-			log.Printf("Error fetching page data: %v\n", err)
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			return
+	router.HandleFunc("/{page}", authorize(PermViewDashboard, PermViewDashboard, s.homeHandler))
+
+	return methodOverride(router)
+}
+
+// methodOverride lets HTML forms, which can only GET and POST, reach PUT,
+// PATCH and DELETE routes: a form posted with a _method field is routed as
+// that method.
+func methodOverride(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+			switch method := strings.ToUpper(r.PostFormValue("_method")); method {
+			case http.MethodPut, http.MethodPatch, http.MethodDelete:
+				r.Method = method
+			}
 		}
-		renderTemplate(w, r, data)
+		next.ServeHTTP(w, r)
 	})
+}
+
+// logRequests is middleware that logs the method, path, status and duration
+// of every request.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		log.Printf("%s %s %d %s\n", r.Method, r.URL.RequestURI(), rec.status, time.Since(start).Round(time.Millisecond))
+	})
+}
+
+// statusRecorder captures the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
 
-	fmt.Println("Server is running at http://localhost:8080")
-	log.Fatal(http.ListenAndServe(":8080", router))
+// startServer sets up and starts the HTTP server.
+func startServer(s *server, cfg Config) {
+	handler := s.routes()
+	if cfg.Log.Requests {
+		handler = logRequests(handler)
+	}
+
+	host := cfg.Server.Addr
+	if strings.HasPrefix(host, ":") {
+		host = "localhost" + host
+	}
+	fmt.Printf("Server is running at http://%s\n", host)
+	log.Fatal(http.ListenAndServe(cfg.Server.Addr, handler))
 }
 
 func main() {
+	cfg, args, err := loadConfig(os.Args[1:])
+	if err == flag.ErrHelp {
+		return
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if cfg.Log.File != "" {
+		f, err := os.OpenFile(cfg.Log.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to open log file: %v\n", err)
+			os.Exit(2)
+		}
+		defer f.Close()
+		log.SetOutput(f)
+	}
+
+	// "slam migrate ..." manages the schema without starting the server
+	if len(args) > 0 && args[0] == "migrate" {
+		openDB(cfg.Database)
+		defer db.Close()
+		runMigrateCommand(args[1:])
+		return
+	} else if len(args) > 0 && args[0] != "compliance" && args[0] != "notify" {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", args[0])
+		os.Exit(2)
+	}
+
 	// Initialize the database before starting the server
-	initDB()
-	seedDB()
+	initDB(cfg.Database)
 	defer db.Close()
 
-	startServer()
+	s := &server{
+		assets:     newSQLAssetRepository(db),
+		licenses:   newSQLLicenseRepository(db),
+		software:   newSQLSoftwareRepository(db),
+		risks:      newSQLRiskRepository(db),
+		foi:        newSQLFOIRepository(db),
+		reports:    newSQLReportRepository(db),
+		backend:    db.dialect.Name,
+		expiryDays: cfg.Expiry.WarningDays,
+
+		notifications: newSQLNotificationRepository(db),
+		notify:        cfg.Notify,
+		webhooks:      newSQLWebhookRepository(db),
+		people:        newSQLPeopleRepository(db),
+		locations:     newSQLLocationRepository(db),
+		assetTypes:    newSQLAssetTypeRepository(db),
+		users:         newSQLUserRepository(db),
+
+		foiCalendar: newFOICalendar(cfg.FOI),
+	}
+	s.auditRepositories(&auditTrail{log: newSQLAuditRepository(db), tx: db})
+
+	// "slam compliance" prints a reconciliation report and "slam notify"
+	// sends due expiry notifications instead of serving
+	if len(args) > 0 && args[0] == "notify" {
+		runNotifyCommand(s, cfg.Notify, args[1:])
+		return
+	} else if len(args) > 0 {
+		runComplianceCommand(s, args[1:])
+		return
+	}
+	if cfg.Seed.Enabled {
+		seedDB(context.Background(), s.assets, s.licenses, s.locations, s.assetTypes)
+	}
+	s.seedAdminUser(context.Background())
+	if cfg.Notify.Enabled {
+		newExpiryNotifier(s, cfg.Notify).Start(context.Background())
+	}
+	s.events = newWebhookDispatcher(s.webhooks, s.licenses, cfg.Notify.Thresholds)
+	s.events.Start(context.Background())
+
+	startServer(s, cfg)
 }
@@ -0,0 +1,684 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migration is a numbered, reversible schema change. Up and Down may hold
+// several statements separated by semicolons, and use the {{serial}},
+// {{timestamp}} and {{longtext}} tokens for column types that differ between
+// backends. Keep keyed or defaulted text columns as VARCHAR so MySQL can
+// index them.
+type migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// migrations lists every schema change in order. Never edit or renumber a
+// migration once it has shipped; append a new one instead.
+var migrations = []migration{
+	{
+		Version: 1,
+		Name:    "create licenses and assets",
+		// IF NOT EXISTS lets databases created before migrations existed
+		// adopt version 1 without losing data.
+		Up: `
+			CREATE TABLE IF NOT EXISTS licenses (
+				id {{serial}},
+				name TEXT NOT NULL,
+				vendor TEXT,
+				expiry_date DATE,
+				renewal_date DATE,
+				status TEXT
+			);
+			CREATE TABLE IF NOT EXISTS assets (
+				id {{serial}},
+				name TEXT NOT NULL,
+				asset_type TEXT,
+				location TEXT
+			);`,
+		Down: `
+			DROP TABLE assets;
+			DROP TABLE licenses;`,
+	},
+	{
+		Version: 2,
+		Name:    "create users and sessions",
+		Up: `
+			CREATE TABLE IF NOT EXISTS users (
+				id {{serial}},
+				username VARCHAR(255) NOT NULL UNIQUE,
+				password_hash TEXT NOT NULL,
+				role VARCHAR(32) NOT NULL DEFAULT 'viewer',
+				created_at {{timestamp}} DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE TABLE IF NOT EXISTS sessions (
+				token_hash VARCHAR(64) PRIMARY KEY,
+				user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				expires_at {{timestamp}} NOT NULL
+			);`,
+		Down: `
+			DROP TABLE sessions;
+			DROP TABLE users;`,
+	},
+	{
+		Version: 3,
+		Name:    "add license entitlements and assignments",
+		Up: `
+			ALTER TABLE licenses ADD COLUMN quantity INTEGER NOT NULL DEFAULT 0;
+			ALTER TABLE licenses ADD COLUMN metric VARCHAR(32) NOT NULL DEFAULT 'per_user';
+			CREATE TABLE license_assignments (
+				id {{serial}},
+				license_id INTEGER NOT NULL REFERENCES licenses(id) ON DELETE CASCADE,
+				asset_id INTEGER REFERENCES assets(id) ON DELETE CASCADE,
+				assignee VARCHAR(255),
+				quantity INTEGER NOT NULL DEFAULT 1,
+				assigned_at {{timestamp}} NOT NULL
+			);
+			CREATE INDEX idx_license_assignments_license ON license_assignments (license_id);`,
+		Down: `
+			DROP TABLE license_assignments;
+			ALTER TABLE licenses DROP COLUMN metric;
+			ALTER TABLE licenses DROP COLUMN quantity;`,
+	},
+	{
+		Version: 4,
+		Name:    "add installed software inventory",
+		Up: `
+			CREATE TABLE installed_software (
+				id {{serial}},
+				asset_id INTEGER NOT NULL REFERENCES assets(id) ON DELETE CASCADE,
+				product VARCHAR(255) NOT NULL,
+				publisher VARCHAR(255),
+				version VARCHAR(64),
+				install_date DATE,
+				source VARCHAR(64) NOT NULL,
+				last_seen {{timestamp}} NOT NULL
+			);
+			CREATE INDEX idx_installed_software_asset ON installed_software (asset_id);`,
+		Down: `
+			DROP TABLE installed_software;`,
+	},
+	{
+		Version: 5,
+		Name:    "add license product and unit cost",
+		Up: `
+			ALTER TABLE licenses ADD COLUMN product VARCHAR(255);
+			ALTER TABLE licenses ADD COLUMN unit_cost DECIMAL(12,2) NOT NULL DEFAULT 0;`,
+		Down: `
+			ALTER TABLE licenses DROP COLUMN unit_cost;
+			ALTER TABLE licenses DROP COLUMN product;`,
+	},
+	{
+		Version: 6,
+		Name:    "add risk register",
+		Up: `
+			CREATE TABLE risks (
+				id {{serial}},
+				title VARCHAR(255) NOT NULL,
+				description TEXT,
+				likelihood INTEGER NOT NULL,
+				impact INTEGER NOT NULL,
+				owner VARCHAR(255),
+				status VARCHAR(32) NOT NULL DEFAULT 'identified',
+				review_date DATE,
+				created_at {{timestamp}} NOT NULL,
+				updated_at {{timestamp}} NOT NULL
+			);
+			CREATE TABLE risk_assets (
+				risk_id INTEGER NOT NULL REFERENCES risks(id) ON DELETE CASCADE,
+				asset_id INTEGER NOT NULL REFERENCES assets(id) ON DELETE CASCADE,
+				PRIMARY KEY (risk_id, asset_id)
+			);
+			CREATE TABLE risk_licenses (
+				risk_id INTEGER NOT NULL REFERENCES risks(id) ON DELETE CASCADE,
+				license_id INTEGER NOT NULL REFERENCES licenses(id) ON DELETE CASCADE,
+				PRIMARY KEY (risk_id, license_id)
+			);
+			CREATE TABLE risk_mitigations (
+				id {{serial}},
+				risk_id INTEGER NOT NULL REFERENCES risks(id) ON DELETE CASCADE,
+				action TEXT NOT NULL,
+				due_date DATE,
+				completed_at {{timestamp}} NULL
+			);`,
+		Down: `
+			DROP TABLE risk_mitigations;
+			DROP TABLE risk_licenses;
+			DROP TABLE risk_assets;
+			DROP TABLE risks;`,
+	},
+	{
+		Version: 7,
+		Name:    "add FOI requests",
+		Up: `
+			CREATE TABLE foi_requests (
+				id {{serial}},
+				requester VARCHAR(255) NOT NULL,
+				contact VARCHAR(255),
+				subject VARCHAR(255) NOT NULL,
+				details TEXT,
+				received_date DATE NOT NULL,
+				due_date DATE NOT NULL,
+				assignee VARCHAR(255),
+				status VARCHAR(32) NOT NULL DEFAULT 'received',
+				created_at {{timestamp}} NOT NULL,
+				updated_at {{timestamp}} NOT NULL
+			);
+			CREATE INDEX idx_foi_requests_due ON foi_requests (status, due_date);
+			CREATE TABLE foi_request_assets (
+				foi_request_id INTEGER NOT NULL REFERENCES foi_requests(id) ON DELETE CASCADE,
+				asset_id INTEGER NOT NULL REFERENCES assets(id) ON DELETE CASCADE,
+				PRIMARY KEY (foi_request_id, asset_id)
+			);
+			CREATE TABLE foi_request_licenses (
+				foi_request_id INTEGER NOT NULL REFERENCES foi_requests(id) ON DELETE CASCADE,
+				license_id INTEGER NOT NULL REFERENCES licenses(id) ON DELETE CASCADE,
+				PRIMARY KEY (foi_request_id, license_id)
+			);`,
+		Down: `
+			DROP TABLE foi_request_licenses;
+			DROP TABLE foi_request_assets;
+			DROP TABLE foi_requests;`,
+	},
+	{
+		Version: 8,
+		Name:    "add saved reports and report runs",
+		// Results are stored as JSON and can exceed MySQL's 64KB TEXT.
+		Up: `
+			CREATE TABLE saved_reports (
+				id {{serial}},
+				name VARCHAR(255) NOT NULL,
+				report_key VARCHAR(64) NOT NULL,
+				params TEXT,
+				created_by VARCHAR(255),
+				created_at {{timestamp}} NOT NULL
+			);
+			CREATE TABLE report_runs (
+				id {{serial}},
+				saved_report_id INTEGER REFERENCES saved_reports(id) ON DELETE SET NULL,
+				name VARCHAR(255) NOT NULL,
+				report_key VARCHAR(64) NOT NULL,
+				params TEXT,
+				run_by VARCHAR(255),
+				started_at {{timestamp}} NOT NULL,
+				finished_at {{timestamp}} NOT NULL,
+				error TEXT,
+				row_count INTEGER NOT NULL DEFAULT 0,
+				result {{longtext}}
+			);
+			CREATE INDEX idx_report_runs_started ON report_runs (started_at);
+			CREATE TABLE foi_request_report_runs (
+				foi_request_id INTEGER NOT NULL REFERENCES foi_requests(id) ON DELETE CASCADE,
+				report_run_id INTEGER NOT NULL REFERENCES report_runs(id) ON DELETE CASCADE,
+				PRIMARY KEY (foi_request_id, report_run_id)
+			);`,
+		Down: `
+			DROP TABLE foi_request_report_runs;
+			DROP TABLE report_runs;
+			DROP TABLE saved_reports;`,
+	},
+	{
+		Version: 9,
+		Name:    "add license expiry notifications",
+		Up: `
+			ALTER TABLE licenses ADD COLUMN owner_email VARCHAR(255);
+			CREATE TABLE license_notifications (
+				id {{serial}},
+				license_id INTEGER REFERENCES licenses(id) ON DELETE SET NULL,
+				license_name VARCHAR(255) NOT NULL,
+				expiry_date DATE NOT NULL,
+				stage INTEGER NOT NULL,
+				recipient VARCHAR(255) NOT NULL,
+				sent_at {{timestamp}} NOT NULL
+			);
+			CREATE INDEX idx_license_notifications_license ON license_notifications (license_id, expiry_date);`,
+		Down: `
+			DROP TABLE license_notifications;
+			ALTER TABLE licenses DROP COLUMN owner_email;`,
+	},
+	{
+		Version: 10,
+		Name:    "add webhooks",
+		Up: `
+			CREATE TABLE webhooks (
+				id {{serial}},
+				url VARCHAR(2048) NOT NULL,
+				secret VARCHAR(255) NOT NULL,
+				events TEXT NOT NULL,
+				created_at {{timestamp}} NOT NULL
+			);
+			CREATE TABLE webhook_deliveries (
+				id {{serial}},
+				webhook_id INTEGER NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+				event VARCHAR(64) NOT NULL,
+				event_key VARCHAR(255),
+				payload {{longtext}} NOT NULL,
+				status VARCHAR(16) NOT NULL,
+				attempts INTEGER NOT NULL DEFAULT 0,
+				next_attempt_at {{timestamp}} NULL,
+				response_status INTEGER NOT NULL DEFAULT 0,
+				error TEXT,
+				created_at {{timestamp}} NOT NULL,
+				delivered_at {{timestamp}} NULL
+			);
+			CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at);
+			CREATE INDEX idx_webhook_deliveries_key ON webhook_deliveries (webhook_id, event_key);`,
+		Down: `
+			DROP TABLE webhook_deliveries;
+			DROP TABLE webhooks;`,
+	},
+	{
+		// Each entry's prev_hash is unique so that two writers cannot both
+		// extend the chain from the same entry.
+		Version: 11,
+		Name:    "add audit log",
+		Up: `
+			CREATE TABLE audit_log (
+				id {{serial}},
+				occurred_at {{timestamp}} NOT NULL,
+				actor VARCHAR(255) NOT NULL,
+				entity VARCHAR(64) NOT NULL,
+				entity_id INTEGER NOT NULL,
+				action VARCHAR(64) NOT NULL,
+				before_data {{longtext}},
+				after_data {{longtext}},
+				prev_hash VARCHAR(64) NOT NULL UNIQUE,
+				hash VARCHAR(64) NOT NULL
+			);
+			CREATE INDEX idx_audit_log_entity ON audit_log (entity, entity_id);
+			CREATE INDEX idx_audit_log_occurred ON audit_log (occurred_at);`,
+		Down: `
+			DROP TABLE audit_log;`,
+	},
+	{
+		Version: 12,
+		Name:    "add asset soft delete",
+		Up: `
+			ALTER TABLE assets ADD COLUMN deleted_at {{timestamp}} NULL;`,
+		Down: `
+			DELETE FROM assets WHERE deleted_at IS NOT NULL;
+			ALTER TABLE assets DROP COLUMN deleted_at;`,
+	},
+	{
+		// Serial numbers and asset tags are optional, so empty values are
+		// stored as NULL to keep them out of the unique indexes.
+		Version: 13,
+		Name:    "add asset hardware attributes",
+		Up: `
+			ALTER TABLE assets ADD COLUMN manufacturer VARCHAR(255);
+			ALTER TABLE assets ADD COLUMN model VARCHAR(255);
+			ALTER TABLE assets ADD COLUMN serial_number VARCHAR(255);
+			ALTER TABLE assets ADD COLUMN asset_tag VARCHAR(255);
+			ALTER TABLE assets ADD COLUMN purchase_date DATE;
+			ALTER TABLE assets ADD COLUMN purchase_cost DECIMAL(12,2) NOT NULL DEFAULT 0;
+			ALTER TABLE assets ADD COLUMN supplier VARCHAR(255);
+			ALTER TABLE assets ADD COLUMN order_reference VARCHAR(255);
+			ALTER TABLE assets ADD COLUMN warranty_end DATE;
+			CREATE UNIQUE INDEX idx_assets_serial_number ON assets (serial_number);
+			CREATE UNIQUE INDEX idx_assets_asset_tag ON assets (asset_tag);`,
+		Down: `
+			{{drop_index idx_assets_asset_tag assets}};
+			{{drop_index idx_assets_serial_number assets}};
+			ALTER TABLE assets DROP COLUMN warranty_end;
+			ALTER TABLE assets DROP COLUMN order_reference;
+			ALTER TABLE assets DROP COLUMN supplier;
+			ALTER TABLE assets DROP COLUMN purchase_cost;
+			ALTER TABLE assets DROP COLUMN purchase_date;
+			ALTER TABLE assets DROP COLUMN asset_tag;
+			ALTER TABLE assets DROP COLUMN serial_number;
+			ALTER TABLE assets DROP COLUMN model;
+			ALTER TABLE assets DROP COLUMN manufacturer;`,
+	},
+	{
+		// Assets recorded before the lifecycle existed are taken to be in
+		// use, and their history starts when the migration runs.
+		Version: 14,
+		Name:    "add asset lifecycle",
+		Up: `
+			ALTER TABLE assets ADD COLUMN state VARCHAR(32) NOT NULL DEFAULT 'deployed';
+			CREATE TABLE asset_state_changes (
+				id {{serial}},
+				asset_id INTEGER NOT NULL,
+				from_state VARCHAR(32),
+				to_state VARCHAR(32) NOT NULL,
+				reason TEXT,
+				changed_at {{timestamp}} NOT NULL
+			);
+			CREATE INDEX idx_asset_state_changes_asset ON asset_state_changes (asset_id);
+			INSERT INTO asset_state_changes (asset_id, to_state, changed_at)
+				SELECT id, state, CURRENT_TIMESTAMP FROM assets;`,
+		Down: `
+			DROP TABLE asset_state_changes;
+			ALTER TABLE assets DROP COLUMN state;`,
+	},
+	{
+		// The custody history keeps the person's name so that it survives
+		// the person being removed from the directory.
+		Version: 15,
+		Name:    "add people and asset custody",
+		Up: `
+			CREATE TABLE departments (
+				id {{serial}},
+				name VARCHAR(255) NOT NULL UNIQUE
+			);
+			CREATE TABLE people (
+				id {{serial}},
+				name VARCHAR(255) NOT NULL,
+				email VARCHAR(255),
+				department_id INTEGER,
+				username VARCHAR(255) UNIQUE
+			);
+			CREATE INDEX idx_people_department ON people (department_id);
+			CREATE TABLE asset_custody (
+				id {{serial}},
+				asset_id INTEGER NOT NULL,
+				person_id INTEGER,
+				person_name VARCHAR(255) NOT NULL,
+				checked_out_at {{timestamp}} NOT NULL,
+				checked_out_by VARCHAR(255),
+				expected_return DATE,
+				notes TEXT,
+				checked_in_at {{timestamp}} NULL,
+				checked_in_by VARCHAR(255),
+				return_notes TEXT
+			);
+			CREATE INDEX idx_asset_custody_asset ON asset_custody (asset_id);
+			CREATE INDEX idx_asset_custody_person ON asset_custody (person_id);`,
+		Down: `
+			DROP TABLE asset_custody;
+			DROP TABLE people;
+			DROP TABLE departments;`,
+	},
+	{
+		// Each distinct free-text location, ignoring case and surrounding
+		// spaces, becomes a top-level site that can be arranged into the
+		// hierarchy afterwards. Reverting keeps only the name of each
+		// asset's own location.
+		Version: 16,
+		Name:    "add hierarchical locations",
+		Up: `
+			CREATE TABLE locations (
+				id {{serial}},
+				parent_id INTEGER,
+				kind VARCHAR(16) NOT NULL,
+				name VARCHAR(255) NOT NULL
+			);
+			CREATE INDEX idx_locations_parent ON locations (parent_id);
+			CREATE TABLE asset_moves (
+				id {{serial}},
+				asset_id INTEGER NOT NULL,
+				from_location_id INTEGER,
+				from_path TEXT,
+				to_location_id INTEGER,
+				to_path TEXT,
+				moved_at {{timestamp}} NOT NULL,
+				moved_by VARCHAR(255)
+			);
+			CREATE INDEX idx_asset_moves_asset ON asset_moves (asset_id);
+			ALTER TABLE assets ADD COLUMN location_id INTEGER;
+			CREATE INDEX idx_assets_location ON assets (location_id);
+			INSERT INTO locations (kind, name)
+				SELECT 'site', MIN(TRIM(location)) FROM assets
+				WHERE TRIM(location) <> '' GROUP BY LOWER(TRIM(location));
+			UPDATE assets SET location_id = (
+				SELECT id FROM locations WHERE LOWER(locations.name) = LOWER(TRIM(assets.location)))
+				WHERE TRIM(location) <> '';
+			INSERT INTO asset_moves (asset_id, to_location_id, to_path, moved_at)
+				SELECT assets.id, locations.id, locations.name, CURRENT_TIMESTAMP
+				FROM assets JOIN locations ON locations.id = assets.location_id;
+			ALTER TABLE assets DROP COLUMN location;`,
+		Down: `
+			ALTER TABLE assets ADD COLUMN location TEXT;
+			UPDATE assets SET location = (SELECT name FROM locations WHERE locations.id = assets.location_id);
+			{{drop_index idx_assets_location assets}};
+			ALTER TABLE assets DROP COLUMN location_id;
+			DROP TABLE asset_moves;
+			DROP TABLE locations;`,
+	},
+	{
+		// Each distinct free-text asset type, ignoring case and surrounding
+		// spaces, becomes a managed type without custom fields. Custom
+		// field values are stored one row per asset and field, in the
+		// canonical form of the field's kind, and only when set. Reverting
+		// keeps each asset's type name but loses the custom fields.
+		Version: 17,
+		Name:    "add managed asset types",
+		Up: `
+			CREATE TABLE asset_types (
+				id {{serial}},
+				name VARCHAR(255) NOT NULL UNIQUE
+			);
+			CREATE TABLE asset_type_fields (
+				id {{serial}},
+				type_id INTEGER NOT NULL,
+				name VARCHAR(64) NOT NULL,
+				label VARCHAR(255) NOT NULL,
+				kind VARCHAR(16) NOT NULL,
+				options TEXT,
+				required INTEGER NOT NULL DEFAULT 0,
+				position INTEGER NOT NULL DEFAULT 0
+			);
+			CREATE UNIQUE INDEX idx_asset_type_fields_name ON asset_type_fields (type_id, name);
+			CREATE TABLE asset_field_values (
+				asset_id INTEGER NOT NULL,
+				field_id INTEGER NOT NULL,
+				value VARCHAR(255) NOT NULL,
+				PRIMARY KEY (asset_id, field_id)
+			);
+			CREATE INDEX idx_asset_field_values_field ON asset_field_values (field_id, value);
+			ALTER TABLE assets ADD COLUMN type_id INTEGER;
+			CREATE INDEX idx_assets_type ON assets (type_id);
+			INSERT INTO asset_types (name)
+				SELECT MIN(TRIM(asset_type)) FROM assets
+				WHERE TRIM(asset_type) <> '' GROUP BY LOWER(TRIM(asset_type));
+			UPDATE assets SET type_id = (
+				SELECT id FROM asset_types WHERE LOWER(asset_types.name) = LOWER(TRIM(assets.asset_type)))
+				WHERE TRIM(asset_type) <> '';
+			ALTER TABLE assets DROP COLUMN asset_type;`,
+		Down: `
+			ALTER TABLE assets ADD COLUMN asset_type TEXT;
+			UPDATE assets SET asset_type = (SELECT name FROM asset_types WHERE asset_types.id = assets.type_id);
+			{{drop_index idx_assets_type assets}};
+			ALTER TABLE assets DROP COLUMN type_id;
+			DROP TABLE asset_field_values;
+			DROP TABLE asset_type_fields;
+			DROP TABLE asset_types;`,
+	},
+	{
+		// Like the custody history, an assignment outlives the person it
+		// links to: removing the person clears person_id and keeps their
+		// name as the assignee.
+		Version: 18,
+		Name:    "add license assignment people",
+		Up: `
+			ALTER TABLE license_assignments ADD COLUMN person_id INTEGER;
+			CREATE INDEX idx_license_assignments_person ON license_assignments (person_id);`,
+		Down: `
+			{{drop_index idx_license_assignments_person license_assignments}};
+			ALTER TABLE license_assignments DROP COLUMN person_id;`,
+	},
+	{
+		// Asset type names are matched ignoring case, so they must be
+		// unique ignoring case too, whatever the column's collation. The
+		// migration fails if two types already differ only in case; rename
+		// one of them first.
+		Version: 19,
+		Name:    "make asset type names unique ignoring case",
+		Up: `
+			CREATE UNIQUE INDEX idx_asset_types_name_lower ON asset_types ((LOWER(name)));`,
+		Down: `
+			{{drop_index idx_asset_types_name_lower asset_types}};`,
+	},
+}
+
+// ensureMigrationsTable creates the schema_migrations bookkeeping table.
+func ensureMigrationsTable() error {
+	_, err := db.ExecContext(context.Background(), `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at `+db.dialect.expandDDL("{{timestamp}}")+` NOT NULL
+		)`)
+	return err
+}
+
+// appliedMigrations returns the applied versions and when each was applied.
+func appliedMigrations() (map[int]time.Time, error) {
+	if err := ensureMigrationsTable(); err != nil {
+		return nil, fmt.Errorf("error creating schema_migrations: %w", err)
+	}
+	rows, err := db.QueryContext(context.Background(), "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("error reading schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt sql.NullString
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("error scanning schema_migrations: %w", err)
+		}
+		applied[version] = parseTimestamp(appliedAt.String)
+	}
+	return applied, rows.Err()
+}
+
+// runMigration executes one direction of a migration and records the result
+// in schema_migrations inside a single transaction. The transaction only
+// makes the migration atomic where the dialect's DDL is transactional; on
+// MySQL every schema change commits as it runs, so a migration failing part
+// way leaves its earlier statements applied and unrecorded.
+func runMigration(m migration, up bool) error {
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	script, record := m.Down, "DELETE FROM schema_migrations WHERE version = ?"
+	args := []interface{}{m.Version}
+	if up {
+		script, record = m.Up, "INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)"
+		args = append(args, m.Name, time.Now().UTC().Format(timestampLayout))
+	}
+
+	// Run statements one at a time; not every driver accepts a batch.
+	for _, stmt := range strings.Split(db.dialect.expandDDL(script), ";") {
+		if strings.TrimSpace(stmt) == "" {
+			continue
+		}
+		if _, err := tx.ExecContext(context.Background(), stmt); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(context.Background(), record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// partialNote warns, for dialects without transactional DDL, that a failed
+// migration may have been partly applied and needs repairing by hand.
+func partialNote() string {
+	if db.dialect.transactionalDDL {
+		return ""
+	}
+	return fmt.Sprintf(" and may be partly applied, as %s commits schema changes immediately", db.dialect.Label)
+}
+
+// migrateUp applies every pending migration in version order.
+func migrateUp() error {
+	applied, err := appliedMigrations()
+	if err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if err := runMigration(m, true); err != nil {
+			return fmt.Errorf("migration %d (%s) failed%s: %w", m.Version, m.Name, partialNote(), err)
+		}
+		log.Printf("Applied migration %d: %s\n", m.Version, m.Name)
+	}
+	return nil
+}
+
+// migrateDown reverts the most recently applied migrations, steps at a time.
+func migrateDown(steps int) error {
+	applied, err := appliedMigrations()
+	if err != nil {
+		return err
+	}
+	for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if err := runMigration(m, false); err != nil {
+			return fmt.Errorf("reverting migration %d (%s) failed%s: %w", m.Version, m.Name, partialNote(), err)
+		}
+		log.Printf("Reverted migration %d: %s\n", m.Version, m.Name)
+		steps--
+	}
+	return nil
+}
+
+// printMigrationStatus lists every migration and whether it has been applied.
+func printMigrationStatus() error {
+	applied, err := appliedMigrations()
+	if err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		state := "pending"
+		if t, ok := applied[m.Version]; ok {
+			state = "applied " + t.Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%4d  %-40s %s\n", m.Version, m.Name, state)
+	}
+	return nil
+}
+
+// runMigrateCommand implements "slam migrate status|up|down [steps]".
+func runMigrateCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: slam migrate status|up|down [steps]")
+		os.Exit(2)
+	}
+
+	var err error
+	switch args[0] {
+	case "status":
+		err = printMigrationStatus()
+	case "up":
+		err = migrateUp()
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				fmt.Fprintf(os.Stderr, "invalid step count %q\n", args[1])
+				os.Exit(2)
+			}
+		}
+		err = migrateDown(steps)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown migrate command %q\n", args[0])
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("Migration error: %v\n", err)
+	}
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// expiredNoticeDays limits on-expiry notices to licenses that lapsed in the
+// last week, so enabling notifications does not announce every license that
+// expired long ago.
+const expiredNoticeDays = 7
+
+// recentNotificationsLimit caps the sent log shown on the notifications page.
+const recentNotificationsLimit = 100
+
+// ExpiryNotification records a notice about one license sent to one
+// recipient.
+type ExpiryNotification struct {
+	ID int
+	// LicenseID is zero once the license has been deleted.
+	LicenseID   int
+	LicenseName string
+	ExpiryDate  time.Time
+	// Stage is the threshold that triggered the notice in days before
+	// expiry; 0 is the notice sent on expiry.
+	Stage     int
+	Recipient string
+	SentAt    time.Time
+}
+
+// StageLabel describes the stage for the sent log.
+func (n ExpiryNotification) StageLabel() string {
+	if n.Stage == 0 {
+		return "On expiry"
+	}
+	return fmt.Sprintf("%d days before", n.Stage)
+}
+
+// parseEmail validates an optional email address and returns it without
+// any display name.
+func parseEmail(v string) (string, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return "", nil
+	}
+	addr, err := mail.ParseAddress(v)
+	if err != nil {
+		return "", fmt.Errorf("invalid email address %q", v)
+	}
+	return addr.Address, nil
+}
+
+// mailer sends a plain text email to one recipient.
+type mailer interface {
+	Send(to, subject, body string) error
+}
+
+// smtpMailer sends email through the configured SMTP server, upgrading to
+// TLS when the server offers STARTTLS.
+type smtpMailer struct {
+	cfg SMTPConfig
+}
+
+func (m smtpMailer) Send(to, subject, body string) error {
+	from, err := mail.ParseAddress(m.cfg.From)
+	if err != nil {
+		return fmt.Errorf("invalid sender %q: %w", m.cfg.From, err)
+	}
+	var auth smtp.Auth
+	if m.cfg.Username != "" {
+		auth = smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+	return smtp.SendMail(addr, auth, from.Address, []string{to}, msg.Bytes())
+}
+
+// expiryNotifier emails license owners as their licenses approach expiry.
+// Each license is notified once per threshold, expiry date and recipient;
+// the sent log is the record of what has gone out.
+type expiryNotifier struct {
+	cfg      NotifyConfig
+	licenses LicenseRepository
+	sent     NotificationRepository
+	mail     mailer
+}
+
+// expiryNotice is a license due a notice at a stage.
+type expiryNotice struct {
+	License  License
+	Stage    int
+	DaysLeft int
+}
+
+// expiryStage returns the expiry stage a license has reached on day: the
+// nearest of the thresholds it has passed, or 0 on and shortly after
+// expiry. Licenses without an expiry date and cancelled licenses never
+// reach a stage. Webhooks raise their expiry events at the same stages.
+func expiryStage(thresholds []int, l License, day time.Time) (stage, daysLeft int, ok bool) {
+	if l.ExpiryDate.IsZero() || l.Status == "cancelled" {
+		return 0, 0, false
+	}
+	daysLeft = int(l.ExpiryDate.Sub(day).Hours() / 24)
+	if daysLeft <= 0 {
+		return 0, daysLeft, daysLeft > -expiredNoticeDays
+	}
+	for _, t := range thresholds {
+		if daysLeft <= t && (!ok || t < stage) {
+			stage, ok = t, true
+		}
+	}
+	return stage, daysLeft, ok
+}
+
+// recipients returns who is told about a license at the given stage: its
+// owner, or the default recipient, plus the escalation list once the
+// notice is urgent.
+func (n *expiryNotifier) recipients(l License, stage int) []string {
+	var to []string
+	add := func(addr string) {
+		for _, existing := range to {
+			if strings.EqualFold(existing, addr) {
+				return
+			}
+		}
+		to = append(to, addr)
+	}
+	if l.OwnerEmail != "" {
+		add(l.OwnerEmail)
+	} else if n.cfg.DefaultRecipient != "" {
+		add(n.cfg.DefaultRecipient)
+	}
+	if stage <= n.cfg.EscalateDays {
+		for _, addr := range n.cfg.EscalateTo {
+			add(addr)
+		}
+	}
+	return to
+}
+
+// alreadySent reports whether the recipient has been sent this stage, or a
+// more urgent one, for the license's current expiry date.
+func alreadySent(sent []ExpiryNotification, to string, stage int) bool {
+	for _, s := range sent {
+		if strings.EqualFold(s.Recipient, to) && s.Stage <= stage {
+			return true
+		}
+	}
+	return false
+}
+
+// Run sends every notice that is due and has not been sent, as one digest
+// per recipient, and returns the number of emails sent. An email that fails
+// is logged and retried on the next run.
+func (n *expiryNotifier) Run(ctx context.Context) (int, error) {
+	licenses, err := n.licenses.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	day := today()
+	digests := make(map[string][]expiryNotice)
+	var order []string
+	for _, l := range licenses {
+		stage, daysLeft, ok := expiryStage(n.cfg.Thresholds, l, day)
+		if !ok {
+			continue
+		}
+		sent, err := n.sent.ForLicense(ctx, l.ID, l.ExpiryDate)
+		if err != nil {
+			return 0, err
+		}
+		for _, to := range n.recipients(l, stage) {
+			if alreadySent(sent, to, stage) {
+				continue
+			}
+			if _, ok := digests[to]; !ok {
+				order = append(order, to)
+			}
+			digests[to] = append(digests[to], expiryNotice{License: l, Stage: stage, DaysLeft: daysLeft})
+		}
+	}
+
+	emails := 0
+	for _, to := range order {
+		notices := digests[to]
+		subject, body := n.digest(notices)
+		if err := n.mail.Send(to, subject, body); err != nil {
+			log.Printf("Error sending expiry notification to %s: %v\n", to, err)
+			continue
+		}
+		emails++
+
+		sentAt := time.Now().UTC().Truncate(time.Second)
+		records := make([]ExpiryNotification, len(notices))
+		for i, notice := range notices {
+			records[i] = ExpiryNotification{
+				LicenseID:   notice.License.ID,
+				LicenseName: notice.License.Name,
+				ExpiryDate:  notice.License.ExpiryDate,
+				Stage:       notice.Stage,
+				Recipient:   to,
+				SentAt:      sentAt,
+			}
+		}
+		if err := n.sent.Record(ctx, records); err != nil {
+			return emails, fmt.Errorf("error recording notifications sent to %s: %w", to, err)
+		}
+	}
+	return emails, nil
+}
+
+// digest writes the email for one recipient, most urgent license first.
+func (n *expiryNotifier) digest(notices []expiryNotice) (subject, body string) {
+	sort.Slice(notices, func(i, j int) bool {
+		if notices[i].DaysLeft != notices[j].DaysLeft {
+			return notices[i].DaysLeft < notices[j].DaysLeft
+		}
+		return notices[i].License.Name < notices[j].License.Name
+	})
+
+	urgent := false
+	var b strings.Builder
+	b.WriteString("The following licenses are due for renewal:\n\n")
+	for _, notice := range notices {
+		l := notice.License
+		name := l.Name
+		if l.Vendor != "" {
+			name += " (" + l.Vendor + ")"
+		}
+		expiry := l.ExpiryDate.Format(dateLayout)
+		switch {
+		case notice.DaysLeft < 0:
+			fmt.Fprintf(&b, "- %s expired on %s\n", name, expiry)
+		case notice.DaysLeft == 0:
+			fmt.Fprintf(&b, "- %s expires today, %s\n", name, expiry)
+		case notice.DaysLeft == 1:
+			fmt.Fprintf(&b, "- %s expires tomorrow, %s\n", name, expiry)
+		default:
+			fmt.Fprintf(&b, "- %s expires on %s, in %d days\n", name, expiry, notice.DaysLeft)
+		}
+		if notice.Stage <= n.cfg.EscalateDays {
+			urgent = true
+		}
+	}
+	b.WriteString("\nRecord the renewal by updating the license's expiry date on the License Renewals page.\n")
+
+	subject = fmt.Sprintf("License expiry notice: %d license", len(notices))
+	if len(notices) != 1 {
+		subject += "s"
+	}
+	if urgent {
+		subject = "Urgent: " + subject
+	}
+	return subject, b.String()
+}
+
+// Start runs the notifier now and then every interval until ctx is done.
+func (n *expiryNotifier) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(n.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			if emails, err := n.Run(ctx); err != nil {
+				log.Printf("Error sending expiry notifications: %v\n", err)
+			} else if emails > 0 {
+				log.Printf("Sent %d expiry notification emails\n", emails)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// newExpiryNotifier builds a notifier that mails through the SMTP server.
+func newExpiryNotifier(s *server, cfg NotifyConfig) *expiryNotifier {
+	return &expiryNotifier{
+		cfg:      cfg,
+		licenses: s.licenses,
+		sent:     s.notifications,
+		mail:     smtpMailer{cfg: cfg.SMTP},
+	}
+}
+
+// runNotifyCommand implements "slam notify": it sends any notices that
+// are due once and exits, for use from cron or to test the mail settings.
+func runNotifyCommand(s *server, cfg NotifyConfig, args []string) {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "usage: slam notify")
+		os.Exit(2)
+	}
+	if cfg.SMTP.Host == "" || cfg.SMTP.From == "" {
+		fmt.Fprintln(os.Stderr, "notify.smtp.host and notify.smtp.from must be set to send notifications")
+		os.Exit(2)
+	}
+	emails, err := newExpiryNotifier(s, cfg).Run(context.Background())
+	if err != nil {
+		log.Fatalf("Notification error: %v\n", err)
+	}
+	fmt.Printf("Sent %d expiry notification emails\n", emails)
+}
+
+// notificationsHandler shows the log of expiry notifications sent.
+func (s *server) notificationsHandler(w http.ResponseWriter, r *http.Request) {
+	data, err := s.getPageData(r.Context())
+	if err != nil {
+		log.Printf("Error fetching page data: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	data.Notifications, err = s.notifications.Recent(r.Context(), recentNotificationsLimit)
+	if err != nil {
+		log.Printf("Error fetching notifications: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	data.Notify = &s.notify
+	renderTemplate(w, r, data)
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpiryStage(t *testing.T) {
+	thresholds := []int{30, 14, 7}
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		license  License
+		stage    int
+		daysLeft int
+		ok       bool
+	}{
+		{"no expiry date", License{}, 0, 0, false},
+		{"cancelled", License{ExpiryDate: day.AddDate(0, 0, 5), Status: "cancelled"}, 0, 0, false},
+		{"before every threshold", License{ExpiryDate: day.AddDate(0, 0, 45)}, 0, 45, false},
+		{"on a threshold", License{ExpiryDate: day.AddDate(0, 0, 30)}, 30, 30, true},
+		{"between thresholds", License{ExpiryDate: day.AddDate(0, 0, 10)}, 14, 10, true},
+		{"inside the last threshold", License{ExpiryDate: day.AddDate(0, 0, 1)}, 7, 1, true},
+		{"on expiry", License{ExpiryDate: day}, 0, 0, true},
+		{"recently expired", License{ExpiryDate: day.AddDate(0, 0, -3)}, 0, -3, true},
+		{"expired long ago", License{ExpiryDate: day.AddDate(0, 0, -expiredNoticeDays)}, 0, -expiredNoticeDays, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stage, daysLeft, ok := expiryStage(thresholds, tt.license, day)
+			if ok != tt.ok || (ok && (stage != tt.stage || daysLeft != tt.daysLeft)) {
+				t.Errorf("expiryStage = %d, %d, %v; want %d, %d, %v", stage, daysLeft, ok, tt.stage, tt.daysLeft, tt.ok)
+			}
+		})
+	}
+}
+
+func TestAlreadySent(t *testing.T) {
+	sent := []ExpiryNotification{{Recipient: "Owner@example.org", Stage: 14}}
+	tests := []struct {
+		to    string
+		stage int
+		want  bool
+	}{
+		{"owner@example.org", 30, true},
+		{"owner@example.org", 14, true},
+		{"owner@example.org", 7, false},
+		{"other@example.org", 30, false},
+	}
+	for _, tt := range tests {
+		if got := alreadySent(sent, tt.to, tt.stage); got != tt.want {
+			t.Errorf("alreadySent(%q, %d) = %v, want %v", tt.to, tt.stage, got, tt.want)
+		}
+	}
+}
+
+// fakeMailer records the emails it is asked to send, failing them while
+// err is set.
+type fakeMailer struct {
+	sent []fakeEmail
+	err  error
+}
+
+type fakeEmail struct {
+	to, subject, body string
+}
+
+func (m *fakeMailer) Send(to, subject, body string) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, fakeEmail{to, subject, body})
+	return nil
+}
+
+func TestExpiryNotifierRun(t *testing.T) {
+	day := today()
+	licenses := newMemLicenseRepository(
+		License{Name: "Office", ExpiryDate: day.AddDate(0, 0, 20), OwnerEmail: "owner@example.org"},
+		License{Name: "CAD", ExpiryDate: day.AddDate(0, 0, 3)},
+		License{Name: "Undated"},
+	)
+	mail := &fakeMailer{}
+	n := &expiryNotifier{
+		cfg: NotifyConfig{
+			Thresholds:       []int{30, 7},
+			DefaultRecipient: "it@example.org",
+			EscalateTo:       []string{"cio@example.org", "IT@example.org"},
+			EscalateDays:     7,
+		},
+		licenses: licenses,
+		sent:     newMemNotificationRepository(),
+		mail:     mail,
+	}
+	ctx := context.Background()
+
+	// While the mail server is down nothing is recorded as sent.
+	mail.err = errors.New("connection refused")
+	if emails, err := n.Run(ctx); err != nil || emails != 0 {
+		t.Fatalf("Run with a failing mailer = %d, %v; want 0, nil", emails, err)
+	}
+	mail.err = nil
+
+	emails, err := n.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]fakeEmail{}
+	for _, e := range mail.sent {
+		got[e.to] = e
+	}
+	if emails != 3 || len(got) != 3 {
+		t.Fatalf("sent %d emails to %v, want one each to the owner, the default recipient and the escalation list", emails, got)
+	}
+	if e := got["owner@example.org"]; !strings.Contains(e.body, "Office") || strings.HasPrefix(e.subject, "Urgent") {
+		t.Errorf("owner got %q: %q, want a routine notice about Office", e.subject, e.body)
+	}
+	for _, to := range []string{"it@example.org", "cio@example.org"} {
+		if e := got[to]; !strings.Contains(e.body, "CAD") || !strings.HasPrefix(e.subject, "Urgent") {
+			t.Errorf("%s got %q: %q, want an urgent notice about CAD", to, e.subject, e.body)
+		}
+	}
+
+	// Each notice goes out once per stage.
+	mail.sent = nil
+	if emails, err := n.Run(ctx); err != nil || emails != 0 {
+		t.Errorf("second Run = %d, %v; want 0, nil", emails, err)
+	}
+}
+
+// smtpStub accepts one SMTP session on a local port and returns the
+// message it was given.
+func smtpStub(t *testing.T) (host string, port int, message <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	out := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		reply("220 localhost ESMTP")
+		var data strings.Builder
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case cmd == "DATA":
+				reply("354 end with <CRLF>.<CRLF>")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				out <- data.String()
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	addr := ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, out
+}
+
+func TestSMTPMailer(t *testing.T) {
+	host, port, message := smtpStub(t)
+	m := smtpMailer{cfg: SMTPConfig{Host: host, Port: port, From: "SLAM <slam@example.org>"}}
+
+	if err := m.Send("owner@example.org", "License expiry notice: 1 license", "Office expires soon.\nRenew it."); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-message:
+		for _, want := range []string{
+			"From: \"SLAM\" <slam@example.org>\r\n",
+			"To: owner@example.org\r\n",
+			"Subject: License expiry notice: 1 license\r\n",
+			"\r\n\r\nOffice expires soon.\r\nRenew it.",
+		} {
+			if !strings.Contains(msg, want) {
+				t.Errorf("message lacks %q:\n%s", want, msg)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message reached the SMTP server")
+	}
+}
+
+func TestSMTPMailerUnreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	m := smtpMailer{cfg: SMTPConfig{Host: "127.0.0.1", Port: port, From: "slam@example.org"}}
+	if err := m.Send("owner@example.org", "subject", "body"); err == nil {
+		t.Error("Send succeeded with no server listening on port " + strconv.Itoa(port))
+	}
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Department groups people in the directory.
+type Department struct {
+	ID   int
+	Name string
+	// Members is the number of people in the department.
+	Members int
+}
+
+// Person is someone assets can be checked out to. Username links the
+// person to a user account, whose "my assets" page lists what they hold.
+type Person struct {
+	ID    int
+	Name  string
+	Email string
+	// DepartmentID is zero for people without a department.
+	DepartmentID int
+	Department   string
+	Username     string
+}
+
+// parsePersonForm reads and validates the person form fields.
+func parsePersonForm(r *http.Request) (Person, error) {
+	p := Person{Name: r.FormValue("name"), Email: r.FormValue("email"), Username: r.FormValue("username")}
+	if v := r.FormValue("department-id"); v != "" {
+		var err error
+		if p.DepartmentID, err = strconv.Atoi(v); err != nil {
+			return p, fmt.Errorf("invalid department %q", v)
+		}
+	}
+	return p, validatePerson(&p)
+}
+
+// validatePerson trims the person's fields and checks that they have a
+// name and, if given, a valid email address.
+func validatePerson(p *Person) error {
+	p.Name = strings.TrimSpace(p.Name)
+	p.Username = strings.TrimSpace(p.Username)
+	if p.Name == "" {
+		return errors.New("a name is required")
+	}
+	var err error
+	p.Email, err = parseEmail(p.Email)
+	return err
+}
+
+// checkPerson verifies that the person's department exists and that no one
+// else is linked to the same user account.
+func (s *server) checkPerson(ctx context.Context, id int, p Person) error {
+	if p.DepartmentID != 0 {
+		departments, err := s.people.Departments(ctx)
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, d := range departments {
+			found = found || d.ID == p.DepartmentID
+		}
+		if !found {
+			return fmt.Errorf("%w: department %d does not exist", errInvalidPerson, p.DepartmentID)
+		}
+	}
+	if p.Username != "" {
+		other, err := s.people.ByUsername(ctx, p.Username)
+		if err == nil && other.ID != id {
+			return fmt.Errorf("%w: user %q is already linked to %s", errInvalidPerson, p.Username, other.Name)
+		} else if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// errInvalidPerson reports a person that refers to a missing department or
+// an already linked user account.
+var errInvalidPerson = errors.New("invalid person")
+
+// peopleHandler handles the people directory and new person submissions.
+func (s *server) peopleHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		p, err := parsePersonForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.checkPerson(r.Context(), 0, p); errors.Is(err, errInvalidPerson) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if err != nil {
+			log.Printf("Error checking person: %v\n", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if _, err := s.people.Create(r.Context(), p); err != nil {
+			log.Printf("Error inserting person: %v\n", err)
+			http.Error(w, "Error saving person", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/people", http.StatusSeeOther)
+		return
+	}
+
+	data, err := s.getPageData(r.Context())
+	if err != nil {
+		log.Printf("Error fetching page data: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if err := s.loadDirectory(r, data); err != nil {
+		log.Printf("Error fetching people: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	renderTemplate(w, r, data)
+}
+
+// loadDirectory fills in the people and departments for the directory and
+// person pages.
+func (s *server) loadDirectory(r *http.Request, data *PageData) error {
+	var err error
+	if data.People, err = s.people.List(r.Context()); err != nil {
+		return err
+	}
+	data.Departments, err = s.people.Departments(r.Context())
+	return err
+}
+
+// personHandler shows a person with the assets they hold.
+func (s *server) personHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	p, err := s.people.Get(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		log.Printf("Error fetching person: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := s.getPageData(r.Context())
+	if err != nil {
+		log.Printf("Error fetching page data: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if err := s.loadDirectory(r, data); err != nil {
+		log.Printf("Error fetching people: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	data.Person = p
+	data.Checkouts, err = s.people.Held(r.Context(), id)
+	if err != nil {
+		log.Printf("Error fetching checkouts: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	renderTemplate(w, r, data)
+}
+
+// updatePersonHandler saves the edit form on a person's page.
+func (s *server) updatePersonHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	p, err := parsePersonForm(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.checkPerson(r.Context(), id, p); errors.Is(err, errInvalidPerson) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Printf("Error checking person: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = s.people.Update(r.Context(), id, p)
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		log.Printf("Error updating person: %v\n", err)
+		http.Error(w, "Error saving person", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/people/%d", id), http.StatusSeeOther)
+}
+
+// deletePersonHandler removes a person who holds no assets. Their custody
+// history keeps their name.
+func (s *server) deletePersonHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	err = s.people.Delete(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if errors.Is(err, errCustody) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Printf("Error deleting person: %v\n", err)
+		http.Error(w, "Error deleting person", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/people", http.StatusSeeOther)
+}
+
+// errDuplicateDepartment reports a department name already in use.
+var errDuplicateDepartment = errors.New("duplicate department")
+
+// createDepartment adds a department, returning an error wrapping
+// errDuplicateDepartment if one with the same name, ignoring case, exists.
+func (s *server) createDepartment(ctx context.Context, name string) (int, error) {
+	departments, err := s.people.Departments(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, d := range departments {
+		if strings.EqualFold(d.Name, name) {
+			return 0, fmt.Errorf("%w: department %q already exists", errDuplicateDepartment, d.Name)
+		}
+	}
+	return s.people.CreateDepartment(ctx, name)
+}
+
+// departmentsHandler adds a department to the directory.
+func (s *server) departmentsHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		http.Error(w, "a department name is required", http.StatusBadRequest)
+		return
+	}
+	_, err := s.createDepartment(r.Context(), name)
+	if errors.Is(err, errDuplicateDepartment) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Printf("Error inserting department: %v\n", err)
+		http.Error(w, "Error saving department", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/people", http.StatusSeeOther)
+}
+
+// deleteDepartmentHandler removes a department; its members are kept
+// without one.
+func (s *server) deleteDepartmentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	err = s.people.DeleteDepartment(r.Context(), id)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		log.Printf("Error deleting department: %v\n", err)
+		http.Error(w, "Error deleting department", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/people", http.StatusSeeOther)
+}
+
+// myAssetsHandler lists the assets checked out to the person linked to the
+// signed-in user.
+func (s *server) myAssetsHandler(w http.ResponseWriter, r *http.Request) {
+	data, err := s.getPageData(r.Context())
+	if err != nil {
+		log.Printf("Error fetching page data: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	p, err := s.people.ByUsername(r.Context(), currentUser(r).Username)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		log.Printf("Error fetching person: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if p != nil {
+		data.Person = p
+		if data.Checkouts, err = s.people.Held(r.Context(), p.ID); err != nil {
+			log.Printf("Error fetching checkouts: %v\n", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+	renderTemplate(w, r, data)
+}
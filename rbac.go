@@ -0,0 +1,214 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Role names a set of permissions granted to a user.
+type Role string
+
+const (
+	RoleViewer         Role = "viewer"
+	RoleAssetManager   Role = "asset_manager"
+	RoleLicenseManager Role = "license_manager"
+	RoleAuditor        Role = "auditor"
+	RoleFOIOfficer     Role = "foi_officer"
+	RoleAdmin          Role = "admin"
+)
+
+// roles lists every role in the order offered by the user form.
+var roles = []Role{RoleViewer, RoleAssetManager, RoleLicenseManager, RoleAuditor, RoleFOIOfficer, RoleAdmin}
+
+// Label returns a human readable name for the role.
+func (r Role) Label() string {
+	switch r {
+	case RoleAssetManager:
+		return "Asset manager"
+	case RoleLicenseManager:
+		return "License manager"
+	case RoleAuditor:
+		return "Auditor"
+	case RoleFOIOfficer:
+		return "FOI officer"
+	case RoleAdmin:
+		return "Administrator"
+	default:
+		return "Viewer"
+	}
+}
+
+// validRole reports whether r is one of the known roles.
+func validRole(r Role) bool {
+	for _, known := range roles {
+		if r == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Permission names an operation that can be granted to a role.
+type Permission string
+
+const (
+	PermViewDashboard  Permission = "dashboard:view"
+	PermViewAssets     Permission = "assets:view"
+	PermManageAssets   Permission = "assets:manage"
+	PermViewLicenses   Permission = "licenses:view"
+	PermManageLicenses Permission = "licenses:manage"
+	PermViewAudit      Permission = "audit:view"
+	// PermViewCompliance runs the license compliance reconciliation.
+	PermViewCompliance Permission = "compliance:view"
+	PermViewRisks      Permission = "risks:view"
+	PermManageRisks    Permission = "risks:manage"
+	PermViewFOI        Permission = "foi:view"
+	PermManageFOI      Permission = "foi:manage"
+	PermViewReports    Permission = "reports:view"
+	PermManageReports  Permission = "reports:manage"
+	PermManageUsers    Permission = "users:manage"
+	// PermManageSettings covers webhooks; only admins hold it.
+	PermManageSettings Permission = "settings:manage"
+)
+
+// viewerPermissions are granted to every role.
+var viewerPermissions = []Permission{PermViewDashboard, PermViewAssets, PermViewLicenses, PermViewRisks, PermViewReports}
+
+// rolePermissions maps each role to the permissions it grants. Admins are
+// handled separately and hold every permission.
+var rolePermissions = map[Role][]Permission{
+	RoleViewer:         viewerPermissions,
+	RoleAssetManager:   append([]Permission{PermManageAssets, PermManageReports}, viewerPermissions...),
+	RoleLicenseManager: append([]Permission{PermManageLicenses, PermViewCompliance, PermManageReports}, viewerPermissions...),
+	RoleAuditor:        append([]Permission{PermViewAudit, PermViewCompliance, PermManageRisks, PermViewFOI, PermManageReports}, viewerPermissions...),
+	// FOI requests hold requesters' personal details, so only FOI officers,
+	// auditors and admins can see them.
+	RoleFOIOfficer: append([]Permission{PermViewFOI, PermManageFOI}, viewerPermissions...),
+}
+
+// Can reports whether the user's role grants the permission.
+func (u *User) Can(p Permission) bool {
+	if u == nil {
+		return false
+	}
+	if u.Role == RoleAdmin {
+		return true
+	}
+	for _, granted := range rolePermissions[u.Role] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Can reports whether the signed-in user holds the permission; templates
+// use it to hide actions the user is not allowed to take.
+func (d *PageData) Can(p Permission) bool {
+	return d.CurrentUser.Can(p)
+}
+
+// isReadMethod reports whether the HTTP method only reads data.
+func isReadMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// authorize wraps a handler so that read requests (GET, HEAD) require the
+// read permission and every other method requires the write permission.
+func authorize(read, write Permission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		need := write
+		if isReadMethod(r.Method) {
+			need = read
+		}
+		if !currentUser(r).Can(need) {
+			forbidden(w, r, need)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// forbidden rejects a request the user lacks permission for.
+func forbidden(w http.ResponseWriter, r *http.Request, need Permission) {
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		writeAPIError(w, http.StatusForbidden, "forbidden", fmt.Sprintf("your role does not allow %s", need))
+		return
+	}
+	http.Error(w, "Forbidden", http.StatusForbidden)
+}
+
+// usersHandler lists users and creates new accounts.
+func (s *server) usersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		username := strings.TrimSpace(r.FormValue("username"))
+		password := r.FormValue("password")
+		role := Role(r.FormValue("role"))
+		if username == "" || len(password) < 8 {
+			http.Error(w, "A username and a password of at least 8 characters are required", http.StatusBadRequest)
+			return
+		}
+		if !validRole(role) {
+			http.Error(w, "Unknown role", http.StatusBadRequest)
+			return
+		}
+
+		_, err := s.createUser(r.Context(), username, password, role)
+		if errors.Is(err, errUsernameTaken) {
+			http.Error(w, "Username is already taken", http.StatusBadRequest)
+			return
+		} else if err != nil {
+			log.Printf("Error creating user: %v\n", err)
+			http.Error(w, "Error saving user", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/users", http.StatusSeeOther)
+		return
+	}
+
+	data, err := s.getPageData(r.Context())
+	if err != nil {
+		log.Printf("Error fetching page data: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	data.Users, err = s.users.List(r.Context())
+	if err != nil {
+		log.Printf("Error fetching users: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	renderTemplate(w, r, data)
+}
+
+// userRoleHandler changes the role of an existing user.
+func (s *server) userRoleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	role := Role(r.FormValue("role"))
+	if !validRole(role) {
+		http.Error(w, "Unknown role", http.StatusBadRequest)
+		return
+	}
+	if u := currentUser(r); u != nil && u.ID == id && role != RoleAdmin {
+		http.Error(w, "You cannot remove your own administrator role", http.StatusBadRequest)
+		return
+	}
+
+	err = s.users.SetRole(r.Context(), id, role)
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		log.Printf("Error updating user role: %v\n", err)
+		http.Error(w, "Error saving user", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/users", http.StatusSeeOther)
+}
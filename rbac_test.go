@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCreateUserDuplicateUsername(t *testing.T) {
+	s := newSQLTestServer(t, nil)
+
+	form := url.Values{"username": {"ada"}, "password": {"correct horse"}, "role": {string(RoleViewer)}}
+	for i, want := range []int{http.StatusSeeOther, http.StatusBadRequest} {
+		req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		s.usersHandler(rec, req)
+		if rec.Code != want {
+			t.Errorf("request %d: status %d, want %d: %s", i+1, rec.Code, want, rec.Body)
+		}
+	}
+
+	users, err := s.users.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 {
+		t.Errorf("got %d users, want 1", len(users))
+	}
+	entries, err := s.auditLog.Search(context.Background(), AuditFilter{Entity: AuditUser, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("got %d audit entries for users, want 1", len(entries))
+	}
+}
+
+func TestUserRoleChange(t *testing.T) {
+	s := newMemTestServer()
+	ctx := context.Background()
+	// signedIn acts as user 1.
+	if _, err := s.createUser(ctx, "tester", "correct horse", RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	id, err := s.createUser(ctx, "ada", "correct horse", RoleViewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := signedIn(s, RoleAdmin)
+
+	path := fmt.Sprintf("/users/%d/role", id)
+	if rec := send(h, http.MethodPost, path, url.Values{"role": {"owner"}}); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown role: status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if rec := send(h, http.MethodPost, "/users/99/role", url.Values{"role": {string(RoleAuditor)}}); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown user: status %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	for i := 0; i < 2; i++ {
+		if rec := send(h, http.MethodPost, path, url.Values{"role": {string(RoleAuditor)}}); rec.Code != http.StatusSeeOther {
+			t.Fatalf("change %d: status %d, want %d: %s", i+1, rec.Code, http.StatusSeeOther, rec.Body)
+		}
+	}
+
+	u, err := s.users.Get(ctx, id)
+	if err != nil || u.Role != RoleAuditor {
+		t.Errorf("user after the change = %+v, %v; want an auditor", u, err)
+	}
+	// The creation and the one change that altered the role are recorded.
+	entries, err := s.auditLog.Search(ctx, AuditFilter{Entity: AuditUser, EntityID: id, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Action != AuditUpdate {
+		t.Errorf("audit entries = %+v, want a creation and one update", entries)
+	}
+}
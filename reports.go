@@ -0,0 +1,717 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reportHistoryLimit is how many recent runs the Report Execution page lists.
+const reportHistoryLimit = 50
+
+// ReportParam describes an input a report accepts.
+type ReportParam struct {
+	Name  string
+	Label string
+	// Type is the HTML input type used for the parameter: "text", "number"
+	// or "date". Number parameters must be non-negative integers.
+	Type string
+	// Default is used when the parameter is left blank. Parameters without
+	// a default are optional.
+	Default string
+}
+
+// ReportParams holds parameter values keyed by ReportParam.Name.
+type ReportParams map[string]string
+
+// Int returns a number parameter that resolveParams has already checked.
+func (p ReportParams) Int(name string) int {
+	n, _ := strconv.Atoi(p[name])
+	return n
+}
+
+// Summary renders the values as "name=value" pairs for display.
+func (p ReportParams) Summary() string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		names[i] = name + "=" + p[name]
+	}
+	return strings.Join(names, ", ")
+}
+
+// ReportResult is the tabular output of a report run. Every cell is a
+// string so results can be stored and downloaded exactly as produced.
+type ReportResult struct {
+	Columns []string   `json:"columns"`
+	Rows    [][]string `json:"rows"`
+}
+
+// ReportDefinition is a built-in report in the catalogue.
+type ReportDefinition struct {
+	Key         string
+	Name        string
+	Description string
+	// Permission is needed, on top of reports:view, to run the report and
+	// see its results.
+	Permission Permission
+	Params     []ReportParam
+	run        func(ctx context.Context, s *server, params ReportParams) (*ReportResult, error)
+}
+
+// reportDefinitions is the catalogue of built-in reports.
+var reportDefinitions = []ReportDefinition{
+	{
+		Key:         "asset-register-by-location",
+		Name:        "Asset register by location",
+		Description: "Every asset grouped by location, optionally limited to a location and everything inside it, given by path or name.",
+		Permission:  PermViewAssets,
+		Params: []ReportParam{
+			{Name: "location", Label: "Location", Type: "text"},
+		},
+		run: runAssetRegisterReport,
+	},
+	{
+		Key:         "assets-per-location",
+		Name:        "Assets per location",
+		Description: "The number of assets kept at each site, building, floor and room, and in total inside it.",
+		Permission:  PermViewAssets,
+		run:         runAssetsPerLocationReport,
+	},
+	{
+		Key:         "assets-per-type",
+		Name:        "Assets per type",
+		Description: "The number of assets of each asset type, with the custom fields each type defines.",
+		Permission:  PermViewAssets,
+		run:         runAssetsPerTypeReport,
+	},
+	{
+		Key:         "assets-by-type",
+		Name:        "Assets by type",
+		Description: "The assets of one type with their custom field values, optionally limited by conditions on the fields separated by semicolons, such as ram_gb>=16; operating_system=Windows.",
+		Permission:  PermViewAssets,
+		Params: []ReportParam{
+			{Name: "type", Label: "Asset type", Type: "text"},
+			{Name: "where", Label: "Conditions", Type: "text"},
+		},
+		run: runAssetsByTypeReport,
+	},
+	{
+		Key:         "licenses-expiring",
+		Name:        "Licenses expiring",
+		Description: "Licenses that expire within the given number of days.",
+		Permission:  PermViewLicenses,
+		Params: []ReportParam{
+			{Name: "days", Label: "Days ahead", Type: "number", Default: "90"},
+		},
+		run: runExpiringLicensesReport,
+	},
+	{
+		Key:         "spend-by-vendor",
+		Name:        "Spend by vendor",
+		Description: "Seats and spend of the licenses in force, totalled per vendor.",
+		Permission:  PermViewLicenses,
+		Params: []ReportParam{
+			{Name: "as_of", Label: "As of", Type: "date"},
+		},
+		run: runSpendByVendorReport,
+	},
+	{
+		Key:         "compliance-position",
+		Name:        "Compliance position",
+		Description: "The effective license position of every product, as on the Compliance Audits page.",
+		Permission:  PermViewCompliance,
+		run:         runCompliancePositionReport,
+	},
+}
+
+// reportDefinition looks up a built-in report by key.
+func reportDefinition(key string) (*ReportDefinition, bool) {
+	for i := range reportDefinitions {
+		if reportDefinitions[i].Key == key {
+			return &reportDefinitions[i], true
+		}
+	}
+	return nil, false
+}
+
+// runnableReports returns the reports the user is allowed to run.
+func runnableReports(u *User) []ReportDefinition {
+	var defs []ReportDefinition
+	for _, def := range reportDefinitions {
+		if u.Can(def.Permission) {
+			defs = append(defs, def)
+		}
+	}
+	return defs
+}
+
+// resolveParams validates the supplied values against the report's
+// parameters, filling in defaults. Unknown parameters are rejected so a
+// typo does not silently run the report unfiltered.
+func (d *ReportDefinition) resolveParams(in ReportParams) (ReportParams, error) {
+	out := make(ReportParams)
+	for name := range in {
+		if !d.hasParam(name) {
+			return nil, fmt.Errorf("report %q has no parameter %q", d.Key, name)
+		}
+	}
+	for _, p := range d.Params {
+		v := strings.TrimSpace(in[p.Name])
+		if v == "" {
+			v = p.Default
+		}
+		if v == "" {
+			continue
+		}
+		switch p.Type {
+		case "number":
+			if n, err := strconv.Atoi(v); err != nil || n < 0 {
+				return nil, fmt.Errorf("%s must be a whole number, got %q", strings.ToLower(p.Label), v)
+			}
+		case "date":
+			if _, err := time.Parse(dateLayout, v); err != nil {
+				return nil, fmt.Errorf("invalid %s %q", strings.ToLower(p.Label), v)
+			}
+		}
+		out[p.Name] = v
+	}
+	return out, nil
+}
+
+func (d *ReportDefinition) hasParam(name string) bool {
+	for _, p := range d.Params {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SavedReport is a user-defined report: a built-in report with stored
+// parameter values.
+type SavedReport struct {
+	ID        int
+	Name      string
+	ReportKey string
+	Params    ReportParams
+	CreatedBy string
+	CreatedAt time.Time
+}
+
+// Definition returns the built-in report the saved report runs.
+func (sr SavedReport) Definition() *ReportDefinition {
+	def, _ := reportDefinition(sr.ReportKey)
+	return def
+}
+
+// ReportRun records one execution of a report and, if it succeeded, the
+// result so it can be downloaded again later.
+type ReportRun struct {
+	ID int
+	// SavedReportID is zero for ad hoc runs and for runs whose saved
+	// report has since been deleted.
+	SavedReportID int
+	Name          string
+	ReportKey     string
+	Params        ReportParams
+	RunBy         string
+	StartedAt     time.Time
+	FinishedAt    time.Time
+	// Error is set when the run failed; Result is then nil.
+	Error    string
+	RowCount int
+	Result   *ReportResult
+}
+
+// Succeeded reports whether the run produced a result.
+func (r ReportRun) Succeeded() bool {
+	return r.Error == ""
+}
+
+// Definition returns the built-in report that was run.
+func (r ReportRun) Definition() *ReportDefinition {
+	def, _ := reportDefinition(r.ReportKey)
+	return def
+}
+
+// Filename is the name offered when the result is downloaded.
+func (r ReportRun) Filename(ext string) string {
+	return fmt.Sprintf("%s-run-%d.%s", r.ReportKey, r.ID, ext)
+}
+
+// canSeeRun reports whether the user may see a run's result.
+func canSeeRun(u *User, run ReportRun) bool {
+	def := run.Definition()
+	return def != nil && u.Can(def.Permission)
+}
+
+// executeReport runs a report and records the run. A report that fails is
+// recorded with its error; only failing to store the run returns an error.
+func (s *server) executeReport(ctx context.Context, def *ReportDefinition, params ReportParams, saved *SavedReport, user *User) (*ReportRun, error) {
+	run := ReportRun{
+		Name:      def.Name,
+		ReportKey: def.Key,
+		Params:    params,
+		StartedAt: time.Now().UTC().Truncate(time.Second),
+	}
+	if saved != nil {
+		run.SavedReportID = saved.ID
+		run.Name = saved.Name
+	}
+	if user != nil {
+		run.RunBy = user.Username
+	}
+
+	result, err := def.run(ctx, s, params)
+	run.FinishedAt = time.Now().UTC().Truncate(time.Second)
+	if err != nil {
+		log.Printf("Error running report %s: %v\n", def.Key, err)
+		run.Error = err.Error()
+	} else {
+		run.Result = result
+		run.RowCount = len(result.Rows)
+	}
+
+	id, err := s.reports.CreateRun(ctx, run)
+	if err != nil {
+		return nil, err
+	}
+	run.ID = id
+	return &run, nil
+}
+
+func runAssetRegisterReport(ctx context.Context, s *server, params ReportParams) (*ReportResult, error) {
+	assets, err := s.assets.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(assets, func(i, j int) bool {
+		if assets[i].Location != assets[j].Location {
+			return assets[i].Location < assets[j].Location
+		}
+		return assets[i].Name < assets[j].Name
+	})
+
+	var inside map[int]bool
+	if want := params["location"]; want != "" {
+		locations, err := s.locations.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		l, err := findLocation(locations, 0, want)
+		if err != nil {
+			return nil, err
+		}
+		inside = locationSubtree(locations, l.ID)
+	}
+
+	result := &ReportResult{Columns: []string{"Location", "Asset", "Type", "Asset ID"}}
+	for _, a := range assets {
+		if inside != nil && !inside[a.LocationID] {
+			continue
+		}
+		result.Rows = append(result.Rows, []string{a.Location, a.Name, a.AssetType, strconv.Itoa(a.ID)})
+	}
+	return result, nil
+}
+
+func runAssetsPerLocationReport(ctx context.Context, s *server, params ReportParams) (*ReportResult, error) {
+	locations, err := s.locations.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	assets, err := s.assets.Count(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReportResult{Columns: []string{"Location", "Kind", "Assets Here", "Total Assets"}}
+	located := 0
+	for _, l := range locations {
+		result.Rows = append(result.Rows, []string{l.Path, l.Kind.Label(), strconv.Itoa(l.Assets), strconv.Itoa(l.Total)})
+		located += l.Assets
+	}
+	result.Rows = append(result.Rows, []string{"No location", "", strconv.Itoa(assets - located), strconv.Itoa(assets - located)})
+	return result, nil
+}
+
+func runAssetsPerTypeReport(ctx context.Context, s *server, params ReportParams) (*ReportResult, error) {
+	types, err := s.assetTypes.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	assets, err := s.assets.Count(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReportResult{Columns: []string{"Type", "Custom Fields", "Assets"}}
+	typed := 0
+	for _, t := range types {
+		labels := make([]string, len(t.Fields))
+		for i, f := range t.Fields {
+			labels[i] = f.Label
+		}
+		result.Rows = append(result.Rows, []string{t.Name, strings.Join(labels, ", "), strconv.Itoa(t.Assets)})
+		typed += t.Assets
+	}
+	result.Rows = append(result.Rows, []string{"No type", "", strconv.Itoa(assets - typed)})
+	return result, nil
+}
+
+func runAssetsByTypeReport(ctx context.Context, s *server, params ReportParams) (*ReportResult, error) {
+	if params["type"] == "" {
+		return nil, fmt.Errorf("an asset type is required")
+	}
+	var where []string
+	for _, expr := range strings.Split(params["where"], ";") {
+		if strings.TrimSpace(expr) != "" {
+			where = append(where, expr)
+		}
+	}
+	t, assets, err := s.assetsOfType(ctx, params["type"], where)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(assets, func(i, j int) bool { return assets[i].Name < assets[j].Name })
+
+	result := &ReportResult{Columns: []string{"Asset ID", "Asset", "Location", "State"}}
+	for _, f := range t.Fields {
+		result.Columns = append(result.Columns, f.Label)
+	}
+	for _, a := range assets {
+		row := []string{strconv.Itoa(a.ID), a.Name, a.Location, a.State.Label()}
+		for _, f := range t.Fields {
+			row = append(row, a.Field(f.Name))
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	return result, nil
+}
+
+func runExpiringLicensesReport(ctx context.Context, s *server, params ReportParams) (*ReportResult, error) {
+	licenses, err := s.licenses.ExpiringWithin(ctx, params.Int("days"))
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(licenses, func(i, j int) bool { return licenses[i].ExpiryDate.Before(licenses[j].ExpiryDate) })
+
+	now := today()
+	result := &ReportResult{Columns: []string{"License", "Vendor", "Expiry Date", "Days Left", "Status", "Quantity", "Metric"}}
+	for _, l := range licenses {
+		daysLeft := int(l.ExpiryDate.Sub(now).Hours() / 24)
+		result.Rows = append(result.Rows, []string{
+			l.Name, l.Vendor, l.ExpiryDate.Format(dateLayout), strconv.Itoa(daysLeft),
+			l.Status, strconv.Itoa(l.Quantity), l.Metric.Label(),
+		})
+	}
+	return result, nil
+}
+
+func runSpendByVendorReport(ctx context.Context, s *server, params ReportParams) (*ReportResult, error) {
+	licenses, err := s.licenses.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	asOf := today()
+	if v := params["as_of"]; v != "" {
+		asOf, _ = time.Parse(dateLayout, v)
+	}
+
+	type spend struct {
+		licenses, seats int
+		total           float64
+	}
+	byVendor := make(map[string]*spend)
+	var vendors []string
+	var total spend
+	for _, l := range licenses {
+		if !licenseCounts(l, asOf) {
+			continue
+		}
+		vendor := l.Vendor
+		if vendor == "" {
+			vendor = "(no vendor)"
+		}
+		v, ok := byVendor[vendor]
+		if !ok {
+			v = &spend{}
+			byVendor[vendor] = v
+			vendors = append(vendors, vendor)
+		}
+		cost := float64(l.Quantity) * l.UnitCost
+		v.licenses++
+		v.seats += l.Quantity
+		v.total += cost
+		total.licenses++
+		total.seats += l.Quantity
+		total.total += cost
+	}
+	sort.Slice(vendors, func(i, j int) bool {
+		if byVendor[vendors[i]].total != byVendor[vendors[j]].total {
+			return byVendor[vendors[i]].total > byVendor[vendors[j]].total
+		}
+		return vendors[i] < vendors[j]
+	})
+
+	result := &ReportResult{Columns: []string{"Vendor", "Licenses", "Seats", "Spend"}}
+	for _, vendor := range vendors {
+		v := byVendor[vendor]
+		result.Rows = append(result.Rows, []string{vendor, strconv.Itoa(v.licenses), strconv.Itoa(v.seats), fmt.Sprintf("%.2f", v.total)})
+	}
+	result.Rows = append(result.Rows, []string{"Total", strconv.Itoa(total.licenses), strconv.Itoa(total.seats), fmt.Sprintf("%.2f", total.total)})
+	return result, nil
+}
+
+func runCompliancePositionReport(ctx context.Context, s *server, params ReportParams) (*ReportResult, error) {
+	report, err := s.runCompliance(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := &ReportResult{Columns: []string{"Product", "Metric", "Position", "Licenses", "Entitled", "Installed", "Assigned", "Shortfall", "Surplus", "Exposure"}}
+	for _, p := range report.Products {
+		entitled := strconv.Itoa(p.Entitled)
+		if p.Unlimited {
+			entitled = "unlimited"
+		}
+		result.Rows = append(result.Rows, []string{
+			p.Product, p.Metric.Label(), p.Position.Label(), strings.Join(p.Licenses, "; "), entitled,
+			strconv.Itoa(p.Installations), strconv.Itoa(p.Assigned), strconv.Itoa(p.Shortfall),
+			strconv.Itoa(p.Surplus), fmt.Sprintf("%.2f", p.Exposure),
+		})
+	}
+	return result, nil
+}
+
+// parseReportParams reads the "param-<name>" form fields of a report.
+func parseReportParams(r *http.Request, def *ReportDefinition) (ReportParams, error) {
+	in := make(ReportParams)
+	for _, p := range def.Params {
+		in[p.Name] = r.FormValue("param-" + p.Name)
+	}
+	return def.resolveParams(in)
+}
+
+// reportsHandler serves the Report Execution page and saves new report
+// definitions.
+func (s *server) reportsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		def, ok := reportDefinition(r.FormValue("report"))
+		if !ok || !currentUser(r).Can(def.Permission) {
+			http.Error(w, "Unknown report", http.StatusBadRequest)
+			return
+		}
+		name := strings.TrimSpace(r.FormValue("name"))
+		if name == "" {
+			http.Error(w, "A name is required to save a report", http.StatusBadRequest)
+			return
+		}
+		params, err := parseReportParams(r, def)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		_, err = s.reports.CreateSaved(r.Context(), SavedReport{Name: name, ReportKey: def.Key, Params: params, CreatedBy: currentUser(r).Username})
+		if err != nil {
+			log.Printf("Error saving report: %v\n", err)
+			http.Error(w, "Error saving report", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/reports", http.StatusSeeOther)
+		return
+	}
+
+	data, err := s.getReportPageData(r)
+	if err != nil {
+		log.Printf("Error fetching page data: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	renderTemplate(w, r, data)
+}
+
+// getReportPageData adds the catalogue, saved reports and run history the
+// user may see to the page data.
+func (s *server) getReportPageData(r *http.Request) (*PageData, error) {
+	data, err := s.getPageData(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	user := currentUser(r)
+	data.ReportDefinitions = runnableReports(user)
+
+	saved, err := s.reports.ListSaved(r.Context())
+	if err != nil {
+		return nil, fmt.Errorf("error fetching saved reports: %w", err)
+	}
+	for _, sr := range saved {
+		if def := sr.Definition(); def != nil && user.Can(def.Permission) {
+			data.SavedReports = append(data.SavedReports, sr)
+		}
+	}
+
+	if data.ReportRuns, err = s.visibleReportRuns(r); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// visibleReportRuns returns the recent runs whose results the user may see.
+func (s *server) visibleReportRuns(r *http.Request) ([]ReportRun, error) {
+	runs, err := s.reports.ListRuns(r.Context(), reportHistoryLimit)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching report runs: %w", err)
+	}
+	user := currentUser(r)
+	var visible []ReportRun
+	for _, run := range runs {
+		if canSeeRun(user, run) {
+			visible = append(visible, run)
+		}
+	}
+	return visible, nil
+}
+
+// runReportHandler runs a built-in report with the submitted parameters.
+func (s *server) runReportHandler(w http.ResponseWriter, r *http.Request) {
+	def, ok := reportDefinition(r.FormValue("report"))
+	if !ok || !currentUser(r).Can(def.Permission) {
+		http.Error(w, "Unknown report", http.StatusBadRequest)
+		return
+	}
+	params, err := parseReportParams(r, def)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.runAndRedirect(w, r, def, params, nil)
+}
+
+// runSavedReportHandler runs a saved report with its stored parameters.
+func (s *server) runSavedReportHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	saved, err := s.reports.GetSaved(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		log.Printf("Error fetching saved report: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	def := saved.Definition()
+	if def == nil || !currentUser(r).Can(def.Permission) {
+		http.NotFound(w, r)
+		return
+	}
+	// Re-check the stored parameters in case the report has changed since
+	// they were saved.
+	params, err := def.resolveParams(saved.Params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.runAndRedirect(w, r, def, params, saved)
+}
+
+// runAndRedirect executes a report and shows the recorded run.
+func (s *server) runAndRedirect(w http.ResponseWriter, r *http.Request, def *ReportDefinition, params ReportParams, saved *SavedReport) {
+	run, err := s.executeReport(r.Context(), def, params, saved, currentUser(r))
+	if err != nil {
+		log.Printf("Error recording report run: %v\n", err)
+		http.Error(w, "Error running report", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/reports/runs/%d", run.ID), http.StatusSeeOther)
+}
+
+// deleteSavedReportHandler removes a saved report. Its past runs are kept.
+func (s *server) deleteSavedReportHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	err = s.reports.DeleteSaved(r.Context(), id)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		log.Printf("Error deleting saved report: %v\n", err)
+		http.Error(w, "Error deleting saved report", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/reports", http.StatusSeeOther)
+}
+
+// getVisibleRun fetches a run for a page handler, answering 404 when it
+// does not exist or the user may not see it.
+func (s *server) getVisibleRun(w http.ResponseWriter, r *http.Request) (*ReportRun, bool) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return nil, false
+	}
+	run, err := s.reports.GetRun(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return nil, false
+	} else if err != nil {
+		log.Printf("Error fetching report run: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if !canSeeRun(currentUser(r), *run) {
+		http.NotFound(w, r)
+		return nil, false
+	}
+	return run, true
+}
+
+// reportRunHandler shows the stored result of a report run.
+func (s *server) reportRunHandler(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.getVisibleRun(w, r)
+	if !ok {
+		return
+	}
+	data, err := s.getReportPageData(r)
+	if err != nil {
+		log.Printf("Error fetching page data: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	data.ReportRun = run
+	renderTemplate(w, r, data)
+}
+
+// downloadReportRunHandler sends the stored result of a run as CSV or
+// XLSX.
+func (s *server) downloadReportRunHandler(w http.ResponseWriter, r *http.Request) {
+	format, err := exportFormat(r, formatCSV, formatCSV, formatXLSX)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	run, ok := s.getVisibleRun(w, r)
+	if !ok {
+		return
+	}
+	if run.Result == nil {
+		http.Error(w, "This run failed and has no result", http.StatusNotFound)
+		return
+	}
+	writeExport(w, format, run.Filename(format), reportResultTable(run.Result))
+}
@@ -0,0 +1,2809 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned by repositories when the requested record does not exist.
+var ErrNotFound = errors.New("record not found")
+
+// AssetRepository stores assets. Deleted assets are kept, marked deleted,
+// so that their history stays on record, but no method returns them.
+type AssetRepository interface {
+	List(ctx context.Context) ([]Asset, error)
+	// ListOfType returns the assets of a type that meet every condition,
+	// see AssetType.parseCondition.
+	ListOfType(ctx context.Context, typeID int, conditions []fieldCondition) ([]Asset, error)
+	Get(ctx context.Context, id int) (*Asset, error)
+	// Create stores the asset in its State, recording that as the first
+	// state change and its location, if any, as the first move.
+	Create(ctx context.Context, a Asset) (int, error)
+	// CreateMany stores every asset in a single transaction and returns
+	// their ids in order; none are stored if any insert fails.
+	CreateMany(ctx context.Context, assets []Asset) ([]int, error)
+	// Update saves everything but the asset's state, which only changes
+	// through Transition. A change of location is recorded as a move.
+	Update(ctx context.Context, id int, a Asset) error
+	Delete(ctx context.Context, id int) error
+	Count(ctx context.Context) (int, error)
+	// Transition moves an asset to another state, returning an error
+	// wrapping errInvalidTransition if the lifecycle does not allow it.
+	Transition(ctx context.Context, id int, state AssetState, reason string) (*AssetStateChange, error)
+	// StateChanges returns the asset's state changes, oldest first.
+	StateChanges(ctx context.Context, id int) ([]AssetStateChange, error)
+	// Moves returns the asset's moves between locations, newest first.
+	Moves(ctx context.Context, id int) ([]AssetMove, error)
+}
+
+// LicenseRepository stores licenses.
+type LicenseRepository interface {
+	List(ctx context.Context) ([]License, error)
+	Get(ctx context.Context, id int) (*License, error)
+	Create(ctx context.Context, l License) (int, error)
+	// CreateMany stores every license in a single transaction and returns
+	// their ids in order; none are stored if any insert fails.
+	CreateMany(ctx context.Context, licenses []License) ([]int, error)
+	Update(ctx context.Context, id int, l License) error
+	Delete(ctx context.Context, id int) error
+	Count(ctx context.Context) (int, error)
+	// ExpiringWithin returns licenses whose expiry date falls between today
+	// and the given number of days from now, inclusive.
+	ExpiringWithin(ctx context.Context, days int) ([]License, error)
+	// Assignments lists the seat assignments of a license.
+	Assignments(ctx context.Context, licenseID int) ([]LicenseAssignment, error)
+	// AssetAssignments lists the seat assignments held by an asset.
+	AssetAssignments(ctx context.Context, assetID int) ([]LicenseAssignment, error)
+	// PersonAssignments lists the seat assignments linked to a person.
+	PersonAssignments(ctx context.Context, personID int) ([]LicenseAssignment, error)
+	// Assign records a seat assignment, returning ErrNotFound if the
+	// license does not exist.
+	Assign(ctx context.Context, a LicenseAssignment) (int, error)
+	// Unassign removes a seat assignment from a license.
+	Unassign(ctx context.Context, licenseID, assignmentID int) error
+}
+
+// SoftwareRepository stores the software installed on each asset.
+type SoftwareRepository interface {
+	// List returns the software installed on an asset.
+	List(ctx context.Context, assetID int) ([]InstalledSoftware, error)
+	// Add records a single installation.
+	Add(ctx context.Context, sw InstalledSoftware) (int, error)
+	// Remove deletes an installation from an asset.
+	Remove(ctx context.Context, assetID, id int) error
+	// All returns every installation across all assets.
+	All(ctx context.Context) ([]InstalledSoftware, error)
+	// ReplaceSource replaces everything previously reported for an asset by
+	// source with the given snapshot, in a single transaction.
+	ReplaceSource(ctx context.Context, assetID int, source string, items []InstalledSoftware) error
+}
+
+// RiskRepository stores the risk register.
+type RiskRepository interface {
+	// List returns every risk, highest score first, with its links and
+	// mitigations.
+	List(ctx context.Context) ([]Risk, error)
+	Get(ctx context.Context, id int) (*Risk, error)
+	Create(ctx context.Context, r Risk) (int, error)
+	// Update saves a risk's details and links. The status is left alone;
+	// it only changes through Transition.
+	Update(ctx context.Context, id int, r Risk) error
+	Delete(ctx context.Context, id int) error
+	// Transition moves a risk to another status, returning an error
+	// wrapping errInvalidTransition if the workflow does not allow it.
+	Transition(ctx context.Context, id int, status RiskStatus) error
+	// AddMitigation records a mitigation action, returning ErrNotFound if
+	// the risk does not exist.
+	AddMitigation(ctx context.Context, m RiskMitigation) (int, error)
+	// CompleteMitigation marks a mitigation action as done.
+	CompleteMitigation(ctx context.Context, riskID, mitigationID int) error
+	// CountHigh counts the open risks scoring at least highRiskScore.
+	CountHigh(ctx context.Context) (int, error)
+}
+
+// FOIRepository stores Freedom of Information requests.
+type FOIRepository interface {
+	// List returns every request, most urgent deadline first.
+	List(ctx context.Context) ([]FOIRequest, error)
+	Get(ctx context.Context, id int) (*FOIRequest, error)
+	Create(ctx context.Context, f FOIRequest) (int, error)
+	// Update saves a request's details and links but not its status.
+	Update(ctx context.Context, id int, f FOIRequest) error
+	Delete(ctx context.Context, id int) error
+	// Transition moves a request to another status, returning an error
+	// wrapping errInvalidTransition if the workflow does not allow it.
+	Transition(ctx context.Context, id int, status FOIStatus) error
+	// CountOverdue counts the open requests past their deadline.
+	CountOverdue(ctx context.Context) (int, error)
+}
+
+// ReportRepository stores saved report definitions and the history of
+// report runs.
+type ReportRepository interface {
+	ListSaved(ctx context.Context) ([]SavedReport, error)
+	GetSaved(ctx context.Context, id int) (*SavedReport, error)
+	CreateSaved(ctx context.Context, sr SavedReport) (int, error)
+	// DeleteSaved removes a saved report. Its runs are kept and become
+	// ad hoc runs.
+	DeleteSaved(ctx context.Context, id int) error
+	// ListRuns returns up to limit runs, newest first, without results.
+	ListRuns(ctx context.Context, limit int) ([]ReportRun, error)
+	// GetRun returns a run with its stored result.
+	GetRun(ctx context.Context, id int) (*ReportRun, error)
+	CreateRun(ctx context.Context, run ReportRun) (int, error)
+}
+
+// NotificationRepository stores the log of license expiry notices sent.
+type NotificationRepository interface {
+	// ForLicense lists the notices sent about a license for one expiry
+	// date, so that renewing the license starts the schedule afresh.
+	ForLicense(ctx context.Context, licenseID int, expiry time.Time) ([]ExpiryNotification, error)
+	// Record stores the notices sent in one email.
+	Record(ctx context.Context, notices []ExpiryNotification) error
+	// Recent returns up to limit notices, newest first.
+	Recent(ctx context.Context, limit int) ([]ExpiryNotification, error)
+}
+
+// WebhookRepository stores webhook subscriptions and their delivery queue.
+type WebhookRepository interface {
+	List(ctx context.Context) ([]Webhook, error)
+	Get(ctx context.Context, id int) (*Webhook, error)
+	Create(ctx context.Context, h Webhook) (int, error)
+	// Delete removes a webhook together with its deliveries.
+	Delete(ctx context.Context, id int) error
+	// HasDelivery reports whether the webhook has a delivery with the
+	// event key, so that scheduled events are only queued once.
+	HasDelivery(ctx context.Context, webhookID int, key string) (bool, error)
+	CreateDelivery(ctx context.Context, d WebhookDelivery) (int, error)
+	GetDelivery(ctx context.Context, id int) (*WebhookDelivery, error)
+	// UpdateDelivery saves the outcome of a delivery attempt.
+	UpdateDelivery(ctx context.Context, d WebhookDelivery) error
+	// DueDeliveries returns up to limit pending deliveries whose next
+	// attempt is at or before now, oldest first.
+	DueDeliveries(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error)
+	// Deliveries returns up to limit deliveries, newest first, for one
+	// webhook or for all of them when webhookID is 0.
+	Deliveries(ctx context.Context, webhookID, limit int) ([]WebhookDelivery, error)
+}
+
+// PeopleRepository stores the people and departments directory and the
+// custody of assets checked out to people.
+type PeopleRepository interface {
+	// Departments lists every department with its number of members, by
+	// name.
+	Departments(ctx context.Context) ([]Department, error)
+	CreateDepartment(ctx context.Context, name string) (int, error)
+	// DeleteDepartment removes a department, leaving its members without
+	// one.
+	DeleteDepartment(ctx context.Context, id int) error
+	// List returns every person, by name.
+	List(ctx context.Context) ([]Person, error)
+	Get(ctx context.Context, id int) (*Person, error)
+	// ByUsername returns the person linked to a user account.
+	ByUsername(ctx context.Context, username string) (*Person, error)
+	Create(ctx context.Context, p Person) (int, error)
+	Update(ctx context.Context, id int, p Person) error
+	// Delete removes a person, returning an error wrapping errCustody if
+	// they still hold assets. Their custody history and seat assignments
+	// are kept, under their name.
+	Delete(ctx context.Context, id int) error
+	// CheckOut records an asset being handed to a person, returning an
+	// error wrapping errCustody if it is already checked out, or
+	// ErrNotFound if the asset does not exist.
+	CheckOut(ctx context.Context, c Checkout) (int, error)
+	// CheckIn closes the asset's open checkout, returning an error
+	// wrapping errCustody if it is not checked out.
+	CheckIn(ctx context.Context, assetID int, by, notes string) (*Checkout, error)
+	// Custody returns the asset's checkouts, newest first.
+	Custody(ctx context.Context, assetID int) ([]Checkout, error)
+	// Held returns the open checkouts of a person, or of everyone when
+	// personID is 0, by expected return date.
+	Held(ctx context.Context, personID int) ([]Checkout, error)
+}
+
+// LocationRepository stores the hierarchy of locations assets are kept in.
+type LocationRepository interface {
+	// List returns every location in tree order, see buildLocationTree,
+	// with the number of assets at and inside each.
+	List(ctx context.Context) ([]Location, error)
+	Get(ctx context.Context, id int) (*Location, error)
+	Create(ctx context.Context, l Location) (int, error)
+	Update(ctx context.Context, id int, l Location) error
+	// Delete removes a location, returning an error wrapping
+	// errLocationInUse if assets or other locations are inside it.
+	Delete(ctx context.Context, id int) error
+}
+
+// AssetTypeRepository stores the asset types and the custom fields
+// defined for each.
+type AssetTypeRepository interface {
+	// List returns every type by name, with its fields in order and the
+	// number of assets of each.
+	List(ctx context.Context) ([]AssetType, error)
+	Get(ctx context.Context, id int) (*AssetType, error)
+	// Create stores a type together with its Fields. Create and Update
+	// return an error wrapping errInvalidAssetType if another type has the
+	// name, ignoring case.
+	Create(ctx context.Context, t AssetType) (int, error)
+	// Update renames a type; its fields are changed one at a time.
+	Update(ctx context.Context, id int, t AssetType) error
+	// Delete removes a type and its fields, returning an error wrapping
+	// errAssetTypeInUse if assets are of that type.
+	Delete(ctx context.Context, id int) error
+	// CreateField adds a field after the type's existing ones.
+	CreateField(ctx context.Context, f AssetField) (int, error)
+	// UpdateField changes a field, respelling the values stored for it as
+	// AssetField.revalidate says, or returns an error wrapping
+	// errFieldValuesConflict and changes nothing if they no longer fit.
+	UpdateField(ctx context.Context, id int, f AssetField) error
+	// DeleteField removes a field and every asset's value for it.
+	DeleteField(ctx context.Context, typeID, id int) error
+}
+
+// UserRepository stores user accounts and their sessions. Passwords and
+// session tokens reach it already hashed.
+type UserRepository interface {
+	// List returns every user, by username.
+	List(ctx context.Context) ([]User, error)
+	Get(ctx context.Context, id int) (*User, error)
+	// Credentials returns the user with the username and their password
+	// hash.
+	Credentials(ctx context.Context, username string) (*User, string, error)
+	// Create stores a user, returning errUsernameTaken if the username is
+	// in use.
+	Create(ctx context.Context, username, passwordHash string, role Role) (int, error)
+	SetRole(ctx context.Context, id int, role Role) error
+	Count(ctx context.Context) (int, error)
+	// CreateSession stores a session for the user until expires, purging
+	// the sessions that have expired so they do not accumulate when users
+	// never sign out.
+	CreateSession(ctx context.Context, tokenHash string, userID int, expires time.Time) error
+	// SessionUser returns the user owning an unexpired session.
+	SessionUser(ctx context.Context, tokenHash string) (*User, error)
+	// DeleteSession removes a session, along with any that have expired.
+	DeleteSession(ctx context.Context, tokenHash string) error
+}
+
+// AuditRepository stores the append-only, hash chained audit log.
+type AuditRepository interface {
+	// Append chains the entry to the latest one, setting its PrevHash,
+	// Hash and ID, and stores it.
+	Append(ctx context.Context, e *AuditEntry) error
+	// Search returns the entries matching the filter, newest first.
+	Search(ctx context.Context, f AuditFilter) ([]AuditEntry, error)
+	// Walk calls fn with every entry in the order they were appended,
+	// stopping at the first error.
+	Walk(ctx context.Context, fn func(AuditEntry) error) error
+}
+
+// Transactor runs fn in a transaction carried by the context passed to it,
+// joining the one ctx already carries if any. Repository calls made with
+// that context commit or roll back together.
+type Transactor interface {
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+var (
+	_ Transactor = (*dbConn)(nil)
+
+	_ AssetRepository    = (*sqlAssetRepository)(nil)
+	_ LicenseRepository  = (*sqlLicenseRepository)(nil)
+	_ SoftwareRepository = (*sqlSoftwareRepository)(nil)
+	_ RiskRepository     = (*sqlRiskRepository)(nil)
+	_ FOIRepository      = (*sqlFOIRepository)(nil)
+	_ ReportRepository   = (*sqlReportRepository)(nil)
+
+	_ NotificationRepository = (*sqlNotificationRepository)(nil)
+	_ WebhookRepository      = (*sqlWebhookRepository)(nil)
+	_ AuditRepository        = (*sqlAuditRepository)(nil)
+	_ PeopleRepository       = (*sqlPeopleRepository)(nil)
+	_ LocationRepository     = (*sqlLocationRepository)(nil)
+	_ AssetTypeRepository    = (*sqlAssetTypeRepository)(nil)
+	_ UserRepository         = (*sqlUserRepository)(nil)
+)
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// rowsQuerier is implemented by *dbConn and *dbTx.
+type rowsQuerier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// parseDate converts a nullable DATE column into a time.Time, returning the
+// zero time for NULL or unparsable values. Drivers that hand DATE columns
+// back as timestamps are handled by only reading the date part.
+func parseDate(s sql.NullString) time.Time {
+	if !s.Valid || len(s.String) < len(dateLayout) {
+		return time.Time{}
+	}
+	t, _ := time.Parse(dateLayout, s.String[:len(dateLayout)])
+	return t
+}
+
+// nullTimestamp converts a time.Time into a value for a TIMESTAMP column,
+// storing the zero time as NULL.
+func nullTimestamp(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC().Format(timestampLayout)
+}
+
+// nullDate converts a time.Time into a value suitable for a DATE column,
+// storing the zero time as NULL.
+func nullDate(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.Format(dateLayout)
+}
+
+// checkAffected converts an UPDATE or DELETE that touched no rows into ErrNotFound.
+func checkAffected(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// sqlAssetRepository is the AssetRepository backed by the configured SQL
+// database (SQLite by default).
+type sqlAssetRepository struct {
+	db *dbConn
+}
+
+func newSQLAssetRepository(db *dbConn) *sqlAssetRepository {
+	return &sqlAssetRepository{db: db}
+}
+
+// assetColumns is the column list understood by scanAsset.
+const assetColumns = "id, name, type_id, location_id, manufacturer, model, serial_number, asset_tag, " +
+	"purchase_date, purchase_cost, supplier, order_reference, warranty_end, state"
+
+// scanAsset reads an asset row selected with assetColumns. The location's
+// path is filled in by withLocationPaths and the type's name and custom
+// fields by withTypes.
+func scanAsset(row rowScanner) (Asset, error) {
+	var a Asset
+	var typeID, locationID sql.NullInt64
+	var manufacturer, model, serial, tag, purchased, supplier, orderRef, warrantyEnd sql.NullString
+	if err := row.Scan(&a.ID, &a.Name, &typeID, &locationID, &manufacturer, &model, &serial, &tag,
+		&purchased, &a.PurchaseCost, &supplier, &orderRef, &warrantyEnd, &a.State); err != nil {
+		return a, err
+	}
+	a.TypeID = int(typeID.Int64)
+	a.LocationID = int(locationID.Int64)
+	a.Manufacturer = manufacturer.String
+	a.Model = model.String
+	a.SerialNumber = serial.String
+	a.AssetTag = tag.String
+	a.PurchaseDate = parseDate(purchased)
+	a.Supplier = supplier.String
+	a.OrderReference = orderRef.String
+	a.WarrantyEnd = parseDate(warrantyEnd)
+	return a, nil
+}
+
+func (r *sqlAssetRepository) List(ctx context.Context) ([]Asset, error) {
+	return r.queryAssets(ctx, "", nil, "")
+}
+
+func (r *sqlAssetRepository) ListOfType(ctx context.Context, typeID int, conditions []fieldCondition) ([]Asset, error) {
+	where := " AND type_id = ?"
+	args := []interface{}{typeID}
+	for _, c := range conditions {
+		cond, condArgs := r.conditionSQL(c)
+		where += " AND " + cond
+		args = append(args, condArgs...)
+	}
+	return r.queryAssets(ctx, where, args, "f.type_id = ?", typeID)
+}
+
+// conditionSQL renders a custom field condition as a test of the assets
+// row. Numbers are compared as numbers, text ignoring case, and dates,
+// enum values and booleans as their canonical text.
+func (r *sqlAssetRepository) conditionSQL(c fieldCondition) (string, []interface{}) {
+	const values = "SELECT 1 FROM asset_field_values v WHERE v.asset_id = assets.id AND v.field_id = ?"
+	if c.Value == "" {
+		if c.Op == "=" {
+			return "NOT EXISTS (" + values + ")", []interface{}{c.Field.ID}
+		}
+		return "EXISTS (" + values + ")", []interface{}{c.Field.ID}
+	}
+	op := c.Op
+	if op == "!=" {
+		op = "<>"
+	}
+	switch c.Field.Kind {
+	case FieldNumber:
+		// The CASE keeps the cast to the field's own values, which are
+		// all numbers, however the database orders the tests.
+		n, _ := strconv.ParseFloat(c.Value, 64)
+		return "EXISTS (" + values + " AND CASE WHEN v.field_id = ? THEN CAST(v.value AS " + r.db.dialect.number + ") END " + op + " ?)",
+			[]interface{}{c.Field.ID, c.Field.ID, n}
+	case FieldText:
+		return "EXISTS (" + values + " AND LOWER(v.value) " + op + " LOWER(?))", []interface{}{c.Field.ID, c.Value}
+	}
+	return "EXISTS (" + values + " AND v.value " + op + " ?)", []interface{}{c.Field.ID, c.Value}
+}
+
+// queryAssets returns the assets that are not deleted and meet where, a
+// condition on the assets row starting with " AND ", with the custom field
+// values matching valuesWhere, a condition on the field f, or all of them.
+func (r *sqlAssetRepository) queryAssets(ctx context.Context, where string, args []interface{}, valuesWhere string, valuesArgs ...interface{}) ([]Asset, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+assetColumns+" FROM assets WHERE deleted_at IS NULL"+where+" ORDER BY id", args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching assets: %w", err)
+	}
+	defer rows.Close()
+
+	var assets []Asset
+	for rows.Next() {
+		a, err := scanAsset(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning asset: %w", err)
+		}
+		assets = append(assets, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := r.withTypes(ctx, assets, valuesWhere, valuesArgs...); err != nil {
+		return nil, err
+	}
+	return assets, r.withLocationPaths(ctx, assets)
+}
+
+func (r *sqlAssetRepository) Get(ctx context.Context, id int) (*Asset, error) {
+	a, err := scanAsset(r.db.QueryRowContext(ctx, "SELECT "+assetColumns+" FROM assets WHERE id = ? AND deleted_at IS NULL", id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	assets := []Asset{a}
+	if err := r.withTypes(ctx, assets, "v.asset_id = ?", id); err != nil {
+		return nil, err
+	}
+	if err := r.withLocationPaths(ctx, assets); err != nil {
+		return nil, err
+	}
+	return &assets[0], nil
+}
+
+// withTypes sets the AssetType of each asset with a type to the type's
+// name and its Fields to its custom field values, in the order the type
+// defines them. where, if set, limits the values loaded with a condition
+// on the value v or its field f.
+func (r *sqlAssetRepository) withTypes(ctx context.Context, assets []Asset, where string, args ...interface{}) error {
+	names := make(map[int]string)
+	rows, err := r.db.QueryContext(ctx, "SELECT id, name FROM asset_types")
+	if err != nil {
+		return fmt.Errorf("error fetching asset types: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return fmt.Errorf("error scanning asset type: %w", err)
+		}
+		names[id] = name
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	query := `SELECT v.asset_id, f.name, f.label, f.kind, v.value
+		FROM asset_field_values v JOIN asset_type_fields f ON f.id = v.field_id`
+	if where != "" {
+		query += " WHERE " + where
+	}
+	values, err := r.db.QueryContext(ctx, query+" ORDER BY v.asset_id, f.position, f.id", args...)
+	if err != nil {
+		return fmt.Errorf("error fetching custom field values: %w", err)
+	}
+	defer values.Close()
+	fields := make(map[int][]AssetFieldValue)
+	for values.Next() {
+		var id int
+		var v AssetFieldValue
+		if err := values.Scan(&id, &v.Name, &v.Label, &v.Kind, &v.Value); err != nil {
+			return fmt.Errorf("error scanning custom field value: %w", err)
+		}
+		fields[id] = append(fields[id], v)
+	}
+	if err := values.Err(); err != nil {
+		return err
+	}
+	for i := range assets {
+		assets[i].AssetType = names[assets[i].TypeID]
+		assets[i].Fields = fields[assets[i].ID]
+	}
+	return nil
+}
+
+// saveAssetFields replaces the asset's custom field values with a.Fields,
+// which are those of a.TypeID as checked by resolveAssetType.
+func saveAssetFields(ctx context.Context, tx *dbTx, assetID int, a Asset) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM asset_field_values WHERE asset_id = ?", assetID); err != nil {
+		return err
+	}
+	if len(a.Fields) == 0 {
+		return nil
+	}
+	rows, err := tx.QueryContext(ctx, "SELECT id, name FROM asset_type_fields WHERE type_id = ?", a.TypeID)
+	if err != nil {
+		return err
+	}
+	ids := make(map[string]int)
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			rows.Close()
+			return err
+		}
+		ids[name] = id
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, v := range a.Fields {
+		fieldID, ok := ids[v.Name]
+		if !ok {
+			return fmt.Errorf("%w: %s has no custom field %q", errInvalidAssetType, a.AssetType, v.Name)
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO asset_field_values (asset_id, field_id, value) VALUES (?, ?, ?)", assetID, fieldID, v.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withLocationPaths sets the Location of each asset with a location to the
+// location's current path.
+func (r *sqlAssetRepository) withLocationPaths(ctx context.Context, assets []Asset) error {
+	paths, err := locationPaths(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	for i := range assets {
+		assets[i].Location = paths[assets[i].LocationID]
+	}
+	return nil
+}
+
+// locationPaths returns the path of every location by id.
+func locationPaths(ctx context.Context, q rowsQuerier) (map[int]string, error) {
+	locations, err := queryLocations(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	paths := make(map[int]string, len(locations))
+	for _, l := range locations {
+		paths[l.ID] = l.Path
+	}
+	return paths, nil
+}
+
+// insertAssetSQL inserts an asset; the arguments come from assetArgs.
+const insertAssetSQL = "INSERT INTO assets (name, type_id, location_id, manufacturer, model, serial_number, asset_tag, " +
+	"purchase_date, purchase_cost, supplier, order_reference, warranty_end, state) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+
+// assetArgs returns the columns Update saves; inserts add the state. Empty
+// serial numbers and asset tags are stored as NULL, which the unique
+// indexes ignore.
+func assetArgs(a Asset) []interface{} {
+	return []interface{}{a.Name, nullID(a.TypeID), nullID(a.LocationID), a.Manufacturer, a.Model, nullText(a.SerialNumber), nullText(a.AssetTag),
+		nullDate(a.PurchaseDate), a.PurchaseCost, a.Supplier, a.OrderReference, nullDate(a.WarrantyEnd)}
+}
+
+// insertStateChangeSQL records an asset entering a state.
+const insertStateChangeSQL = "INSERT INTO asset_state_changes (asset_id, from_state, to_state, reason, changed_at) VALUES (?, ?, ?, ?, ?)"
+
+// recordMove records an asset moving from one location to another; either
+// may be zero for no location. paths maps location ids to their paths.
+func recordMove(ctx context.Context, tx *dbTx, assetID, from, to int, paths map[int]string, now string) error {
+	_, err := tx.ExecContext(ctx, "INSERT INTO asset_moves (asset_id, from_location_id, from_path, to_location_id, to_path, moved_at, moved_by) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		assetID, nullID(from), nullText(paths[from]), nullID(to), nullText(paths[to]), now, nullText(auditActor(ctx)))
+	return err
+}
+
+// checkAssetIdentifiers returns an error wrapping errDuplicateAsset if
+// another asset than id, deleted or not, has a's serial number or tag.
+func checkAssetIdentifiers(ctx context.Context, tx *dbTx, a Asset, id int) error {
+	for _, field := range []struct{ column, label, value string }{
+		{"serial_number", "serial number", a.SerialNumber},
+		{"asset_tag", "asset tag", a.AssetTag},
+	} {
+		if field.value == "" {
+			continue
+		}
+		var other int
+		var deletedAt sql.NullString
+		err := tx.QueryRowContext(ctx, "SELECT id, deleted_at FROM assets WHERE "+field.column+" = ? AND id <> ?", field.value, id).Scan(&other, &deletedAt)
+		if err == sql.ErrNoRows {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if deletedAt.Valid {
+			return fmt.Errorf("%w: %s %q belongs to deleted asset %d", errDuplicateAsset, field.label, field.value, other)
+		}
+		return fmt.Errorf("%w: %s %q is already used by asset %d", errDuplicateAsset, field.label, field.value, other)
+	}
+	return nil
+}
+
+func (r *sqlAssetRepository) Create(ctx context.Context, a Asset) (int, error) {
+	ids, err := r.CreateMany(ctx, []Asset{a})
+	if err != nil {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+func (r *sqlAssetRepository) CreateMany(ctx context.Context, assets []Asset) ([]int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	paths, err := locationPaths(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC().Format(timestampLayout)
+	ids := make([]int, len(assets))
+	for i, a := range assets {
+		if err := checkAssetIdentifiers(ctx, tx, a, 0); err != nil {
+			return nil, err
+		}
+		if ids[i], err = tx.InsertContext(ctx, insertAssetSQL, append(assetArgs(a), a.State)...); err != nil {
+			return nil, fmt.Errorf("error inserting asset %q: %w", a.Name, err)
+		}
+		if _, err := tx.ExecContext(ctx, insertStateChangeSQL, ids[i], nil, a.State, nil, now); err != nil {
+			return nil, fmt.Errorf("error recording state of asset %q: %w", a.Name, err)
+		}
+		if err := saveAssetFields(ctx, tx, ids[i], a); err != nil {
+			return nil, fmt.Errorf("error saving custom fields of asset %q: %w", a.Name, err)
+		}
+		if a.LocationID != 0 {
+			if err := recordMove(ctx, tx, ids[i], 0, a.LocationID, paths, now); err != nil {
+				return nil, fmt.Errorf("error recording location of asset %q: %w", a.Name, err)
+			}
+		}
+	}
+	return ids, tx.Commit()
+}
+
+func (r *sqlAssetRepository) Update(ctx context.Context, id int, a Asset) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var current sql.NullInt64
+	err = tx.QueryRowContext(ctx, "SELECT location_id FROM assets WHERE id = ? AND deleted_at IS NULL", id).Scan(&current)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+	if err := checkAssetIdentifiers(ctx, tx, a, id); err != nil {
+		return err
+	}
+	args := append(assetArgs(a), id)
+	if err := checkAffected(tx.ExecContext(ctx, "UPDATE assets SET name = ?, type_id = ?, location_id = ?, manufacturer = ?, model = ?, serial_number = ?, asset_tag = ?, "+
+		"purchase_date = ?, purchase_cost = ?, supplier = ?, order_reference = ?, warranty_end = ? WHERE id = ? AND deleted_at IS NULL", args...)); err != nil {
+		return err
+	}
+	if err := saveAssetFields(ctx, tx, id, a); err != nil {
+		return err
+	}
+	if from := int(current.Int64); from != a.LocationID {
+		paths, err := locationPaths(ctx, tx)
+		if err != nil {
+			return err
+		}
+		if err := recordMove(ctx, tx, id, from, a.LocationID, paths, time.Now().UTC().Format(timestampLayout)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *sqlAssetRepository) Delete(ctx context.Context, id int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Mark the asset deleted first, so that a missing or already deleted
+	// asset is reported before anything else changes.
+	now := time.Now().UTC().Format(timestampLayout)
+	if err := checkAffected(tx.ExecContext(ctx, "UPDATE assets SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL", now, id)); err != nil {
+		return err
+	}
+	// Whoever held the asset no longer does; the custody history is kept.
+	if _, err := tx.ExecContext(ctx, "UPDATE asset_custody SET checked_in_at = ?, checked_in_by = ?, return_notes = ? WHERE asset_id = ? AND checked_in_at IS NULL",
+		now, auditActor(ctx), "asset deleted", id); err != nil {
+		return err
+	}
+	// Release any license seats the asset held and drop its inventory.
+	if _, err := tx.ExecContext(ctx, "DELETE FROM license_assignments WHERE asset_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM installed_software WHERE asset_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM risk_assets WHERE asset_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM foi_request_assets WHERE asset_id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *sqlAssetRepository) Count(ctx context.Context) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM assets WHERE deleted_at IS NULL").Scan(&n)
+	return n, err
+}
+
+func (r *sqlAssetRepository) Transition(ctx context.Context, id int, state AssetState, reason string) (*AssetStateChange, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var current AssetState
+	err = tx.QueryRowContext(ctx, "SELECT state FROM assets WHERE id = ? AND deleted_at IS NULL", id).Scan(&current)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if !current.CanMoveTo(state) {
+		return nil, fmt.Errorf("%w: %s to %s", errInvalidTransition, current, state)
+	}
+	res, err := tx.ExecContext(ctx, "UPDATE assets SET state = ? WHERE id = ? AND state = ? AND deleted_at IS NULL", state, id, current)
+	if err := checkAffected(res, err); errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("%w: asset %d changed concurrently", errInvalidTransition, id)
+	} else if err != nil {
+		return nil, err
+	}
+
+	change := AssetStateChange{AssetID: id, From: current, To: state, Reason: reason, ChangedAt: time.Now().UTC().Truncate(time.Second)}
+	change.ID, err = tx.InsertContext(ctx, insertStateChangeSQL, id, current, state, nullText(reason), change.ChangedAt.Format(timestampLayout))
+	if err != nil {
+		return nil, err
+	}
+	return &change, tx.Commit()
+}
+
+func (r *sqlAssetRepository) StateChanges(ctx context.Context, id int) ([]AssetStateChange, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT id, asset_id, from_state, to_state, reason, changed_at FROM asset_state_changes WHERE asset_id = ? ORDER BY id", id)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching asset state changes: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []AssetStateChange
+	for rows.Next() {
+		var c AssetStateChange
+		var from, reason, changedAt sql.NullString
+		if err := rows.Scan(&c.ID, &c.AssetID, &from, &c.To, &reason, &changedAt); err != nil {
+			return nil, fmt.Errorf("error scanning asset state change: %w", err)
+		}
+		c.From = AssetState(from.String)
+		c.Reason = reason.String
+		c.ChangedAt = parseTimestamp(changedAt.String)
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+func (r *sqlAssetRepository) Moves(ctx context.Context, id int) ([]AssetMove, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT id, asset_id, from_location_id, from_path, to_location_id, to_path, moved_at, moved_by FROM asset_moves WHERE asset_id = ? ORDER BY id DESC", id)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching asset moves: %w", err)
+	}
+	defer rows.Close()
+
+	var moves []AssetMove
+	for rows.Next() {
+		var m AssetMove
+		var fromID, toID sql.NullInt64
+		var from, to, movedAt, movedBy sql.NullString
+		if err := rows.Scan(&m.ID, &m.AssetID, &fromID, &from, &toID, &to, &movedAt, &movedBy); err != nil {
+			return nil, fmt.Errorf("error scanning asset move: %w", err)
+		}
+		m.FromID, m.From = int(fromID.Int64), from.String
+		m.ToID, m.To = int(toID.Int64), to.String
+		m.MovedAt = parseTimestamp(movedAt.String)
+		m.MovedBy = movedBy.String
+		moves = append(moves, m)
+	}
+	return moves, rows.Err()
+}
+
+// sqlLicenseRepository is the LicenseRepository backed by the configured SQL
+// database (SQLite by default).
+type sqlLicenseRepository struct {
+	db *dbConn
+}
+
+func newSQLLicenseRepository(db *dbConn) *sqlLicenseRepository {
+	return &sqlLicenseRepository{db: db}
+}
+
+// licenseColumns is the column list understood by scanLicense. The last
+// column totals the seats consumed by the license's assignments.
+const licenseColumns = "id, name, vendor, expiry_date, renewal_date, status, quantity, metric, product, unit_cost, owner_email, " +
+	"(SELECT COALESCE(SUM(la.quantity), 0) FROM license_assignments la WHERE la.license_id = licenses.id)"
+
+// scanLicense reads a license row selected with licenseColumns.
+func scanLicense(row rowScanner) (License, error) {
+	var l License
+	var vendor, expiry, renewal, status, product, ownerEmail sql.NullString
+	if err := row.Scan(&l.ID, &l.Name, &vendor, &expiry, &renewal, &status, &l.Quantity, &l.Metric, &product, &l.UnitCost, &ownerEmail, &l.Consumed); err != nil {
+		return l, err
+	}
+	l.Vendor = vendor.String
+	l.ExpiryDate = parseDate(expiry)
+	l.RenewalDate = parseDate(renewal)
+	l.Status = status.String
+	l.Product = product.String
+	l.OwnerEmail = ownerEmail.String
+	return l, nil
+}
+
+// queryLicenses runs a SELECT over licenseColumns and scans every row.
+func (r *sqlLicenseRepository) queryLicenses(ctx context.Context, query string, args ...interface{}) ([]License, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching licenses: %w", err)
+	}
+	defer rows.Close()
+
+	var licenses []License
+	for rows.Next() {
+		l, err := scanLicense(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning license: %w", err)
+		}
+		licenses = append(licenses, l)
+	}
+	return licenses, rows.Err()
+}
+
+func (r *sqlLicenseRepository) List(ctx context.Context) ([]License, error) {
+	return r.queryLicenses(ctx, "SELECT "+licenseColumns+" FROM licenses ORDER BY expiry_date IS NULL, expiry_date, name")
+}
+
+func (r *sqlLicenseRepository) ExpiringWithin(ctx context.Context, days int) ([]License, error) {
+	d := r.db.dialect
+	return r.queryLicenses(ctx, "SELECT "+licenseColumns+" FROM licenses WHERE expiry_date BETWEEN "+d.Today()+" AND "+d.DateOffset(days)+" ORDER BY expiry_date, name")
+}
+
+func (r *sqlLicenseRepository) Get(ctx context.Context, id int) (*License, error) {
+	l, err := scanLicense(r.db.QueryRowContext(ctx, "SELECT "+licenseColumns+" FROM licenses WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// insertLicenseSQL inserts a license; the arguments come from licenseArgs.
+const insertLicenseSQL = "INSERT INTO licenses (name, vendor, expiry_date, renewal_date, status, quantity, metric, product, unit_cost, owner_email) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+
+func licenseArgs(l License) []interface{} {
+	return []interface{}{l.Name, l.Vendor, nullDate(l.ExpiryDate), nullDate(l.RenewalDate), l.Status, l.Quantity, l.Metric, l.Product, l.UnitCost, l.OwnerEmail}
+}
+
+func (r *sqlLicenseRepository) Create(ctx context.Context, l License) (int, error) {
+	return r.db.InsertContext(ctx, insertLicenseSQL, licenseArgs(l)...)
+}
+
+func (r *sqlLicenseRepository) CreateMany(ctx context.Context, licenses []License) ([]int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	ids := make([]int, len(licenses))
+	for i, l := range licenses {
+		if ids[i], err = tx.InsertContext(ctx, insertLicenseSQL, licenseArgs(l)...); err != nil {
+			return nil, fmt.Errorf("error inserting license %q: %w", l.Name, err)
+		}
+	}
+	return ids, tx.Commit()
+}
+
+func (r *sqlLicenseRepository) Update(ctx context.Context, id int, l License) error {
+	return checkAffected(r.db.ExecContext(ctx, "UPDATE licenses SET name = ?, vendor = ?, expiry_date = ?, renewal_date = ?, status = ?, quantity = ?, metric = ?, product = ?, unit_cost = ?, owner_email = ? WHERE id = ?",
+		l.Name, l.Vendor, nullDate(l.ExpiryDate), nullDate(l.RenewalDate), l.Status, l.Quantity, l.Metric, l.Product, l.UnitCost, l.OwnerEmail, id))
+}
+
+func (r *sqlLicenseRepository) Delete(ctx context.Context, id int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM license_assignments WHERE license_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM risk_licenses WHERE license_id = ?", id); err != nil {
+		return err
+	}
+	// The notification log outlives the license it was sent about.
+	if _, err := tx.ExecContext(ctx, "UPDATE license_notifications SET license_id = NULL WHERE license_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM foi_request_licenses WHERE license_id = ?", id); err != nil {
+		return err
+	}
+	if err := checkAffected(tx.ExecContext(ctx, "DELETE FROM licenses WHERE id = ?", id)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *sqlLicenseRepository) Count(ctx context.Context) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM licenses").Scan(&n)
+	return n, err
+}
+
+func (r *sqlLicenseRepository) Assignments(ctx context.Context, licenseID int) ([]LicenseAssignment, error) {
+	return r.queryAssignments(ctx, "la.license_id = ?", licenseID)
+}
+
+func (r *sqlLicenseRepository) AssetAssignments(ctx context.Context, assetID int) ([]LicenseAssignment, error) {
+	return r.queryAssignments(ctx, "la.asset_id = ?", assetID)
+}
+
+func (r *sqlLicenseRepository) PersonAssignments(ctx context.Context, personID int) ([]LicenseAssignment, error) {
+	return r.queryAssignments(ctx, "la.person_id = ?", personID)
+}
+
+// queryAssignments returns the seat assignments matching the condition,
+// with the names of the asset and person each is assigned to.
+func (r *sqlLicenseRepository) queryAssignments(ctx context.Context, where string, args ...interface{}) ([]LicenseAssignment, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT la.id, la.license_id, la.asset_id, a.name, la.person_id, p.name, la.assignee, la.quantity, la.assigned_at
+		FROM license_assignments la LEFT JOIN assets a ON a.id = la.asset_id LEFT JOIN people p ON p.id = la.person_id
+		WHERE `+where+` ORDER BY la.id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching license assignments: %w", err)
+	}
+	defer rows.Close()
+
+	var assignments []LicenseAssignment
+	for rows.Next() {
+		var a LicenseAssignment
+		var assetID, personID sql.NullInt64
+		var assetName, personName, assignee, assignedAt sql.NullString
+		if err := rows.Scan(&a.ID, &a.LicenseID, &assetID, &assetName, &personID, &personName, &assignee, &a.Quantity, &assignedAt); err != nil {
+			return nil, fmt.Errorf("error scanning license assignment: %w", err)
+		}
+		a.AssetID = int(assetID.Int64)
+		a.AssetName = assetName.String
+		a.PersonID = int(personID.Int64)
+		a.PersonName = personName.String
+		a.Assignee = assignee.String
+		a.AssignedAt = parseTimestamp(assignedAt.String)
+		assignments = append(assignments, a)
+	}
+	return assignments, rows.Err()
+}
+
+func (r *sqlLicenseRepository) Assign(ctx context.Context, a LicenseAssignment) (int, error) {
+	if _, err := r.Get(ctx, a.LicenseID); err != nil {
+		return 0, err
+	}
+	return r.db.InsertContext(ctx, "INSERT INTO license_assignments (license_id, asset_id, person_id, assignee, quantity, assigned_at) VALUES (?, ?, ?, ?, ?, ?)",
+		a.LicenseID, nullID(a.AssetID), nullID(a.PersonID), a.Assignee, a.Quantity, time.Now().UTC().Format(timestampLayout))
+}
+
+func (r *sqlLicenseRepository) Unassign(ctx context.Context, licenseID, assignmentID int) error {
+	return checkAffected(r.db.ExecContext(ctx, "DELETE FROM license_assignments WHERE id = ? AND license_id = ?", assignmentID, licenseID))
+}
+
+// sqlSoftwareRepository is the SoftwareRepository backed by the configured
+// SQL database (SQLite by default).
+type sqlSoftwareRepository struct {
+	db *dbConn
+}
+
+func newSQLSoftwareRepository(db *dbConn) *sqlSoftwareRepository {
+	return &sqlSoftwareRepository{db: db}
+}
+
+// softwareColumns is the column list read by querySoftware.
+const softwareColumns = "id, asset_id, product, publisher, version, install_date, source, last_seen"
+
+func (r *sqlSoftwareRepository) List(ctx context.Context, assetID int) ([]InstalledSoftware, error) {
+	return r.querySoftware(ctx, "SELECT "+softwareColumns+" FROM installed_software WHERE asset_id = ? ORDER BY product, version", assetID)
+}
+
+func (r *sqlSoftwareRepository) All(ctx context.Context) ([]InstalledSoftware, error) {
+	return r.querySoftware(ctx, "SELECT "+softwareColumns+" FROM installed_software ORDER BY asset_id, product, version")
+}
+
+// querySoftware runs a SELECT over softwareColumns and scans every row.
+func (r *sqlSoftwareRepository) querySoftware(ctx context.Context, query string, args ...interface{}) ([]InstalledSoftware, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching installed software: %w", err)
+	}
+	defer rows.Close()
+
+	var software []InstalledSoftware
+	for rows.Next() {
+		var sw InstalledSoftware
+		var publisher, version, installDate, lastSeen sql.NullString
+		if err := rows.Scan(&sw.ID, &sw.AssetID, &sw.Product, &publisher, &version, &installDate, &sw.Source, &lastSeen); err != nil {
+			return nil, fmt.Errorf("error scanning installed software: %w", err)
+		}
+		sw.Publisher = publisher.String
+		sw.Version = version.String
+		sw.InstallDate = parseDate(installDate)
+		sw.LastSeen = parseTimestamp(lastSeen.String)
+		software = append(software, sw)
+	}
+	return software, rows.Err()
+}
+
+// insertSoftware is shared by Add and ReplaceSource.
+const insertSoftware = "INSERT INTO installed_software (asset_id, product, publisher, version, install_date, source, last_seen) VALUES (?, ?, ?, ?, ?, ?, ?)"
+
+func (r *sqlSoftwareRepository) Add(ctx context.Context, sw InstalledSoftware) (int, error) {
+	return r.db.InsertContext(ctx, insertSoftware,
+		sw.AssetID, sw.Product, sw.Publisher, sw.Version, nullDate(sw.InstallDate), sw.Source, time.Now().UTC().Format(timestampLayout))
+}
+
+func (r *sqlSoftwareRepository) Remove(ctx context.Context, assetID, id int) error {
+	return checkAffected(r.db.ExecContext(ctx, "DELETE FROM installed_software WHERE id = ? AND asset_id = ?", id, assetID))
+}
+
+func (r *sqlSoftwareRepository) ReplaceSource(ctx context.Context, assetID int, source string, items []InstalledSoftware) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM installed_software WHERE asset_id = ? AND source = ?", assetID, source); err != nil {
+		return err
+	}
+	now := time.Now().UTC().Format(timestampLayout)
+	for _, sw := range items {
+		if _, err := tx.ExecContext(ctx, insertSoftware, assetID, sw.Product, sw.Publisher, sw.Version, nullDate(sw.InstallDate), source, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// sqlRiskRepository is the RiskRepository backed by the configured SQL
+// database (SQLite by default).
+type sqlRiskRepository struct {
+	db *dbConn
+}
+
+func newSQLRiskRepository(db *dbConn) *sqlRiskRepository {
+	return &sqlRiskRepository{db: db}
+}
+
+// riskColumns is the column list understood by scanRisk.
+const riskColumns = "id, title, description, likelihood, impact, owner, status, review_date, created_at, updated_at"
+
+// scanRisk reads a risk row selected with riskColumns.
+func scanRisk(row rowScanner) (Risk, error) {
+	var r Risk
+	var description, owner, reviewDate, createdAt, updatedAt sql.NullString
+	if err := row.Scan(&r.ID, &r.Title, &description, &r.Likelihood, &r.Impact, &owner, &r.Status, &reviewDate, &createdAt, &updatedAt); err != nil {
+		return r, err
+	}
+	r.Description = description.String
+	r.Owner = owner.String
+	r.ReviewDate = parseDate(reviewDate)
+	r.CreatedAt = parseTimestamp(createdAt.String)
+	r.UpdatedAt = parseTimestamp(updatedAt.String)
+	return r, nil
+}
+
+func (r *sqlRiskRepository) List(ctx context.Context) ([]Risk, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+riskColumns+" FROM risks ORDER BY likelihood * impact DESC, review_date IS NULL, review_date, id")
+	if err != nil {
+		return nil, fmt.Errorf("error fetching risks: %w", err)
+	}
+	defer rows.Close()
+
+	var risks []Risk
+	for rows.Next() {
+		risk, err := scanRisk(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning risk: %w", err)
+		}
+		risks = append(risks, risk)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i := range risks {
+		if err := r.loadDetails(ctx, &risks[i]); err != nil {
+			return nil, err
+		}
+	}
+	return risks, nil
+}
+
+func (r *sqlRiskRepository) Get(ctx context.Context, id int) (*Risk, error) {
+	risk, err := scanRisk(r.db.QueryRowContext(ctx, "SELECT "+riskColumns+" FROM risks WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if err := r.loadDetails(ctx, &risk); err != nil {
+		return nil, err
+	}
+	return &risk, nil
+}
+
+// loadDetails fills in the risk's linked assets, licenses and mitigations.
+func (r *sqlRiskRepository) loadDetails(ctx context.Context, risk *Risk) error {
+	var err error
+	if risk.AssetIDs, err = queryIDs(ctx, r.db, "SELECT asset_id FROM risk_assets WHERE risk_id = ? ORDER BY asset_id", risk.ID); err != nil {
+		return fmt.Errorf("error fetching risk assets: %w", err)
+	}
+	if risk.LicenseIDs, err = queryIDs(ctx, r.db, "SELECT license_id FROM risk_licenses WHERE risk_id = ? ORDER BY license_id", risk.ID); err != nil {
+		return fmt.Errorf("error fetching risk licenses: %w", err)
+	}
+
+	rows, err := r.db.QueryContext(ctx, "SELECT id, risk_id, action, due_date, completed_at FROM risk_mitigations WHERE risk_id = ? ORDER BY id", risk.ID)
+	if err != nil {
+		return fmt.Errorf("error fetching mitigations: %w", err)
+	}
+	defer rows.Close()
+	risk.Mitigations = nil
+	for rows.Next() {
+		var m RiskMitigation
+		var dueDate, completedAt sql.NullString
+		if err := rows.Scan(&m.ID, &m.RiskID, &m.Action, &dueDate, &completedAt); err != nil {
+			return fmt.Errorf("error scanning mitigation: %w", err)
+		}
+		m.DueDate = parseDate(dueDate)
+		if completedAt.Valid {
+			m.CompletedAt = parseTimestamp(completedAt.String)
+		}
+		risk.Mitigations = append(risk.Mitigations, m)
+	}
+	return rows.Err()
+}
+
+// queryIDs runs a query selecting a single integer column.
+func queryIDs(ctx context.Context, db *dbConn, query string, args ...interface{}) ([]int, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// replaceLinks replaces the rows of a link table belonging to ownerID, such
+// as the assets linked to a risk, with one row per target ID.
+func replaceLinks(ctx context.Context, tx *dbTx, table, ownerColumn, targetColumn string, ownerID int, targetIDs []int) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE "+ownerColumn+" = ?", ownerID); err != nil {
+		return err
+	}
+	for _, id := range uniqueIDs(targetIDs) {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO "+table+" ("+ownerColumn+", "+targetColumn+") VALUES (?, ?)", ownerID, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveRiskLinks replaces the risk's links to assets and licenses.
+func saveRiskLinks(ctx context.Context, tx *dbTx, id int, risk Risk) error {
+	if err := replaceLinks(ctx, tx, "risk_assets", "risk_id", "asset_id", id, risk.AssetIDs); err != nil {
+		return err
+	}
+	return replaceLinks(ctx, tx, "risk_licenses", "risk_id", "license_id", id, risk.LicenseIDs)
+}
+
+// uniqueIDs drops repeated IDs, keeping the first occurrence.
+func uniqueIDs(ids []int) []int {
+	seen := make(map[int]bool, len(ids))
+	var out []int
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+func (r *sqlRiskRepository) Create(ctx context.Context, risk Risk) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC().Format(timestampLayout)
+	id, err := tx.InsertContext(ctx, "INSERT INTO risks (title, description, likelihood, impact, owner, status, review_date, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		risk.Title, risk.Description, risk.Likelihood, risk.Impact, risk.Owner, risk.Status, nullDate(risk.ReviewDate), now, now)
+	if err != nil {
+		return 0, err
+	}
+	if err := saveRiskLinks(ctx, tx, id, risk); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+func (r *sqlRiskRepository) Update(ctx context.Context, id int, risk Risk) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkAffected(tx.ExecContext(ctx, "UPDATE risks SET title = ?, description = ?, likelihood = ?, impact = ?, owner = ?, review_date = ?, updated_at = ? WHERE id = ?",
+		risk.Title, risk.Description, risk.Likelihood, risk.Impact, risk.Owner, nullDate(risk.ReviewDate), time.Now().UTC().Format(timestampLayout), id)); err != nil {
+		return err
+	}
+	if err := saveRiskLinks(ctx, tx, id, risk); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *sqlRiskRepository) Delete(ctx context.Context, id int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"risk_assets", "risk_licenses", "risk_mitigations"} {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE risk_id = ?", id); err != nil {
+			return err
+		}
+	}
+	if err := checkAffected(tx.ExecContext(ctx, "DELETE FROM risks WHERE id = ?", id)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *sqlRiskRepository) Transition(ctx context.Context, id int, status RiskStatus) error {
+	var current RiskStatus
+	err := r.db.QueryRowContext(ctx, "SELECT status FROM risks WHERE id = ?", id).Scan(&current)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+	if !current.CanMoveTo(status) {
+		return fmt.Errorf("%w: %s to %s", errInvalidTransition, current, status)
+	}
+	// Only move from the status read above, so concurrent changes cannot
+	// skip a step of the workflow.
+	res, err := r.db.ExecContext(ctx, "UPDATE risks SET status = ?, updated_at = ? WHERE id = ? AND status = ?",
+		status, time.Now().UTC().Format(timestampLayout), id, current)
+	if err := checkAffected(res, err); errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("%w: risk %d changed concurrently", errInvalidTransition, id)
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *sqlRiskRepository) AddMitigation(ctx context.Context, m RiskMitigation) (int, error) {
+	var exists int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM risks WHERE id = ?", m.RiskID).Scan(&exists)
+	if err != nil {
+		return 0, err
+	} else if exists == 0 {
+		return 0, ErrNotFound
+	}
+	return r.db.InsertContext(ctx, "INSERT INTO risk_mitigations (risk_id, action, due_date) VALUES (?, ?, ?)",
+		m.RiskID, m.Action, nullDate(m.DueDate))
+}
+
+func (r *sqlRiskRepository) CompleteMitigation(ctx context.Context, riskID, mitigationID int) error {
+	return checkAffected(r.db.ExecContext(ctx, "UPDATE risk_mitigations SET completed_at = ? WHERE id = ? AND risk_id = ? AND completed_at IS NULL",
+		time.Now().UTC().Format(timestampLayout), mitigationID, riskID))
+}
+
+func (r *sqlRiskRepository) CountHigh(ctx context.Context) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM risks WHERE status <> ? AND likelihood * impact >= ?", RiskClosed, highRiskScore).Scan(&n)
+	return n, err
+}
+
+// sqlFOIRepository is the FOIRepository backed by the configured SQL
+// database (SQLite by default).
+type sqlFOIRepository struct {
+	db *dbConn
+}
+
+func newSQLFOIRepository(db *dbConn) *sqlFOIRepository {
+	return &sqlFOIRepository{db: db}
+}
+
+// foiColumns is the column list understood by scanFOIRequest.
+const foiColumns = "id, requester, contact, subject, details, received_date, due_date, assignee, status, created_at, updated_at"
+
+// scanFOIRequest reads a request row selected with foiColumns.
+func scanFOIRequest(row rowScanner) (FOIRequest, error) {
+	var f FOIRequest
+	var contact, details, received, due, assignee, createdAt, updatedAt sql.NullString
+	if err := row.Scan(&f.ID, &f.Requester, &contact, &f.Subject, &details, &received, &due, &assignee, &f.Status, &createdAt, &updatedAt); err != nil {
+		return f, err
+	}
+	f.Contact = contact.String
+	f.Details = details.String
+	f.ReceivedDate = parseDate(received)
+	f.DueDate = parseDate(due)
+	f.Assignee = assignee.String
+	f.CreatedAt = parseTimestamp(createdAt.String)
+	f.UpdatedAt = parseTimestamp(updatedAt.String)
+	return f, nil
+}
+
+// loadLinks fills in the assets, licenses and report runs the request
+// concerns.
+func (r *sqlFOIRepository) loadLinks(ctx context.Context, f *FOIRequest) error {
+	var err error
+	if f.AssetIDs, err = queryIDs(ctx, r.db, "SELECT asset_id FROM foi_request_assets WHERE foi_request_id = ? ORDER BY asset_id", f.ID); err != nil {
+		return fmt.Errorf("error fetching FOI request assets: %w", err)
+	}
+	if f.LicenseIDs, err = queryIDs(ctx, r.db, "SELECT license_id FROM foi_request_licenses WHERE foi_request_id = ? ORDER BY license_id", f.ID); err != nil {
+		return fmt.Errorf("error fetching FOI request licenses: %w", err)
+	}
+	if f.ReportRunIDs, err = queryIDs(ctx, r.db, "SELECT report_run_id FROM foi_request_report_runs WHERE foi_request_id = ? ORDER BY report_run_id", f.ID); err != nil {
+		return fmt.Errorf("error fetching FOI request reports: %w", err)
+	}
+	return nil
+}
+
+// saveFOILinks replaces the request's links to assets, licenses and report
+// runs.
+func saveFOILinks(ctx context.Context, tx *dbTx, id int, f FOIRequest) error {
+	if err := replaceLinks(ctx, tx, "foi_request_assets", "foi_request_id", "asset_id", id, f.AssetIDs); err != nil {
+		return err
+	}
+	if err := replaceLinks(ctx, tx, "foi_request_licenses", "foi_request_id", "license_id", id, f.LicenseIDs); err != nil {
+		return err
+	}
+	return replaceLinks(ctx, tx, "foi_request_report_runs", "foi_request_id", "report_run_id", id, f.ReportRunIDs)
+}
+
+func (r *sqlFOIRepository) List(ctx context.Context) ([]FOIRequest, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+foiColumns+" FROM foi_requests ORDER BY due_date, id")
+	if err != nil {
+		return nil, fmt.Errorf("error fetching FOI requests: %w", err)
+	}
+	defer rows.Close()
+
+	var requests []FOIRequest
+	for rows.Next() {
+		f, err := scanFOIRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning FOI request: %w", err)
+		}
+		requests = append(requests, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i := range requests {
+		if err := r.loadLinks(ctx, &requests[i]); err != nil {
+			return nil, err
+		}
+	}
+	return requests, nil
+}
+
+func (r *sqlFOIRepository) Get(ctx context.Context, id int) (*FOIRequest, error) {
+	f, err := scanFOIRequest(r.db.QueryRowContext(ctx, "SELECT "+foiColumns+" FROM foi_requests WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if err := r.loadLinks(ctx, &f); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func (r *sqlFOIRepository) Create(ctx context.Context, f FOIRequest) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC().Format(timestampLayout)
+	id, err := tx.InsertContext(ctx, "INSERT INTO foi_requests (requester, contact, subject, details, received_date, due_date, assignee, status, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		f.Requester, f.Contact, f.Subject, f.Details, nullDate(f.ReceivedDate), nullDate(f.DueDate), f.Assignee, f.Status, now, now)
+	if err != nil {
+		return 0, err
+	}
+	if err := saveFOILinks(ctx, tx, id, f); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+func (r *sqlFOIRepository) Update(ctx context.Context, id int, f FOIRequest) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkAffected(tx.ExecContext(ctx, "UPDATE foi_requests SET requester = ?, contact = ?, subject = ?, details = ?, received_date = ?, due_date = ?, assignee = ?, updated_at = ? WHERE id = ?",
+		f.Requester, f.Contact, f.Subject, f.Details, nullDate(f.ReceivedDate), nullDate(f.DueDate), f.Assignee, time.Now().UTC().Format(timestampLayout), id)); err != nil {
+		return err
+	}
+	if err := saveFOILinks(ctx, tx, id, f); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *sqlFOIRepository) Delete(ctx context.Context, id int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"foi_request_assets", "foi_request_licenses", "foi_request_report_runs"} {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE foi_request_id = ?", id); err != nil {
+			return err
+		}
+	}
+	if err := checkAffected(tx.ExecContext(ctx, "DELETE FROM foi_requests WHERE id = ?", id)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *sqlFOIRepository) Transition(ctx context.Context, id int, status FOIStatus) error {
+	var current FOIStatus
+	err := r.db.QueryRowContext(ctx, "SELECT status FROM foi_requests WHERE id = ?", id).Scan(&current)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+	if !current.CanMoveTo(status) {
+		return fmt.Errorf("%w: %s to %s", errInvalidTransition, current, status)
+	}
+	res, err := r.db.ExecContext(ctx, "UPDATE foi_requests SET status = ?, updated_at = ? WHERE id = ? AND status = ?",
+		status, time.Now().UTC().Format(timestampLayout), id, current)
+	if err := checkAffected(res, err); errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("%w: FOI request %d changed concurrently", errInvalidTransition, id)
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *sqlFOIRepository) CountOverdue(ctx context.Context) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM foi_requests WHERE status IN (?, ?, ?) AND due_date < "+r.db.dialect.Today(),
+		FOIReceived, FOIInProgress, FOIOnHold).Scan(&n)
+	return n, err
+}
+
+// sqlReportRepository is the ReportRepository backed by the configured SQL
+// database (SQLite by default). Parameters and results are stored as JSON.
+type sqlReportRepository struct {
+	db *dbConn
+}
+
+func newSQLReportRepository(db *dbConn) *sqlReportRepository {
+	return &sqlReportRepository{db: db}
+}
+
+// savedReportColumns is the column list understood by scanSavedReport.
+const savedReportColumns = "id, name, report_key, params, created_by, created_at"
+
+// scanSavedReport reads a saved report row selected with savedReportColumns.
+func scanSavedReport(row rowScanner) (SavedReport, error) {
+	var sr SavedReport
+	var params, createdBy, createdAt sql.NullString
+	if err := row.Scan(&sr.ID, &sr.Name, &sr.ReportKey, &params, &createdBy, &createdAt); err != nil {
+		return sr, err
+	}
+	if err := unmarshalParams(params, &sr.Params); err != nil {
+		return sr, err
+	}
+	sr.CreatedBy = createdBy.String
+	sr.CreatedAt = parseTimestamp(createdAt.String)
+	return sr, nil
+}
+
+// unmarshalParams decodes a JSON params column, treating NULL as empty.
+func unmarshalParams(s sql.NullString, params *ReportParams) error {
+	*params = ReportParams{}
+	if !s.Valid || s.String == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(s.String), params); err != nil {
+		return fmt.Errorf("error decoding report parameters: %w", err)
+	}
+	return nil
+}
+
+func (r *sqlReportRepository) ListSaved(ctx context.Context) ([]SavedReport, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+savedReportColumns+" FROM saved_reports ORDER BY name, id")
+	if err != nil {
+		return nil, fmt.Errorf("error fetching saved reports: %w", err)
+	}
+	defer rows.Close()
+
+	var saved []SavedReport
+	for rows.Next() {
+		sr, err := scanSavedReport(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning saved report: %w", err)
+		}
+		saved = append(saved, sr)
+	}
+	return saved, rows.Err()
+}
+
+func (r *sqlReportRepository) GetSaved(ctx context.Context, id int) (*SavedReport, error) {
+	sr, err := scanSavedReport(r.db.QueryRowContext(ctx, "SELECT "+savedReportColumns+" FROM saved_reports WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &sr, nil
+}
+
+func (r *sqlReportRepository) CreateSaved(ctx context.Context, sr SavedReport) (int, error) {
+	params, err := json.Marshal(sr.Params)
+	if err != nil {
+		return 0, err
+	}
+	return r.db.InsertContext(ctx, "INSERT INTO saved_reports (name, report_key, params, created_by, created_at) VALUES (?, ?, ?, ?, ?)",
+		sr.Name, sr.ReportKey, string(params), sr.CreatedBy, time.Now().UTC().Format(timestampLayout))
+}
+
+func (r *sqlReportRepository) DeleteSaved(ctx context.Context, id int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE report_runs SET saved_report_id = NULL WHERE saved_report_id = ?", id); err != nil {
+		return err
+	}
+	if err := checkAffected(tx.ExecContext(ctx, "DELETE FROM saved_reports WHERE id = ?", id)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// reportRunColumns is the column list understood by scanReportRun; the
+// result column is fetched separately by GetRun.
+const reportRunColumns = "id, saved_report_id, name, report_key, params, run_by, started_at, finished_at, error, row_count"
+
+// scanReportRun reads a run row selected with reportRunColumns.
+func scanReportRun(row rowScanner, extra ...interface{}) (ReportRun, error) {
+	var run ReportRun
+	var savedID sql.NullInt64
+	var params, runBy, startedAt, finishedAt, runErr sql.NullString
+	dest := append([]interface{}{&run.ID, &savedID, &run.Name, &run.ReportKey, &params, &runBy, &startedAt, &finishedAt, &runErr, &run.RowCount}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return run, err
+	}
+	if err := unmarshalParams(params, &run.Params); err != nil {
+		return run, err
+	}
+	run.SavedReportID = int(savedID.Int64)
+	run.RunBy = runBy.String
+	run.StartedAt = parseTimestamp(startedAt.String)
+	run.FinishedAt = parseTimestamp(finishedAt.String)
+	run.Error = runErr.String
+	return run, nil
+}
+
+func (r *sqlReportRepository) ListRuns(ctx context.Context, limit int) ([]ReportRun, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+reportRunColumns+" FROM report_runs ORDER BY started_at DESC, id DESC LIMIT ?", limit)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching report runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []ReportRun
+	for rows.Next() {
+		run, err := scanReportRun(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning report run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+func (r *sqlReportRepository) GetRun(ctx context.Context, id int) (*ReportRun, error) {
+	var result sql.NullString
+	run, err := scanReportRun(r.db.QueryRowContext(ctx, "SELECT "+reportRunColumns+", result FROM report_runs WHERE id = ?", id), &result)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if result.Valid && result.String != "" {
+		run.Result = &ReportResult{}
+		if err := json.Unmarshal([]byte(result.String), run.Result); err != nil {
+			return nil, fmt.Errorf("error decoding report result: %w", err)
+		}
+	}
+	return &run, nil
+}
+
+func (r *sqlReportRepository) CreateRun(ctx context.Context, run ReportRun) (int, error) {
+	params, err := json.Marshal(run.Params)
+	if err != nil {
+		return 0, err
+	}
+	var result sql.NullString
+	if run.Result != nil {
+		b, err := json.Marshal(run.Result)
+		if err != nil {
+			return 0, err
+		}
+		result = sql.NullString{String: string(b), Valid: true}
+	}
+	var savedID sql.NullInt64
+	if run.SavedReportID != 0 {
+		savedID = sql.NullInt64{Int64: int64(run.SavedReportID), Valid: true}
+	}
+	return r.db.InsertContext(ctx, "INSERT INTO report_runs (saved_report_id, name, report_key, params, run_by, started_at, finished_at, error, row_count, result) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		savedID, run.Name, run.ReportKey, string(params), run.RunBy,
+		run.StartedAt.Format(timestampLayout), run.FinishedAt.Format(timestampLayout), run.Error, run.RowCount, result)
+}
+
+// sqlNotificationRepository is the NotificationRepository backed by the
+// configured SQL database.
+type sqlNotificationRepository struct {
+	db *dbConn
+}
+
+func newSQLNotificationRepository(db *dbConn) *sqlNotificationRepository {
+	return &sqlNotificationRepository{db: db}
+}
+
+const notificationColumns = "id, license_id, license_name, expiry_date, stage, recipient, sent_at"
+
+func (r *sqlNotificationRepository) queryNotifications(ctx context.Context, query string, args ...interface{}) ([]ExpiryNotification, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching notifications: %w", err)
+	}
+	defer rows.Close()
+
+	var notices []ExpiryNotification
+	for rows.Next() {
+		var n ExpiryNotification
+		var licenseID sql.NullInt64
+		var expiry, sentAt sql.NullString
+		if err := rows.Scan(&n.ID, &licenseID, &n.LicenseName, &expiry, &n.Stage, &n.Recipient, &sentAt); err != nil {
+			return nil, fmt.Errorf("error scanning notification: %w", err)
+		}
+		n.LicenseID = int(licenseID.Int64)
+		n.ExpiryDate = parseDate(expiry)
+		n.SentAt = parseTimestamp(sentAt.String)
+		notices = append(notices, n)
+	}
+	return notices, rows.Err()
+}
+
+func (r *sqlNotificationRepository) ForLicense(ctx context.Context, licenseID int, expiry time.Time) ([]ExpiryNotification, error) {
+	return r.queryNotifications(ctx, "SELECT "+notificationColumns+" FROM license_notifications WHERE license_id = ? AND expiry_date = ? ORDER BY id",
+		licenseID, expiry.Format(dateLayout))
+}
+
+func (r *sqlNotificationRepository) Record(ctx context.Context, notices []ExpiryNotification) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, n := range notices {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO license_notifications (license_id, license_name, expiry_date, stage, recipient, sent_at) VALUES (?, ?, ?, ?, ?, ?)",
+			n.LicenseID, n.LicenseName, n.ExpiryDate.Format(dateLayout), n.Stage, n.Recipient, n.SentAt.UTC().Format(timestampLayout)); err != nil {
+			return fmt.Errorf("error recording notification: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *sqlNotificationRepository) Recent(ctx context.Context, limit int) ([]ExpiryNotification, error) {
+	return r.queryNotifications(ctx, "SELECT "+notificationColumns+" FROM license_notifications ORDER BY sent_at DESC, id DESC LIMIT ?", limit)
+}
+
+// sqlWebhookRepository is the WebhookRepository backed by the configured
+// SQL database.
+type sqlWebhookRepository struct {
+	db *dbConn
+}
+
+func newSQLWebhookRepository(db *dbConn) *sqlWebhookRepository {
+	return &sqlWebhookRepository{db: db}
+}
+
+const webhookColumns = "id, url, secret, events, created_at"
+
+// scanWebhook reads a webhook row selected with webhookColumns. Events are
+// stored as a comma separated list.
+func scanWebhook(row rowScanner) (Webhook, error) {
+	var h Webhook
+	var events, createdAt string
+	if err := row.Scan(&h.ID, &h.URL, &h.Secret, &events, &createdAt); err != nil {
+		return h, err
+	}
+	if events != "" {
+		h.Events = strings.Split(events, ",")
+	}
+	h.CreatedAt = parseTimestamp(createdAt)
+	return h, nil
+}
+
+func (r *sqlWebhookRepository) List(ctx context.Context) ([]Webhook, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+webhookColumns+" FROM webhooks ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("error fetching webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	var hooks []Webhook
+	for rows.Next() {
+		h, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning webhook: %w", err)
+		}
+		hooks = append(hooks, h)
+	}
+	return hooks, rows.Err()
+}
+
+func (r *sqlWebhookRepository) Get(ctx context.Context, id int) (*Webhook, error) {
+	h, err := scanWebhook(r.db.QueryRowContext(ctx, "SELECT "+webhookColumns+" FROM webhooks WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+func (r *sqlWebhookRepository) Create(ctx context.Context, h Webhook) (int, error) {
+	return r.db.InsertContext(ctx, "INSERT INTO webhooks (url, secret, events, created_at) VALUES (?, ?, ?, ?)",
+		h.URL, h.Secret, strings.Join(h.Events, ","), time.Now().UTC().Format(timestampLayout))
+}
+
+func (r *sqlWebhookRepository) Delete(ctx context.Context, id int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM webhook_deliveries WHERE webhook_id = ?", id); err != nil {
+		return err
+	}
+	if err := checkAffected(tx.ExecContext(ctx, "DELETE FROM webhooks WHERE id = ?", id)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *sqlWebhookRepository) HasDelivery(ctx context.Context, webhookID int, key string) (bool, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM webhook_deliveries WHERE webhook_id = ? AND event_key = ?", webhookID, key).Scan(&n)
+	return n > 0, err
+}
+
+func (r *sqlWebhookRepository) CreateDelivery(ctx context.Context, d WebhookDelivery) (int, error) {
+	var key sql.NullString
+	if d.EventKey != "" {
+		key = sql.NullString{String: d.EventKey, Valid: true}
+	}
+	return r.db.InsertContext(ctx, "INSERT INTO webhook_deliveries (webhook_id, event, event_key, payload, status, attempts, next_attempt_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		d.WebhookID, d.Event, key, d.Payload, d.Status, d.Attempts, nullTimestamp(d.NextAttemptAt), d.CreatedAt.UTC().Format(timestampLayout))
+}
+
+// webhookDeliveryColumns is the column list understood by
+// scanWebhookDelivery; it joins the webhook for its URL.
+const webhookDeliveryColumns = "d.id, d.webhook_id, h.url, d.event, d.event_key, d.payload, d.status, d.attempts, d.next_attempt_at, d.response_status, d.error, d.created_at, d.delivered_at"
+
+const webhookDeliveryFrom = " FROM webhook_deliveries d JOIN webhooks h ON h.id = d.webhook_id"
+
+func scanWebhookDelivery(row rowScanner) (WebhookDelivery, error) {
+	var d WebhookDelivery
+	var key, nextAttempt, deliveryErr, createdAt, deliveredAt sql.NullString
+	if err := row.Scan(&d.ID, &d.WebhookID, &d.WebhookURL, &d.Event, &key, &d.Payload, &d.Status, &d.Attempts,
+		&nextAttempt, &d.ResponseStatus, &deliveryErr, &createdAt, &deliveredAt); err != nil {
+		return d, err
+	}
+	d.EventKey = key.String
+	d.NextAttemptAt = parseTimestamp(nextAttempt.String)
+	d.Error = deliveryErr.String
+	d.CreatedAt = parseTimestamp(createdAt.String)
+	d.DeliveredAt = parseTimestamp(deliveredAt.String)
+	return d, nil
+}
+
+func (r *sqlWebhookRepository) queryDeliveries(ctx context.Context, query string, args ...interface{}) ([]WebhookDelivery, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+webhookDeliveryColumns+webhookDeliveryFrom+query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []WebhookDelivery
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (r *sqlWebhookRepository) GetDelivery(ctx context.Context, id int) (*WebhookDelivery, error) {
+	d, err := scanWebhookDelivery(r.db.QueryRowContext(ctx, "SELECT "+webhookDeliveryColumns+webhookDeliveryFrom+" WHERE d.id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *sqlWebhookRepository) UpdateDelivery(ctx context.Context, d WebhookDelivery) error {
+	return checkAffected(r.db.ExecContext(ctx, "UPDATE webhook_deliveries SET status = ?, attempts = ?, next_attempt_at = ?, response_status = ?, error = ?, delivered_at = ? WHERE id = ?",
+		d.Status, d.Attempts, nullTimestamp(d.NextAttemptAt), d.ResponseStatus, d.Error, nullTimestamp(d.DeliveredAt), d.ID))
+}
+
+func (r *sqlWebhookRepository) DueDeliveries(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error) {
+	return r.queryDeliveries(ctx, " WHERE d.status = ? AND d.next_attempt_at <= ? ORDER BY d.next_attempt_at, d.id LIMIT ?",
+		DeliveryPending, now.UTC().Format(timestampLayout), limit)
+}
+
+func (r *sqlWebhookRepository) Deliveries(ctx context.Context, webhookID, limit int) ([]WebhookDelivery, error) {
+	if webhookID != 0 {
+		return r.queryDeliveries(ctx, " WHERE d.webhook_id = ? ORDER BY d.created_at DESC, d.id DESC LIMIT ?", webhookID, limit)
+	}
+	return r.queryDeliveries(ctx, " ORDER BY d.created_at DESC, d.id DESC LIMIT ?", limit)
+}
+
+// sqlAuditRepository is the AuditRepository backed by the configured SQL
+// database.
+type sqlAuditRepository struct {
+	db *dbConn
+	// mu serialises appends from this process. The unique prev_hash column
+	// rejects an append racing from another process, which is retried
+	// unless the append is part of a larger transaction; that fails as a
+	// whole instead.
+	mu sync.Mutex
+}
+
+func newSQLAuditRepository(db *dbConn) *sqlAuditRepository {
+	return &sqlAuditRepository{db: db}
+}
+
+// auditAppendAttempts is how many times an append is tried when another
+// writer extends the chain first.
+const auditAppendAttempts = 3
+
+const auditColumns = "id, occurred_at, actor, entity, entity_id, action, before_data, after_data, prev_hash, hash"
+
+// scanAuditEntry reads a row selected with auditColumns.
+func scanAuditEntry(row rowScanner) (AuditEntry, error) {
+	var e AuditEntry
+	var occurredAt string
+	var before, after sql.NullString
+	if err := row.Scan(&e.ID, &occurredAt, &e.Actor, &e.Entity, &e.EntityID, &e.Action, &before, &after, &e.PrevHash, &e.Hash); err != nil {
+		return e, err
+	}
+	e.OccurredAt = parseTimestamp(occurredAt)
+	e.Before, e.After = before.String, after.String
+	return e, nil
+}
+
+// nullText stores an empty string as NULL.
+func nullText(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// nullID stores a zero id, meaning no record, as NULL.
+func nullID(id int) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+func (r *sqlAuditRepository) Append(ctx context.Context, e *AuditEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	attempts := auditAppendAttempts
+	if txFrom(ctx) != nil {
+		attempts = 1
+	}
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if err = r.append(ctx, e); err == nil || ctx.Err() != nil {
+			break
+		}
+	}
+	return err
+}
+
+func (r *sqlAuditRepository) append(ctx context.Context, e *AuditEntry) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var prev string
+	err = tx.QueryRowContext(ctx, "SELECT hash FROM audit_log ORDER BY id DESC LIMIT 1").Scan(&prev)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("error fetching latest audit entry: %w", err)
+	}
+	e.PrevHash = prev
+	e.Hash = e.computeHash()
+	id, err := tx.InsertContext(ctx, "INSERT INTO audit_log (occurred_at, actor, entity, entity_id, action, before_data, after_data, prev_hash, hash) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		e.OccurredAt.UTC().Format(timestampLayout), e.Actor, e.Entity, e.EntityID, e.Action, nullText(e.Before), nullText(e.After), e.PrevHash, e.Hash)
+	if err != nil {
+		return fmt.Errorf("error inserting audit entry: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	e.ID = id
+	return nil
+}
+
+// likeEscaper escapes LIKE wildcards with '!', which every supported
+// database accepts as an ESCAPE character.
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+func (r *sqlAuditRepository) Search(ctx context.Context, f AuditFilter) ([]AuditEntry, error) {
+	var where []string
+	var args []interface{}
+	add := func(cond string, values ...interface{}) {
+		where = append(where, cond)
+		args = append(args, values...)
+	}
+	if f.Entity != "" {
+		add("entity = ?", f.Entity)
+	}
+	if f.EntityID != 0 {
+		add("entity_id = ?", f.EntityID)
+	}
+	if f.Actor != "" {
+		add("LOWER(actor) = ?", strings.ToLower(f.Actor))
+	}
+	if f.Action != "" {
+		add("action = ?", f.Action)
+	}
+	if f.Query != "" {
+		pattern := "%" + likeEscaper.Replace(strings.ToLower(f.Query)) + "%"
+		add("(LOWER(before_data) LIKE ? ESCAPE '!' OR LOWER(after_data) LIKE ? ESCAPE '!')", pattern, pattern)
+	}
+	if !f.From.IsZero() {
+		add("occurred_at >= ?", f.From.Format(timestampLayout))
+	}
+	if !f.To.IsZero() {
+		add("occurred_at < ?", f.To.AddDate(0, 0, 1).Format(timestampLayout))
+	}
+
+	query := "SELECT " + auditColumns + " FROM audit_log"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY id DESC LIMIT ? OFFSET ?"
+	args = append(args, f.Limit, f.Offset)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error searching audit log: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		e, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning audit entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (r *sqlAuditRepository) Walk(ctx context.Context, fn func(AuditEntry) error) error {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+auditColumns+" FROM audit_log ORDER BY id")
+	if err != nil {
+		return fmt.Errorf("error reading audit log: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanAuditEntry(rows)
+		if err != nil {
+			return fmt.Errorf("error scanning audit entry: %w", err)
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// sqlPeopleRepository is the PeopleRepository backed by the configured SQL
+// database.
+type sqlPeopleRepository struct {
+	db *dbConn
+}
+
+func newSQLPeopleRepository(db *dbConn) *sqlPeopleRepository {
+	return &sqlPeopleRepository{db: db}
+}
+
+func (r *sqlPeopleRepository) Departments(ctx context.Context) ([]Department, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT d.id, d.name, COUNT(p.id)
+		FROM departments d LEFT JOIN people p ON p.department_id = d.id
+		GROUP BY d.id, d.name ORDER BY d.name`)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching departments: %w", err)
+	}
+	defer rows.Close()
+
+	var departments []Department
+	for rows.Next() {
+		var d Department
+		if err := rows.Scan(&d.ID, &d.Name, &d.Members); err != nil {
+			return nil, fmt.Errorf("error scanning department: %w", err)
+		}
+		departments = append(departments, d)
+	}
+	return departments, rows.Err()
+}
+
+func (r *sqlPeopleRepository) CreateDepartment(ctx context.Context, name string) (int, error) {
+	return r.db.InsertContext(ctx, "INSERT INTO departments (name) VALUES (?)", name)
+}
+
+func (r *sqlPeopleRepository) DeleteDepartment(ctx context.Context, id int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE people SET department_id = NULL WHERE department_id = ?", id); err != nil {
+		return err
+	}
+	if err := checkAffected(tx.ExecContext(ctx, "DELETE FROM departments WHERE id = ?", id)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// personColumns is the column list understood by scanPerson; it joins the
+// person's department for its name.
+const personColumns = "p.id, p.name, p.email, p.department_id, d.name, p.username"
+
+const personFrom = " FROM people p LEFT JOIN departments d ON d.id = p.department_id"
+
+func scanPerson(row rowScanner) (Person, error) {
+	var p Person
+	var email, department, username sql.NullString
+	var departmentID sql.NullInt64
+	if err := row.Scan(&p.ID, &p.Name, &email, &departmentID, &department, &username); err != nil {
+		return p, err
+	}
+	p.Email = email.String
+	p.DepartmentID = int(departmentID.Int64)
+	p.Department = department.String
+	p.Username = username.String
+	return p, nil
+}
+
+func (r *sqlPeopleRepository) List(ctx context.Context) ([]Person, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+personColumns+personFrom+" ORDER BY p.name, p.id")
+	if err != nil {
+		return nil, fmt.Errorf("error fetching people: %w", err)
+	}
+	defer rows.Close()
+
+	var people []Person
+	for rows.Next() {
+		p, err := scanPerson(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning person: %w", err)
+		}
+		people = append(people, p)
+	}
+	return people, rows.Err()
+}
+
+func (r *sqlPeopleRepository) Get(ctx context.Context, id int) (*Person, error) {
+	return r.getPerson(ctx, "p.id = ?", id)
+}
+
+func (r *sqlPeopleRepository) ByUsername(ctx context.Context, username string) (*Person, error) {
+	return r.getPerson(ctx, "p.username = ?", username)
+}
+
+func (r *sqlPeopleRepository) getPerson(ctx context.Context, where string, arg interface{}) (*Person, error) {
+	p, err := scanPerson(r.db.QueryRowContext(ctx, "SELECT "+personColumns+personFrom+" WHERE "+where, arg))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// personArgs returns the columns saved for a person. A missing department
+// and an unlinked username are stored as NULL; the unique index on
+// username ignores NULLs.
+func personArgs(p Person) []interface{} {
+	return []interface{}{p.Name, nullText(p.Email), nullID(p.DepartmentID), nullText(p.Username)}
+}
+
+func (r *sqlPeopleRepository) Create(ctx context.Context, p Person) (int, error) {
+	return r.db.InsertContext(ctx, "INSERT INTO people (name, email, department_id, username) VALUES (?, ?, ?, ?)", personArgs(p)...)
+}
+
+func (r *sqlPeopleRepository) Update(ctx context.Context, id int, p Person) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkAffected(tx.ExecContext(ctx, "UPDATE people SET name = ?, email = ?, department_id = ?, username = ? WHERE id = ?",
+		append(personArgs(p), id)...)); err != nil {
+		return err
+	}
+	// Open checkouts show the holder's current name.
+	if _, err := tx.ExecContext(ctx, "UPDATE asset_custody SET person_name = ? WHERE person_id = ? AND checked_in_at IS NULL", p.Name, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *sqlPeopleRepository) Delete(ctx context.Context, id int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var held int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM asset_custody WHERE person_id = ? AND checked_in_at IS NULL", id).Scan(&held); err != nil {
+		return err
+	}
+	if held > 0 {
+		return fmt.Errorf("%w: person %d still holds %d asset(s)", errCustody, id, held)
+	}
+	// The custody history and seat assignments outlive the person,
+	// keeping their name.
+	if _, err := tx.ExecContext(ctx, "UPDATE asset_custody SET person_id = NULL WHERE person_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE license_assignments SET assignee = (SELECT name FROM people WHERE people.id = license_assignments.person_id)
+		WHERE person_id = ? AND (assignee IS NULL OR assignee = '')`, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE license_assignments SET person_id = NULL WHERE person_id = ?", id); err != nil {
+		return err
+	}
+	if err := checkAffected(tx.ExecContext(ctx, "DELETE FROM people WHERE id = ?", id)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CheckOut locks the asset's row before looking for an open checkout, so
+// that concurrent checkouts of one asset wait for each other and only the
+// first succeeds.
+func (r *sqlPeopleRepository) CheckOut(ctx context.Context, c Checkout) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err := checkAffected(tx.ExecContext(ctx, "UPDATE assets SET id = id WHERE id = ? AND deleted_at IS NULL", c.AssetID)); err != nil {
+		return 0, err
+	}
+	var holder string
+	err = tx.QueryRowContext(ctx, "SELECT person_name FROM asset_custody WHERE asset_id = ? AND checked_in_at IS NULL", c.AssetID).Scan(&holder)
+	if err == nil {
+		return 0, fmt.Errorf("%w: asset %d is already checked out to %s", errCustody, c.AssetID, holder)
+	} else if err != sql.ErrNoRows {
+		return 0, err
+	}
+	var personID interface{}
+	if c.PersonID != 0 {
+		personID = c.PersonID
+	}
+	id, err := tx.InsertContext(ctx, "INSERT INTO asset_custody (asset_id, person_id, person_name, checked_out_at, checked_out_by, expected_return, notes) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		c.AssetID, personID, c.PersonName, c.CheckedOutAt.UTC().Format(timestampLayout), nullText(c.CheckedOutBy), nullDate(c.ExpectedReturn), nullText(c.Notes))
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// checkoutColumns is the column list understood by scanCheckout; it joins
+// the asset for its name.
+const checkoutColumns = "c.id, c.asset_id, a.name, c.person_id, c.person_name, c.checked_out_at, c.checked_out_by, c.expected_return, c.notes, " +
+	"c.checked_in_at, c.checked_in_by, c.return_notes"
+
+const checkoutFrom = " FROM asset_custody c LEFT JOIN assets a ON a.id = c.asset_id"
+
+func scanCheckout(row rowScanner) (Checkout, error) {
+	var c Checkout
+	var personID sql.NullInt64
+	var assetName, outAt, outBy, expected, notes, inAt, inBy, returnNotes sql.NullString
+	if err := row.Scan(&c.ID, &c.AssetID, &assetName, &personID, &c.PersonName, &outAt, &outBy, &expected, &notes,
+		&inAt, &inBy, &returnNotes); err != nil {
+		return c, err
+	}
+	c.AssetName = assetName.String
+	c.PersonID = int(personID.Int64)
+	c.CheckedOutAt = parseTimestamp(outAt.String)
+	c.CheckedOutBy = outBy.String
+	c.ExpectedReturn = parseDate(expected)
+	c.Notes = notes.String
+	c.CheckedInAt = parseTimestamp(inAt.String)
+	c.CheckedInBy = inBy.String
+	c.ReturnNotes = returnNotes.String
+	return c, nil
+}
+
+func (r *sqlPeopleRepository) queryCheckouts(ctx context.Context, query string, args ...interface{}) ([]Checkout, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching checkouts: %w", err)
+	}
+	defer rows.Close()
+
+	var checkouts []Checkout
+	for rows.Next() {
+		c, err := scanCheckout(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning checkout: %w", err)
+		}
+		checkouts = append(checkouts, c)
+	}
+	return checkouts, rows.Err()
+}
+
+func (r *sqlPeopleRepository) CheckIn(ctx context.Context, assetID int, by, notes string) (*Checkout, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	c, err := scanCheckout(tx.QueryRowContext(ctx, "SELECT "+checkoutColumns+checkoutFrom+" WHERE c.asset_id = ? AND c.checked_in_at IS NULL", assetID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: asset %d is not checked out", errCustody, assetID)
+	} else if err != nil {
+		return nil, err
+	}
+	c.CheckedInAt, c.CheckedInBy, c.ReturnNotes = time.Now().UTC().Truncate(time.Second), by, notes
+	res, err := tx.ExecContext(ctx, "UPDATE asset_custody SET checked_in_at = ?, checked_in_by = ?, return_notes = ? WHERE id = ? AND checked_in_at IS NULL",
+		c.CheckedInAt.Format(timestampLayout), nullText(by), nullText(notes), c.ID)
+	if err := checkAffected(res, err); errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("%w: asset %d was checked in concurrently", errCustody, assetID)
+	} else if err != nil {
+		return nil, err
+	}
+	return &c, tx.Commit()
+}
+
+func (r *sqlPeopleRepository) Custody(ctx context.Context, assetID int) ([]Checkout, error) {
+	return r.queryCheckouts(ctx, "SELECT "+checkoutColumns+checkoutFrom+" WHERE c.asset_id = ? ORDER BY c.id DESC", assetID)
+}
+
+func (r *sqlPeopleRepository) Held(ctx context.Context, personID int) ([]Checkout, error) {
+	query := "SELECT " + checkoutColumns + checkoutFrom + " WHERE c.checked_in_at IS NULL"
+	var args []interface{}
+	if personID != 0 {
+		query += " AND c.person_id = ?"
+		args = append(args, personID)
+	}
+	return r.queryCheckouts(ctx, query+" ORDER BY c.expected_return IS NULL, c.expected_return, c.id", args...)
+}
+
+// sqlLocationRepository is the LocationRepository backed by the configured
+// SQL database (SQLite by default).
+type sqlLocationRepository struct {
+	db *dbConn
+}
+
+func newSQLLocationRepository(db *dbConn) *sqlLocationRepository {
+	return &sqlLocationRepository{db: db}
+}
+
+// queryLocations returns every location in tree order with the number of
+// assets kept at each.
+func queryLocations(ctx context.Context, q rowsQuerier) ([]Location, error) {
+	rows, err := q.QueryContext(ctx, `SELECT l.id, l.parent_id, l.kind, l.name, COUNT(a.id)
+		FROM locations l LEFT JOIN assets a ON a.location_id = l.id AND a.deleted_at IS NULL
+		GROUP BY l.id, l.parent_id, l.kind, l.name`)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching locations: %w", err)
+	}
+	defer rows.Close()
+
+	var locations []Location
+	for rows.Next() {
+		var l Location
+		var parentID sql.NullInt64
+		if err := rows.Scan(&l.ID, &parentID, &l.Kind, &l.Name, &l.Assets); err != nil {
+			return nil, fmt.Errorf("error scanning location: %w", err)
+		}
+		l.ParentID = int(parentID.Int64)
+		locations = append(locations, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return buildLocationTree(locations), nil
+}
+
+func (r *sqlLocationRepository) List(ctx context.Context) ([]Location, error) {
+	return queryLocations(ctx, r.db)
+}
+
+func (r *sqlLocationRepository) Get(ctx context.Context, id int) (*Location, error) {
+	locations, err := queryLocations(ctx, r.db)
+	if err != nil {
+		return nil, err
+	}
+	for i := range locations {
+		if locations[i].ID == id {
+			return &locations[i], nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *sqlLocationRepository) Create(ctx context.Context, l Location) (int, error) {
+	return r.db.InsertContext(ctx, "INSERT INTO locations (parent_id, kind, name) VALUES (?, ?, ?)", nullID(l.ParentID), l.Kind, l.Name)
+}
+
+func (r *sqlLocationRepository) Update(ctx context.Context, id int, l Location) error {
+	return checkAffected(r.db.ExecContext(ctx, "UPDATE locations SET parent_id = ?, kind = ?, name = ? WHERE id = ?", nullID(l.ParentID), l.Kind, l.Name, id))
+}
+
+func (r *sqlLocationRepository) Delete(ctx context.Context, id int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var children, assets int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM locations WHERE parent_id = ?", id).Scan(&children); err != nil {
+		return err
+	}
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM assets WHERE location_id = ? AND deleted_at IS NULL", id).Scan(&assets); err != nil {
+		return err
+	}
+	if children > 0 || assets > 0 {
+		return fmt.Errorf("%w: %d locations and %d assets are inside location %d", errLocationInUse, children, assets, id)
+	}
+	// Deleted assets may still refer to the location, and moves keep its
+	// path.
+	if _, err := tx.ExecContext(ctx, "UPDATE assets SET location_id = NULL WHERE location_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE asset_moves SET from_location_id = NULL WHERE from_location_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE asset_moves SET to_location_id = NULL WHERE to_location_id = ?", id); err != nil {
+		return err
+	}
+	if err := checkAffected(tx.ExecContext(ctx, "DELETE FROM locations WHERE id = ?", id)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// sqlAssetTypeRepository is the AssetTypeRepository backed by the
+// configured SQL database (SQLite by default).
+type sqlAssetTypeRepository struct {
+	db *dbConn
+}
+
+func newSQLAssetTypeRepository(db *dbConn) *sqlAssetTypeRepository {
+	return &sqlAssetTypeRepository{db: db}
+}
+
+// assetFieldOptionsSeparator joins the options of an enum field in the
+// options column.
+const assetFieldOptionsSeparator = "\n"
+
+func (r *sqlAssetTypeRepository) List(ctx context.Context) ([]AssetType, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT t.id, t.name, COUNT(a.id)
+		FROM asset_types t LEFT JOIN assets a ON a.type_id = t.id AND a.deleted_at IS NULL
+		GROUP BY t.id, t.name ORDER BY t.name`)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching asset types: %w", err)
+	}
+	defer rows.Close()
+
+	var types []AssetType
+	index := make(map[int]int)
+	for rows.Next() {
+		var t AssetType
+		if err := rows.Scan(&t.ID, &t.Name, &t.Assets); err != nil {
+			return nil, fmt.Errorf("error scanning asset type: %w", err)
+		}
+		index[t.ID] = len(types)
+		types = append(types, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	fields, err := r.db.QueryContext(ctx, "SELECT id, type_id, name, label, kind, options, required FROM asset_type_fields ORDER BY type_id, position, id")
+	if err != nil {
+		return nil, fmt.Errorf("error fetching asset fields: %w", err)
+	}
+	defer fields.Close()
+	for fields.Next() {
+		var f AssetField
+		var options sql.NullString
+		var required int
+		if err := fields.Scan(&f.ID, &f.TypeID, &f.Name, &f.Label, &f.Kind, &options, &required); err != nil {
+			return nil, fmt.Errorf("error scanning asset field: %w", err)
+		}
+		if options.String != "" {
+			f.Options = strings.Split(options.String, assetFieldOptionsSeparator)
+		}
+		f.Required = required != 0
+		if i, ok := index[f.TypeID]; ok {
+			types[i].Fields = append(types[i].Fields, f)
+		}
+	}
+	return types, fields.Err()
+}
+
+func (r *sqlAssetTypeRepository) Get(ctx context.Context, id int) (*AssetType, error) {
+	types, err := r.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range types {
+		if types[i].ID == id {
+			return &types[i], nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// assetFieldArgs returns the columns UpdateField saves.
+func assetFieldArgs(f AssetField) []interface{} {
+	return []interface{}{f.Name, f.Label, nullText(strings.Join(f.Options, assetFieldOptionsSeparator)), boolInt(f.Required)}
+}
+
+// boolInt stores a flag in an INTEGER column.
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (r *sqlAssetTypeRepository) Create(ctx context.Context, t AssetType) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	id, err := tx.InsertContext(ctx, "INSERT INTO asset_types (name) VALUES (?)", t.Name)
+	if isUniqueViolation(err) {
+		return 0, fmt.Errorf("%w: %s already exists", errInvalidAssetType, t.Name)
+	} else if err != nil {
+		return 0, err
+	}
+	for i, f := range t.Fields {
+		args := append([]interface{}{id, f.Kind, i + 1}, assetFieldArgs(f)...)
+		if _, err := tx.ExecContext(ctx, "INSERT INTO asset_type_fields (type_id, kind, position, name, label, options, required) VALUES (?, ?, ?, ?, ?, ?, ?)", args...); err != nil {
+			return 0, fmt.Errorf("error inserting field %q: %w", f.Name, err)
+		}
+	}
+	return id, tx.Commit()
+}
+
+func (r *sqlAssetTypeRepository) Update(ctx context.Context, id int, t AssetType) error {
+	err := checkAffected(r.db.ExecContext(ctx, "UPDATE asset_types SET name = ? WHERE id = ?", t.Name, id))
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%w: %s already exists", errInvalidAssetType, t.Name)
+	}
+	return err
+}
+
+func (r *sqlAssetTypeRepository) Delete(ctx context.Context, id int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var assets int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM assets WHERE type_id = ? AND deleted_at IS NULL", id).Scan(&assets); err != nil {
+		return err
+	}
+	if assets > 0 {
+		return fmt.Errorf("%w: %d assets are of asset type %d", errAssetTypeInUse, assets, id)
+	}
+	// Deleted assets may still be of the type.
+	if _, err := tx.ExecContext(ctx, "UPDATE assets SET type_id = NULL WHERE type_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM asset_field_values WHERE field_id IN (SELECT id FROM asset_type_fields WHERE type_id = ?)", id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM asset_type_fields WHERE type_id = ?", id); err != nil {
+		return err
+	}
+	if err := checkAffected(tx.ExecContext(ctx, "DELETE FROM asset_types WHERE id = ?", id)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *sqlAssetTypeRepository) CreateField(ctx context.Context, f AssetField) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var position int
+	if err := tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(position), 0) FROM asset_type_fields WHERE type_id = ?", f.TypeID).Scan(&position); err != nil {
+		return 0, err
+	}
+	args := append([]interface{}{f.TypeID, f.Kind, position + 1}, assetFieldArgs(f)...)
+	id, err := tx.InsertContext(ctx, "INSERT INTO asset_type_fields (type_id, kind, position, name, label, options, required) VALUES (?, ?, ?, ?, ?, ?, ?)", args...)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+func (r *sqlAssetTypeRepository) UpdateField(ctx context.Context, id int, f AssetField) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	args := append(assetFieldArgs(f), id, f.TypeID)
+	if err := checkAffected(tx.ExecContext(ctx, "UPDATE asset_type_fields SET name = ?, label = ?, options = ?, required = ? WHERE id = ? AND type_id = ?", args...)); err != nil {
+		return err
+	}
+	rows, err := tx.QueryContext(ctx, `SELECT COALESCE(v.value, '')
+		FROM assets a LEFT JOIN asset_field_values v ON v.asset_id = a.id AND v.field_id = ?
+		WHERE a.type_id = ? AND a.deleted_at IS NULL`, id, f.TypeID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var values []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return err
+		}
+		values = append(values, v)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+	respelled, err := f.revalidate(values)
+	if err != nil {
+		return err
+	}
+	for from, to := range respelled {
+		if _, err := tx.ExecContext(ctx, "UPDATE asset_field_values SET value = ? WHERE field_id = ? AND value = ?", to, id, from); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *sqlAssetTypeRepository) DeleteField(ctx context.Context, typeID, id int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM asset_field_values WHERE field_id = ?", id); err != nil {
+		return err
+	}
+	if err := checkAffected(tx.ExecContext(ctx, "DELETE FROM asset_type_fields WHERE id = ? AND type_id = ?", id, typeID)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// sqlUserRepository is the UserRepository backed by the configured SQL
+// database (SQLite by default).
+type sqlUserRepository struct {
+	db *dbConn
+}
+
+func newSQLUserRepository(db *dbConn) *sqlUserRepository {
+	return &sqlUserRepository{db: db}
+}
+
+func (r *sqlUserRepository) List(ctx context.Context) ([]User, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT id, username, role FROM users ORDER BY username")
+	if err != nil {
+		return nil, fmt.Errorf("error fetching users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Username, &u.Role); err != nil {
+			return nil, fmt.Errorf("error scanning user: %w", err)
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (r *sqlUserRepository) Get(ctx context.Context, id int) (*User, error) {
+	u := User{ID: id}
+	err := r.db.QueryRowContext(ctx, "SELECT username, role FROM users WHERE id = ?", id).Scan(&u.Username, &u.Role)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (r *sqlUserRepository) Credentials(ctx context.Context, username string) (*User, string, error) {
+	var u User
+	var hash string
+	err := r.db.QueryRowContext(ctx, "SELECT id, username, role, password_hash FROM users WHERE username = ?", username).Scan(&u.ID, &u.Username, &u.Role, &hash)
+	if err == sql.ErrNoRows {
+		return nil, "", ErrNotFound
+	} else if err != nil {
+		return nil, "", err
+	}
+	return &u, hash, nil
+}
+
+func (r *sqlUserRepository) Create(ctx context.Context, username, passwordHash string, role Role) (int, error) {
+	var existing int
+	err := r.db.QueryRowContext(ctx, "SELECT id FROM users WHERE username = ?", username).Scan(&existing)
+	if err == nil {
+		return 0, errUsernameTaken
+	} else if err != sql.ErrNoRows {
+		return 0, err
+	}
+	return r.db.InsertContext(ctx, "INSERT INTO users (username, password_hash, role) VALUES (?, ?, ?)", username, passwordHash, role)
+}
+
+func (r *sqlUserRepository) SetRole(ctx context.Context, id int, role Role) error {
+	return checkAffected(r.db.ExecContext(ctx, "UPDATE users SET role = ? WHERE id = ?", role, id))
+}
+
+func (r *sqlUserRepository) Count(ctx context.Context) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&count)
+	return count, err
+}
+
+func (r *sqlUserRepository) CreateSession(ctx context.Context, tokenHash string, userID int, expires time.Time) error {
+	now := time.Now().UTC().Format(timestampLayout)
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM sessions WHERE expires_at <= ?", now); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, "INSERT INTO sessions (token_hash, user_id, expires_at) VALUES (?, ?, ?)", tokenHash, userID, expires.UTC().Format(timestampLayout))
+	return err
+}
+
+func (r *sqlUserRepository) SessionUser(ctx context.Context, tokenHash string) (*User, error) {
+	var u User
+	err := r.db.QueryRowContext(ctx, `
+		SELECT u.id, u.username, u.role FROM sessions s JOIN users u ON u.id = s.user_id
+		WHERE s.token_hash = ? AND s.expires_at > ?`, tokenHash, time.Now().UTC().Format(timestampLayout)).Scan(&u.ID, &u.Username, &u.Role)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (r *sqlUserRepository) DeleteSession(ctx context.Context, tokenHash string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM sessions WHERE token_hash = ? OR expires_at <= ?", tokenHash, time.Now().UTC().Format(timestampLayout))
+	return err
+}
@@ -0,0 +1,1786 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	_ Transactor = memTransactor{}
+
+	_ AssetRepository    = (*memAssetRepository)(nil)
+	_ LicenseRepository  = (*memLicenseRepository)(nil)
+	_ SoftwareRepository = (*memSoftwareRepository)(nil)
+	_ RiskRepository     = (*memRiskRepository)(nil)
+	_ FOIRepository      = (*memFOIRepository)(nil)
+	_ ReportRepository   = (*memReportRepository)(nil)
+
+	_ NotificationRepository = (*memNotificationRepository)(nil)
+	_ WebhookRepository      = (*memWebhookRepository)(nil)
+	_ AuditRepository        = (*memAuditRepository)(nil)
+	_ PeopleRepository       = (*memPeopleRepository)(nil)
+	_ LocationRepository     = (*memLocationRepository)(nil)
+	_ AssetTypeRepository    = (*memAssetTypeRepository)(nil)
+	_ UserRepository         = (*memUserRepository)(nil)
+)
+
+// memTransactor is the Transactor for the in-memory repositories, which
+// have no transactions: fn simply runs, and what it changed before failing
+// stays changed.
+type memTransactor struct{}
+
+func (memTransactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// memAssetRepository is an in-memory AssetRepository for tests and demos.
+type memAssetRepository struct {
+	mu      sync.Mutex
+	nextID  int
+	assets  map[int]Asset
+	changes []AssetStateChange
+	moves   []AssetMove
+}
+
+func newMemAssetRepository(seed ...Asset) *memAssetRepository {
+	r := &memAssetRepository{assets: make(map[int]Asset)}
+	for _, a := range seed {
+		r.Create(context.Background(), a)
+	}
+	return r
+}
+
+func (r *memAssetRepository) List(ctx context.Context) ([]Asset, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	assets := make([]Asset, 0, len(r.assets))
+	for _, a := range r.assets {
+		assets = append(assets, a)
+	}
+	sort.Slice(assets, func(i, j int) bool { return assets[i].ID < assets[j].ID })
+	return assets, nil
+}
+
+func (r *memAssetRepository) ListOfType(ctx context.Context, typeID int, conditions []fieldCondition) ([]Asset, error) {
+	all, err := r.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var assets []Asset
+	for _, a := range all {
+		ok := a.TypeID == typeID
+		for _, c := range conditions {
+			ok = ok && c.match(a)
+		}
+		if ok {
+			assets = append(assets, a)
+		}
+	}
+	return assets, nil
+}
+
+func (r *memAssetRepository) Get(ctx context.Context, id int) (*Asset, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	a, ok := r.assets[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &a, nil
+}
+
+// checkIdentifiers returns an error wrapping errDuplicateAsset if another
+// asset than id has a's serial number or tag. The caller holds r.mu.
+func (r *memAssetRepository) checkIdentifiers(a Asset, id int) error {
+	for _, other := range r.assets {
+		if other.ID == id {
+			continue
+		}
+		if a.SerialNumber != "" && other.SerialNumber == a.SerialNumber {
+			return fmt.Errorf("%w: serial number %q is already used by asset %d", errDuplicateAsset, a.SerialNumber, other.ID)
+		}
+		if a.AssetTag != "" && other.AssetTag == a.AssetTag {
+			return fmt.Errorf("%w: asset tag %q is already used by asset %d", errDuplicateAsset, a.AssetTag, other.ID)
+		}
+	}
+	return nil
+}
+
+func (r *memAssetRepository) Create(ctx context.Context, a Asset) (int, error) {
+	ids, err := r.CreateMany(ctx, []Asset{a})
+	if err != nil {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+func (r *memAssetRepository) CreateMany(ctx context.Context, assets []Asset) ([]int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make([]int, len(assets))
+	for i, a := range assets {
+		if err := r.checkIdentifiers(a, 0); err != nil {
+			// Leave none of the batch stored.
+			for _, id := range ids[:i] {
+				delete(r.assets, id)
+			}
+			return nil, err
+		}
+		r.nextID++
+		a.ID = r.nextID
+		r.assets[a.ID] = a
+		ids[i] = a.ID
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	for _, id := range ids {
+		a := r.assets[id]
+		r.recordChange(AssetStateChange{AssetID: id, To: a.State, ChangedAt: now})
+		if a.LocationID != 0 {
+			r.recordMove(ctx, Asset{}, a)
+		}
+	}
+	return ids, nil
+}
+
+// recordMove appends a move from the location of one version of an asset
+// to that of another. The caller holds r.mu.
+func (r *memAssetRepository) recordMove(ctx context.Context, from, to Asset) {
+	r.moves = append(r.moves, AssetMove{ID: len(r.moves) + 1, AssetID: to.ID, FromID: from.LocationID, From: from.Location,
+		ToID: to.LocationID, To: to.Location, MovedAt: time.Now().UTC().Truncate(time.Second), MovedBy: auditActor(ctx)})
+}
+
+// recordChange appends a state change. The caller holds r.mu.
+func (r *memAssetRepository) recordChange(c AssetStateChange) AssetStateChange {
+	c.ID = len(r.changes) + 1
+	r.changes = append(r.changes, c)
+	return c
+}
+
+func (r *memAssetRepository) Update(ctx context.Context, id int, a Asset) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.assets[id]; !ok {
+		return ErrNotFound
+	}
+	if err := r.checkIdentifiers(a, id); err != nil {
+		return err
+	}
+	a.ID = id
+	a.State = r.assets[id].State
+	if a.LocationID != r.assets[id].LocationID {
+		r.recordMove(ctx, r.assets[id], a)
+	}
+	r.assets[id] = a
+	return nil
+}
+
+func (r *memAssetRepository) Delete(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.assets[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.assets, id)
+	return nil
+}
+
+func (r *memAssetRepository) Count(ctx context.Context) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.assets), nil
+}
+
+func (r *memAssetRepository) Transition(ctx context.Context, id int, state AssetState, reason string) (*AssetStateChange, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	a, ok := r.assets[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if !a.State.CanMoveTo(state) {
+		return nil, fmt.Errorf("%w: %s to %s", errInvalidTransition, a.State, state)
+	}
+	change := r.recordChange(AssetStateChange{AssetID: id, From: a.State, To: state, Reason: reason, ChangedAt: time.Now().UTC().Truncate(time.Second)})
+	a.State = state
+	r.assets[id] = a
+	return &change, nil
+}
+
+func (r *memAssetRepository) StateChanges(ctx context.Context, id int) ([]AssetStateChange, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var changes []AssetStateChange
+	for _, c := range r.changes {
+		if c.AssetID == id {
+			changes = append(changes, c)
+		}
+	}
+	return changes, nil
+}
+
+func (r *memAssetRepository) Moves(ctx context.Context, id int) ([]AssetMove, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var moves []AssetMove
+	for i := len(r.moves) - 1; i >= 0; i-- {
+		if r.moves[i].AssetID == id {
+			moves = append(moves, r.moves[i])
+		}
+	}
+	return moves, nil
+}
+
+// memLicenseRepository is an in-memory LicenseRepository for tests and demos.
+type memLicenseRepository struct {
+	mu          sync.Mutex
+	nextID      int
+	licenses    map[int]License
+	assignments []LicenseAssignment
+	// now returns the current time; tests may override it.
+	now func() time.Time
+}
+
+func newMemLicenseRepository(seed ...License) *memLicenseRepository {
+	r := &memLicenseRepository{licenses: make(map[int]License), now: time.Now}
+	for _, l := range seed {
+		r.Create(context.Background(), l)
+	}
+	return r
+}
+
+// sorted returns the licenses matching keep in the same order as the SQL
+// repository: by expiry date with undated licenses last, then by name.
+func (r *memLicenseRepository) sorted(keep func(License) bool) []License {
+	licenses := make([]License, 0, len(r.licenses))
+	for _, l := range r.licenses {
+		if keep(l) {
+			licenses = append(licenses, r.withConsumed(l))
+		}
+	}
+	sort.Slice(licenses, func(i, j int) bool {
+		a, b := licenses[i], licenses[j]
+		if a.ExpiryDate.IsZero() != b.ExpiryDate.IsZero() {
+			return b.ExpiryDate.IsZero()
+		}
+		if !a.ExpiryDate.Equal(b.ExpiryDate) {
+			return a.ExpiryDate.Before(b.ExpiryDate)
+		}
+		return a.Name < b.Name
+	})
+	return licenses
+}
+
+func (r *memLicenseRepository) List(ctx context.Context) ([]License, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sorted(func(License) bool { return true }), nil
+}
+
+func (r *memLicenseRepository) ExpiringWithin(ctx context.Context, days int) ([]License, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	y, m, d := r.now().Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	until := today.AddDate(0, 0, days)
+	return r.sorted(func(l License) bool {
+		return !l.ExpiryDate.IsZero() && !l.ExpiryDate.Before(today) && !l.ExpiryDate.After(until)
+	}), nil
+}
+
+func (r *memLicenseRepository) Get(ctx context.Context, id int) (*License, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	l, ok := r.licenses[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	l = r.withConsumed(l)
+	return &l, nil
+}
+
+func (r *memLicenseRepository) Create(ctx context.Context, l License) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	l.ID = r.nextID
+	r.licenses[l.ID] = l
+	return l.ID, nil
+}
+
+func (r *memLicenseRepository) CreateMany(ctx context.Context, licenses []License) ([]int, error) {
+	ids := make([]int, len(licenses))
+	for i, l := range licenses {
+		id, err := r.Create(ctx, l)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+func (r *memLicenseRepository) Update(ctx context.Context, id int, l License) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.licenses[id]; !ok {
+		return ErrNotFound
+	}
+	l.ID = id
+	r.licenses[id] = l
+	return nil
+}
+
+func (r *memLicenseRepository) Delete(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.licenses[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.licenses, id)
+	kept := r.assignments[:0]
+	for _, a := range r.assignments {
+		if a.LicenseID != id {
+			kept = append(kept, a)
+		}
+	}
+	r.assignments = kept
+	return nil
+}
+
+func (r *memLicenseRepository) Count(ctx context.Context) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.licenses), nil
+}
+
+// withConsumed fills in the seats consumed by the license's assignments.
+func (r *memLicenseRepository) withConsumed(l License) License {
+	l.Consumed = 0
+	for _, a := range r.assignments {
+		if a.LicenseID == l.ID {
+			l.Consumed += a.Quantity
+		}
+	}
+	return l
+}
+
+func (r *memLicenseRepository) Assignments(ctx context.Context, licenseID int) ([]LicenseAssignment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var assignments []LicenseAssignment
+	for _, a := range r.assignments {
+		if a.LicenseID == licenseID {
+			assignments = append(assignments, a)
+		}
+	}
+	return assignments, nil
+}
+
+func (r *memLicenseRepository) AssetAssignments(ctx context.Context, assetID int) ([]LicenseAssignment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var assignments []LicenseAssignment
+	for _, a := range r.assignments {
+		if a.AssetID == assetID {
+			assignments = append(assignments, a)
+		}
+	}
+	return assignments, nil
+}
+
+func (r *memLicenseRepository) PersonAssignments(ctx context.Context, personID int) ([]LicenseAssignment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var assignments []LicenseAssignment
+	for _, a := range r.assignments {
+		if a.PersonID == personID {
+			assignments = append(assignments, a)
+		}
+	}
+	return assignments, nil
+}
+
+func (r *memLicenseRepository) Assign(ctx context.Context, a LicenseAssignment) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.licenses[a.LicenseID]; !ok {
+		return 0, ErrNotFound
+	}
+	r.nextID++
+	a.ID = r.nextID
+	a.AssignedAt = r.now().UTC()
+	r.assignments = append(r.assignments, a)
+	return a.ID, nil
+}
+
+func (r *memLicenseRepository) Unassign(ctx context.Context, licenseID, assignmentID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, a := range r.assignments {
+		if a.ID == assignmentID && a.LicenseID == licenseID {
+			r.assignments = append(r.assignments[:i], r.assignments[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// memSoftwareRepository is an in-memory SoftwareRepository for tests and demos.
+type memSoftwareRepository struct {
+	mu       sync.Mutex
+	nextID   int
+	software []InstalledSoftware
+}
+
+func newMemSoftwareRepository() *memSoftwareRepository {
+	return &memSoftwareRepository{}
+}
+
+func (r *memSoftwareRepository) List(ctx context.Context, assetID int) ([]InstalledSoftware, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var software []InstalledSoftware
+	for _, sw := range r.software {
+		if sw.AssetID == assetID {
+			software = append(software, sw)
+		}
+	}
+	sort.Slice(software, func(i, j int) bool {
+		if software[i].Product != software[j].Product {
+			return software[i].Product < software[j].Product
+		}
+		return software[i].Version < software[j].Version
+	})
+	return software, nil
+}
+
+func (r *memSoftwareRepository) All(ctx context.Context) ([]InstalledSoftware, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	software := append([]InstalledSoftware(nil), r.software...)
+	sort.SliceStable(software, func(i, j int) bool { return software[i].AssetID < software[j].AssetID })
+	return software, nil
+}
+
+func (r *memSoftwareRepository) Add(ctx context.Context, sw InstalledSoftware) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	sw.ID = r.nextID
+	sw.LastSeen = time.Now().UTC()
+	r.software = append(r.software, sw)
+	return sw.ID, nil
+}
+
+func (r *memSoftwareRepository) Remove(ctx context.Context, assetID, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, sw := range r.software {
+		if sw.ID == id && sw.AssetID == assetID {
+			r.software = append(r.software[:i], r.software[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *memSoftwareRepository) ReplaceSource(ctx context.Context, assetID int, source string, items []InstalledSoftware) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kept := r.software[:0]
+	for _, sw := range r.software {
+		if sw.AssetID != assetID || sw.Source != source {
+			kept = append(kept, sw)
+		}
+	}
+	r.software = kept
+	now := time.Now().UTC()
+	for _, sw := range items {
+		r.nextID++
+		sw.ID = r.nextID
+		sw.AssetID = assetID
+		sw.Source = source
+		sw.LastSeen = now
+		r.software = append(r.software, sw)
+	}
+	return nil
+}
+
+// memRiskRepository is an in-memory RiskRepository for tests and demos.
+type memRiskRepository struct {
+	mu     sync.Mutex
+	nextID int
+	risks  map[int]Risk
+}
+
+func newMemRiskRepository() *memRiskRepository {
+	return &memRiskRepository{risks: make(map[int]Risk)}
+}
+
+// clone copies a risk so callers cannot modify the stored slices.
+func (r *memRiskRepository) clone(risk Risk) Risk {
+	risk.AssetIDs = append([]int(nil), risk.AssetIDs...)
+	risk.LicenseIDs = append([]int(nil), risk.LicenseIDs...)
+	risk.Mitigations = append([]RiskMitigation(nil), risk.Mitigations...)
+	return risk
+}
+
+func (r *memRiskRepository) List(ctx context.Context) ([]Risk, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	risks := make([]Risk, 0, len(r.risks))
+	for _, risk := range r.risks {
+		risks = append(risks, r.clone(risk))
+	}
+	sort.Slice(risks, func(i, j int) bool {
+		if risks[i].Score() != risks[j].Score() {
+			return risks[i].Score() > risks[j].Score()
+		}
+		return risks[i].ID < risks[j].ID
+	})
+	return risks, nil
+}
+
+func (r *memRiskRepository) Get(ctx context.Context, id int) (*Risk, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	risk, ok := r.risks[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	risk = r.clone(risk)
+	return &risk, nil
+}
+
+func (r *memRiskRepository) Create(ctx context.Context, risk Risk) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	risk.ID = r.nextID
+	risk.AssetIDs = uniqueIDs(risk.AssetIDs)
+	risk.LicenseIDs = uniqueIDs(risk.LicenseIDs)
+	risk.Mitigations = nil
+	risk.CreatedAt = time.Now().UTC()
+	risk.UpdatedAt = risk.CreatedAt
+	r.risks[risk.ID] = risk
+	return risk.ID, nil
+}
+
+func (r *memRiskRepository) Update(ctx context.Context, id int, risk Risk) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	existing, ok := r.risks[id]
+	if !ok {
+		return ErrNotFound
+	}
+	existing.Title = risk.Title
+	existing.Description = risk.Description
+	existing.Likelihood = risk.Likelihood
+	existing.Impact = risk.Impact
+	existing.Owner = risk.Owner
+	existing.ReviewDate = risk.ReviewDate
+	existing.AssetIDs = uniqueIDs(risk.AssetIDs)
+	existing.LicenseIDs = uniqueIDs(risk.LicenseIDs)
+	existing.UpdatedAt = time.Now().UTC()
+	r.risks[id] = existing
+	return nil
+}
+
+func (r *memRiskRepository) Delete(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.risks[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.risks, id)
+	return nil
+}
+
+func (r *memRiskRepository) Transition(ctx context.Context, id int, status RiskStatus) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	risk, ok := r.risks[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if !risk.Status.CanMoveTo(status) {
+		return fmt.Errorf("%w: %s to %s", errInvalidTransition, risk.Status, status)
+	}
+	risk.Status = status
+	risk.UpdatedAt = time.Now().UTC()
+	r.risks[id] = risk
+	return nil
+}
+
+func (r *memRiskRepository) AddMitigation(ctx context.Context, m RiskMitigation) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	risk, ok := r.risks[m.RiskID]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	r.nextID++
+	m.ID = r.nextID
+	m.CompletedAt = time.Time{}
+	risk.Mitigations = append(risk.Mitigations, m)
+	r.risks[m.RiskID] = risk
+	return m.ID, nil
+}
+
+func (r *memRiskRepository) CompleteMitigation(ctx context.Context, riskID, mitigationID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	risk, ok := r.risks[riskID]
+	if !ok {
+		return ErrNotFound
+	}
+	for i, m := range risk.Mitigations {
+		if m.ID == mitigationID && !m.Done() {
+			risk.Mitigations[i].CompletedAt = time.Now().UTC()
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *memRiskRepository) CountHigh(ctx context.Context) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, risk := range r.risks {
+		if risk.High() {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// memFOIRepository is an in-memory FOIRepository for tests and demos.
+type memFOIRepository struct {
+	mu       sync.Mutex
+	nextID   int
+	requests map[int]FOIRequest
+}
+
+func newMemFOIRepository() *memFOIRepository {
+	return &memFOIRepository{requests: make(map[int]FOIRequest)}
+}
+
+func (r *memFOIRepository) clone(f FOIRequest) FOIRequest {
+	f.AssetIDs = append([]int(nil), f.AssetIDs...)
+	f.LicenseIDs = append([]int(nil), f.LicenseIDs...)
+	f.ReportRunIDs = append([]int(nil), f.ReportRunIDs...)
+	return f
+}
+
+func (r *memFOIRepository) List(ctx context.Context) ([]FOIRequest, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	requests := make([]FOIRequest, 0, len(r.requests))
+	for _, f := range r.requests {
+		requests = append(requests, r.clone(f))
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		if !requests[i].DueDate.Equal(requests[j].DueDate) {
+			return requests[i].DueDate.Before(requests[j].DueDate)
+		}
+		return requests[i].ID < requests[j].ID
+	})
+	return requests, nil
+}
+
+func (r *memFOIRepository) Get(ctx context.Context, id int) (*FOIRequest, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, ok := r.requests[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	f = r.clone(f)
+	return &f, nil
+}
+
+func (r *memFOIRepository) Create(ctx context.Context, f FOIRequest) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	f.ID = r.nextID
+	f.AssetIDs = uniqueIDs(f.AssetIDs)
+	f.LicenseIDs = uniqueIDs(f.LicenseIDs)
+	f.ReportRunIDs = uniqueIDs(f.ReportRunIDs)
+	f.CreatedAt = time.Now().UTC()
+	f.UpdatedAt = f.CreatedAt
+	r.requests[f.ID] = f
+	return f.ID, nil
+}
+
+func (r *memFOIRepository) Update(ctx context.Context, id int, f FOIRequest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	existing, ok := r.requests[id]
+	if !ok {
+		return ErrNotFound
+	}
+	f.ID = id
+	f.Status = existing.Status
+	f.AssetIDs = uniqueIDs(f.AssetIDs)
+	f.LicenseIDs = uniqueIDs(f.LicenseIDs)
+	f.ReportRunIDs = uniqueIDs(f.ReportRunIDs)
+	f.CreatedAt = existing.CreatedAt
+	f.UpdatedAt = time.Now().UTC()
+	r.requests[id] = f
+	return nil
+}
+
+func (r *memFOIRepository) Delete(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.requests[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.requests, id)
+	return nil
+}
+
+func (r *memFOIRepository) Transition(ctx context.Context, id int, status FOIStatus) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, ok := r.requests[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if !f.Status.CanMoveTo(status) {
+		return fmt.Errorf("%w: %s to %s", errInvalidTransition, f.Status, status)
+	}
+	f.Status = status
+	f.UpdatedAt = time.Now().UTC()
+	r.requests[id] = f
+	return nil
+}
+
+func (r *memFOIRepository) CountOverdue(ctx context.Context) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, f := range r.requests {
+		if f.Overdue() {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// memReportRepository is an in-memory ReportRepository for tests and demos.
+type memReportRepository struct {
+	mu        sync.Mutex
+	nextSaved int
+	nextRun   int
+	saved     map[int]SavedReport
+	runs      map[int]ReportRun
+}
+
+func newMemReportRepository() *memReportRepository {
+	return &memReportRepository{saved: make(map[int]SavedReport), runs: make(map[int]ReportRun)}
+}
+
+func cloneParams(p ReportParams) ReportParams {
+	out := make(ReportParams, len(p))
+	for k, v := range p {
+		out[k] = v
+	}
+	return out
+}
+
+func (r *memReportRepository) ListSaved(ctx context.Context) ([]SavedReport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	saved := make([]SavedReport, 0, len(r.saved))
+	for _, sr := range r.saved {
+		sr.Params = cloneParams(sr.Params)
+		saved = append(saved, sr)
+	}
+	sort.Slice(saved, func(i, j int) bool {
+		if saved[i].Name != saved[j].Name {
+			return saved[i].Name < saved[j].Name
+		}
+		return saved[i].ID < saved[j].ID
+	})
+	return saved, nil
+}
+
+func (r *memReportRepository) GetSaved(ctx context.Context, id int) (*SavedReport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sr, ok := r.saved[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	sr.Params = cloneParams(sr.Params)
+	return &sr, nil
+}
+
+func (r *memReportRepository) CreateSaved(ctx context.Context, sr SavedReport) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextSaved++
+	sr.ID = r.nextSaved
+	sr.Params = cloneParams(sr.Params)
+	sr.CreatedAt = time.Now().UTC()
+	r.saved[sr.ID] = sr
+	return sr.ID, nil
+}
+
+func (r *memReportRepository) DeleteSaved(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.saved[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.saved, id)
+	for runID, run := range r.runs {
+		if run.SavedReportID == id {
+			run.SavedReportID = 0
+			r.runs[runID] = run
+		}
+	}
+	return nil
+}
+
+func (r *memReportRepository) ListRuns(ctx context.Context, limit int) ([]ReportRun, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	runs := make([]ReportRun, 0, len(r.runs))
+	for _, run := range r.runs {
+		run.Params = cloneParams(run.Params)
+		run.Result = nil
+		runs = append(runs, run)
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		if !runs[i].StartedAt.Equal(runs[j].StartedAt) {
+			return runs[i].StartedAt.After(runs[j].StartedAt)
+		}
+		return runs[i].ID > runs[j].ID
+	})
+	if len(runs) > limit {
+		runs = runs[:limit]
+	}
+	return runs, nil
+}
+
+func (r *memReportRepository) GetRun(ctx context.Context, id int) (*ReportRun, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	run, ok := r.runs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	run.Params = cloneParams(run.Params)
+	return &run, nil
+}
+
+// CreateRun stores the run. Results are never modified once recorded, so
+// the result is shared rather than copied.
+func (r *memReportRepository) CreateRun(ctx context.Context, run ReportRun) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextRun++
+	run.ID = r.nextRun
+	run.Params = cloneParams(run.Params)
+	r.runs[run.ID] = run
+	return run.ID, nil
+}
+
+// memNotificationRepository is an in-memory NotificationRepository for
+// tests and demos.
+type memNotificationRepository struct {
+	mu      sync.Mutex
+	notices []ExpiryNotification
+}
+
+func newMemNotificationRepository() *memNotificationRepository {
+	return &memNotificationRepository{}
+}
+
+func (r *memNotificationRepository) ForLicense(ctx context.Context, licenseID int, expiry time.Time) ([]ExpiryNotification, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var notices []ExpiryNotification
+	for _, n := range r.notices {
+		if n.LicenseID == licenseID && n.ExpiryDate.Equal(expiry) {
+			notices = append(notices, n)
+		}
+	}
+	return notices, nil
+}
+
+func (r *memNotificationRepository) Record(ctx context.Context, notices []ExpiryNotification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, n := range notices {
+		n.ID = len(r.notices) + 1
+		r.notices = append(r.notices, n)
+	}
+	return nil
+}
+
+func (r *memNotificationRepository) Recent(ctx context.Context, limit int) ([]ExpiryNotification, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var notices []ExpiryNotification
+	for i := len(r.notices) - 1; i >= 0 && len(notices) < limit; i-- {
+		notices = append(notices, r.notices[i])
+	}
+	return notices, nil
+}
+
+// memWebhookRepository is an in-memory WebhookRepository for tests and
+// demos.
+type memWebhookRepository struct {
+	mu           sync.Mutex
+	nextID       int
+	nextDelivery int
+	hooks        map[int]Webhook
+	deliveries   map[int]WebhookDelivery
+}
+
+func newMemWebhookRepository() *memWebhookRepository {
+	return &memWebhookRepository{hooks: make(map[int]Webhook), deliveries: make(map[int]WebhookDelivery)}
+}
+
+func (r *memWebhookRepository) List(ctx context.Context) ([]Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hooks := make([]Webhook, 0, len(r.hooks))
+	for _, h := range r.hooks {
+		h.Events = append([]string(nil), h.Events...)
+		hooks = append(hooks, h)
+	}
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].ID < hooks[j].ID })
+	return hooks, nil
+}
+
+func (r *memWebhookRepository) Get(ctx context.Context, id int) (*Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h, ok := r.hooks[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	h.Events = append([]string(nil), h.Events...)
+	return &h, nil
+}
+
+func (r *memWebhookRepository) Create(ctx context.Context, h Webhook) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	h.ID = r.nextID
+	h.Events = append([]string(nil), h.Events...)
+	h.CreatedAt = time.Now().UTC()
+	r.hooks[h.ID] = h
+	return h.ID, nil
+}
+
+func (r *memWebhookRepository) Delete(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.hooks[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.hooks, id)
+	for deliveryID, d := range r.deliveries {
+		if d.WebhookID == id {
+			delete(r.deliveries, deliveryID)
+		}
+	}
+	return nil
+}
+
+func (r *memWebhookRepository) HasDelivery(ctx context.Context, webhookID int, key string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, d := range r.deliveries {
+		if d.WebhookID == webhookID && d.EventKey == key {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memWebhookRepository) CreateDelivery(ctx context.Context, d WebhookDelivery) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h, ok := r.hooks[d.WebhookID]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	r.nextDelivery++
+	d.ID = r.nextDelivery
+	d.WebhookURL = h.URL
+	r.deliveries[d.ID] = d
+	return d.ID, nil
+}
+
+func (r *memWebhookRepository) GetDelivery(ctx context.Context, id int) (*WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d, ok := r.deliveries[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &d, nil
+}
+
+func (r *memWebhookRepository) UpdateDelivery(ctx context.Context, d WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	existing, ok := r.deliveries[d.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	existing.Status = d.Status
+	existing.Attempts = d.Attempts
+	existing.NextAttemptAt = d.NextAttemptAt
+	existing.ResponseStatus = d.ResponseStatus
+	existing.Error = d.Error
+	existing.DeliveredAt = d.DeliveredAt
+	r.deliveries[d.ID] = existing
+	return nil
+}
+
+func (r *memWebhookRepository) DueDeliveries(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var due []WebhookDelivery
+	for _, d := range r.deliveries {
+		if d.Status == DeliveryPending && !d.NextAttemptAt.After(now) {
+			due = append(due, d)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].NextAttemptAt.Equal(due[j].NextAttemptAt) {
+			return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+		}
+		return due[i].ID < due[j].ID
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+func (r *memWebhookRepository) Deliveries(ctx context.Context, webhookID, limit int) ([]WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var deliveries []WebhookDelivery
+	for _, d := range r.deliveries {
+		if webhookID == 0 || d.WebhookID == webhookID {
+			deliveries = append(deliveries, d)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID > deliveries[j].ID })
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+// memAuditRepository is an in-memory AuditRepository for tests and demos.
+type memAuditRepository struct {
+	mu      sync.Mutex
+	entries []AuditEntry
+}
+
+func newMemAuditRepository() *memAuditRepository {
+	return &memAuditRepository{}
+}
+
+func (r *memAuditRepository) Append(ctx context.Context, e *AuditEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e.PrevHash = ""
+	if n := len(r.entries); n > 0 {
+		e.PrevHash = r.entries[n-1].Hash
+	}
+	e.Hash = e.computeHash()
+	e.ID = len(r.entries) + 1
+	r.entries = append(r.entries, *e)
+	return nil
+}
+
+func (r *memAuditRepository) Search(ctx context.Context, f AuditFilter) ([]AuditEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	query := strings.ToLower(f.Query)
+	var matched []AuditEntry
+	for i := len(r.entries) - 1; i >= 0; i-- {
+		e := r.entries[i]
+		switch {
+		case f.Entity != "" && e.Entity != f.Entity,
+			f.EntityID != 0 && e.EntityID != f.EntityID,
+			f.Actor != "" && !strings.EqualFold(e.Actor, f.Actor),
+			f.Action != "" && e.Action != f.Action,
+			query != "" && !strings.Contains(strings.ToLower(e.Before), query) && !strings.Contains(strings.ToLower(e.After), query),
+			!f.From.IsZero() && e.OccurredAt.Before(f.From),
+			!f.To.IsZero() && !e.OccurredAt.Before(f.To.AddDate(0, 0, 1)):
+			continue
+		}
+		matched = append(matched, e)
+	}
+	if f.Offset >= len(matched) {
+		return nil, nil
+	}
+	matched = matched[f.Offset:]
+	if f.Limit < len(matched) {
+		matched = matched[:f.Limit]
+	}
+	return matched, nil
+}
+
+func (r *memAuditRepository) Walk(ctx context.Context, fn func(AuditEntry) error) error {
+	r.mu.Lock()
+	entries := append([]AuditEntry(nil), r.entries...)
+	r.mu.Unlock()
+	for _, e := range entries {
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// memPeopleRepository is an in-memory PeopleRepository for tests and demos.
+type memPeopleRepository struct {
+	mu          sync.Mutex
+	nextID      int
+	departments map[int]Department
+	people      map[int]Person
+	checkouts   []Checkout
+}
+
+func newMemPeopleRepository() *memPeopleRepository {
+	return &memPeopleRepository{departments: make(map[int]Department), people: make(map[int]Person)}
+}
+
+func (r *memPeopleRepository) Departments(ctx context.Context) ([]Department, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	departments := make([]Department, 0, len(r.departments))
+	for _, d := range r.departments {
+		d.Members = 0
+		for _, p := range r.people {
+			if p.DepartmentID == d.ID {
+				d.Members++
+			}
+		}
+		departments = append(departments, d)
+	}
+	sort.Slice(departments, func(i, j int) bool { return departments[i].Name < departments[j].Name })
+	return departments, nil
+}
+
+func (r *memPeopleRepository) CreateDepartment(ctx context.Context, name string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	r.departments[r.nextID] = Department{ID: r.nextID, Name: name}
+	return r.nextID, nil
+}
+
+func (r *memPeopleRepository) DeleteDepartment(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.departments[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.departments, id)
+	for personID, p := range r.people {
+		if p.DepartmentID == id {
+			p.DepartmentID = 0
+			r.people[personID] = p
+		}
+	}
+	return nil
+}
+
+// withDepartment fills in the name of the person's department. The caller
+// holds r.mu.
+func (r *memPeopleRepository) withDepartment(p Person) Person {
+	p.Department = r.departments[p.DepartmentID].Name
+	return p
+}
+
+func (r *memPeopleRepository) List(ctx context.Context) ([]Person, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	people := make([]Person, 0, len(r.people))
+	for _, p := range r.people {
+		people = append(people, r.withDepartment(p))
+	}
+	sort.Slice(people, func(i, j int) bool {
+		if people[i].Name != people[j].Name {
+			return people[i].Name < people[j].Name
+		}
+		return people[i].ID < people[j].ID
+	})
+	return people, nil
+}
+
+func (r *memPeopleRepository) Get(ctx context.Context, id int) (*Person, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.people[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	p = r.withDepartment(p)
+	return &p, nil
+}
+
+func (r *memPeopleRepository) ByUsername(ctx context.Context, username string) (*Person, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range r.people {
+		if p.Username != "" && p.Username == username {
+			p = r.withDepartment(p)
+			return &p, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memPeopleRepository) Create(ctx context.Context, p Person) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	p.ID = r.nextID
+	p.Department = ""
+	r.people[p.ID] = p
+	return p.ID, nil
+}
+
+func (r *memPeopleRepository) Update(ctx context.Context, id int, p Person) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.people[id]; !ok {
+		return ErrNotFound
+	}
+	p.ID = id
+	p.Department = ""
+	r.people[id] = p
+	for i, c := range r.checkouts {
+		if c.PersonID == id && c.Open() {
+			r.checkouts[i].PersonName = p.Name
+		}
+	}
+	return nil
+}
+
+func (r *memPeopleRepository) Delete(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.people[id]; !ok {
+		return ErrNotFound
+	}
+	held := 0
+	for _, c := range r.checkouts {
+		if c.PersonID == id && c.Open() {
+			held++
+		}
+	}
+	if held > 0 {
+		return fmt.Errorf("%w: person %d still holds %d asset(s)", errCustody, id, held)
+	}
+	delete(r.people, id)
+	for i, c := range r.checkouts {
+		if c.PersonID == id {
+			r.checkouts[i].PersonID = 0
+		}
+	}
+	return nil
+}
+
+func (r *memPeopleRepository) CheckOut(ctx context.Context, c Checkout) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.checkouts {
+		if existing.AssetID == c.AssetID && existing.Open() {
+			return 0, fmt.Errorf("%w: asset %d is already checked out to %s", errCustody, c.AssetID, existing.PersonName)
+		}
+	}
+	r.nextID++
+	c.ID = r.nextID
+	c.CheckedInAt, c.CheckedInBy, c.ReturnNotes = time.Time{}, "", ""
+	r.checkouts = append(r.checkouts, c)
+	return c.ID, nil
+}
+
+func (r *memPeopleRepository) CheckIn(ctx context.Context, assetID int, by, notes string) (*Checkout, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, c := range r.checkouts {
+		if c.AssetID == assetID && c.Open() {
+			c.CheckedInAt, c.CheckedInBy, c.ReturnNotes = time.Now().UTC().Truncate(time.Second), by, notes
+			r.checkouts[i] = c
+			return &c, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: asset %d is not checked out", errCustody, assetID)
+}
+
+func (r *memPeopleRepository) Custody(ctx context.Context, assetID int) ([]Checkout, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var checkouts []Checkout
+	for i := len(r.checkouts) - 1; i >= 0; i-- {
+		if r.checkouts[i].AssetID == assetID {
+			checkouts = append(checkouts, r.checkouts[i])
+		}
+	}
+	return checkouts, nil
+}
+
+func (r *memPeopleRepository) Held(ctx context.Context, personID int) ([]Checkout, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var checkouts []Checkout
+	for _, c := range r.checkouts {
+		if c.Open() && (personID == 0 || c.PersonID == personID) {
+			checkouts = append(checkouts, c)
+		}
+	}
+	sort.SliceStable(checkouts, func(i, j int) bool {
+		a, b := checkouts[i].ExpectedReturn, checkouts[j].ExpectedReturn
+		if a.IsZero() != b.IsZero() {
+			return b.IsZero()
+		}
+		return a.Before(b)
+	})
+	return checkouts, nil
+}
+
+// memLocationRepository is an in-memory LocationRepository for tests and
+// demos. It counts the assets kept in the given asset repository.
+type memLocationRepository struct {
+	mu        sync.Mutex
+	nextID    int
+	locations map[int]Location
+	assets    *memAssetRepository
+}
+
+func newMemLocationRepository(assets *memAssetRepository) *memLocationRepository {
+	return &memLocationRepository{locations: make(map[int]Location), assets: assets}
+}
+
+func (r *memLocationRepository) List(ctx context.Context) ([]Location, error) {
+	assets, err := r.assets.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	locations := make([]Location, 0, len(r.locations))
+	for _, l := range r.locations {
+		l.Assets = 0
+		for _, a := range assets {
+			if a.LocationID == l.ID {
+				l.Assets++
+			}
+		}
+		locations = append(locations, l)
+	}
+	return buildLocationTree(locations), nil
+}
+
+func (r *memLocationRepository) Get(ctx context.Context, id int) (*Location, error) {
+	locations, err := r.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range locations {
+		if locations[i].ID == id {
+			return &locations[i], nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memLocationRepository) Create(ctx context.Context, l Location) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	l.ID = r.nextID
+	r.locations[l.ID] = l
+	return l.ID, nil
+}
+
+func (r *memLocationRepository) Update(ctx context.Context, id int, l Location) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.locations[id]; !ok {
+		return ErrNotFound
+	}
+	l.ID = id
+	r.locations[id] = l
+	return nil
+}
+
+func (r *memLocationRepository) Delete(ctx context.Context, id int) error {
+	location, err := r.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, l := range r.locations {
+		if l.ParentID == id {
+			return fmt.Errorf("%w: location %d has locations inside it", errLocationInUse, id)
+		}
+	}
+	if location.Assets > 0 {
+		return fmt.Errorf("%w: %d assets are inside location %d", errLocationInUse, location.Assets, id)
+	}
+	delete(r.locations, id)
+
+	// Moves keep the location's path.
+	r.assets.mu.Lock()
+	defer r.assets.mu.Unlock()
+	for i, m := range r.assets.moves {
+		if m.FromID == id {
+			r.assets.moves[i].FromID = 0
+		}
+		if m.ToID == id {
+			r.assets.moves[i].ToID = 0
+		}
+	}
+	return nil
+}
+
+// memAssetTypeRepository is an in-memory AssetTypeRepository for tests and
+// demos. Assets keep their type's name and field values themselves, so
+// changes to types are applied to them as well.
+type memAssetTypeRepository struct {
+	mu     sync.Mutex
+	nextID int
+	types  map[int]AssetType
+	assets *memAssetRepository
+}
+
+func newMemAssetTypeRepository(assets *memAssetRepository) *memAssetTypeRepository {
+	return &memAssetTypeRepository{types: make(map[int]AssetType), assets: assets}
+}
+
+func (r *memAssetTypeRepository) List(ctx context.Context) ([]AssetType, error) {
+	assets, err := r.assets.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	types := make([]AssetType, 0, len(r.types))
+	for _, t := range r.types {
+		t.Fields = append([]AssetField(nil), t.Fields...)
+		t.Assets = 0
+		for _, a := range assets {
+			if a.TypeID == t.ID {
+				t.Assets++
+			}
+		}
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Name < types[j].Name })
+	return types, nil
+}
+
+func (r *memAssetTypeRepository) Get(ctx context.Context, id int) (*AssetType, error) {
+	types, err := r.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range types {
+		if types[i].ID == id {
+			return &types[i], nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// checkName returns an error wrapping errInvalidAssetType if a type other
+// than id has the name, ignoring case, as the unique index does in SQL.
+func (r *memAssetTypeRepository) checkName(id int, name string) error {
+	for _, other := range r.types {
+		if other.ID != id && strings.EqualFold(other.Name, name) {
+			return fmt.Errorf("%w: %s already exists", errInvalidAssetType, name)
+		}
+	}
+	return nil
+}
+
+func (r *memAssetTypeRepository) Create(ctx context.Context, t AssetType) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.checkName(0, t.Name); err != nil {
+		return 0, err
+	}
+	r.nextID++
+	t.ID = r.nextID
+	fields := t.Fields
+	t.Fields = nil
+	r.types[t.ID] = t
+	for _, f := range fields {
+		f.TypeID = t.ID
+		r.addField(f)
+	}
+	return t.ID, nil
+}
+
+// addField appends a field to its type. The caller holds r.mu.
+func (r *memAssetTypeRepository) addField(f AssetField) int {
+	r.nextID++
+	f.ID = r.nextID
+	t := r.types[f.TypeID]
+	t.Fields = append(t.Fields, f)
+	r.types[f.TypeID] = t
+	return f.ID
+}
+
+// updateAssets applies fn to every asset of type id.
+func (r *memAssetTypeRepository) updateAssets(id int, fn func(a *Asset)) {
+	r.assets.mu.Lock()
+	defer r.assets.mu.Unlock()
+	for assetID, a := range r.assets.assets {
+		if a.TypeID == id {
+			fn(&a)
+			r.assets.assets[assetID] = a
+		}
+	}
+}
+
+func (r *memAssetTypeRepository) Update(ctx context.Context, id int, t AssetType) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current, ok := r.types[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if err := r.checkName(id, t.Name); err != nil {
+		return err
+	}
+	current.Name = t.Name
+	r.types[id] = current
+	r.updateAssets(id, func(a *Asset) { a.AssetType = t.Name })
+	return nil
+}
+
+func (r *memAssetTypeRepository) Delete(ctx context.Context, id int) error {
+	t, err := r.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if t.Assets > 0 {
+		return fmt.Errorf("%w: %d assets are of asset type %d", errAssetTypeInUse, t.Assets, id)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.types, id)
+	return nil
+}
+
+func (r *memAssetTypeRepository) CreateField(ctx context.Context, f AssetField) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.types[f.TypeID]; !ok {
+		return 0, ErrNotFound
+	}
+	return r.addField(f), nil
+}
+
+// field returns the index of field id of type typeID, or -1. The caller
+// holds r.mu.
+func (r *memAssetTypeRepository) field(typeID, id int) int {
+	for i, f := range r.types[typeID].Fields {
+		if f.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *memAssetTypeRepository) UpdateField(ctx context.Context, id int, f AssetField) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.field(f.TypeID, id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	current := r.types[f.TypeID].Fields[i]
+	f.ID, f.Kind = id, current.Kind
+	var values []string
+	r.updateAssets(f.TypeID, func(a *Asset) { values = append(values, a.Field(current.Name)) })
+	respelled, err := f.revalidate(values)
+	if err != nil {
+		return err
+	}
+	r.types[f.TypeID].Fields[i] = f
+	r.updateAssets(f.TypeID, func(a *Asset) {
+		for j, v := range a.Fields {
+			if v.Name == current.Name {
+				a.Fields[j].Name, a.Fields[j].Label = f.Name, f.Label
+				if to, ok := respelled[v.Value]; ok {
+					a.Fields[j].Value = to
+				}
+			}
+		}
+	})
+	return nil
+}
+
+func (r *memAssetTypeRepository) DeleteField(ctx context.Context, typeID, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.field(typeID, id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	t := r.types[typeID]
+	name := t.Fields[i].Name
+	t.Fields = append(t.Fields[:i:i], t.Fields[i+1:]...)
+	r.types[typeID] = t
+	r.updateAssets(typeID, func(a *Asset) {
+		var kept []AssetFieldValue
+		for _, v := range a.Fields {
+			if v.Name != name {
+				kept = append(kept, v)
+			}
+		}
+		a.Fields = kept
+	})
+	return nil
+}
+
+// memUserRepository is an in-memory UserRepository for tests and demos.
+type memUserRepository struct {
+	mu       sync.Mutex
+	nextID   int
+	users    map[int]User
+	hashes   map[int]string
+	sessions map[string]memSession
+}
+
+// memSession is a session held by memUserRepository.
+type memSession struct {
+	userID  int
+	expires time.Time
+}
+
+func newMemUserRepository() *memUserRepository {
+	return &memUserRepository{users: make(map[int]User), hashes: make(map[int]string), sessions: make(map[string]memSession)}
+}
+
+func (r *memUserRepository) List(ctx context.Context) ([]User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	users := make([]User, 0, len(r.users))
+	for _, u := range r.users {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users, nil
+}
+
+func (r *memUserRepository) Get(ctx context.Context, id int) (*User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &u, nil
+}
+
+func (r *memUserRepository) Credentials(ctx context.Context, username string) (*User, string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, u := range r.users {
+		if u.Username == username {
+			return &u, r.hashes[id], nil
+		}
+	}
+	return nil, "", ErrNotFound
+}
+
+func (r *memUserRepository) Create(ctx context.Context, username, passwordHash string, role Role) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range r.users {
+		if u.Username == username {
+			return 0, errUsernameTaken
+		}
+	}
+	r.nextID++
+	r.users[r.nextID] = User{ID: r.nextID, Username: username, Role: role}
+	r.hashes[r.nextID] = passwordHash
+	return r.nextID, nil
+}
+
+func (r *memUserRepository) SetRole(ctx context.Context, id int, role Role) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	u.Role = role
+	r.users[id] = u
+	return nil
+}
+
+func (r *memUserRepository) Count(ctx context.Context) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.users), nil
+}
+
+// purgeSessions drops the expired sessions. The caller holds r.mu.
+func (r *memUserRepository) purgeSessions() {
+	now := time.Now()
+	for hash, s := range r.sessions {
+		if !s.expires.After(now) {
+			delete(r.sessions, hash)
+		}
+	}
+}
+
+func (r *memUserRepository) CreateSession(ctx context.Context, tokenHash string, userID int, expires time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.purgeSessions()
+	r.sessions[tokenHash] = memSession{userID: userID, expires: expires}
+	return nil
+}
+
+func (r *memUserRepository) SessionUser(ctx context.Context, tokenHash string) (*User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sessions[tokenHash]
+	if !ok || !s.expires.After(time.Now()) {
+		return nil, ErrNotFound
+	}
+	u, ok := r.users[s.userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &u, nil
+}
+
+func (r *memUserRepository) DeleteSession(ctx context.Context, tokenHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sessions, tokenHash)
+	r.purgeSessions()
+	return nil
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// highRiskScore is the lowest score reported as a high risk on the dashboard.
+const highRiskScore = 10
+
+// errInvalidTransition is returned when the risk workflow does not allow a
+// status change.
+var errInvalidTransition = errors.New("status change not allowed")
+
+// RiskStatus is a stage of the risk workflow.
+type RiskStatus string
+
+const (
+	RiskIdentified RiskStatus = "identified"
+	RiskAssessed   RiskStatus = "assessed"
+	RiskMitigating RiskStatus = "mitigating"
+	RiskMonitoring RiskStatus = "monitoring"
+	RiskAccepted   RiskStatus = "accepted"
+	RiskClosed     RiskStatus = "closed"
+)
+
+// riskStatuses lists every status in workflow order.
+var riskStatuses = []RiskStatus{RiskIdentified, RiskAssessed, RiskMitigating, RiskMonitoring, RiskAccepted, RiskClosed}
+
+// riskTransitions lists the statuses each status may move to. A closed risk
+// can only be reopened as identified.
+var riskTransitions = map[RiskStatus][]RiskStatus{
+	RiskIdentified: {RiskAssessed, RiskClosed},
+	RiskAssessed:   {RiskMitigating, RiskAccepted, RiskClosed},
+	RiskMitigating: {RiskMonitoring, RiskAccepted, RiskClosed},
+	RiskMonitoring: {RiskMitigating, RiskClosed},
+	RiskAccepted:   {RiskAssessed, RiskClosed},
+	RiskClosed:     {RiskIdentified},
+}
+
+// Label returns a human readable name for the status.
+func (s RiskStatus) Label() string {
+	if s == "" {
+		return ""
+	}
+	return strings.ToUpper(string(s[:1])) + string(s[1:])
+}
+
+// Next returns the statuses the risk may move to from s.
+func (s RiskStatus) Next() []RiskStatus {
+	return riskTransitions[s]
+}
+
+// CanMoveTo reports whether the workflow allows moving from s to next.
+func (s RiskStatus) CanMoveTo(next RiskStatus) bool {
+	for _, allowed := range riskTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Risk is an entry in the risk register. Likelihood and Impact are scored
+// from 1 (lowest) to 5 (highest).
+type Risk struct {
+	ID          int
+	Title       string
+	Description string
+	Likelihood  int
+	Impact      int
+	Owner       string
+	Status      RiskStatus
+	ReviewDate  time.Time
+	AssetIDs    []int
+	LicenseIDs  []int
+	Mitigations []RiskMitigation
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// RiskMitigation is an action taken to reduce a risk.
+type RiskMitigation struct {
+	ID          int
+	RiskID      int
+	Action      string
+	DueDate     time.Time
+	CompletedAt time.Time
+}
+
+// Done reports whether the mitigation has been completed.
+func (m RiskMitigation) Done() bool {
+	return !m.CompletedAt.IsZero()
+}
+
+// Score is the product of likelihood and impact, from 1 to 25.
+func (r Risk) Score() int {
+	return r.Likelihood * r.Impact
+}
+
+// Level names the band the score falls in on a 5x5 risk matrix.
+func (r Risk) Level() string {
+	switch score := r.Score(); {
+	case score >= 20:
+		return "Critical"
+	case score >= highRiskScore:
+		return "High"
+	case score >= 5:
+		return "Medium"
+	default:
+		return "Low"
+	}
+}
+
+// Open reports whether the risk is still being managed.
+func (r Risk) Open() bool {
+	return r.Status != RiskClosed
+}
+
+// High reports whether the risk counts towards the dashboard's high-risk items.
+func (r Risk) High() bool {
+	return r.Open() && r.Score() >= highRiskScore
+}
+
+// ReviewOverdue reports whether an open risk has passed its review date.
+func (r Risk) ReviewOverdue() bool {
+	return r.Open() && !r.ReviewDate.IsZero() && r.ReviewDate.Before(time.Now().UTC().Truncate(24*time.Hour))
+}
+
+// LinksAsset reports whether the risk affects the asset.
+func (r Risk) LinksAsset(id int) bool {
+	return containsID(r.AssetIDs, id)
+}
+
+// LinksLicense reports whether the risk affects the license.
+func (r Risk) LinksLicense(id int) bool {
+	return containsID(r.LicenseIDs, id)
+}
+
+func containsID(ids []int, id int) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRisk checks a risk before it is stored.
+func validateRisk(r Risk) error {
+	if r.Title == "" {
+		return errors.New("title is required")
+	}
+	if r.Likelihood < 1 || r.Likelihood > 5 {
+		return errors.New("likelihood must be between 1 and 5")
+	}
+	if r.Impact < 1 || r.Impact > 5 {
+		return errors.New("impact must be between 1 and 5")
+	}
+	if _, ok := riskTransitions[r.Status]; !ok {
+		return fmt.Errorf("unknown risk status %q", r.Status)
+	}
+	return nil
+}
+
+// parseIDs converts form values into record IDs.
+func parseIDs(values []string) ([]int, error) {
+	var ids []int
+	for _, v := range values {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid id %q", v)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// parseRiskForm reads the risk form fields. New risks start as identified;
+// the status of an existing risk is changed through the workflow instead.
+func parseRiskForm(r *http.Request) (Risk, error) {
+	if err := r.ParseForm(); err != nil {
+		return Risk{}, err
+	}
+	risk := Risk{
+		Title:       strings.TrimSpace(r.FormValue("title")),
+		Description: strings.TrimSpace(r.FormValue("description")),
+		Owner:       strings.TrimSpace(r.FormValue("owner")),
+		Status:      RiskIdentified,
+	}
+	var err error
+	if risk.Likelihood, err = strconv.Atoi(r.FormValue("likelihood")); err != nil {
+		return risk, fmt.Errorf("invalid likelihood %q", r.FormValue("likelihood"))
+	}
+	if risk.Impact, err = strconv.Atoi(r.FormValue("impact")); err != nil {
+		return risk, fmt.Errorf("invalid impact %q", r.FormValue("impact"))
+	}
+	if v := r.FormValue("review-date"); v != "" {
+		if risk.ReviewDate, err = time.Parse(dateLayout, v); err != nil {
+			return risk, fmt.Errorf("invalid review date %q", v)
+		}
+	}
+	if risk.AssetIDs, err = parseIDs(r.Form["asset-ids"]); err != nil {
+		return risk, err
+	}
+	if risk.LicenseIDs, err = parseIDs(r.Form["license-ids"]); err != nil {
+		return risk, err
+	}
+	return risk, validateRisk(risk)
+}
+
+// risksHandler handles the risk register page and new risk submissions.
+func (s *server) risksHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		risk, err := parseRiskForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.checkLinks(r.Context(), risk.AssetIDs, risk.LicenseIDs); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		_, err = s.risks.Create(r.Context(), risk)
+		if err != nil {
+			log.Printf("Error inserting risk: %v\n", err)
+			http.Error(w, "Error saving risk", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/risks", http.StatusSeeOther)
+		return
+	}
+
+	data, err := s.getPageData(r.Context())
+	if err != nil {
+		log.Printf("Error fetching page data: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	renderTemplate(w, r, data)
+}
+
+// editRiskHandler shows a risk for editing and saves changes to it.
+func (s *server) editRiskHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	existing, err := s.risks.Get(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		log.Printf("Error fetching risk: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		risk, err := parseRiskForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.checkLinks(r.Context(), risk.AssetIDs, risk.LicenseIDs); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		risk.Status = existing.Status
+
+		if err := s.risks.Update(r.Context(), id, risk); err != nil {
+			log.Printf("Error updating risk: %v\n", err)
+			http.Error(w, "Error saving risk", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("/risks/%d", id), http.StatusSeeOther)
+		return
+	}
+
+	data, err := s.getPageData(r.Context())
+	if err != nil {
+		log.Printf("Error fetching page data: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	data.EditRisk = existing
+	renderTemplate(w, r, data)
+}
+
+// riskStatusHandler moves a risk to another stage of the workflow.
+func (s *server) riskStatusHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	err = s.risks.Transition(r.Context(), id, RiskStatus(r.FormValue("status")))
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if errors.Is(err, errInvalidTransition) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Printf("Error changing risk status: %v\n", err)
+		http.Error(w, "Error saving risk", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/risks/%d", id), http.StatusSeeOther)
+}
+
+// deleteRiskHandler removes a risk from the register.
+func (s *server) deleteRiskHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	err = s.risks.Delete(r.Context(), id)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		log.Printf("Error deleting risk: %v\n", err)
+		http.Error(w, "Error deleting risk", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/risks", http.StatusSeeOther)
+}
+
+// addMitigationHandler records a mitigation action against a risk.
+func (s *server) addMitigationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	m := RiskMitigation{RiskID: id, Action: strings.TrimSpace(r.FormValue("action"))}
+	if m.Action == "" {
+		http.Error(w, "A mitigation action is required", http.StatusBadRequest)
+		return
+	}
+	if v := r.FormValue("due-date"); v != "" {
+		if m.DueDate, err = time.Parse(dateLayout, v); err != nil {
+			http.Error(w, fmt.Sprintf("invalid due date %q", v), http.StatusBadRequest)
+			return
+		}
+	}
+
+	_, err = s.risks.AddMitigation(r.Context(), m)
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		log.Printf("Error inserting mitigation: %v\n", err)
+		http.Error(w, "Error saving mitigation", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/risks/%d", id), http.StatusSeeOther)
+}
+
+// completeMitigationHandler marks a mitigation action as done.
+func (s *server) completeMitigationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	mitigationID, err := strconv.Atoi(mux.Vars(r)["mitigation"])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	err = s.risks.CompleteMitigation(r.Context(), id, mitigationID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		log.Printf("Error completing mitigation: %v\n", err)
+		http.Error(w, "Error saving mitigation", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/risks/%d", id), http.StatusSeeOther)
+}
@@ -3,4 +3,8 @@ go mod init slam-system
 go get modernc.org/sqlite@v1.38.2
 go get golang.org/x/crypto/bcrypt
 go get github.com/gorilla/mux
+go get github.com/lib/pq
+go get github.com/go-sql-driver/mysql
+go get gopkg.in/yaml.v3
+go get github.com/xuri/excelize/v2
 go run .
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// sourceManual marks software entered by hand on the asset page, as opposed
+// to a discovery tool reporting through the ingestion API.
+const sourceManual = "manual"
+
+// InstalledSoftware is one product installation found on an asset.
+type InstalledSoftware struct {
+	ID          int
+	AssetID     int
+	Product     string
+	Publisher   string
+	Version     string
+	InstallDate time.Time
+	// Source names where the record came from, e.g. "manual" or the name
+	// of the inventory agent that reported it.
+	Source string
+	// LastSeen is when the record was last reported.
+	LastSeen time.Time
+}
+
+// validateSoftware checks an installation record before it is stored.
+func validateSoftware(sw InstalledSoftware) error {
+	if sw.Product == "" {
+		return errors.New("product is required")
+	}
+	if sw.Source == "" {
+		return errors.New("source is required")
+	}
+	if len(sw.Source) > 64 {
+		return errors.New("source must be at most 64 characters")
+	}
+	return nil
+}
+
+// parseSoftwareForm reads the installed software form fields.
+func parseSoftwareForm(r *http.Request, assetID int) (InstalledSoftware, error) {
+	sw := InstalledSoftware{
+		AssetID:   assetID,
+		Product:   strings.TrimSpace(r.FormValue("product")),
+		Publisher: strings.TrimSpace(r.FormValue("publisher")),
+		Version:   strings.TrimSpace(r.FormValue("version")),
+		Source:    sourceManual,
+	}
+	if v := r.FormValue("install-date"); v != "" {
+		t, err := time.Parse(dateLayout, v)
+		if err != nil {
+			return sw, fmt.Errorf("invalid install date %q", v)
+		}
+		sw.InstallDate = t
+	}
+	return sw, validateSoftware(sw)
+}
+
+// addSoftwareHandler records software installed on an asset by hand.
+func (s *server) addSoftwareHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if _, err := s.assets.Get(r.Context(), id); errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		log.Printf("Error fetching asset: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	sw, err := parseSoftwareForm(r, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := s.software.Add(r.Context(), sw); err != nil {
+		log.Printf("Error inserting installed software: %v\n", err)
+		http.Error(w, "Error saving software", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/assets/%d", id), http.StatusSeeOther)
+}
+
+// deleteSoftwareHandler removes an installation record from an asset.
+func (s *server) deleteSoftwareHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	softwareID, err := strconv.Atoi(mux.Vars(r)["software"])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	err = s.software.Remove(r.Context(), id, softwareID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		log.Printf("Error removing installed software: %v\n", err)
+		http.Error(w, "Error removing software", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/assets/%d", id), http.StatusSeeOther)
+}
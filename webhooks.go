@@ -0,0 +1,512 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Webhook event types.
+const (
+	EventAssetCreated      = "asset.created"
+	EventAssetUpdated      = "asset.updated"
+	EventAssetDeleted      = "asset.deleted"
+	EventAssetStateChanged = "asset.state_changed"
+	EventAssetCheckedOut   = "asset.checked_out"
+	EventAssetCheckedIn    = "asset.checked_in"
+	EventLicenseExpiring   = "license.expiring"
+	EventLicenseExpired    = "license.expired"
+)
+
+// webhookEvents lists the events a webhook can subscribe to, in the order
+// offered by the settings form.
+var webhookEvents = []string{EventAssetCreated, EventAssetUpdated, EventAssetDeleted, EventAssetStateChanged, EventAssetCheckedOut, EventAssetCheckedIn, EventLicenseExpiring, EventLicenseExpired}
+
+// Webhook delivery statuses.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+const (
+	// webhookMaxAttempts is how many times a delivery is tried before it
+	// is marked failed.
+	webhookMaxAttempts = 6
+	// webhookRetryDelay is the wait before the first retry; each later
+	// retry waits twice as long as the one before.
+	webhookRetryDelay = 30 * time.Second
+	// webhookTimeout bounds a single delivery attempt.
+	webhookTimeout = 10 * time.Second
+	// webhookPollInterval is how often the queue is checked for retries
+	// that have become due.
+	webhookPollInterval = 10 * time.Second
+	// webhookExpiryScanInterval is how often licenses are checked for
+	// license.expiring and license.expired events.
+	webhookExpiryScanInterval = time.Hour
+	// recentDeliveriesLimit caps the delivery log shown in Settings.
+	recentDeliveriesLimit = 50
+)
+
+// errDeliveryNotFailed is returned when retrying a delivery that is still
+// pending or was delivered.
+var errDeliveryNotFailed = errors.New("only failed deliveries can be retried")
+
+// errWebhooksDisabled is returned when retrying a delivery without a
+// dispatcher running, as in the command line tools.
+var errWebhooksDisabled = errors.New("webhook deliveries are not running")
+
+// Webhook is a subscription that receives signed JSON event payloads.
+type Webhook struct {
+	ID  int
+	URL string
+	// Secret keys the HMAC-SHA256 signature sent with each delivery.
+	Secret    string
+	Events    []string
+	CreatedAt time.Time
+}
+
+// Subscribes reports whether the webhook receives the event.
+func (h Webhook) Subscribes(event string) bool {
+	for _, e := range h.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one event queued for, or delivered to, a webhook.
+type WebhookDelivery struct {
+	ID         int
+	WebhookID  int
+	WebhookURL string
+	Event      string
+	// EventKey identifies scheduled events, such as a license reaching an
+	// expiry threshold, so that each is delivered only once.
+	EventKey       string
+	Payload        string
+	Status         string
+	Attempts       int
+	NextAttemptAt  time.Time
+	ResponseStatus int
+	Error          string
+	CreatedAt      time.Time
+	DeliveredAt    time.Time
+}
+
+// webhookPayload is the JSON body posted to webhooks. ID is shared by every
+// delivery of the same event, so receivers can ignore duplicates.
+type webhookPayload struct {
+	ID         string      `json:"id"`
+	Event      string      `json:"event"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data"`
+}
+
+// apiLicenseExpiry is the data of license.expiring and license.expired
+// events. ThresholdDays is 0 for license.expired.
+type apiLicenseExpiry struct {
+	License       apiLicense `json:"license"`
+	DaysLeft      int        `json:"days_left"`
+	ThresholdDays int        `json:"threshold_days"`
+}
+
+// signWebhook returns the X-SLAM-Signature value for a payload: the
+// hex HMAC-SHA256 of the timestamp, a dot and the body.
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// randomHex returns n random bytes as hex, for secrets and event ids.
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// validateWebhook checks the URL and events and generates a secret when
+// none was given.
+func validateWebhook(h *Webhook) error {
+	h.URL = strings.TrimSpace(h.URL)
+	u, err := url.Parse(h.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook URL must be an absolute http or https URL")
+	}
+	if len(h.Events) == 0 {
+		return fmt.Errorf("choose at least one event")
+	}
+	for _, e := range h.Events {
+		known := false
+		for _, k := range webhookEvents {
+			known = known || e == k
+		}
+		if !known {
+			return fmt.Errorf("unknown event %q", e)
+		}
+	}
+	h.Secret = strings.TrimSpace(h.Secret)
+	if h.Secret == "" {
+		if h.Secret, err = randomHex(24); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// webhookDispatcher queues events for subscribed webhooks and delivers
+// them in the background. The queue is stored, so pending retries survive
+// a restart.
+type webhookDispatcher struct {
+	hooks      WebhookRepository
+	licenses   LicenseRepository
+	thresholds []int
+	client     *http.Client
+	wake       chan struct{}
+}
+
+func newWebhookDispatcher(hooks WebhookRepository, licenses LicenseRepository, thresholds []int) *webhookDispatcher {
+	return &webhookDispatcher{
+		hooks:      hooks,
+		licenses:   licenses,
+		thresholds: thresholds,
+		client:     &http.Client{Timeout: webhookTimeout},
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// Emit queues an event for every webhook subscribed to it. Errors are
+// logged rather than returned so that a webhook problem never fails the
+// change that raised the event. A nil dispatcher does nothing.
+func (d *webhookDispatcher) Emit(ctx context.Context, event string, data interface{}) {
+	if d == nil {
+		return
+	}
+	if _, err := d.enqueue(ctx, event, "", data); err != nil {
+		log.Printf("Error queuing %s webhooks: %v\n", event, err)
+	}
+}
+
+// enqueue stores a pending delivery of the event for each subscribed
+// webhook, skipping webhooks that already have one with the key, and
+// returns the number queued.
+func (d *webhookDispatcher) enqueue(ctx context.Context, event, key string, data interface{}) (int, error) {
+	hooks, err := d.hooks.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var subscribed []Webhook
+	for _, h := range hooks {
+		if !h.Subscribes(event) {
+			continue
+		}
+		if key != "" {
+			sent, err := d.hooks.HasDelivery(ctx, h.ID, key)
+			if err != nil {
+				return 0, err
+			} else if sent {
+				continue
+			}
+		}
+		subscribed = append(subscribed, h)
+	}
+	if len(subscribed) == 0 {
+		return 0, nil
+	}
+
+	id, err := randomHex(16)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	payload, err := json.Marshal(webhookPayload{ID: id, Event: event, OccurredAt: now, Data: data})
+	if err != nil {
+		return 0, err
+	}
+	for _, h := range subscribed {
+		if _, err := d.hooks.CreateDelivery(ctx, WebhookDelivery{
+			WebhookID:     h.ID,
+			Event:         event,
+			EventKey:      key,
+			Payload:       string(payload),
+			Status:        DeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		}); err != nil {
+			return 0, err
+		}
+	}
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+	return len(subscribed), nil
+}
+
+// Start delivers queued events in the background until ctx is done,
+// checking for expiring licenses now and then every hour.
+func (d *webhookDispatcher) Start(ctx context.Context) {
+	go func() {
+		poll := time.NewTicker(webhookPollInterval)
+		defer poll.Stop()
+		scan := time.NewTicker(webhookExpiryScanInterval)
+		defer scan.Stop()
+
+		d.scanExpiry(ctx)
+		for {
+			d.deliverDue(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-scan.C:
+				d.scanExpiry(ctx)
+			case <-poll.C:
+			case <-d.wake:
+			}
+		}
+	}()
+}
+
+// scanExpiry raises license.expiring when a license reaches one of the
+// notification thresholds and license.expired when it expires. A nil
+// dispatcher does nothing.
+func (d *webhookDispatcher) scanExpiry(ctx context.Context) {
+	if d == nil {
+		return
+	}
+	licenses, err := d.licenses.List(ctx)
+	if err != nil {
+		log.Printf("Error fetching licenses for webhooks: %v\n", err)
+		return
+	}
+	day := today()
+	for _, l := range licenses {
+		stage, daysLeft, ok := expiryStage(d.thresholds, l, day)
+		if !ok {
+			continue
+		}
+		event := EventLicenseExpiring
+		if stage == 0 {
+			event = EventLicenseExpired
+		}
+		key := fmt.Sprintf("%s:%d:%s:%d", event, l.ID, l.ExpiryDate.Format(dateLayout), stage)
+		data := apiLicenseExpiry{License: toAPILicense(l), DaysLeft: daysLeft, ThresholdDays: stage}
+		if _, err := d.enqueue(ctx, event, key, data); err != nil {
+			log.Printf("Error queuing %s webhooks: %v\n", event, err)
+			return
+		}
+	}
+}
+
+// deliverDue attempts every delivery whose next attempt has come.
+func (d *webhookDispatcher) deliverDue(ctx context.Context) {
+	const batch = 50
+	for {
+		due, err := d.hooks.DueDeliveries(ctx, time.Now(), batch)
+		if err != nil {
+			log.Printf("Error fetching webhook deliveries: %v\n", err)
+			return
+		}
+		hooks := make(map[int]*Webhook)
+		for _, delivery := range due {
+			h, ok := hooks[delivery.WebhookID]
+			if !ok {
+				if h, err = d.hooks.Get(ctx, delivery.WebhookID); err != nil {
+					log.Printf("Error fetching webhook %d: %v\n", delivery.WebhookID, err)
+					continue
+				}
+				hooks[delivery.WebhookID] = h
+			}
+			d.attempt(ctx, *h, delivery)
+		}
+		if len(due) < batch || ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// attempt posts a delivery once and records the outcome. Failures are
+// retried with exponential backoff until webhookMaxAttempts is reached.
+func (d *webhookDispatcher) attempt(ctx context.Context, h Webhook, delivery WebhookDelivery) {
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	err := func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "SLAM-Webhooks/1.0")
+		req.Header.Set("X-SLAM-Event", delivery.Event)
+		req.Header.Set("X-SLAM-Delivery", strconv.Itoa(delivery.ID))
+		req.Header.Set("X-SLAM-Timestamp", timestamp)
+		req.Header.Set("X-SLAM-Signature", signWebhook(h.Secret, timestamp, body))
+
+		resp, err := d.client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		delivery.ResponseStatus = resp.StatusCode
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("unexpected response %s", resp.Status)
+		}
+		return nil
+	}()
+
+	now := time.Now().UTC()
+	delivery.Attempts++
+	switch {
+	case err == nil:
+		delivery.Status = DeliveryDelivered
+		delivery.Error = ""
+		delivery.NextAttemptAt = time.Time{}
+		delivery.DeliveredAt = now
+	case delivery.Attempts >= webhookMaxAttempts:
+		delivery.Status = DeliveryFailed
+		delivery.Error = err.Error()
+		delivery.NextAttemptAt = time.Time{}
+	default:
+		delivery.Error = err.Error()
+		delivery.NextAttemptAt = now.Add(webhookRetryDelay << (delivery.Attempts - 1))
+	}
+	if err := d.hooks.UpdateDelivery(ctx, delivery); err != nil {
+		log.Printf("Error saving webhook delivery %d: %v\n", delivery.ID, err)
+	}
+}
+
+// Retry puts a failed delivery back in the queue for another full set of
+// attempts. A nil dispatcher returns errWebhooksDisabled.
+func (d *webhookDispatcher) Retry(ctx context.Context, id int) error {
+	if d == nil {
+		return errWebhooksDisabled
+	}
+	delivery, err := d.hooks.GetDelivery(ctx, id)
+	if err != nil {
+		return err
+	}
+	if delivery.Status != DeliveryFailed {
+		return errDeliveryNotFailed
+	}
+	delivery.Status = DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Now().UTC()
+	if err := d.hooks.UpdateDelivery(ctx, *delivery); err != nil {
+		return err
+	}
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// settingsHandler shows the Settings page. Administrators also see the
+// webhook subscriptions and delivery log.
+func (s *server) settingsHandler(w http.ResponseWriter, r *http.Request) {
+	data, err := s.getPageData(r.Context())
+	if err != nil {
+		log.Printf("Error fetching page data: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if currentUser(r).Can(PermManageSettings) {
+		data.Webhooks, err = s.webhooks.List(r.Context())
+		if err != nil {
+			log.Printf("Error fetching webhooks: %v\n", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		data.WebhookDeliveries, err = s.webhooks.Deliveries(r.Context(), 0, recentDeliveriesLimit)
+		if err != nil {
+			log.Printf("Error fetching webhook deliveries: %v\n", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		data.WebhookEvents = webhookEvents
+	}
+	renderTemplate(w, r, data)
+}
+
+// webhooksHandler adds a webhook from the settings form.
+func (s *server) webhooksHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h := Webhook{URL: r.FormValue("url"), Secret: r.FormValue("secret"), Events: r.Form["events"]}
+	if err := validateWebhook(&h); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := s.webhooks.Create(r.Context(), h); err != nil {
+		log.Printf("Error inserting webhook: %v\n", err)
+		http.Error(w, "Error saving webhook", http.StatusInternalServerError)
+		return
+	}
+	// Bring the new webhook up to date with licenses already expiring.
+	s.events.scanExpiry(r.Context())
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
+}
+
+// deleteWebhookHandler removes a webhook and its delivery log.
+func (s *server) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	err = s.webhooks.Delete(r.Context(), id)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		log.Printf("Error deleting webhook: %v\n", err)
+		http.Error(w, "Error deleting webhook", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
+}
+
+// retryDeliveryHandler queues a failed delivery again.
+func (s *server) retryDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := routeID(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	err = s.events.Retry(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if errors.Is(err, errDeliveryNotFailed) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if errors.Is(err, errWebhooksDisabled) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	} else if err != nil {
+		log.Printf("Error retrying webhook delivery: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSignWebhook(t *testing.T) {
+	body := []byte(`{"event":"asset.created"}`)
+	// The documented scheme: hex HMAC-SHA256 of timestamp + "." + body.
+	want := "sha256=5f7518d3b2616ed96213aedf2297e9e40a8bebd73cc030540362c62c4f04a2f7"
+	if got := signWebhook("s3cret", "1700000000", body); got != want {
+		t.Errorf("signWebhook = %s, want %s", got, want)
+	}
+	if signWebhook("s3cret", "1700000001", body) == want {
+		t.Error("signature does not cover the timestamp")
+	}
+	if signWebhook("other", "1700000000", body) == want {
+		t.Error("signature does not depend on the secret")
+	}
+}
+
+// webhookReceiver is a test endpoint that answers every delivery with
+// status and records the last request.
+type webhookReceiver struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+func (rcv *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rcv.header = r.Header.Clone()
+	rcv.body, _ = io.ReadAll(r.Body)
+	w.WriteHeader(rcv.status)
+}
+
+// newTestDispatcher returns a dispatcher with one webhook, subscribed to
+// events, that posts to rcv.
+func newTestDispatcher(t *testing.T, rcv *webhookReceiver, events ...string) (*webhookDispatcher, Webhook) {
+	t.Helper()
+	srv := httptest.NewServer(rcv)
+	t.Cleanup(srv.Close)
+	hooks := newMemWebhookRepository()
+	h := Webhook{URL: srv.URL, Secret: "s3cret", Events: events}
+	id, err := hooks.Create(context.Background(), h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.ID = id
+	return newWebhookDispatcher(hooks, newMemLicenseRepository(), []int{30, 7}), h
+}
+
+func TestWebhookDeliverySigned(t *testing.T) {
+	rcv := &webhookReceiver{status: http.StatusNoContent}
+	d, h := newTestDispatcher(t, rcv, EventAssetCreated)
+	ctx := context.Background()
+
+	d.Emit(ctx, EventAssetUpdated, map[string]int{"id": 1})
+	d.Emit(ctx, EventAssetCreated, map[string]int{"id": 2})
+	d.deliverDue(ctx)
+
+	deliveries, err := d.hooks.Deliveries(ctx, h.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 || deliveries[0].Event != EventAssetCreated || deliveries[0].Status != DeliveryDelivered {
+		t.Fatalf("deliveries = %+v, want one delivered asset.created", deliveries)
+	}
+	if got := rcv.header.Get("X-SLAM-Event"); got != EventAssetCreated {
+		t.Errorf("X-SLAM-Event = %q", got)
+	}
+	want := signWebhook(h.Secret, rcv.header.Get("X-SLAM-Timestamp"), rcv.body)
+	if got := rcv.header.Get("X-SLAM-Signature"); got != want {
+		t.Errorf("X-SLAM-Signature = %q, want %q", got, want)
+	}
+	var payload struct {
+		ID    string         `json:"id"`
+		Event string         `json:"event"`
+		Data  map[string]int `json:"data"`
+	}
+	if err := json.Unmarshal(rcv.body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.ID == "" || payload.Event != EventAssetCreated || payload.Data["id"] != 2 {
+		t.Errorf("payload = %s", rcv.body)
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	rcv := &webhookReceiver{status: http.StatusInternalServerError}
+	d, h := newTestDispatcher(t, rcv, EventAssetDeleted)
+	ctx := context.Background()
+	d.Emit(ctx, EventAssetDeleted, map[string]int{"id": 1})
+
+	deliveries, err := d.hooks.Deliveries(ctx, h.ID, 10)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("Deliveries = %v, %v; want one", deliveries, err)
+	}
+	id := deliveries[0].ID
+
+	// Retries wait 30s, 1m, 2m, 4m and 8m; the sixth failure is final.
+	delays := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute}
+	for attempt := 1; attempt <= webhookMaxAttempts; attempt++ {
+		delivery, err := d.hooks.GetDelivery(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		before := time.Now().UTC()
+		d.attempt(ctx, h, *delivery)
+		after := time.Now().UTC()
+
+		if delivery, err = d.hooks.GetDelivery(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+		if delivery.Attempts != attempt || delivery.ResponseStatus != http.StatusInternalServerError || delivery.Error == "" {
+			t.Fatalf("after attempt %d: %+v", attempt, delivery)
+		}
+		if attempt == webhookMaxAttempts {
+			if delivery.Status != DeliveryFailed || !delivery.NextAttemptAt.IsZero() {
+				t.Errorf("after the last attempt: status %q, next attempt %v; want failed and none", delivery.Status, delivery.NextAttemptAt)
+			}
+			break
+		}
+		delay := delays[attempt-1]
+		if delivery.Status != DeliveryPending {
+			t.Errorf("after attempt %d: status %q, want pending", attempt, delivery.Status)
+		}
+		if next := delivery.NextAttemptAt; next.Before(before.Add(delay)) || next.After(after.Add(delay)) {
+			t.Errorf("after attempt %d: next attempt in %v, want %v", attempt, next.Sub(before), delay)
+		}
+	}
+}
+
+func TestWebhookRetry(t *testing.T) {
+	rcv := &webhookReceiver{status: http.StatusOK}
+	d, h := newTestDispatcher(t, rcv, EventAssetCreated)
+	ctx := context.Background()
+	id, err := d.hooks.CreateDelivery(ctx, WebhookDelivery{
+		WebhookID: h.ID,
+		Event:     EventAssetCreated,
+		Payload:   `{}`,
+		Status:    DeliveryFailed,
+		Attempts:  webhookMaxAttempts,
+		Error:     "unexpected response 500 Internal Server Error",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := time.Now().UTC()
+	if err := d.Retry(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	delivery, err := d.hooks.GetDelivery(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivery.Status != DeliveryPending || delivery.Attempts != 0 || delivery.NextAttemptAt.Before(before.Truncate(time.Second)) {
+		t.Errorf("after Retry: %+v; want pending, no attempts and due now", delivery)
+	}
+	if err := d.Retry(ctx, id); !errors.Is(err, errDeliveryNotFailed) {
+		t.Errorf("Retry of a pending delivery: err = %v, want errDeliveryNotFailed", err)
+	}
+
+	d.deliverDue(ctx)
+	if delivery, err = d.hooks.GetDelivery(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if delivery.Status != DeliveryDelivered || delivery.Attempts != 1 || delivery.Error != "" {
+		t.Errorf("retried delivery = %+v; want delivered on its first new attempt", delivery)
+	}
+}
+
+func TestWebhookExpiryEventsQueuedOnce(t *testing.T) {
+	rcv := &webhookReceiver{status: http.StatusOK}
+	d, h := newTestDispatcher(t, rcv, EventLicenseExpiring, EventLicenseExpired)
+	ctx := context.Background()
+	d.licenses = newMemLicenseRepository(
+		License{Name: "Office", ExpiryDate: today().AddDate(0, 0, 20)},
+		License{Name: "CAD", ExpiryDate: today().AddDate(0, 0, -1)},
+		License{Name: "Visio", ExpiryDate: today().AddDate(0, 0, 90)},
+	)
+
+	d.scanExpiry(ctx)
+	d.scanExpiry(ctx)
+	deliveries, err := d.hooks.Deliveries(ctx, h.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := map[string]int{}
+	for _, delivery := range deliveries {
+		events[delivery.Event]++
+	}
+	if len(deliveries) != 2 || events[EventLicenseExpiring] != 1 || events[EventLicenseExpired] != 1 {
+		t.Errorf("queued %v, want one license.expiring and one license.expired", events)
+	}
+}
+
+func TestRetryWithoutDispatcher(t *testing.T) {
+	var d *webhookDispatcher
+	if err := d.Retry(context.Background(), 1); !errors.Is(err, errWebhooksDisabled) {
+		t.Errorf("Retry on a nil dispatcher: err = %v, want errWebhooksDisabled", err)
+	}
+
+	// The server only starts a dispatcher when it serves.
+	s := newMemTestServer()
+	rec := send(signedIn(s, RoleAdmin), http.MethodPost, "/settings/deliveries/1/retry", nil)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("retry without a dispatcher: status %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}